@@ -0,0 +1,94 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// uniondiff lists the changes that were made in the writable branch
+// of a github.com/hanwen/go-fuse/newunionfs union, or writes them as
+// an OCI image layer.
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	unionfs "github.com/hanwen/go-fuse/v2/newunionfs"
+)
+
+func main() {
+	whiteouts := flag.String("whiteouts", "markers", "whiteout format of the writable branch: markers, overlay or aufs.")
+	metacopy := flag.Bool("metacopy", false, "the writable branch has metadata-only copies.")
+	layers := flag.Bool("layers", false, "LOWER are image layer tar files, base first.")
+	out := flag.String("o", "", "write an image layer to this file, gzipped if it ends in .gz, instead of listing the changes.")
+	flag.Parse()
+	if flag.NArg() < 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s [options] RW LOWER...\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	opts := unionfs.Options{MetaCopy: *metacopy}
+	switch *whiteouts {
+	case "markers":
+		opts.Whiteouts = unionfs.DeletionMarkers
+	case "overlay":
+		opts.Whiteouts = unionfs.OverlayWhiteouts
+	case "aufs":
+		opts.Whiteouts = unionfs.AUFSWhiteouts
+	default:
+		log.Fatalf("unknown whiteout format %q", *whiteouts)
+	}
+
+	var root fs.InodeEmbedder
+	if *layers {
+		var layers io.Closer
+		var err error
+		root, layers, err = unionfs.NewLayerFS(flag.Arg(0), flag.Args()[1:], &opts)
+		if err != nil {
+			log.Fatalf("NewLayerFS: %v", err)
+		}
+		defer layers.Close()
+	} else {
+		root = unionfs.NewUnionFS(flag.Args(), &opts)
+	}
+
+	if *out == "" {
+		changes, err := unionfs.Changes(root)
+		if err != nil {
+			log.Fatalf("Changes: %v", err)
+		}
+		w := bufio.NewWriter(os.Stdout)
+		for _, c := range changes {
+			fmt.Fprintf(w, "%v /%s\n", c.Kind, c.Path)
+		}
+		w.Flush()
+		return
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("os.Create: %v", err)
+	}
+	var w io.Writer = f
+	var zw *gzip.Writer
+	if strings.HasSuffix(*out, ".gz") {
+		zw = gzip.NewWriter(f)
+		w = zw
+	}
+	if err := unionfs.WriteLayer(root, w); err != nil {
+		log.Fatalf("WriteLayer: %v", err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			log.Fatalf("gzip: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("Close: %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"encoding/binary"
+	"sort"
+	"syscall"
+)
+
+// Tags of POSIX ACL entries.
+const (
+	ACL_USER_OBJ  = 0x01
+	ACL_USER      = 0x02
+	ACL_GROUP_OBJ = 0x04
+	ACL_GROUP     = 0x08
+	ACL_MASK      = 0x10
+	ACL_OTHER     = 0x20
+)
+
+const (
+	aclXattrAccess  = "system.posix_acl_access"
+	aclXattrDefault = "system.posix_acl_default"
+
+	aclVersion     = 2
+	aclUndefinedID = ^uint32(0)
+)
+
+// isACLXattr returns true for the extended attributes that store
+// POSIX ACLs.
+func isACLXattr(attr string) bool {
+	return attr == aclXattrAccess || attr == aclXattrDefault
+}
+
+// ACLEntry is an entry of a POSIX access control list.
+type ACLEntry struct {
+	// Tag is one of the ACL_* constants.
+	Tag uint16
+
+	// Perm holds the read (4), write (2) and execute (1) bits.
+	Perm uint16
+
+	// ID is the user or group ID for ACL_USER and ACL_GROUP
+	// entries.
+	ID uint32
+}
+
+// ACL is a POSIX access control list, as stored in the
+// system.posix_acl_access and system.posix_acl_default extended
+// attributes. See acl(5).
+type ACL []ACLEntry
+
+// ParseACL decodes the value of a POSIX ACL extended attribute. It
+// returns EINVAL if the value is malformed.
+func ParseACL(data []byte) (ACL, error) {
+	if len(data) < 4 || (len(data)-4)%8 != 0 || binary.LittleEndian.Uint32(data) != aclVersion {
+		return nil, syscall.EINVAL
+	}
+	var acl ACL
+	for e := data[4:]; len(e) > 0; e = e[8:] {
+		acl = append(acl, ACLEntry{
+			Tag:  binary.LittleEndian.Uint16(e),
+			Perm: binary.LittleEndian.Uint16(e[2:]),
+			ID:   binary.LittleEndian.Uint32(e[4:]),
+		})
+	}
+	return acl, nil
+}
+
+// Bytes encodes the ACL as the value of an extended attribute.
+// Entries are stored in the canonical order, and the IDs of entries
+// other than ACL_USER and ACL_GROUP are left undefined.
+func (a ACL) Bytes() []byte {
+	sorted := append(ACL(nil), a...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Tag != sorted[j].Tag {
+			return sorted[i].Tag < sorted[j].Tag
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	b := binary.LittleEndian.AppendUint32(nil, aclVersion)
+	for _, e := range sorted {
+		id := e.ID
+		if e.Tag != ACL_USER && e.Tag != ACL_GROUP {
+			id = aclUndefinedID
+		}
+		b = binary.LittleEndian.AppendUint16(b, e.Tag)
+		b = binary.LittleEndian.AppendUint16(b, e.Perm)
+		b = binary.LittleEndian.AppendUint32(b, id)
+	}
+	return b
+}
+
+// ACLFromMode returns the minimal ACL that is equivalent to the
+// permission bits of mode.
+func ACLFromMode(mode uint32) ACL {
+	return ACL{
+		{Tag: ACL_USER_OBJ, Perm: uint16(mode>>6) & 7},
+		{Tag: ACL_GROUP_OBJ, Perm: uint16(mode>>3) & 7},
+		{Tag: ACL_OTHER, Perm: uint16(mode) & 7},
+	}
+}
+
+// IsMinimal returns true if the ACL has no entries besides those
+// equivalent to permission bits.
+func (a ACL) IsMinimal() bool {
+	for _, e := range a {
+		switch e.Tag {
+		case ACL_USER_OBJ, ACL_GROUP_OBJ, ACL_OTHER:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// find returns the index of the first entry with the given tag, or
+// -1.
+func (a ACL) find(tag uint16) int {
+	for i, e := range a {
+		if e.Tag == tag {
+			return i
+		}
+	}
+	return -1
+}
+
+// withMode returns a copy of the ACL where the entries equivalent to
+// the permission bits are taken from mode. If the ACL has a mask, the
+// group bits of the mode are the mask, as with chmod(2).
+func (a ACL) withMode(mode uint32) ACL {
+	c := append(ACL(nil), a...)
+	group := ACL_GROUP_OBJ
+	if c.find(ACL_MASK) >= 0 {
+		group = ACL_MASK
+	}
+	for i := range c {
+		switch c[i].Tag {
+		case ACL_USER_OBJ:
+			c[i].Perm = uint16(mode>>6) & 7
+		case uint16(group):
+			c[i].Perm = uint16(mode>>3) & 7
+		case ACL_OTHER:
+			c[i].Perm = uint16(mode) & 7
+		}
+	}
+	return c
+}
+
+// inherit returns the access ACL and mode for a new file in a
+// directory that has a the default ACL a, where mode holds the
+// permission bits requested by the creator. This follows
+// posix_acl_create() in Linux: the permissions of the default ACL are
+// limited to those of mode, and the umask does not apply.
+func (a ACL) inherit(mode uint32) (ACL, uint32) {
+	c := append(ACL(nil), a...)
+	hasMask := c.find(ACL_MASK) >= 0
+	perm := uint32(0)
+	for i := range c {
+		switch c[i].Tag {
+		case ACL_USER_OBJ:
+			c[i].Perm &= uint16(mode>>6) & 7
+			perm |= uint32(c[i].Perm) << 6
+		case ACL_GROUP_OBJ:
+			if !hasMask {
+				c[i].Perm &= uint16(mode>>3) & 7
+				perm |= uint32(c[i].Perm) << 3
+			}
+		case ACL_MASK:
+			c[i].Perm &= uint16(mode>>3) & 7
+			perm |= uint32(c[i].Perm) << 3
+		case ACL_OTHER:
+			c[i].Perm &= uint16(mode) & 7
+			perm |= uint32(c[i].Perm)
+		}
+	}
+	return c, mode&^0777 | perm
+}
+
+// permits checks if a caller may access a file with owner uid and
+// group gid in mode mask (a combination of R_OK, W_OK and X_OK),
+// following the access check algorithm of acl(5). inGroup reports
+// whether the caller is a member of a group.
+func (a ACL) permits(caller, uid, gid uint32, inGroup func(uint32) bool, mask uint32) bool {
+	mask &= 7
+	maskPerm := uint16(7)
+	if i := a.find(ACL_MASK); i >= 0 {
+		maskPerm = a[i].Perm
+	}
+	granted := func(perm uint16) bool {
+		return uint32(perm)&mask == mask
+	}
+
+	for _, e := range a {
+		if e.Tag == ACL_USER_OBJ && caller == uid {
+			return granted(e.Perm)
+		}
+	}
+	for _, e := range a {
+		if e.Tag == ACL_USER && e.ID == caller {
+			return granted(e.Perm & maskPerm)
+		}
+	}
+
+	// Any matching group entry that grants the access suffices,
+	// but if some group matches, the other entry does not apply.
+	matched := false
+	for _, e := range a {
+		var g uint32
+		switch e.Tag {
+		case ACL_GROUP_OBJ:
+			g = gid
+		case ACL_GROUP:
+			g = e.ID
+		default:
+			continue
+		}
+		if !inGroup(g) {
+			continue
+		}
+		if granted(e.Perm & maskPerm) {
+			return true
+		}
+		matched = true
+	}
+	if matched {
+		return false
+	}
+
+	for _, e := range a {
+		if e.Tag == ACL_OTHER {
+			return granted(e.Perm)
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestACLBytes(t *testing.T) {
+	acl := ACL{
+		{Tag: ACL_OTHER, Perm: 4},
+		{Tag: ACL_GROUP, Perm: 6, ID: 20},
+		{Tag: ACL_USER_OBJ, Perm: 7, ID: 5},
+		{Tag: ACL_MASK, Perm: 6},
+		{Tag: ACL_USER, Perm: 5, ID: 10},
+		{Tag: ACL_GROUP_OBJ, Perm: 4},
+	}
+	got, err := ParseACL(acl.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ACL{
+		{Tag: ACL_USER_OBJ, Perm: 7, ID: aclUndefinedID},
+		{Tag: ACL_USER, Perm: 5, ID: 10},
+		{Tag: ACL_GROUP_OBJ, Perm: 4, ID: aclUndefinedID},
+		{Tag: ACL_GROUP, Perm: 6, ID: 20},
+		{Tag: ACL_MASK, Perm: 6, ID: aclUndefinedID},
+		{Tag: ACL_OTHER, Perm: 4, ID: aclUndefinedID},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, data := range [][]byte{nil, {1, 0, 0, 0}, append(acl.Bytes(), 0)} {
+		if _, err := ParseACL(data); err == nil {
+			t.Errorf("ParseACL(%x) succeeded", data)
+		}
+	}
+}
+
+func TestACLPermits(t *testing.T) {
+	// Owner 1, group 10; user 2 may read and write, but the mask
+	// only grants reading. Group 20 may write.
+	acl := ACL{
+		{Tag: ACL_USER_OBJ, Perm: 6},
+		{Tag: ACL_USER, Perm: 6, ID: 2},
+		{Tag: ACL_GROUP_OBJ, Perm: 0},
+		{Tag: ACL_GROUP, Perm: 6, ID: 20},
+		{Tag: ACL_MASK, Perm: 6},
+		{Tag: ACL_OTHER, Perm: 4},
+	}
+	for _, c := range []struct {
+		caller uint32
+		groups []uint32
+		mask   uint32
+		want   bool
+	}{
+		{1, nil, 6, true},
+		{1, nil, 1, false},
+		{2, nil, 6, true},
+		{2, []uint32{20}, 1, false},
+		{3, nil, 4, true},
+		{3, nil, 2, false},
+		{3, []uint32{20}, 2, true},
+		// A matching group that grants nothing hides the
+		// other entry.
+		{3, []uint32{10}, 4, false},
+		{3, []uint32{10, 20}, 4, true},
+	} {
+		inGroup := func(g uint32) bool {
+			for _, cg := range c.groups {
+				if cg == g {
+					return true
+				}
+			}
+			return false
+		}
+		if got := acl.permits(c.caller, 1, 10, inGroup, c.mask); got != c.want {
+			t.Errorf("caller %d groups %v mask %o: got %v, want %v", c.caller, c.groups, c.mask, got, c.want)
+		}
+	}
+
+	masked := acl.withMode(0640)
+	if got := masked.permits(2, 1, 10, func(uint32) bool { return false }, 2); got {
+		t.Errorf("mask from mode 0640 grants writing")
+	}
+}
+
+func TestACLInherit(t *testing.T) {
+	def := ACL{
+		{Tag: ACL_USER_OBJ, Perm: 7},
+		{Tag: ACL_USER, Perm: 7, ID: 2},
+		{Tag: ACL_GROUP_OBJ, Perm: 5},
+		{Tag: ACL_MASK, Perm: 7},
+		{Tag: ACL_OTHER, Perm: 0},
+	}
+	acl, mode := def.inherit(0100664)
+	if mode != 0100660 {
+		t.Errorf("got mode %o, want %o", mode, 0100660)
+	}
+	want := ACL{
+		{Tag: ACL_USER_OBJ, Perm: 6},
+		{Tag: ACL_USER, Perm: 7, ID: 2},
+		{Tag: ACL_GROUP_OBJ, Perm: 5},
+		{Tag: ACL_MASK, Perm: 6},
+		{Tag: ACL_OTHER, Perm: 0},
+	}
+	if !reflect.DeepEqual(acl, want) {
+		t.Errorf("got %v, want %v", acl, want)
+	}
+
+	if _, mode := ACLFromMode(0750).inherit(0777); mode != 0750 {
+		t.Errorf("minimal ACL: got mode %o, want 0750", mode)
+	}
+}
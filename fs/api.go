@@ -138,7 +138,8 @@
 //
 // Locks for networked filesystems are supported through the suite of
 // Getlk, Setlk and Setlkw methods. They alllow locks on regions of
-// regular files.
+// regular files. File systems that don't forward locks to another
+// system can use a [LockTable] to implement them.
 //
 // # Parallelism
 //
@@ -223,6 +224,11 @@
 // memory generates a page fault, which blocks the OS thread running
 // the goroutine.
 //
+// 4. Calling Inode.NotifyEntry or Inode.NotifyDelete from an
+// operation handler on the same directory. The kernel holds the
+// directory lock while waiting for the handler, and the notification
+// needs the same lock. Setting Options.AsyncNotify avoids this.
+//
 // # Dynamically discovered file systems
 //
 // File system data usually cannot fit all in RAM, so the kernel must
@@ -300,6 +306,9 @@ type NodeStatfser interface {
 // Without [Options.NullPermissions], a missing permission (mode =
 // 0000) is interpreted as 0755 for directories, and chdir is always
 // allowed.
+//
+// With [Options.CheckPermissions], Access also decides the
+// permission checks that precede other operations on the node.
 type NodeAccesser interface {
 	Access(ctx context.Context, mask uint32) syscall.Errno
 }
@@ -518,6 +527,22 @@ type NodeLookuper interface {
 	Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*Inode, syscall.Errno)
 }
 
+// NodeIDLookuper is implemented by the root of a file system that can
+// find nodes by inode number. This is needed to export the mount over
+// NFS (see fuse.MountOptions.EnableExportSupport): NFS file handles
+// contain the node ID of a file, and may be used after the kernel has
+// forgotten the node. With export support, the bridge uses inode
+// numbers as node IDs where it can, and asks LookupID for the node
+// with inode number ino when the kernel looks up such a handle.
+//
+// LookupID should return the node and fill out with its attributes,
+// like Lookup does. Nodes that are not in the tree should be added
+// to it, so ".." can be resolved. The kernel checks the generation
+// number of the result. Return ESTALE if the node no longer exists.
+type NodeIDLookuper interface {
+	LookupID(ctx context.Context, ino uint64, out *fuse.EntryOut) (*Inode, syscall.Errno)
+}
+
 // NodeWrapChilder wraps a FS node implementation in another one. If
 // defined, it is called automatically from NewInode and
 // NewPersistentInode. Thus, existing file system implementations,
@@ -535,7 +560,8 @@ type NodeLookuper interface {
 //	}
 //
 // See also the LoopbackReuse example for a more practical
-// application.
+// application, and Wrap, which intercepts all operations of an
+// arbitrary tree.
 type NodeWrapChilder interface {
 	WrapChild(ctx context.Context, ops InodeEmbedder) InodeEmbedder
 }
@@ -689,6 +715,16 @@ type FileSetlkwer interface {
 	Setlkw(ctx context.Context, owner uint64, lk *fuse.FileLock, flags uint32) syscall.Errno
 }
 
+// FileUnlocker is called to drop all locks of a lock owner. The
+// kernel does not send unlock requests when files are closed, so
+// file systems that keep track of locks themselves must release
+// them here. It is called with flags 0 for POSIX locks when a file
+// descriptor is closed, and with fuse.FUSE_LK_FLOCK for flock(2)
+// locks when the file is released.
+type FileUnlocker interface {
+	Unlock(ctx context.Context, owner uint64, flags uint32)
+}
+
 // See NodeLseeker.
 type FileLseeker interface {
 	Lseek(ctx context.Context, off uint64, whence uint32) (uint64, syscall.Errno)
@@ -795,6 +831,26 @@ type Options struct {
 	// directories.
 	NullPermissions bool
 
+	// CheckPermissions, if set, makes the library check
+	// permissions before calling Lookup, Open, Opendir, Create,
+	// Mkdir, Mknod, Symlink, Link, Unlink, Rmdir, Rename,
+	// Setattr and the ACL changes of Setxattr and Removexattr on
+	// nodes, rather than relying on the kernel's
+	// default_permissions mount option. Permissions are taken
+	// from the mode bits, the supplementary groups of the caller
+	// and the system.posix_acl_access extended attribute, as
+	// described in acl(5). Nodes implementing NodeAccesser decide
+	// their own policy.
+	//
+	// New files in a directory with a system.posix_acl_default
+	// attribute inherit it: their permissions are limited by the
+	// default ACL instead of the umask, and their ACL attributes
+	// are set with Setxattr. The kernel applies the umask before
+	// the file system sees the mode, unless fuse.CAP_DONT_MASK is
+	// in MountOptions.ExtraCapabilities, in which case the library
+	// applies it for directories without default ACL.
+	CheckPermissions bool
+
 	// UID, if nonzero, is the default UID to use instead of the
 	// zero (zero) UID.
 	UID uint32
@@ -819,4 +875,19 @@ type Options struct {
 	// RootStableAttr is an optional way to set e.g. Ino and/or Gen for
 	// the root directory when calling fs.Mount(), Mode is ignored.
 	RootStableAttr *StableAttr
+
+	// AsyncNotify, if set, makes Inode.NotifyEntry, NotifyDelete,
+	// NotifyContent and NotifyPrune queue the notification and
+	// return immediately. The queue is sent from a separate
+	// goroutine, so these methods can be called from within
+	// operation handlers. Duplicate notifications are merged,
+	// content ranges of the same inode are combined, and
+	// notifications for inodes the kernel has forgotten are
+	// dropped.
+	AsyncNotify bool
+
+	// OnNotifyError, if set, is called with notifications that
+	// failed when AsyncNotify is set. Otherwise, failures are
+	// logged to Logger.
+	OnNotifyError func(err *NotifyError)
 }
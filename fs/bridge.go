@@ -80,13 +80,9 @@ type rawBridge struct {
 	// taken before rawBridge.mu
 	mu sync.Mutex
 
-	// stableAttrs is used to detect already-known nodes and hard links by
-	// looking at:
-	// 1) file type ......... StableAttr.Mode
-	// 2) inode number ...... StableAttr.Ino
-	// 3) generation number . StableAttr.Gen
-	stableAttrs  map[StableAttr]*Inode
-	automaticIno uint64
+	// mounts holds the root tree and the trees added with
+	// Inode.MountChild.
+	mounts map[*fsMount]struct{}
 
 	// The *Node ID* is an arbitrary uint64 identifier chosen by the FUSE library.
 	// It is used the identify *nodes* (files/directories/symlinks/...) in the
@@ -100,9 +96,17 @@ type rawBridge struct {
 
 	// nextNodeID is the next free NodeID. Increment after copying the value.
 	nextNodeId uint64
+
+	// seqNodeIds holds the node IDs from nextNodeId that were
+	// handed to the kernel while export support was on. Such an ID
+	// may be the inode number of another file, so it cannot be
+	// resolved with NodeIDLookuper. The entry is dropped when the
+	// kernel forgets the node, after which the number can be used
+	// for the file with that inode number.
+	seqNodeIds map[uint64]struct{}
 	// nodeCountHigh records the highest number of entries we had in the
 	// kernelNodeIds map.
-	// As the size of stableAttrs tracks kernelNodeIds (+- a few entries due to
+	// As the size of the stableAttrs maps tracks kernelNodeIds (+- a few entries due to
 	// concurrent FORGETs, LOOKUPs, and the fixed NodeID 1), this is also a good
 	// estimate for stableAttrs.
 	nodeCountHigh int
@@ -114,10 +118,54 @@ type rawBridge struct {
 
 	// If set, don't try to register backing file for Create/Open calls.
 	disableBackingFiles bool
+
+	// notifier queues notifications if Options.AsyncNotify is set.
+	notifier *notifier
+}
+
+// fsMount is a tree of nodes that shares a set of Options. The root of
+// the file system is one, and Inode.MountChild adds more. Nodes
+// belong to the mount of the node that created them.
+type fsMount struct {
+	options Options
+	root    *Inode
+
+	// stableAttrs is used to detect already-known nodes and hard links by
+	// looking at:
+	// 1) file type ......... StableAttr.Mode
+	// 2) inode number ...... StableAttr.Ino
+	// 3) generation number . StableAttr.Gen
+	// Protected by rawBridge.mu.
+	stableAttrs  map[StableAttr]*Inode
+	automaticIno uint64
+}
+
+func newFSMount(opts *Options) *fsMount {
+	m := &fsMount{
+		options:      *opts,
+		stableAttrs:  make(map[StableAttr]*Inode),
+		automaticIno: opts.FirstAutomaticIno,
+	}
+	if m.automaticIno == 0 {
+		m.automaticIno = 1 << 63
+	}
+	return m
+}
+
+// newIno returns a free inode number. Must hold rawBridge.mu.
+func (m *fsMount) newIno(id StableAttr) uint64 {
+	for {
+		id.Ino = m.automaticIno
+		m.automaticIno++
+		_, ok := m.stableAttrs[id]
+		if !ok {
+			return id.Ino
+		}
+	}
 }
 
 // newInode creates creates new inode pointing to ops.
-func (b *rawBridge) newInodeUnlocked(ops InodeEmbedder, id StableAttr, persistent bool) *Inode {
+func (b *rawBridge) newInodeUnlocked(m *fsMount, ops InodeEmbedder, id StableAttr, persistent bool) *Inode {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -137,30 +185,58 @@ func (b *rawBridge) newInodeUnlocked(ops InodeEmbedder, id StableAttr, persisten
 	}
 
 	if id.Ino == 0 {
-		// Find free inode number.
-		for {
-			id.Ino = b.automaticIno
-			b.automaticIno++
-			_, ok := b.stableAttrs[id]
-			if !ok {
-				break
-			}
-		}
+		id.Ino = m.newIno(id)
 	}
 
-	initInode(ops.embed(), ops, id, b, persistent, b.nextNodeId)
-	b.nextNodeId++
+	initInode(ops.embed(), ops, id, b, m, persistent, b.newNodeId(id.Ino))
 	return ops.embed()
 }
 
+// newNodeId returns the node ID for a new node with inode number
+// ino. Node IDs are normally handed out sequentially, but NFS file
+// handles contain node IDs, so with export support the inode number
+// is used if it is free, which lets NodeIDLookuper find the node
+// again. Must be called with b.mu held.
+func (b *rawBridge) newNodeId(ino uint64) uint64 {
+	if b.options.EnableExportSupport && ino > 1 && b.kernelNodeIds[ino] == nil {
+		return ino
+	}
+	for b.kernelNodeIds[b.nextNodeId] != nil {
+		b.nextNodeId++
+	}
+	id := b.nextNodeId
+	b.nextNodeId++
+	return id
+}
+
+// registerNodeId makes n known under its node ID. With export support,
+// a node that the kernel does not know yet may have the same ID as
+// one that it does; it then gets a fresh ID. Must be called with n.mu
+// and b.mu held.
+func (b *rawBridge) registerNodeId(n *Inode) {
+	if old := b.kernelNodeIds[n.nodeId]; old != nil && old != n {
+		n.nodeId = b.newNodeId(0)
+	}
+	b.kernelNodeIds[n.nodeId] = n
+	if b.options.EnableExportSupport && n.nodeId != n.stableAttr.Ino && n.nodeId != 1 {
+		if b.seqNodeIds == nil {
+			b.seqNodeIds = map[uint64]struct{}{}
+		}
+		b.seqNodeIds[n.nodeId] = struct{}{}
+	}
+	if len(b.kernelNodeIds) > b.nodeCountHigh {
+		b.nodeCountHigh = len(b.kernelNodeIds)
+	}
+}
+
 func (b *rawBridge) logf(format string, args ...interface{}) {
 	if b.options.Logger != nil {
 		b.options.Logger.Printf(format, args...)
 	}
 }
 
-func (b *rawBridge) newInode(ctx context.Context, ops InodeEmbedder, id StableAttr, persistent bool) *Inode {
-	ch := b.newInodeUnlocked(ops, id, persistent)
+func (b *rawBridge) newInode(ctx context.Context, m *fsMount, ops InodeEmbedder, id StableAttr, persistent bool) *Inode {
+	ch := b.newInodeUnlocked(m, ops, id, persistent)
 	if ch != ops.embed() {
 		return ch
 	}
@@ -171,6 +247,25 @@ func (b *rawBridge) newInode(ctx context.Context, ops InodeEmbedder, id StableAt
 	return ch
 }
 
+// nodeDiscarder is implemented by nodes that hold resources from the
+// moment they are created, such as the file descriptor of a
+// LoopbackNode.
+type nodeDiscarder interface {
+	// discard releases the resources of a node that was dropped
+	// in favor of an existing node for the same file.
+	discard()
+}
+
+// discardNode calls discard on n if it was never added to the tree.
+func discardNode(n *Inode) {
+	n.mu.Lock()
+	unused := n.lookupCount == 0 && !n.persistent && n.parents.count() == 0
+	n.mu.Unlock()
+	if d, ok := unwrapOps(n.ops).(nodeDiscarder); ok && unused {
+		d.discard()
+	}
+}
+
 // addNewChild inserts the child into the tree. Returns file handle if file != nil.
 // Unless fileFlags has the syscall.O_EXCL bit set, child.stableAttr will be used
 // to find an already-known node. If one is found, `child` is ignored and the
@@ -203,7 +298,7 @@ func (b *rawBridge) addNewChild(parent *Inode, name string, child *Inode, file F
 			// must create a new node - don't look for existing nodes
 			break
 		}
-		old := b.stableAttrs[id]
+		old := child.mount.stableAttrs[id]
 		if old == nil {
 			if child == orig {
 				// no pre-existing node under this inode number
@@ -230,12 +325,9 @@ func (b *rawBridge) addNewChild(parent *Inode, name string, child *Inode, file F
 	child.lookupCount++
 	child.changeCounter++
 
-	b.kernelNodeIds[child.nodeId] = child
-	if len(b.kernelNodeIds) > b.nodeCountHigh {
-		b.nodeCountHigh = len(b.kernelNodeIds)
-	}
+	b.registerNodeId(child)
 	// Any node that might be there is overwritten - it is obsolete now
-	b.stableAttrs[id] = child
+	child.mount.stableAttrs[id] = child
 	if file != nil {
 		fe = b.registerFile(child, file, fileFlags)
 	}
@@ -249,38 +341,47 @@ func (b *rawBridge) addNewChild(parent *Inode, name string, child *Inode, file F
 	b.mu.Unlock()
 	unlockNodes(parent, child)
 
+	if child != orig {
+		discardNode(orig)
+	}
 	return child, fe
 }
 
-func (b *rawBridge) setEntryOutTimeout(out *fuse.EntryOut) {
-	b.setAttr(&out.Attr)
-	if b.options.AttrTimeout != nil && out.AttrTimeout() == 0 {
-		out.SetAttrTimeout(*b.options.AttrTimeout)
+func (m *fsMount) setEntryOutTimeout(out *fuse.EntryOut) {
+	m.setAttr(&out.Attr)
+	if m.options.AttrTimeout != nil && out.AttrTimeout() == 0 {
+		out.SetAttrTimeout(*m.options.AttrTimeout)
 	}
-	if b.options.EntryTimeout != nil && out.EntryTimeout() == 0 {
-		out.SetEntryTimeout(*b.options.EntryTimeout)
+	if m.options.EntryTimeout != nil && out.EntryTimeout() == 0 {
+		out.SetEntryTimeout(*m.options.EntryTimeout)
 	}
 }
 
-func (b *rawBridge) setAttr(out *fuse.Attr) {
-	if !b.options.NullPermissions && out.Mode&07777 == 0 {
+func (m *fsMount) setNegativeTimeout(out *fuse.EntryOut) {
+	if m.options.NegativeTimeout != nil && out.EntryTimeout() == 0 {
+		out.SetEntryTimeout(*m.options.NegativeTimeout)
+	}
+}
+
+func (m *fsMount) setAttr(out *fuse.Attr) {
+	if !m.options.NullPermissions && out.Mode&07777 == 0 {
 		out.Mode |= 0644
 		if out.Mode&syscall.S_IFDIR != 0 {
 			out.Mode |= 0111
 		}
 	}
-	if b.options.UID != 0 && out.Uid == 0 {
-		out.Uid = b.options.UID
+	if m.options.UID != 0 && out.Uid == 0 {
+		out.Uid = m.options.UID
 	}
-	if b.options.GID != 0 && out.Gid == 0 {
-		out.Gid = b.options.GID
+	if m.options.GID != 0 && out.Gid == 0 {
+		out.Gid = m.options.GID
 	}
 	setBlocks(out)
 }
 
-func (b *rawBridge) setAttrTimeout(out *fuse.AttrOut) {
-	if b.options.AttrTimeout != nil && out.Timeout() == 0 {
-		out.SetTimeout(*b.options.AttrTimeout)
+func (m *fsMount) setAttrTimeout(out *fuse.AttrOut) {
+	if m.options.AttrTimeout != nil && out.Timeout() == 0 {
+		out.SetTimeout(*m.options.AttrTimeout)
 	}
 }
 
@@ -296,16 +397,15 @@ func NewNodeFS(root InodeEmbedder, opts *Options) fuse.RawFileSystem {
 			AttrTimeout:  &oneSec,
 		}
 	}
+	mount := newFSMount(opts)
 	bridge := &rawBridge{
-		automaticIno: opts.FirstAutomaticIno,
-		server:       opts.ServerCallbacks,
-		nextNodeId:   2, // the root node has nodeid 1
-		stableAttrs:  make(map[StableAttr]*Inode),
-		options:      *opts,
+		server:     opts.ServerCallbacks,
+		nextNodeId: 2, // the root node has nodeid 1
+		mounts:     map[*fsMount]struct{}{mount: {}},
+		options:    *opts,
 	}
-
-	if bridge.automaticIno == 0 {
-		bridge.automaticIno = 1 << 63
+	if opts.AsyncNotify {
+		bridge.notifier = newNotifier(bridge, opts.OnNotifyError)
 	}
 
 	stableAttr := StableAttr{
@@ -320,10 +420,12 @@ func NewNodeFS(root InodeEmbedder, opts *Options) fuse.RawFileSystem {
 	initInode(root.embed(), root,
 		stableAttr,
 		bridge,
+		mount,
 		false,
 		1,
 	)
 	bridge.root = root.embed()
+	mount.root = bridge.root
 	bridge.root.lookupCount = 1
 	bridge.kernelNodeIds = map[uint64]*Inode{
 		1: bridge.root,
@@ -356,13 +458,19 @@ func (b *rawBridge) inode(id uint64, fh uint64) (*Inode, *fileEntry) {
 }
 
 func (b *rawBridge) Lookup(cancel <-chan struct{}, header *fuse.InHeader, name string, out *fuse.EntryOut) fuse.Status {
+	ctx := b.newContext(cancel, header)
+	if name == "." || name == ".." {
+		return b.lookupDot(ctx, header.NodeId, name, out)
+	}
 	parent, _ := b.inode(header.NodeId, 0)
-	ctx := &fuse.Context{Caller: header.Caller, Cancel: cancel}
+	if errno := b.checkAccess(ctx, parent, fuse.X_OK); errno != 0 {
+		return errnoToStatus(errno)
+	}
 	child, errno := b.lookup(ctx, parent, name, out)
 
 	if errno != 0 {
-		if errno == syscall.ENOENT && b.options.NegativeTimeout != nil && out.EntryTimeout() == 0 {
-			out.SetEntryTimeout(*b.options.NegativeTimeout)
+		if errno == syscall.ENOENT && parent.mount.options.NegativeTimeout != nil && out.EntryTimeout() == 0 {
+			parent.mount.setNegativeTimeout(out)
 			errno = 0
 		}
 		return errnoToStatus(errno)
@@ -370,36 +478,169 @@ func (b *rawBridge) Lookup(cancel <-chan struct{}, header *fuse.InHeader, name s
 
 	child, _ = b.addNewChild(parent, name, child, nil, 0, out)
 	child.setEntryOut(out)
-	b.setEntryOutTimeout(out)
+	child.mount.setEntryOutTimeout(out)
 	return fuse.OK
 }
 
 func (b *rawBridge) lookup(ctx *fuse.Context, parent *Inode, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
-	if lu, ok := parent.ops.(NodeLookuper); ok {
-		return lu.Lookup(ctx, name, out)
-	}
-
+	// Mount points hide the entries of the parent file system.
 	child := parent.GetChild(name)
+	if child == nil || !child.isMountRoot() {
+		if lu, ok := asNode[NodeLookuper](parent.ops); ok {
+			return lu.Lookup(ctx, name, out)
+		}
+	}
 	if child == nil {
 		return nil, syscall.ENOENT
 	}
 
-	if ga, ok := child.ops.(NodeGetattrer); ok {
+	getattrEntry(ctx, child, out)
+	return child, OK
+}
+
+// getattrEntry fills out with the attributes of a node that is
+// returned from a lookup without calling Lookup.
+func getattrEntry(ctx *fuse.Context, n *Inode, out *fuse.EntryOut) {
+	if ga, ok := asNode[NodeGetattrer](n.ops); ok {
 		var a fuse.AttrOut
 		errno := ga.Getattr(ctx, nil, &a)
 		if errno == 0 {
 			out.Attr = a.Attr
 		}
 	}
+}
+
+// lookupDot answers lookups of "." and "..", which the kernel issues
+// to resolve NFS file handles. A lookup of "." can be for a node ID
+// that the kernel has forgotten, and whose node is then found with
+// the NodeIDLookuper of the root.
+func (b *rawBridge) lookupDot(ctx *fuse.Context, nodeId uint64, name string, out *fuse.EntryOut) fuse.Status {
+	b.mu.Lock()
+	n := b.kernelNodeIds[nodeId]
+	b.mu.Unlock()
+
+	var child *Inode
+	errno := OK
+	switch {
+	case n == nil && name == "..":
+		errno = syscall.ESTALE
+	case n == nil:
+		child, errno = b.lookupID(ctx, nodeId, out)
+	case name == ".":
+		child = n
+		getattrEntry(ctx, child, out)
+	default:
+		child, errno = b.lookupParent(ctx, n, out)
+	}
+	if errno != 0 {
+		return errnoToStatus(errno)
+	}
 
+	want := uint64(0)
+	if name == "." {
+		want = nodeId
+	}
+	child = b.addDotEntry(child, want, out)
+	if child == nil {
+		return fuse.Status(syscall.ESTALE)
+	}
+	child.setEntryOut(out)
+	child.mount.setEntryOutTimeout(out)
+	return fuse.OK
+}
+
+func (b *rawBridge) lookupID(ctx *fuse.Context, nodeId uint64, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	il, ok := asNode[NodeIDLookuper](b.root.ops)
+	if !ok {
+		return nil, syscall.ESTALE
+	}
+	b.mu.Lock()
+	_, seq := b.seqNodeIds[nodeId]
+	b.mu.Unlock()
+	if seq {
+		return nil, syscall.ESTALE
+	}
+	child, errno := il.LookupID(ctx, nodeId, out)
+	if errno != 0 {
+		return nil, errno
+	}
+	if child.stableAttr.Ino != nodeId {
+		return nil, syscall.ESTALE
+	}
 	return child, OK
 }
 
+func (b *rawBridge) lookupParent(ctx *fuse.Context, n *Inode, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	if n == b.root {
+		getattrEntry(ctx, n, out)
+		return n, OK
+	}
+	if _, p := n.Parent(); p != nil {
+		getattrEntry(ctx, p, out)
+		return p, OK
+	}
+	if lu, ok := asNode[NodeLookuper](n.ops); ok {
+		return lu.Lookup(ctx, "..", out)
+	}
+	return nil, syscall.ENOENT
+}
+
+// addDotEntry hands out a kernel reference to child, like
+// addNewChild, but without adding it to the tree. If want is nonzero,
+// the node must have that node ID; if it has another one already,
+// nil is returned.
+func (b *rawBridge) addDotEntry(child *Inode, want uint64, out *fuse.EntryOut) *Inode {
+	orig := child
+	for {
+		child.mu.Lock()
+		b.mu.Lock()
+		old := child.mount.stableAttrs[child.stableAttr]
+		if old == nil && child != orig {
+			b.mu.Unlock()
+			child.mu.Unlock()
+			child = orig
+			continue
+		}
+		if old == nil || old == child {
+			break
+		}
+		b.mu.Unlock()
+		child.mu.Unlock()
+		child = old
+	}
+	if child != orig {
+		defer discardNode(orig)
+	}
+	defer child.mu.Unlock()
+	defer b.mu.Unlock()
+
+	if want != 0 && child.nodeId != want {
+		if child.lookupCount > 0 || b.kernelNodeIds[want] != nil {
+			return nil
+		}
+		child.nodeId = want
+	}
+
+	child.lookupCount++
+	child.changeCounter++
+	b.registerNodeId(child)
+	child.mount.stableAttrs[child.stableAttr] = child
+
+	out.NodeId = child.nodeId
+	out.Generation = child.stableAttr.Gen
+	out.Attr.Ino = child.stableAttr.Ino
+	return child
+}
+
 func (b *rawBridge) Rmdir(cancel <-chan struct{}, header *fuse.InHeader, name string) fuse.Status {
 	parent, _ := b.inode(header.NodeId, 0)
-	var errno syscall.Errno
-	if mops, ok := parent.ops.(NodeRmdirer); ok {
-		errno = mops.Rmdir(&fuse.Context{Caller: header.Caller, Cancel: cancel}, name)
+	if parent.hasMountChild(name) {
+		return fuse.EBUSY
+	}
+	ctx := b.newContext(cancel, header)
+	errno := b.checkRemove(ctx, parent, name)
+	if mops, ok := asNode[NodeRmdirer](parent.ops); ok && errno == 0 {
+		errno = mops.Rmdir(ctx, name)
 	}
 
 	// TODO - this should not succeed silently.
@@ -412,9 +653,13 @@ func (b *rawBridge) Rmdir(cancel <-chan struct{}, header *fuse.InHeader, name st
 
 func (b *rawBridge) Unlink(cancel <-chan struct{}, header *fuse.InHeader, name string) fuse.Status {
 	parent, _ := b.inode(header.NodeId, 0)
-	var errno syscall.Errno
-	if mops, ok := parent.ops.(NodeUnlinker); ok {
-		errno = mops.Unlink(&fuse.Context{Caller: header.Caller, Cancel: cancel}, name)
+	if parent.hasMountChild(name) {
+		return fuse.EBUSY
+	}
+	ctx := b.newContext(cancel, header)
+	errno := b.checkRemove(ctx, parent, name)
+	if mops, ok := asNode[NodeUnlinker](parent.ops); ok && errno == 0 {
+		errno = mops.Unlink(ctx, name)
 	}
 
 	// TODO - this should not succeed silently.
@@ -428,12 +673,16 @@ func (b *rawBridge) Unlink(cancel <-chan struct{}, header *fuse.InHeader, name s
 func (b *rawBridge) Mkdir(cancel <-chan struct{}, input *fuse.MkdirIn, name string, out *fuse.EntryOut) fuse.Status {
 	parent, _ := b.inode(input.NodeId, 0)
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
-	mops, ok := parent.ops.(NodeMkdirer)
+	ctx := b.newContext(cancel, &input.InHeader)
+	mops, ok := asNode[NodeMkdirer](parent.ops)
 	if !ok {
 		return fuse.ENOTSUP
 	}
-	child, errno := mops.Mkdir(ctx, name, input.Mode, out)
+	if errno := b.checkAccess(ctx, parent, fuse.W_OK|fuse.X_OK); errno != 0 {
+		return errnoToStatus(errno)
+	}
+	mode, acl := b.createMode(ctx, parent, input.Mode, input.Umask)
+	child, errno := mops.Mkdir(ctx, name, mode, out)
 
 	if errno != 0 {
 		return errnoToStatus(errno)
@@ -448,45 +697,56 @@ func (b *rawBridge) Mkdir(cancel <-chan struct{}, input *fuse.MkdirIn, name stri
 	}
 
 	child, _ = b.addNewChild(parent, name, child, nil, syscall.O_EXCL, out)
+	b.inheritACL(ctx, child, acl, mode)
 	child.setEntryOut(out)
-	b.setEntryOutTimeout(out)
+	child.mount.setEntryOutTimeout(out)
 	return fuse.OK
 }
 
 func (b *rawBridge) Mknod(cancel <-chan struct{}, input *fuse.MknodIn, name string, out *fuse.EntryOut) fuse.Status {
 	parent, _ := b.inode(input.NodeId, 0)
 
-	mops, ok := parent.ops.(NodeMknoder)
+	mops, ok := asNode[NodeMknoder](parent.ops)
 	if !ok {
 		return fuse.ENOTSUP
 	}
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
-	child, errno := mops.Mknod(ctx, name, input.Mode, input.Rdev, out)
+	ctx := b.newContext(cancel, &input.InHeader)
+	if errno := b.checkAccess(ctx, parent, fuse.W_OK|fuse.X_OK); errno != 0 {
+		return errnoToStatus(errno)
+	}
+	mode, acl := b.createMode(ctx, parent, input.Mode, input.Umask)
+	child, errno := mops.Mknod(ctx, name, mode, input.Rdev, out)
 	if errno != 0 {
 		return errnoToStatus(errno)
 	}
 
 	child, _ = b.addNewChild(parent, name, child, nil, syscall.O_EXCL, out)
+	b.inheritACL(ctx, child, acl, mode)
 	child.setEntryOut(out)
-	b.setEntryOutTimeout(out)
+	child.mount.setEntryOutTimeout(out)
 	return fuse.OK
 }
 
 func (b *rawBridge) Create(cancel <-chan struct{}, input *fuse.CreateIn, name string, out *fuse.CreateOut) fuse.Status {
 	parent, _ := b.inode(input.NodeId, 0)
 
-	mops, ok := parent.ops.(NodeCreater)
+	mops, ok := asNode[NodeCreater](parent.ops)
 	if !ok {
 		return fuse.EROFS
 	}
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
-	child, f, flags, errno := mops.Create(ctx, name, input.Flags, input.Mode, &out.EntryOut)
+	ctx := b.newContext(cancel, &input.InHeader)
+	if errno := b.checkAccess(ctx, parent, fuse.W_OK|fuse.X_OK); errno != 0 {
+		return errnoToStatus(errno)
+	}
+	mode, acl := b.createMode(ctx, parent, input.Mode, input.Umask)
+	child, f, flags, errno := mops.Create(ctx, name, input.Flags, mode, &out.EntryOut)
 
 	if errno != 0 {
 		return errnoToStatus(errno)
 	}
 
 	child, fe := b.addNewChild(parent, name, child, f, input.Flags|syscall.O_CREAT|syscall.O_EXCL, &out.EntryOut)
+	b.inheritACL(ctx, child, acl, mode)
 	if fe != nil {
 		out.Fh = uint64(fe.fh)
 	}
@@ -494,7 +754,7 @@ func (b *rawBridge) Create(cancel <-chan struct{}, input *fuse.CreateIn, name st
 
 	b.addBackingID(child, f, &out.OpenOut)
 	child.setEntryOut(&out.EntryOut)
-	b.setEntryOutTimeout(&out.EntryOut)
+	child.mount.setEntryOutTimeout(&out.EntryOut)
 	return fuse.OK
 }
 
@@ -523,11 +783,13 @@ func (b *rawBridge) compactMemory() {
 		return
 	}
 
-	tmpStableAttrs := make(map[StableAttr]*Inode, len(b.stableAttrs))
-	for i, v := range b.stableAttrs {
-		tmpStableAttrs[i] = v
+	for m := range b.mounts {
+		tmpStableAttrs := make(map[StableAttr]*Inode, len(m.stableAttrs))
+		for i, v := range m.stableAttrs {
+			tmpStableAttrs[i] = v
+		}
+		m.stableAttrs = tmpStableAttrs
 	}
-	b.stableAttrs = tmpStableAttrs
 
 	tmpKernelNodeIds := make(map[uint64]*Inode, len(b.kernelNodeIds))
 	for i, v := range b.kernelNodeIds {
@@ -561,16 +823,16 @@ func (b *rawBridge) GetAttr(cancel <-chan struct{}, input *fuse.GetAttrIn, out *
 		}
 		b.mu.Unlock()
 	}
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
+	ctx := b.newContext(cancel, &input.InHeader)
 	return errnoToStatus(b.getattr(ctx, n, f, out))
 }
 
 func (b *rawBridge) getattr(ctx context.Context, n *Inode, f FileHandle, out *fuse.AttrOut) syscall.Errno {
 	var errno syscall.Errno
 
-	if nodeOps, ok := n.ops.(NodeGetattrer); ok {
+	if nodeOps, ok := asNode[NodeGetattrer](n.ops); ok {
 		errno = nodeOps.Getattr(ctx, f, out)
-	} else if fileOps, ok := f.(FileGetattrer); ok {
+	} else if fileOps, ok := asFile[FileGetattrer](f); ok {
 		errno = fileOps.Getattr(ctx, out)
 	} else {
 		// We set Mode below, which is the minimum for success
@@ -582,24 +844,29 @@ func (b *rawBridge) getattr(ctx context.Context, n *Inode, f FileHandle, out *fu
 		}
 		out.Ino = n.stableAttr.Ino
 		out.Mode = (out.Attr.Mode & 07777) | n.stableAttr.Mode
-		b.setAttr(&out.Attr)
-		b.setAttrTimeout(out)
+		n.mount.setAttr(&out.Attr)
+		n.mount.setAttrTimeout(out)
 	}
 	return errno
 }
 
 func (b *rawBridge) SetAttr(cancel <-chan struct{}, in *fuse.SetAttrIn, out *fuse.AttrOut) fuse.Status {
-	ctx := &fuse.Context{Caller: in.Caller, Cancel: cancel}
+	ctx := b.newContext(cancel, &in.InHeader)
 
 	fh, _ := in.GetFh()
 
 	n, fEntry := b.inode(in.NodeId, fh)
 	f := fEntry.file
 
-	var errno = syscall.ENOTSUP
-	if fops, ok := n.ops.(NodeSetattrer); ok {
+	in, errno := b.checkSetattr(ctx, n, in)
+	if errno != 0 {
+		return errnoToStatus(errno)
+	}
+
+	errno = syscall.ENOTSUP
+	if fops, ok := asNode[NodeSetattrer](n.ops); ok {
 		errno = fops.Setattr(ctx, f, in, out)
-	} else if fops, ok := f.(FileSetattrer); ok {
+	} else if fops, ok := asFile[FileSetattrer](f); ok {
 		errno = fops.Setattr(ctx, in, out)
 	}
 
@@ -610,9 +877,22 @@ func (b *rawBridge) SetAttr(cancel <-chan struct{}, in *fuse.SetAttrIn, out *fus
 func (b *rawBridge) Rename(cancel <-chan struct{}, input *fuse.RenameIn, oldName string, newName string) fuse.Status {
 	p1, _ := b.inode(input.NodeId, 0)
 	p2, _ := b.inode(input.Newdir, 0)
+	if p1.hasMountChild(oldName) || p2.hasMountChild(newName) {
+		return fuse.EBUSY
+	}
+	if p1.mount != p2.mount {
+		return fuse.EXDEV
+	}
 
-	if mops, ok := p1.ops.(NodeRenamer); ok {
-		errno := mops.Rename(&fuse.Context{Caller: input.Caller, Cancel: cancel}, oldName, p2.ops, newName, input.Flags)
+	if mops, ok := asNode[NodeRenamer](p1.ops); ok {
+		ctx := b.newContext(cancel, &input.InHeader)
+		errno := b.checkRemove(ctx, p1, oldName)
+		if errno == 0 {
+			errno = b.checkRemove(ctx, p2, newName)
+		}
+		if errno == 0 {
+			errno = mops.Rename(ctx, oldName, p2.ops, newName, input.Flags)
+		}
 		if errno == 0 {
 			if input.Flags&RENAME_EXCHANGE != 0 {
 				p1.ExchangeChild(oldName, p2, newName)
@@ -629,13 +909,19 @@ func (b *rawBridge) Rename(cancel <-chan struct{}, input *fuse.RenameIn, oldName
 func (b *rawBridge) Link(cancel <-chan struct{}, input *fuse.LinkIn, name string, out *fuse.EntryOut) fuse.Status {
 	parent, _ := b.inode(input.NodeId, 0)
 	target, _ := b.inode(input.Oldnodeid, 0)
+	if parent.mount != target.mount {
+		return fuse.EXDEV
+	}
 
-	mops, ok := parent.ops.(NodeLinker)
+	mops, ok := asNode[NodeLinker](parent.ops)
 	if !ok {
 		return fuse.ENOTSUP
 	}
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
+	ctx := b.newContext(cancel, &input.InHeader)
+	if errno := b.checkAccess(ctx, parent, fuse.W_OK|fuse.X_OK); errno != 0 {
+		return errnoToStatus(errno)
+	}
 	child, errno := mops.Link(ctx, target.ops, name, out)
 	if errno != 0 {
 		return errnoToStatus(errno)
@@ -643,18 +929,21 @@ func (b *rawBridge) Link(cancel <-chan struct{}, input *fuse.LinkIn, name string
 
 	child, _ = b.addNewChild(parent, name, child, nil, 0, out)
 	child.setEntryOut(out)
-	b.setEntryOutTimeout(out)
+	child.mount.setEntryOutTimeout(out)
 	return fuse.OK
 }
 
 func (b *rawBridge) Symlink(cancel <-chan struct{}, header *fuse.InHeader, target string, name string, out *fuse.EntryOut) fuse.Status {
 	parent, _ := b.inode(header.NodeId, 0)
 
-	mops, ok := parent.ops.(NodeSymlinker)
+	mops, ok := asNode[NodeSymlinker](parent.ops)
 	if !ok {
 		return fuse.ENOTSUP
 	}
-	ctx := &fuse.Context{Caller: header.Caller, Cancel: cancel}
+	ctx := b.newContext(cancel, header)
+	if errno := b.checkAccess(ctx, parent, fuse.W_OK|fuse.X_OK); errno != 0 {
+		return errnoToStatus(errno)
+	}
 	child, status := mops.Symlink(ctx, target, name, out)
 	if status != 0 {
 		return errnoToStatus(status)
@@ -662,18 +951,18 @@ func (b *rawBridge) Symlink(cancel <-chan struct{}, header *fuse.InHeader, targe
 
 	child, _ = b.addNewChild(parent, name, child, nil, syscall.O_EXCL, out)
 	child.setEntryOut(out)
-	b.setEntryOutTimeout(out)
+	child.mount.setEntryOutTimeout(out)
 	return fuse.OK
 }
 
 func (b *rawBridge) Readlink(cancel <-chan struct{}, header *fuse.InHeader) (out []byte, status fuse.Status) {
 	n, _ := b.inode(header.NodeId, 0)
 
-	linker, ok := n.ops.(NodeReadlinker)
+	linker, ok := asNode[NodeReadlinker](n.ops)
 	if !ok {
 		return nil, fuse.ENOTSUP
 	}
-	ctx := &fuse.Context{Caller: header.Caller, Cancel: cancel}
+	ctx := b.newContext(cancel, header)
 	result, errno := linker.Readlink(ctx)
 	if errno != 0 {
 		return nil, errnoToStatus(errno)
@@ -685,8 +974,8 @@ func (b *rawBridge) Readlink(cancel <-chan struct{}, header *fuse.InHeader) (out
 func (b *rawBridge) Access(cancel <-chan struct{}, input *fuse.AccessIn) fuse.Status {
 	n, _ := b.inode(input.NodeId, 0)
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
-	if a, ok := n.ops.(NodeAccesser); ok {
+	ctx := b.newContext(cancel, &input.InHeader)
+	if a, ok := asNode[NodeAccesser](n.ops); ok {
 		return errnoToStatus(a.Access(ctx, input.Mask))
 	}
 
@@ -698,6 +987,9 @@ func (b *rawBridge) Access(cancel <-chan struct{}, input *fuse.AccessIn) fuse.St
 		return errnoToStatus(s)
 	}
 
+	if n.mount.options.CheckPermissions {
+		return errnoToStatus(b.accessAttr(ctx, n, &out.Attr, input.Mask))
+	}
 	if !internal.HasAccess(caller.Uid, caller.Gid, out.Uid, out.Gid, out.Mode, input.Mask) {
 		return fuse.EACCES
 	}
@@ -709,8 +1001,8 @@ func (b *rawBridge) Access(cancel <-chan struct{}, input *fuse.AccessIn) fuse.St
 func (b *rawBridge) GetXAttr(cancel <-chan struct{}, header *fuse.InHeader, attr string, data []byte) (uint32, fuse.Status) {
 	n, _ := b.inode(header.NodeId, 0)
 
-	if xops, ok := n.ops.(NodeGetxattrer); ok {
-		nb, errno := xops.Getxattr(&fuse.Context{Caller: header.Caller, Cancel: cancel}, attr, data)
+	if xops, ok := asNode[NodeGetxattrer](n.ops); ok {
+		nb, errno := xops.Getxattr(b.newContext(cancel, header), attr, data)
 		return nb, errnoToStatus(errno)
 	}
 
@@ -719,8 +1011,8 @@ func (b *rawBridge) GetXAttr(cancel <-chan struct{}, header *fuse.InHeader, attr
 
 func (b *rawBridge) ListXAttr(cancel <-chan struct{}, header *fuse.InHeader, dest []byte) (sz uint32, status fuse.Status) {
 	n, _ := b.inode(header.NodeId, 0)
-	if xops, ok := n.ops.(NodeListxattrer); ok {
-		sz, errno := xops.Listxattr(&fuse.Context{Caller: header.Caller, Cancel: cancel}, dest)
+	if xops, ok := asNode[NodeListxattrer](n.ops); ok {
+		sz, errno := xops.Listxattr(b.newContext(cancel, header), dest)
 		return sz, errnoToStatus(errno)
 	}
 	return 0, fuse.OK
@@ -728,16 +1020,24 @@ func (b *rawBridge) ListXAttr(cancel <-chan struct{}, header *fuse.InHeader, des
 
 func (b *rawBridge) SetXAttr(cancel <-chan struct{}, input *fuse.SetXAttrIn, attr string, data []byte) fuse.Status {
 	n, _ := b.inode(input.NodeId, 0)
-	if xops, ok := n.ops.(NodeSetxattrer); ok {
-		return errnoToStatus(xops.Setxattr(&fuse.Context{Caller: input.Caller, Cancel: cancel}, attr, data, input.Flags))
+	if xops, ok := asNode[NodeSetxattrer](n.ops); ok {
+		ctx := b.newContext(cancel, &input.InHeader)
+		if errno := b.checkXattr(ctx, n, attr); errno != 0 {
+			return errnoToStatus(errno)
+		}
+		return errnoToStatus(xops.Setxattr(ctx, attr, data, input.Flags))
 	}
 	return fuse.ENOATTR
 }
 
 func (b *rawBridge) RemoveXAttr(cancel <-chan struct{}, header *fuse.InHeader, attr string) fuse.Status {
 	n, _ := b.inode(header.NodeId, 0)
-	if xops, ok := n.ops.(NodeRemovexattrer); ok {
-		return errnoToStatus(xops.Removexattr(&fuse.Context{Caller: header.Caller, Cancel: cancel}, attr))
+	if xops, ok := asNode[NodeRemovexattrer](n.ops); ok {
+		ctx := b.newContext(cancel, header)
+		if errno := b.checkXattr(ctx, n, attr); errno != 0 {
+			return errnoToStatus(errno)
+		}
+		return errnoToStatus(xops.Removexattr(ctx, attr))
 	}
 	return fuse.ENOATTR
 }
@@ -745,11 +1045,15 @@ func (b *rawBridge) RemoveXAttr(cancel <-chan struct{}, header *fuse.InHeader, a
 func (b *rawBridge) Open(cancel <-chan struct{}, input *fuse.OpenIn, out *fuse.OpenOut) fuse.Status {
 	n, _ := b.inode(input.NodeId, 0)
 
-	op, ok := n.ops.(NodeOpener)
+	op, ok := asNode[NodeOpener](n.ops)
 	if !ok {
 		return fuse.ENOTSUP
 	}
-	f, flags, errno := op.Open(&fuse.Context{Caller: input.Caller, Cancel: cancel}, input.Flags)
+	ctx := b.newContext(cancel, &input.InHeader)
+	if errno := b.checkOpen(ctx, n, input.Flags); errno != 0 {
+		return errnoToStatus(errno)
+	}
+	f, flags, errno := op.Open(ctx, input.Flags)
 	if errno != 0 {
 		return errnoToStatus(errno)
 	}
@@ -777,7 +1081,7 @@ func (b *rawBridge) addBackingID(n *Inode, f FileHandle, out *fuse.OpenOut) {
 		b.disableBackingFiles = true
 		return
 	}
-	pth, ok := f.(FilePassthroughFder)
+	pth, ok := asFile[FilePassthroughFder](f)
 	if !ok {
 		return
 	}
@@ -840,7 +1144,7 @@ func (b *rawBridge) registerFile(n *Inode, f FileHandle, flags uint32) *fileEntr
 		b.files = append(b.files, fe)
 	}
 
-	if _, ok := f.(FileReaddirenter); ok {
+	if _, ok := asFile[FileReaddirenter](f); ok {
 		fe.lastRead = make([]fuse.DirEntry, 0, 100)
 	}
 	fe.nodeIndex = len(n.openFiles)
@@ -853,12 +1157,12 @@ func (b *rawBridge) registerFile(n *Inode, f FileHandle, flags uint32) *fileEntr
 func (b *rawBridge) Read(cancel <-chan struct{}, input *fuse.ReadIn, buf []byte) (fuse.ReadResult, fuse.Status) {
 	n, f := b.inode(input.NodeId, input.Fh)
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
-	if fops, ok := n.ops.(NodeReader); ok {
+	ctx := b.newContext(cancel, &input.InHeader)
+	if fops, ok := asNode[NodeReader](n.ops); ok {
 		res, errno := fops.Read(ctx, f.file, buf, int64(input.Offset))
 		return res, errnoToStatus(errno)
 	}
-	if fr, ok := f.file.(FileReader); ok {
+	if fr, ok := asFile[FileReader](f.file); ok {
 		res, errno := fr.Read(ctx, buf, int64(input.Offset))
 		return res, errnoToStatus(errno)
 	}
@@ -869,11 +1173,11 @@ func (b *rawBridge) Read(cancel <-chan struct{}, input *fuse.ReadIn, buf []byte)
 func (b *rawBridge) GetLk(cancel <-chan struct{}, input *fuse.LkIn, out *fuse.LkOut) fuse.Status {
 	n, f := b.inode(input.NodeId, input.Fh)
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
-	if lops, ok := n.ops.(NodeGetlker); ok {
+	ctx := b.newContext(cancel, &input.InHeader)
+	if lops, ok := asNode[NodeGetlker](n.ops); ok {
 		return errnoToStatus(lops.Getlk(ctx, f.file, input.Owner, &input.Lk, input.LkFlags, &out.Lk))
 	}
-	if gl, ok := f.file.(FileGetlker); ok {
+	if gl, ok := asFile[FileGetlker](f.file); ok {
 		return errnoToStatus(gl.Getlk(ctx, input.Owner, &input.Lk, input.LkFlags, &out.Lk))
 	}
 	return fuse.ENOTSUP
@@ -881,22 +1185,22 @@ func (b *rawBridge) GetLk(cancel <-chan struct{}, input *fuse.LkIn, out *fuse.Lk
 
 func (b *rawBridge) SetLk(cancel <-chan struct{}, input *fuse.LkIn) fuse.Status {
 	n, f := b.inode(input.NodeId, input.Fh)
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
-	if lops, ok := n.ops.(NodeSetlker); ok {
+	ctx := b.newContext(cancel, &input.InHeader)
+	if lops, ok := asNode[NodeSetlker](n.ops); ok {
 		return errnoToStatus(lops.Setlk(ctx, f.file, input.Owner, &input.Lk, input.LkFlags))
 	}
-	if sl, ok := f.file.(FileSetlker); ok {
+	if sl, ok := asFile[FileSetlker](f.file); ok {
 		return errnoToStatus(sl.Setlk(ctx, input.Owner, &input.Lk, input.LkFlags))
 	}
 	return fuse.ENOTSUP
 }
 func (b *rawBridge) SetLkw(cancel <-chan struct{}, input *fuse.LkIn) fuse.Status {
 	n, f := b.inode(input.NodeId, input.Fh)
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
-	if lops, ok := n.ops.(NodeSetlkwer); ok {
+	ctx := b.newContext(cancel, &input.InHeader)
+	if lops, ok := asNode[NodeSetlkwer](n.ops); ok {
 		return errnoToStatus(lops.Setlkw(ctx, f.file, input.Owner, &input.Lk, input.LkFlags))
 	}
-	if sl, ok := f.file.(FileSetlkwer); ok {
+	if sl, ok := asFile[FileSetlkwer](f.file); ok {
 		return errnoToStatus(sl.Setlkw(ctx, input.Owner, &input.Lk, input.LkFlags))
 	}
 	return fuse.ENOTSUP
@@ -910,10 +1214,15 @@ func (b *rawBridge) Release(cancel <-chan struct{}, input *fuse.ReleaseIn) {
 
 	f.wg.Wait()
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
-	if r, ok := n.ops.(NodeReleaser); ok {
+	ctx := b.newContext(cancel, &input.InHeader)
+	if input.ReleaseFlags&fuse.FUSE_RELEASE_FLOCK_UNLOCK != 0 {
+		if u, ok := asFile[FileUnlocker](f.file); ok {
+			u.Unlock(ctx, input.LockOwner, fuse.FUSE_LK_FLOCK)
+		}
+	}
+	if r, ok := asNode[NodeReleaser](n.ops); ok {
 		r.Release(ctx, f.file)
-	} else if r, ok := f.file.(FileReleaser); ok {
+	} else if r, ok := asFile[FileReleaser](f.file); ok {
 		r.Release(ctx)
 	}
 
@@ -931,8 +1240,8 @@ func (b *rawBridge) ReleaseDir(input *fuse.ReleaseIn) {
 	}
 	f.wg.Wait()
 
-	if frd, ok := f.file.(FileReleasedirer); ok {
-		frd.Releasedir(context.Background(), input.ReleaseFlags)
+	if frd, ok := asFile[FileReleasedirer](f.file); ok {
+		frd.Releasedir(&fuse.Context{Caller: input.Caller}, input.ReleaseFlags)
 	}
 
 	b.mu.Lock()
@@ -966,12 +1275,12 @@ func (b *rawBridge) releaseFileEntry(nid uint64, fh uint64) (*Inode, *fileEntry)
 func (b *rawBridge) Write(cancel <-chan struct{}, input *fuse.WriteIn, data []byte) (written uint32, status fuse.Status) {
 	n, f := b.inode(input.NodeId, input.Fh)
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
-	if wr, ok := n.ops.(NodeWriter); ok {
+	ctx := b.newContext(cancel, &input.InHeader)
+	if wr, ok := asNode[NodeWriter](n.ops); ok {
 		w, errno := wr.Write(ctx, f.file, data, int64(input.Offset))
 		return w, errnoToStatus(errno)
 	}
-	if fr, ok := f.file.(FileWriter); ok {
+	if fr, ok := asFile[FileWriter](f.file); ok {
 		w, errno := fr.Write(ctx, data, int64(input.Offset))
 		return w, errnoToStatus(errno)
 	}
@@ -981,11 +1290,14 @@ func (b *rawBridge) Write(cancel <-chan struct{}, input *fuse.WriteIn, data []by
 
 func (b *rawBridge) Flush(cancel <-chan struct{}, input *fuse.FlushIn) fuse.Status {
 	n, f := b.inode(input.NodeId, input.Fh)
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
-	if fl, ok := n.ops.(NodeFlusher); ok {
+	ctx := b.newContext(cancel, &input.InHeader)
+	if u, ok := asFile[FileUnlocker](f.file); ok {
+		defer u.Unlock(ctx, input.LockOwner, 0)
+	}
+	if fl, ok := asNode[NodeFlusher](n.ops); ok {
 		return errnoToStatus(fl.Flush(ctx, f.file))
 	}
-	if fl, ok := f.file.(FileFlusher); ok {
+	if fl, ok := asFile[FileFlusher](f.file); ok {
 		return errnoToStatus(fl.Flush(ctx))
 	}
 	return 0
@@ -993,11 +1305,11 @@ func (b *rawBridge) Flush(cancel <-chan struct{}, input *fuse.FlushIn) fuse.Stat
 
 func (b *rawBridge) Fsync(cancel <-chan struct{}, input *fuse.FsyncIn) fuse.Status {
 	n, f := b.inode(input.NodeId, input.Fh)
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
-	if fs, ok := n.ops.(NodeFsyncer); ok {
+	ctx := b.newContext(cancel, &input.InHeader)
+	if fs, ok := asNode[NodeFsyncer](n.ops); ok {
 		return errnoToStatus(fs.Fsync(ctx, f.file, input.FsyncFlags))
 	}
-	if fs, ok := f.file.(FileFsyncer); ok {
+	if fs, ok := asFile[FileFsyncer](f.file); ok {
 		return errnoToStatus(fs.Fsync(ctx, input.FsyncFlags))
 	}
 	return fuse.ENOTSUP
@@ -1005,11 +1317,11 @@ func (b *rawBridge) Fsync(cancel <-chan struct{}, input *fuse.FsyncIn) fuse.Stat
 
 func (b *rawBridge) Fallocate(cancel <-chan struct{}, input *fuse.FallocateIn) fuse.Status {
 	n, f := b.inode(input.NodeId, input.Fh)
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
-	if a, ok := n.ops.(NodeAllocater); ok {
+	ctx := b.newContext(cancel, &input.InHeader)
+	if a, ok := asNode[NodeAllocater](n.ops); ok {
 		return errnoToStatus(a.Allocate(ctx, f.file, input.Offset, input.Length, input.Mode))
 	}
-	if a, ok := f.file.(FileAllocater); ok {
+	if a, ok := asFile[FileAllocater](f.file); ok {
 		return errnoToStatus(a.Allocate(ctx, input.Offset, input.Length, input.Mode))
 	}
 	return fuse.ENOTSUP
@@ -1022,12 +1334,15 @@ func (b *rawBridge) OpenDir(cancel <-chan struct{}, input *fuse.OpenIn, out *fus
 	var fuseFlags uint32
 	var errno syscall.Errno
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
+	ctx := b.newContext(cancel, &input.InHeader)
+	if errno := b.checkAccess(ctx, n, fuse.R_OK); errno != 0 {
+		return errnoToStatus(errno)
+	}
 
-	nod, _ := n.ops.(NodeOpendirer)
-	nrd, _ := n.ops.(NodeReaddirer)
+	nod, _ := asNode[NodeOpendirer](n.ops)
+	nrd, _ := asNode[NodeReaddirer](n.ops)
 
-	if odh, ok := n.ops.(NodeOpendirHandler); ok {
+	if odh, ok := asNode[NodeOpendirHandler](n.ops); ok {
 		fh, fuseFlags, errno = odh.OpendirHandle(ctx, input.Flags)
 
 		if errno != 0 {
@@ -1087,7 +1402,7 @@ func (b *rawBridge) ReadDir(cancel <-chan struct{}, input *fuse.ReadIn, out *fus
 func (b *rawBridge) readDirMaybeLookup(cancel <-chan struct{}, input *fuse.ReadIn, out *fuse.DirEntryList, lookup bool) fuse.Status {
 	n, f := b.inode(input.NodeId, input.Fh)
 
-	direnter, ok := f.file.(FileReaddirenter)
+	direnter, ok := asFile[FileReaddirenter](f.file)
 	if !ok {
 		return fuse.OK
 	}
@@ -1096,7 +1411,7 @@ func (b *rawBridge) readDirMaybeLookup(cancel <-chan struct{}, input *fuse.ReadI
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
+	ctx := b.newContext(cancel, &input.InHeader)
 	interruptedRead := false
 	if input.Offset != f.dirOffset {
 		// If the last readdir(plus) was interrupted, the
@@ -1123,7 +1438,7 @@ func (b *rawBridge) readDirMaybeLookup(cancel <-chan struct{}, input *fuse.ReadI
 	}
 
 	if input.Offset != f.dirOffset {
-		if sd, ok := f.file.(FileSeekdirer); ok {
+		if sd, ok := asFile[FileSeekdirer](f.file); ok {
 			errno := sd.Seekdir(ctx, input.Offset)
 			if errno != 0 {
 				return errnoToStatus(errno)
@@ -1202,15 +1517,15 @@ func (b *rawBridge) readDirMaybeLookup(cancel <-chan struct{}, input *fuse.ReadI
 		}
 
 		var child *Inode
-		if fileLookupper, ok := f.file.(FileLookuper); ok {
+		if fileLookupper, ok := asFile[FileLookuper](f.file); ok {
 			child, errno = fileLookupper.Lookup(ctx, de.Name, entryOut)
 		} else {
 			child, errno = b.lookup(ctx, n, de.Name, entryOut)
 		}
 
 		if errno != 0 {
-			if b.options.NegativeTimeout != nil {
-				entryOut.SetEntryTimeout(*b.options.NegativeTimeout)
+			if n.mount.options.NegativeTimeout != nil {
+				n.mount.setNegativeTimeout(entryOut)
 
 				// TODO: maybe simply not produce the dirent here?
 				// test?
@@ -1219,7 +1534,7 @@ func (b *rawBridge) readDirMaybeLookup(cancel <-chan struct{}, input *fuse.ReadI
 		} else {
 			child, _ = b.addNewChild(n, de.Name, child, nil, 0, entryOut)
 			child.setEntryOut(entryOut)
-			b.setEntryOutTimeout(entryOut)
+			child.mount.setEntryOutTimeout(entryOut)
 			if de.Mode&syscall.S_IFMT != child.stableAttr.Mode&syscall.S_IFMT {
 				// The file type has changed behind our back. Use the new value.
 				out.FixMode(child.stableAttr.Mode)
@@ -1233,10 +1548,10 @@ func (b *rawBridge) readDirMaybeLookup(cancel <-chan struct{}, input *fuse.ReadI
 
 func (b *rawBridge) FsyncDir(cancel <-chan struct{}, input *fuse.FsyncIn) fuse.Status {
 	n, f := b.inode(input.NodeId, input.Fh)
-	ctx := &fuse.Context{Caller: input.Caller, Cancel: cancel}
-	if fsd, ok := f.file.(FileFsyncdirer); ok {
+	ctx := b.newContext(cancel, &input.InHeader)
+	if fsd, ok := asFile[FileFsyncdirer](f.file); ok {
 		return errnoToStatus(fsd.Fsyncdir(ctx, input.FsyncFlags))
-	} else if fs, ok := n.ops.(NodeFsyncer); ok {
+	} else if fs, ok := asNode[NodeFsyncer](n.ops); ok {
 		return errnoToStatus(fs.Fsync(ctx, f.file, input.FsyncFlags))
 	}
 
@@ -1245,8 +1560,8 @@ func (b *rawBridge) FsyncDir(cancel <-chan struct{}, input *fuse.FsyncIn) fuse.S
 
 func (b *rawBridge) StatFs(cancel <-chan struct{}, input *fuse.InHeader, out *fuse.StatfsOut) fuse.Status {
 	n, _ := b.inode(input.NodeId, 0)
-	if sf, ok := n.ops.(NodeStatfser); ok {
-		return errnoToStatus(sf.Statfs(&fuse.Context{Caller: input.Caller, Cancel: cancel}, out))
+	if sf, ok := asNode[NodeStatfser](n.ops); ok {
+		return errnoToStatus(sf.Statfs(b.newContext(cancel, input), out))
 	}
 
 	// leave zeroed out
@@ -1257,30 +1572,40 @@ func (b *rawBridge) Init(s *fuse.Server) {
 	b.server = s
 }
 
+// newContext returns the context for the request with the given
+// header. If the bridge is served by a fuse.Server, the context
+// reports the deadline and cancellation cause of the request.
+func (b *rawBridge) newContext(cancel <-chan struct{}, header *fuse.InHeader) *fuse.Context {
+	if s, ok := b.server.(*fuse.Server); ok {
+		return s.RequestContext(header, cancel)
+	}
+	return &fuse.Context{Caller: header.Caller, Cancel: cancel}
+}
+
 func (b *rawBridge) CopyFileRange(cancel <-chan struct{}, in *fuse.CopyFileRangeIn) (size uint32, status fuse.Status) {
 	n1, f1 := b.inode(in.NodeId, in.FhIn)
-	cfr, ok := n1.ops.(NodeCopyFileRanger)
+	cfr, ok := asNode[NodeCopyFileRanger](n1.ops)
 	if !ok {
 		return 0, fuse.ENOTSUP
 	}
 
 	n2, f2 := b.inode(in.NodeIdOut, in.FhOut)
 
-	sz, errno := cfr.CopyFileRange(&fuse.Context{Caller: in.Caller, Cancel: cancel},
+	sz, errno := cfr.CopyFileRange(b.newContext(cancel, &in.InHeader),
 		f1.file, in.OffIn, n2, f2.file, in.OffOut, in.Len, in.Flags)
 	return sz, errnoToStatus(errno)
 }
 
 func (b *rawBridge) Ioctl(cancel <-chan struct{}, in *fuse.IoctlIn, inbuf []byte, out *fuse.IoctlOut, outbuf []byte) (code fuse.Status) {
 	n, f := b.inode(in.NodeId, in.Fh)
-	if nio, ok := n.ops.(NodeIoctler); ok {
-		ctx := &fuse.Context{Caller: in.Caller, Cancel: cancel}
+	if nio, ok := asNode[NodeIoctler](n.ops); ok {
+		ctx := b.newContext(cancel, &in.InHeader)
 		result, errno := nio.Ioctl(ctx, f.file, in.Cmd, in.Arg, inbuf, outbuf)
 		out.Result = result
 		return errnoToStatus(errno)
 	}
-	if fio, ok := f.file.(FileIoctler); ok {
-		ctx := &fuse.Context{Caller: in.Caller, Cancel: cancel}
+	if fio, ok := asFile[FileIoctler](f.file); ok {
+		ctx := b.newContext(cancel, &in.InHeader)
 		result, errno := fio.Ioctl(ctx, in.Cmd, in.Arg, inbuf, outbuf)
 		out.Result = result
 		return errnoToStatus(errno)
@@ -1291,16 +1616,16 @@ func (b *rawBridge) Ioctl(cancel <-chan struct{}, in *fuse.IoctlIn, inbuf []byte
 func (b *rawBridge) Lseek(cancel <-chan struct{}, in *fuse.LseekIn, out *fuse.LseekOut) fuse.Status {
 	n, f := b.inode(in.NodeId, in.Fh)
 
-	ctx := &fuse.Context{Caller: in.Caller, Cancel: cancel}
+	ctx := b.newContext(cancel, &in.InHeader)
 
-	ls, ok := n.ops.(NodeLseeker)
+	ls, ok := asNode[NodeLseeker](n.ops)
 	if ok {
 		off, errno := ls.Lseek(ctx,
 			f.file, in.Offset, in.Whence)
 		out.Offset = off
 		return errnoToStatus(errno)
 	}
-	if fs, ok := f.file.(FileLseeker); ok {
+	if fs, ok := asFile[FileLseeker](f.file); ok {
 		off, errno := fs.Lseek(ctx, in.Offset, in.Whence)
 		out.Offset = off
 		return errnoToStatus(errno)
@@ -1329,6 +1654,9 @@ func (b *rawBridge) Lseek(cancel <-chan struct{}, in *fuse.LseekIn, out *fuse.Ls
 }
 
 func (b *rawBridge) OnUnmount() {
+	if b.notifier != nil {
+		b.notifier.close()
+	}
 	if of, ok := b.root.ops.(NodeOnForgetter); ok {
 		of.OnForget()
 	}
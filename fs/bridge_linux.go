@@ -7,26 +7,26 @@ import (
 )
 
 // see rawBridge.setAttr
-func (b *rawBridge) setStatx(out *fuse.Statx) {
-	if !b.options.NullPermissions && out.Mode&07777 == 0 {
+func (m *fsMount) setStatx(out *fuse.Statx) {
+	if !m.options.NullPermissions && out.Mode&07777 == 0 {
 		out.Mode |= 0644
 		if out.Mode&syscall.S_IFDIR != 0 {
 			out.Mode |= 0111
 		}
 	}
-	if b.options.UID != 0 && out.Uid == 0 {
-		out.Uid = b.options.UID
+	if m.options.UID != 0 && out.Uid == 0 {
+		out.Uid = m.options.UID
 	}
-	if b.options.GID != 0 && out.Gid == 0 {
-		out.Gid = b.options.GID
+	if m.options.GID != 0 && out.Gid == 0 {
+		out.Gid = m.options.GID
 	}
 	setStatxBlocks(out)
 }
 
-// see rawBridge.setAttrTimeout
-func (b *rawBridge) setStatxTimeout(out *fuse.StatxOut) {
-	if b.options.AttrTimeout != nil && out.Timeout() == 0 {
-		out.SetTimeout(*b.options.AttrTimeout)
+// see fsMount.setAttrTimeout
+func (m *fsMount) setStatxTimeout(out *fuse.StatxOut) {
+	if m.options.AttrTimeout != nil && out.Timeout() == 0 {
+		out.SetTimeout(*m.options.AttrTimeout)
 	}
 }
 
@@ -37,12 +37,12 @@ func (b *rawBridge) Statx(cancel <-chan struct{}, in *fuse.StatxIn, out *fuse.St
 		fh = fe.file
 	}
 
-	ctx := &fuse.Context{Caller: in.Caller, Cancel: cancel}
+	ctx := b.newContext(cancel, &in.InHeader)
 
 	errno := syscall.ENOSYS
-	if sx, ok := n.ops.(NodeStatxer); ok {
+	if sx, ok := asNode[NodeStatxer](n.ops); ok {
 		errno = sx.Statx(ctx, fh, in.SxFlags, in.SxMask, out)
-	} else if fsx, ok := n.ops.(FileStatxer); ok {
+	} else if fsx, ok := asFile[FileStatxer](fh); ok {
 		errno = fsx.Statx(ctx, in.SxFlags, in.SxMask, out)
 	}
 
@@ -52,8 +52,8 @@ func (b *rawBridge) Statx(cancel <-chan struct{}, in *fuse.StatxIn, out *fuse.St
 		}
 		out.Ino = n.stableAttr.Ino
 		out.Mode = (out.Statx.Mode & 07777) | uint16(n.stableAttr.Mode)
-		b.setStatx(&out.Statx)
-		b.setStatxTimeout(out)
+		n.mount.setStatx(&out.Statx)
+		n.mount.setStatxTimeout(out)
 	}
 
 	return errnoToStatus(errno)
@@ -20,6 +20,9 @@ func ToErrno(err error) syscall.Errno {
 	return syscall.Errno(s)
 }
 
+// RENAME_NOREPLACE is a flag argument for renameat2()
+const RENAME_NOREPLACE = 0x1
+
 // RENAME_EXCHANGE is a flag argument for renameat2()
 const RENAME_EXCHANGE = 0x2
 
@@ -58,6 +58,10 @@ type Inode struct {
 	ops    InodeEmbedder
 	bridge *rawBridge
 
+	// mount is the tree this node belongs to. It differs from the
+	// bridge root for nodes below Inode.MountChild.
+	mount *fsMount
+
 	// The *Node ID* is an arbitrary uint64 identifier chosen by the FUSE library.
 	// It is used the identify *nodes* (files/directories/symlinks/...) in the
 	// communication between the FUSE library and the Linux kernel.
@@ -120,10 +124,11 @@ func (n *Inode) EmbeddedInode() *Inode {
 	return n
 }
 
-func initInode(n *Inode, ops InodeEmbedder, attr StableAttr, bridge *rawBridge, persistent bool, nodeId uint64) {
+func initInode(n *Inode, ops InodeEmbedder, attr StableAttr, bridge *rawBridge, mount *fsMount, persistent bool, nodeId uint64) {
 	n.ops = ops
 	n.stableAttr = attr
 	n.bridge = bridge
+	n.mount = mount
 	n.persistent = persistent
 	n.nodeId = nodeId
 	if attr.Mode == fuse.S_IFDIR {
@@ -148,14 +153,16 @@ func (n *Inode) Mode() uint32 {
 	return n.stableAttr.Mode
 }
 
-// Returns the root of the tree
+// Returns the root of the tree. For nodes below a mount added with
+// MountChild, this is the root of that mount.
 func (n *Inode) Root() *Inode {
-	return n.bridge.root
+	return n.mount.root
 }
 
-// Returns whether this is the root of the tree
+// Returns whether this is the root of the tree, or of a mount added
+// with MountChild.
 func (n *Inode) IsRoot() bool {
-	return n.bridge.root == n
+	return n.mount.root == n
 }
 
 func modeStr(m uint32) string {
@@ -275,9 +282,10 @@ func (n *Inode) Forgotten() bool {
 }
 
 // Operations returns the object implementing the file system
-// operations.
+// operations. For trees wrapped with Wrap, this is the original
+// node.
 func (n *Inode) Operations() InodeEmbedder {
-	return n.ops
+	return unwrapOps(n.ops)
 }
 
 // Path returns a path string to the inode relative to `root`.
@@ -377,7 +385,7 @@ func (n *Inode) newInode(ctx context.Context, ops InodeEmbedder, id StableAttr,
 	if wc, ok := n.ops.(NodeWrapChilder); ok {
 		ops = wc.WrapChild(ctx, ops)
 	}
-	return n.bridge.newInode(ctx, ops, id, persistent)
+	return n.bridge.newInode(ctx, n.mount, ops, id, persistent)
 }
 
 // removeRef decreases references. Returns if this operation caused
@@ -434,8 +442,11 @@ func (n *Inode) removeRefInner(nlookup uint64, dropPersistence bool, inputUnused
 	if n.lookupCount == 0 {
 		// Dropping the node from stableAttrs guarantees that no new references to this node are
 		// handed out to the kernel, hence we can also safely delete it from kernelNodeIds.
-		delete(n.bridge.stableAttrs, n.stableAttr)
-		delete(n.bridge.kernelNodeIds, n.nodeId)
+		delete(n.mount.stableAttrs, n.stableAttr)
+		if n.bridge.kernelNodeIds[n.nodeId] == n {
+			delete(n.bridge.kernelNodeIds, n.nodeId)
+			delete(n.bridge.seqNodeIds, n.nodeId)
+		}
 	}
 	n.bridge.mu.Unlock()
 
@@ -733,6 +744,9 @@ func (n *Inode) NotifyEntry(name string) syscall.Errno {
 	if n.bridge.server == nil {
 		return syscall.ENOSYS
 	}
+	if nt := n.bridge.notifier; nt != nil {
+		return nt.enqueue(&notifyItem{notifyKey: notifyKey{op: notifyEntry, node: n, name: name}})
+	}
 	status := n.bridge.server.EntryNotify(n.nodeId, name)
 	return syscall.Errno(status)
 }
@@ -745,6 +759,9 @@ func (n *Inode) NotifyPrune(nodes []*Inode) syscall.Errno {
 	if n.bridge.server == nil {
 		return syscall.ENOSYS
 	}
+	if nt := n.bridge.notifier; nt != nil {
+		return nt.enqueuePrune(nodes)
+	}
 	ids := make([]uint64, 0, len(nodes))
 	for _, n := range nodes {
 		ids = append(ids, n.nodeId)
@@ -760,6 +777,9 @@ func (n *Inode) NotifyDelete(name string, child *Inode) syscall.Errno {
 	if n.bridge.server == nil {
 		return syscall.ENOSYS
 	}
+	if nt := n.bridge.notifier; nt != nil {
+		return nt.enqueue(&notifyItem{notifyKey: notifyKey{op: notifyDelete, node: n, child: child.nodeId, name: name}})
+	}
 	// XXX arg ordering?
 	return syscall.Errno(n.bridge.server.DeleteNotify(n.nodeId, child.nodeId, name))
 }
@@ -770,6 +790,13 @@ func (n *Inode) NotifyContent(off, sz int64) syscall.Errno {
 	if n.bridge.server == nil {
 		return syscall.ENOSYS
 	}
+	if nt := n.bridge.notifier; nt != nil {
+		it := &notifyItem{notifyKey: notifyKey{op: notifyContent, node: n}, off: off, end: -1}
+		if off >= 0 && sz > 0 {
+			it.end = off + sz
+		}
+		return nt.enqueue(it)
+	}
 	// XXX how does this work for directories?
 	return syscall.Errno(n.bridge.server.InodeNotify(n.nodeId, off, sz))
 }
@@ -0,0 +1,498 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// InodeFS serves a tree of nodes as an io/fs.FS, without mounting
+// it. It issues the same calls to the nodes as a mounted file system
+// would for lookups, stat, readdir, open and read, as the process
+// itself. This is useful for testing file systems, and for using them
+// in process, for example with http.FileServer.
+//
+// Symlinks are followed if their targets are relative and stay
+// within the tree. InodeFS is safe for concurrent use.
+type InodeFS struct {
+	bridge *rawBridge
+	caller fuse.Caller
+}
+
+var _ = (iofs.FS)((*InodeFS)(nil))
+var _ = (iofs.ReadDirFS)((*InodeFS)(nil))
+var _ = (iofs.ReadFileFS)((*InodeFS)(nil))
+var _ = (iofs.StatFS)((*InodeFS)(nil))
+
+// NewInodeFS returns an InodeFS for the tree rooted at root. The
+// root must not be mounted or used otherwise, and opts are
+// interpreted as for Mount.
+func NewInodeFS(root InodeEmbedder, opts *Options) *InodeFS {
+	return &InodeFS{
+		bridge: NewNodeFS(root, opts).(*rawBridge),
+		caller: fuse.Caller{
+			Owner: fuse.Owner{
+				Uid: uint32(os.Getuid()),
+				Gid: uint32(os.Getgid()),
+			},
+			Pid: uint32(os.Getpid()),
+		},
+	}
+}
+
+func (fsys *InodeFS) header(nodeID uint64) fuse.InHeader {
+	return fuse.InHeader{NodeId: nodeID, Caller: fsys.caller}
+}
+
+// forget drops the lookups done by walk.
+func (fsys *InodeFS) forget(ids []uint64) {
+	for _, id := range ids {
+		fsys.bridge.Forget(id, 1)
+	}
+}
+
+// walk looks up name. It returns the node IDs that were looked up,
+// which must be passed to forget, and the node ID and attributes of
+// the result. Symlinks in the directories of name are followed, and
+// a symlink at the end if follow is set.
+func (fsys *InodeFS) walk(op, name string, follow bool) (ids []uint64, nodeID uint64, attr fuse.Attr, err error) {
+	fail := func(err error) ([]uint64, uint64, fuse.Attr, error) {
+		fsys.forget(ids)
+		return nil, 0, attr, &iofs.PathError{Op: op, Path: name, Err: err}
+	}
+	if !iofs.ValidPath(name) {
+		return fail(iofs.ErrInvalid)
+	}
+
+	nodeID = 1
+	var done []string
+	todo := inodeFSSplit(name)
+	links := 0
+	for len(todo) > 0 {
+		if nodeID != 1 && attr.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+			return fail(syscall.ENOTDIR)
+		}
+		h := fsys.header(nodeID)
+		var out fuse.EntryOut
+		st := fsys.bridge.Lookup(nil, &h, todo[0], &out)
+		if st.Ok() && out.NodeId == 0 {
+			st = fuse.ENOENT
+		}
+		if !st.Ok() {
+			return fail(syscall.Errno(st))
+		}
+		ids = append(ids, out.NodeId)
+		if out.Attr.Mode&syscall.S_IFMT != syscall.S_IFLNK || (len(todo) == 1 && !follow) {
+			nodeID, attr = out.NodeId, out.Attr
+			done = append(done, todo[0])
+			todo = todo[1:]
+			continue
+		}
+
+		links++
+		if links > 40 {
+			return fail(syscall.ELOOP)
+		}
+		h = fsys.header(out.NodeId)
+		target, st := fsys.bridge.Readlink(nil, &h)
+		if !st.Ok() {
+			return fail(syscall.Errno(st))
+		}
+		// Links are resolved within the tree, so they may not be
+		// absolute or point above the root.
+		p := path.Join(path.Join(done...), string(target))
+		if path.IsAbs(string(target)) || !iofs.ValidPath(p) {
+			return fail(iofs.ErrInvalid)
+		}
+		todo = append(inodeFSSplit(p), todo[1:]...)
+		done = nil
+		nodeID = 1
+	}
+	if nodeID == 1 {
+		in := fuse.GetAttrIn{InHeader: fsys.header(1)}
+		var out fuse.AttrOut
+		if st := fsys.bridge.GetAttr(nil, &in, &out); !st.Ok() {
+			return fail(syscall.Errno(st))
+		}
+		attr = out.Attr
+	}
+	return ids, nodeID, attr, nil
+}
+
+func inodeFSSplit(name string) []string {
+	if name == "." || name == "" {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
+
+// Stat returns the attributes of name, following symlinks.
+func (fsys *InodeFS) Stat(name string) (iofs.FileInfo, error) {
+	return fsys.stat("stat", name, true)
+}
+
+// Lstat returns the attributes of name, without following a symlink
+// at the end.
+func (fsys *InodeFS) Lstat(name string) (iofs.FileInfo, error) {
+	return fsys.stat("lstat", name, false)
+}
+
+func (fsys *InodeFS) stat(op, name string, follow bool) (iofs.FileInfo, error) {
+	ids, _, attr, err := fsys.walk(op, name, follow)
+	if err != nil {
+		return nil, err
+	}
+	fsys.forget(ids)
+	return &inodeFileInfo{name: path.Base(name), attr: attr}, nil
+}
+
+// ReadLink returns the target of the symlink name. With Lstat, it
+// implements io/fs.ReadLinkFS.
+func (fsys *InodeFS) ReadLink(name string) (string, error) {
+	ids, nodeID, attr, err := fsys.walk("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	defer fsys.forget(ids)
+	if attr.Mode&syscall.S_IFMT != syscall.S_IFLNK {
+		return "", &iofs.PathError{Op: "readlink", Path: name, Err: iofs.ErrInvalid}
+	}
+	h := fsys.header(nodeID)
+	target, st := fsys.bridge.Readlink(nil, &h)
+	if !st.Ok() {
+		return "", &iofs.PathError{Op: "readlink", Path: name, Err: syscall.Errno(st)}
+	}
+	return string(target), nil
+}
+
+// Open opens name for reading.
+func (fsys *InodeFS) Open(name string) (iofs.File, error) {
+	ids, nodeID, attr, err := fsys.walk("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	f := &inodeFile{
+		fsys:   fsys,
+		name:   name,
+		ids:    ids,
+		nodeID: nodeID,
+		dir:    attr.Mode&syscall.S_IFMT == syscall.S_IFDIR,
+	}
+
+	in := fuse.OpenIn{InHeader: fsys.header(f.nodeID), Flags: syscall.O_RDONLY}
+	var out fuse.OpenOut
+	var st fuse.Status
+	if f.dir {
+		in.Flags |= syscall.O_DIRECTORY
+		st = fsys.bridge.OpenDir(nil, &in, &out)
+	} else {
+		st = fsys.bridge.Open(nil, &in, &out)
+	}
+	if !st.Ok() {
+		fsys.forget(ids)
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: syscall.Errno(st)}
+	}
+	f.fh = out.Fh
+	return f, nil
+}
+
+// ReadFile reads the contents of name.
+func (fsys *InodeFS) ReadFile(name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// ReadDir returns the entries of the directory name, sorted by name.
+func (fsys *InodeFS) ReadDir(name string) ([]iofs.DirEntry, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d, ok := f.(iofs.ReadDirFile)
+	if !ok {
+		return nil, &iofs.PathError{Op: "readdir", Path: name, Err: syscall.ENOTDIR}
+	}
+	return d.ReadDir(-1)
+}
+
+// inodeFile is an open file or directory of an InodeFS.
+type inodeFile struct {
+	fsys   *InodeFS
+	name   string
+	ids    []uint64
+	nodeID uint64
+	fh     uint64
+	dir    bool
+
+	mu     sync.Mutex
+	closed bool
+	off    int64
+
+	// entries holds the directory entries not yet returned by
+	// ReadDir. It is nil until the first ReadDir call.
+	entries []iofs.DirEntry
+}
+
+var _ = (iofs.ReadDirFile)((*inodeFile)(nil))
+var _ = (io.ReaderAt)((*inodeFile)(nil))
+var _ = (io.Seeker)((*inodeFile)(nil))
+
+func (f *inodeFile) pathError(op string, err error) error {
+	return &iofs.PathError{Op: op, Path: f.name, Err: err}
+}
+
+func (f *inodeFile) Stat() (iofs.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, f.pathError("stat", iofs.ErrClosed)
+	}
+	return f.statLocked()
+}
+
+func (f *inodeFile) statLocked() (iofs.FileInfo, error) {
+	in := fuse.GetAttrIn{
+		InHeader: f.fsys.header(f.nodeID),
+		Flags_:   fuse.FUSE_GETATTR_FH,
+		Fh_:      f.fh,
+	}
+	var out fuse.AttrOut
+	if st := f.fsys.bridge.GetAttr(nil, &in, &out); !st.Ok() {
+		return nil, f.pathError("stat", syscall.Errno(st))
+	}
+	return &inodeFileInfo{name: path.Base(f.name), attr: out.Attr}, nil
+}
+
+func (f *inodeFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.readAtLocked(p, off)
+}
+
+func (f *inodeFile) readAtLocked(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, f.pathError("read", iofs.ErrClosed)
+	}
+	if f.dir {
+		return 0, f.pathError("read", syscall.EISDIR)
+	}
+	n := 0
+	for n < len(p) {
+		in := fuse.ReadIn{
+			InHeader: f.fsys.header(f.nodeID),
+			Fh:       f.fh,
+			Offset:   uint64(off) + uint64(n),
+			Size:     uint32(min(len(p)-n, 1<<20)),
+			Flags:    syscall.O_RDONLY,
+		}
+		buf := p[n : n+int(in.Size)]
+		res, st := f.fsys.bridge.Read(nil, &in, buf)
+		if st.Ok() {
+			var data []byte
+			data, st = res.Bytes(buf)
+			n += copy(buf, data)
+			res.Done()
+			if st.Ok() && len(data) == 0 {
+				return n, io.EOF
+			}
+		}
+		if !st.Ok() {
+			return n, f.pathError("read", syscall.Errno(st))
+		}
+	}
+	return n, nil
+}
+
+func (f *inodeFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.readAtLocked(p, f.off)
+	f.off += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *inodeFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, f.pathError("seek", iofs.ErrClosed)
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		fi, err := f.statLocked()
+		if err != nil {
+			return 0, err
+		}
+		offset += fi.Size()
+	}
+	if offset < 0 {
+		return 0, f.pathError("seek", iofs.ErrInvalid)
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *inodeFile) ReadDir(n int) ([]iofs.DirEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, f.pathError("readdir", iofs.ErrClosed)
+	}
+	if !f.dir {
+		return nil, f.pathError("readdir", syscall.ENOTDIR)
+	}
+	if f.entries == nil {
+		if err := f.readEntriesLocked(); err != nil {
+			return nil, err
+		}
+	}
+	if n <= 0 {
+		r := f.entries
+		f.entries = f.entries[len(r):]
+		return r, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(f.entries))
+	r := f.entries[:n:n]
+	f.entries = f.entries[n:]
+	return r, nil
+}
+
+// readEntriesLocked reads all entries from the directory handle
+// through READDIR.
+func (f *inodeFile) readEntriesLocked() error {
+	entries := []iofs.DirEntry{}
+	buf := make([]byte, 64*1024)
+	in := fuse.ReadIn{
+		InHeader: f.fsys.header(f.nodeID),
+		Fh:       f.fh,
+		Size:     uint32(len(buf)),
+	}
+	for {
+		out := fuse.NewDirEntryList(buf, in.Offset)
+		if st := f.fsys.bridge.ReadDir(nil, &in, out); !st.Ok() {
+			return f.pathError("readdir", syscall.Errno(st))
+		}
+		des := out.Entries()
+		if len(des) == 0 {
+			break
+		}
+		for _, de := range des {
+			if de.Name == "." || de.Name == ".." {
+				continue
+			}
+			entries = append(entries, &inodeDirEntry{
+				fsys: f.fsys,
+				path: path.Join(f.name, de.Name),
+				name: de.Name,
+				mode: de.Mode,
+			})
+		}
+		in.Offset = out.Offset
+	}
+	slices.SortFunc(entries, func(a, b iofs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	f.entries = entries
+	return nil
+}
+
+func (f *inodeFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return f.pathError("close", iofs.ErrClosed)
+	}
+	f.closed = true
+	in := fuse.ReleaseIn{InHeader: f.fsys.header(f.nodeID), Fh: f.fh}
+	if f.dir {
+		f.fsys.bridge.ReleaseDir(&in)
+	} else {
+		fl := fuse.FlushIn{InHeader: in.InHeader, Fh: f.fh}
+		f.fsys.bridge.Flush(nil, &fl)
+		f.fsys.bridge.Release(nil, &in)
+	}
+	f.fsys.forget(f.ids)
+	return nil
+}
+
+// inodeDirEntry is a directory entry returned by inodeFile.ReadDir.
+type inodeDirEntry struct {
+	fsys *InodeFS
+	path string
+	name string
+	mode uint32
+}
+
+func (e *inodeDirEntry) Name() string                 { return e.name }
+func (e *inodeDirEntry) IsDir() bool                  { return e.mode&syscall.S_IFMT == syscall.S_IFDIR }
+func (e *inodeDirEntry) Type() iofs.FileMode          { return inodeFileMode(e.mode).Type() }
+func (e *inodeDirEntry) Info() (iofs.FileInfo, error) { return e.fsys.Lstat(e.path) }
+
+// inodeFileInfo is the FileInfo of a node. Sys returns the
+// *fuse.Attr.
+type inodeFileInfo struct {
+	name string
+	attr fuse.Attr
+}
+
+func (i *inodeFileInfo) Name() string        { return i.name }
+func (i *inodeFileInfo) Size() int64         { return int64(i.attr.Size) }
+func (i *inodeFileInfo) Mode() iofs.FileMode { return inodeFileMode(i.attr.Mode) }
+func (i *inodeFileInfo) ModTime() time.Time  { return i.attr.ModTime() }
+func (i *inodeFileInfo) IsDir() bool         { return i.Mode().IsDir() }
+func (i *inodeFileInfo) Sys() any            { return &i.attr }
+
+// inodeFileMode converts a mode with S_IFMT bits to a FileMode. It
+// is the inverse of ioFSAttr.
+func inodeFileMode(mode uint32) iofs.FileMode {
+	m := iofs.FileMode(mode & 0777)
+	switch mode & syscall.S_IFMT {
+	case syscall.S_IFDIR:
+		m |= iofs.ModeDir
+	case syscall.S_IFLNK:
+		m |= iofs.ModeSymlink
+	case syscall.S_IFIFO:
+		m |= iofs.ModeNamedPipe
+	case syscall.S_IFSOCK:
+		m |= iofs.ModeSocket
+	case syscall.S_IFCHR:
+		m |= iofs.ModeDevice | iofs.ModeCharDevice
+	case syscall.S_IFBLK:
+		m |= iofs.ModeDevice
+	}
+	if mode&syscall.S_ISUID != 0 {
+		m |= iofs.ModeSetuid
+	}
+	if mode&syscall.S_ISGID != 0 {
+		m |= iofs.ModeSetgid
+	}
+	if mode&syscall.S_ISVTX != 0 {
+		m |= iofs.ModeSticky
+	}
+	return m
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	iofs "io/fs"
+	"syscall"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestInodeFSTree(t *testing.T) {
+	root := &Inode{}
+	fsys := NewInodeFS(root, &Options{
+		OnAdd: func(ctx context.Context) {
+			dir := root.NewPersistentInode(ctx, &Inode{}, StableAttr{Mode: fuse.S_IFDIR})
+			root.AddChild("dir", dir, false)
+			for _, nm := range []string{"a", "b"} {
+				f := root.NewPersistentInode(ctx, &MemRegularFile{
+					Data: []byte("contents of " + nm),
+					Attr: fuse.Attr{Mode: 0644},
+				}, StableAttr{})
+				dir.AddChild(nm, f, false)
+			}
+			link := root.NewPersistentInode(ctx, &MemSymlink{Data: []byte("dir/a")}, StableAttr{Mode: fuse.S_IFLNK})
+			root.AddChild("link", link, false)
+		},
+	})
+
+	if err := fstest.TestFS(fsys, "dir/a", "dir/b", "link"); err != nil {
+		t.Error(err)
+	}
+
+	if got, err := fsys.ReadFile("dir/b"); err != nil || string(got) != "contents of b" {
+		t.Errorf("ReadFile: got %q, %v", got, err)
+	}
+	fi, err := fsys.Lstat("link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Type() != iofs.ModeSymlink {
+		t.Errorf("Lstat(link): got mode %v", fi.Mode())
+	}
+	if got, err := fsys.ReadLink("link"); err != nil || got != "dir/a" {
+		t.Errorf("ReadLink: got %q, %v", got, err)
+	}
+	if got, err := fsys.ReadFile("link"); err != nil || string(got) != "contents of a" {
+		t.Errorf("ReadFile(link): got %q, %v", got, err)
+	}
+	if _, err := fsys.Open("dir/a/x"); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("Open below file: got %v, want ENOTDIR", err)
+	}
+	if _, err := fsys.Stat("missing"); !errors.Is(err, iofs.ErrNotExist) {
+		t.Errorf("Stat(missing): got %v", err)
+	}
+
+	if l := len(fsys.bridge.kernelNodeIds); l != 1 {
+		t.Errorf("got %d node IDs after closing all files, want 1", l)
+	}
+}
+
+func TestInodeFSLookup(t *testing.T) {
+	mfs := ioFSTestData()
+	fsys := NewInodeFS(NewIOFSRoot(mfs), nil)
+	if err := fstest.TestFS(fsys, "dir/file.txt", "dir/exec", "big"); err != nil {
+		t.Error(err)
+	}
+
+	f, err := fsys.Open("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	buf := make([]byte, 5000)
+	if _, err := f.(*inodeFile).ReadAt(buf, 123456); err != nil {
+		t.Fatal(err)
+	}
+	if want := mfs["big"].Data[123456 : 123456+5000]; !bytes.Equal(buf, want) {
+		t.Errorf("ReadAt: wrong data")
+	}
+}
+
+func TestInodeFSUseAfterClose(t *testing.T) {
+	fsys := NewInodeFS(NewIOFSRoot(ioFSTestData()), nil)
+	for _, name := range []string{"big", "dir"} {
+		f, err := fsys.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := f.Stat(); !errors.Is(err, iofs.ErrClosed) {
+			t.Errorf("%s: Stat: got %v, want ErrClosed", name, err)
+		}
+		if _, err := f.Read(make([]byte, 1)); !errors.Is(err, iofs.ErrClosed) {
+			t.Errorf("%s: Read: got %v, want ErrClosed", name, err)
+		}
+		if _, err := f.(io.ReaderAt).ReadAt(make([]byte, 1), 0); !errors.Is(err, iofs.ErrClosed) {
+			t.Errorf("%s: ReadAt: got %v, want ErrClosed", name, err)
+		}
+		if _, err := f.(io.Seeker).Seek(0, io.SeekEnd); !errors.Is(err, iofs.ErrClosed) {
+			t.Errorf("%s: Seek: got %v, want ErrClosed", name, err)
+		}
+		if _, err := f.(iofs.ReadDirFile).ReadDir(-1); !errors.Is(err, iofs.ErrClosed) {
+			t.Errorf("%s: ReadDir: got %v, want ErrClosed", name, err)
+		}
+		if err := f.Close(); !errors.Is(err, iofs.ErrClosed) {
+			t.Errorf("%s: Close: got %v, want ErrClosed", name, err)
+		}
+	}
+}
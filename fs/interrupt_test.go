@@ -6,7 +6,9 @@ package fs
 
 import (
 	"context"
+	"errors"
 	"os/exec"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
@@ -34,17 +36,37 @@ func (r *interruptRoot) Lookup(ctx context.Context, name string, out *fuse.Entry
 
 type interruptOps struct {
 	Inode
+
+	// mu protects the fields below, which are written by the
+	// request handler and read by the test.
+	mu          sync.Mutex
 	interrupted bool
+	cause       error
+	hasDeadline bool
+}
+
+// result returns what the last OPEN saw.
+func (o *interruptOps) result() (interrupted bool, cause error, hasDeadline bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.interrupted, o.cause, o.hasDeadline
 }
 
 var _ = (NodeOpener)((*interruptOps)(nil))
 
 func (o *interruptOps) Open(ctx context.Context, flags uint32) (FileHandle, uint32, syscall.Errno) {
+	_, hasDeadline := ctx.Deadline()
+	o.mu.Lock()
+	o.hasDeadline = hasDeadline
+	o.mu.Unlock()
 	select {
 	case <-time.After(100 * time.Millisecond):
 		return nil, 0, syscall.EIO
 	case <-ctx.Done():
+		o.mu.Lock()
+		defer o.mu.Unlock()
 		o.interrupted = true
+		o.cause = context.Cause(ctx)
 		return nil, 0, syscall.EINTR
 	}
 }
@@ -71,7 +93,41 @@ func TestInterrupt(t *testing.T) {
 	}
 	server.Unmount()
 
-	if !root.child.interrupted {
+	interrupted, c, _ := root.child.result()
+	if !interrupted {
 		t.Errorf("open request was not interrupted")
 	}
+	if c != fuse.ErrInterrupted && c != fuse.ErrServerShutdown {
+		t.Errorf("got cause %v, want %v", c, fuse.ErrInterrupted)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	root := &interruptRoot{}
+
+	oneSec := time.Second
+	opts := &Options{
+		EntryTimeout: &oneSec,
+		AttrTimeout:  &oneSec,
+	}
+	opts.RequestTimeouts = map[string]time.Duration{
+		"OPEN": 10 * time.Millisecond,
+	}
+	mntDir, _ := testMount(t, root, opts)
+
+	// os.Open retries on EINTR.
+	_, err := syscall.Open(mntDir+"/file", syscall.O_RDONLY, 0)
+	if err != syscall.EINTR {
+		t.Errorf("got %v, want EINTR", err)
+	}
+	_, c, hasDeadline := root.child.result()
+	if !hasDeadline {
+		t.Errorf("OPEN had no deadline")
+	}
+	if c != fuse.ErrRequestTimeout {
+		t.Errorf("got cause %v, want %v", c, fuse.ErrRequestTimeout)
+	}
+	if !errors.Is(c, context.DeadlineExceeded) {
+		t.Errorf("cause %v should wrap DeadlineExceeded", c)
+	}
 }
@@ -0,0 +1,253 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"io"
+	iofs "io/fs"
+	"path"
+	"sync"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// ioFSReadLinker is implemented by file systems that support
+// symlinks. It has the same methods as io/fs.ReadLinkFS from Go 1.25.
+type ioFSReadLinker interface {
+	ReadLink(name string) (string, error)
+	Lstat(name string) (iofs.FileInfo, error)
+}
+
+// NewIOFSRoot returns a root node that serves fsys read-only, for
+// example an embed.FS, a fstest.MapFS or a *zip.Reader:
+//
+//	server, err := fs.Mount(dir, fs.NewIOFSRoot(fsys), nil)
+//
+// Directories are populated lazily, as the kernel looks up names in
+// them. Files are read with ReadAt if they implement io.ReaderAt,
+// and sequentially otherwise. If fsys has ReadLink and Lstat
+// methods, like io/fs.ReadLinkFS, symlinks are supported.
+//
+// The modes, sizes and modification times are taken from the
+// FileInfo. Inode numbers are assigned automatically.
+func NewIOFSRoot(fsys iofs.FS) InodeEmbedder {
+	return &ioFSNode{fsys: fsys, name: "."}
+}
+
+// ioFSNode is a file, directory or symlink of an io/fs.FS.
+type ioFSNode struct {
+	Inode
+
+	fsys iofs.FS
+	name string
+}
+
+var _ = (NodeLookuper)((*ioFSNode)(nil))
+var _ = (NodeReaddirer)((*ioFSNode)(nil))
+var _ = (NodeGetattrer)((*ioFSNode)(nil))
+var _ = (NodeOpener)((*ioFSNode)(nil))
+var _ = (NodeReadlinker)((*ioFSNode)(nil))
+
+// ioFSErrno converts errors from an io/fs.FS, which are usually not
+// syscall errors.
+func ioFSErrno(err error) syscall.Errno {
+	var errno syscall.Errno
+	switch {
+	case err == nil:
+		return OK
+	case errors.As(err, &errno):
+		return errno
+	case errors.Is(err, iofs.ErrNotExist):
+		return syscall.ENOENT
+	case errors.Is(err, iofs.ErrPermission):
+		return syscall.EACCES
+	case errors.Is(err, iofs.ErrInvalid):
+		return syscall.EINVAL
+	}
+	return syscall.EIO
+}
+
+func (n *ioFSNode) lstat(name string) (iofs.FileInfo, error) {
+	if rl, ok := n.fsys.(ioFSReadLinker); ok {
+		return rl.Lstat(name)
+	}
+	return iofs.Stat(n.fsys, name)
+}
+
+// ioFSMode converts the type bits of a FileMode to S_IFMT bits.
+func ioFSMode(m iofs.FileMode) uint32 {
+	switch {
+	case m.IsDir():
+		return syscall.S_IFDIR
+	case m&iofs.ModeSymlink != 0:
+		return syscall.S_IFLNK
+	case m&iofs.ModeNamedPipe != 0:
+		return syscall.S_IFIFO
+	case m&iofs.ModeSocket != 0:
+		return syscall.S_IFSOCK
+	case m&iofs.ModeCharDevice != 0:
+		return syscall.S_IFCHR
+	case m&iofs.ModeDevice != 0:
+		return syscall.S_IFBLK
+	}
+	return syscall.S_IFREG
+}
+
+func ioFSAttr(fi iofs.FileInfo, out *fuse.Attr) {
+	m := fi.Mode()
+	out.Mode = ioFSMode(m) | uint32(m.Perm())
+	if m&iofs.ModeSetuid != 0 {
+		out.Mode |= syscall.S_ISUID
+	}
+	if m&iofs.ModeSetgid != 0 {
+		out.Mode |= syscall.S_ISGID
+	}
+	if m&iofs.ModeSticky != 0 {
+		out.Mode |= syscall.S_ISVTX
+	}
+	out.Size = uint64(fi.Size())
+	mtime := fi.ModTime()
+	out.SetTimes(&mtime, &mtime, &mtime)
+}
+
+func (n *ioFSNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	p := path.Join(n.name, name)
+	fi, err := n.lstat(p)
+	if err != nil {
+		return nil, ioFSErrno(err)
+	}
+	ioFSAttr(fi, &out.Attr)
+	ch := &ioFSNode{fsys: n.fsys, name: p}
+	return n.NewInode(ctx, ch, StableAttr{Mode: ioFSMode(fi.Mode())}), OK
+}
+
+func (n *ioFSNode) Readdir(ctx context.Context) (DirStream, syscall.Errno) {
+	es, err := iofs.ReadDir(n.fsys, n.name)
+	if err != nil {
+		return nil, ioFSErrno(err)
+	}
+	r := make([]fuse.DirEntry, 0, len(es))
+	for _, e := range es {
+		r = append(r, fuse.DirEntry{
+			Name: e.Name(),
+			Mode: ioFSMode(e.Type()),
+		})
+	}
+	return NewListDirStream(r), OK
+}
+
+func (n *ioFSNode) Getattr(ctx context.Context, f FileHandle, out *fuse.AttrOut) syscall.Errno {
+	fi, err := n.lstat(n.name)
+	if err != nil {
+		return ioFSErrno(err)
+	}
+	ioFSAttr(fi, &out.Attr)
+	return OK
+}
+
+func (n *ioFSNode) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
+	rl, ok := n.fsys.(ioFSReadLinker)
+	if !ok {
+		return nil, syscall.EINVAL
+	}
+	target, err := rl.ReadLink(n.name)
+	if err != nil {
+		return nil, ioFSErrno(err)
+	}
+	return []byte(target), OK
+}
+
+func (n *ioFSNode) Open(ctx context.Context, flags uint32) (FileHandle, uint32, syscall.Errno) {
+	if flags&(syscall.O_WRONLY|syscall.O_RDWR|syscall.O_TRUNC) != 0 {
+		return nil, 0, syscall.EROFS
+	}
+	f, err := n.fsys.Open(n.name)
+	if err != nil {
+		return nil, 0, ioFSErrno(err)
+	}
+	fh := &ioFSFile{fsys: n.fsys, name: n.name, file: f}
+	fh.readerAt, _ = f.(io.ReaderAt)
+	return fh, fuse.FOPEN_KEEP_CACHE, OK
+}
+
+// ioFSFile is an open file of an io/fs.FS.
+type ioFSFile struct {
+	fsys iofs.FS
+	name string
+
+	// readerAt is set if file supports random reads.
+	readerAt io.ReaderAt
+
+	mu   sync.Mutex
+	file iofs.File
+
+	// pos is the read position of file, if it is read
+	// sequentially.
+	pos int64
+}
+
+var _ = (FileReader)((*ioFSFile)(nil))
+var _ = (FileReleaser)((*ioFSFile)(nil))
+
+func (f *ioFSFile) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	if f.readerAt != nil {
+		n, err := f.readerAt.ReadAt(dest, off)
+		if err != nil && err != io.EOF {
+			return nil, ioFSErrno(err)
+		}
+		return fuse.ReadResultData(dest[:n]), OK
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if errno := f.seekLocked(off); errno != 0 {
+		return nil, errno
+	}
+	n, err := io.ReadFull(f.file, dest)
+	f.pos += int64(n)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, ioFSErrno(err)
+	}
+	return fuse.ReadResultData(dest[:n]), OK
+}
+
+// seekLocked moves the read position to off. Files that can't seek
+// are reopened to go backwards, and read to go forwards.
+func (f *ioFSFile) seekLocked(off int64) syscall.Errno {
+	if off == f.pos {
+		return OK
+	}
+	if s, ok := f.file.(io.Seeker); ok {
+		pos, err := s.Seek(off, io.SeekStart)
+		if err != nil {
+			return ioFSErrno(err)
+		}
+		f.pos = pos
+		return OK
+	}
+	if off < f.pos {
+		nf, err := f.fsys.Open(f.name)
+		if err != nil {
+			return ioFSErrno(err)
+		}
+		f.file.Close()
+		f.file, f.pos = nf, 0
+	}
+	n, err := io.CopyN(io.Discard, f.file, off-f.pos)
+	f.pos += n
+	if err != nil && err != io.EOF {
+		return ioFSErrno(err)
+	}
+	return OK
+}
+
+func (f *ioFSFile) Release(ctx context.Context) syscall.Errno {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return ioFSErrno(f.file.Close())
+}
@@ -0,0 +1,146 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"bytes"
+	iofs "io/fs"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// ioFSLinks adds symlink support to a MapFS; entries with
+// ModeSymlink hold the link target as data.
+type ioFSLinks struct {
+	fstest.MapFS
+}
+
+// ioFSLinkInfo is the FileInfo of a symlink in ioFSLinks.
+type ioFSLinkInfo struct {
+	name string
+	*fstest.MapFile
+}
+
+func (i ioFSLinkInfo) Name() string        { return path.Base(i.name) }
+func (i ioFSLinkInfo) Size() int64         { return int64(len(i.Data)) }
+func (i ioFSLinkInfo) Mode() iofs.FileMode { return i.MapFile.Mode }
+func (i ioFSLinkInfo) ModTime() time.Time  { return i.MapFile.ModTime }
+func (i ioFSLinkInfo) IsDir() bool         { return false }
+func (i ioFSLinkInfo) Sys() any            { return nil }
+
+func (l ioFSLinks) Lstat(name string) (iofs.FileInfo, error) {
+	if f, ok := l.MapFS[name]; ok && f.Mode&iofs.ModeSymlink != 0 {
+		return ioFSLinkInfo{name, f}, nil
+	}
+	return l.MapFS.Stat(name)
+}
+
+func (l ioFSLinks) ReadLink(name string) (string, error) {
+	f, ok := l.MapFS[name]
+	if !ok || f.Mode&iofs.ModeSymlink == 0 {
+		return "", &iofs.PathError{Op: "readlink", Path: name, Err: iofs.ErrInvalid}
+	}
+	return string(f.Data), nil
+}
+
+// ioFSSequential hides ReadAt and Seek from the files of a MapFS.
+type ioFSSequential struct {
+	fstest.MapFS
+}
+
+func (s ioFSSequential) Open(name string) (iofs.File, error) {
+	f, err := s.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return struct{ iofs.File }{f}, nil
+}
+
+func ioFSTestData() fstest.MapFS {
+	data := make([]byte, 300000)
+	for i := range data {
+		data[i] = byte(i / 1000)
+	}
+	return fstest.MapFS{
+		"dir/file.txt": {Data: []byte("hello"), Mode: 0640, ModTime: time.Unix(1700000000, 0)},
+		"dir/exec":     {Data: []byte("#!/bin/sh"), Mode: 0755},
+		"big":          {Data: data, Mode: 0644},
+		"link":         {Data: []byte("dir/file.txt"), Mode: iofs.ModeSymlink | 0777},
+	}
+}
+
+func TestIOFS(t *testing.T) {
+	mfs := ioFSTestData()
+	mnt, _ := testMount(t, NewIOFSRoot(ioFSLinks{mfs}), nil)
+
+	fi, err := os.Lstat(mnt + "/dir/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != 0640 || fi.Size() != 5 || fi.ModTime().Unix() != 1700000000 {
+		t.Errorf("got mode %v, size %d, mtime %v", fi.Mode(), fi.Size(), fi.ModTime())
+	}
+	if fi, err := os.Lstat(mnt + "/dir"); err != nil {
+		t.Fatal(err)
+	} else if !fi.IsDir() {
+		t.Errorf("dir: got mode %v", fi.Mode())
+	}
+
+	es, err := os.ReadDir(mnt + "/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range es {
+		names = append(names, e.Name())
+	}
+	if want := []string{"exec", "file.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ReadDir: got %v, want %v", names, want)
+	}
+
+	if got, err := os.Readlink(mnt + "/link"); err != nil || got != "dir/file.txt" {
+		t.Errorf("Readlink: got %q, %v", got, err)
+	}
+	if got, err := os.ReadFile(mnt + "/link"); err != nil || string(got) != "hello" {
+		t.Errorf("ReadFile through link: got %q, %v", got, err)
+	}
+	if got, err := os.ReadFile(mnt + "/big"); err != nil || !bytes.Equal(got, mfs["big"].Data) {
+		t.Errorf("ReadFile(big): %v", err)
+	}
+
+	if _, err := os.OpenFile(mnt+"/dir/exec", os.O_WRONLY, 0); err == nil {
+		t.Errorf("opened read-only file for writing")
+	}
+	if _, err := os.Stat(mnt + "/missing"); !os.IsNotExist(err) {
+		t.Errorf("Stat(missing): got %v", err)
+	}
+}
+
+func TestIOFSSequential(t *testing.T) {
+	mfs := ioFSTestData()
+	mnt, _ := testMount(t, NewIOFSRoot(ioFSSequential{mfs}), nil)
+
+	f, err := os.Open(mnt + "/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	want := mfs["big"].Data
+	buf := make([]byte, 1000)
+	for _, off := range []int64{250000, 1000, 0, 299500} {
+		n, err := f.ReadAt(buf, off)
+		if n == 0 && err != nil {
+			t.Fatalf("ReadAt(%d): %v", off, err)
+		}
+		if !bytes.Equal(buf[:n], want[off:off+int64(n)]) {
+			t.Errorf("ReadAt(%d): wrong data", off)
+		}
+	}
+}
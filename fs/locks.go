@@ -0,0 +1,287 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// LockTable keeps track of the POSIX record locks and flock(2) locks
+// of a single file. It implements FileGetlker, FileSetlker,
+// FileSetlkwer and FileUnlocker, so a file handle gets locking by
+// embedding the LockTable of its inode:
+//
+//	type myFile struct {
+//		*fs.LockTable
+//		...
+//	}
+//
+// The kernel only forwards lock requests if
+// fuse.MountOptions.EnableLocks is set.
+//
+// POSIX locks belong to a lock owner, and cover byte ranges. Locking
+// a range splits or merges the locks the owner already holds. flock
+// locks cover the whole file, and are independent of POSIX locks.
+//
+// Setlkw waits until the lock can be taken, or the request is
+// interrupted, in which case it returns EINTR. If waiting for a
+// POSIX lock would deadlock, it returns EDEADLK instead. Deadlocks
+// are detected between the owners waiting on the same LockTable.
+//
+// The zero value is an empty LockTable.
+type LockTable struct {
+	mu    sync.Mutex
+	posix []fileLock
+	flock []fileLock
+
+	// waiters holds the blocked POSIX lock requests by owner.
+	waiters map[uint64][]*fileLock
+
+	// wake is closed when locks are released.
+	wake chan struct{}
+}
+
+var _ = (FileGetlker)((*LockTable)(nil))
+var _ = (FileSetlker)((*LockTable)(nil))
+var _ = (FileSetlkwer)((*LockTable)(nil))
+var _ = (FileUnlocker)((*LockTable)(nil))
+
+// fileLock is a lock held by, or requested by an owner. The range
+// includes End.
+type fileLock struct {
+	owner uint64
+	fuse.FileLock
+}
+
+func (l *fileLock) overlaps(o *fileLock) bool {
+	return l.Start <= o.End && o.Start <= l.End
+}
+
+// touches returns true if o starts right after l.
+func (l *fileLock) touches(o *fileLock) bool {
+	return l.End != math.MaxUint64 && l.End+1 == o.Start
+}
+
+// conflicts returns true if l and o cannot both be held.
+func (l *fileLock) conflicts(o *fileLock) bool {
+	return l.owner != o.owner && l.overlaps(o) &&
+		(l.Typ == syscall.F_WRLCK || o.Typ == syscall.F_WRLCK)
+}
+
+func newFileLock(owner uint64, lk *fuse.FileLock, flags uint32) (fileLock, syscall.Errno) {
+	l := fileLock{owner: owner, FileLock: *lk}
+	switch l.Typ {
+	case syscall.F_RDLCK, syscall.F_WRLCK, syscall.F_UNLCK:
+	default:
+		return l, syscall.EINVAL
+	}
+	if flags&fuse.FUSE_LK_FLOCK != 0 {
+		l.Start, l.End = 0, math.MaxUint64
+	} else if l.Start > l.End {
+		return l, syscall.EINVAL
+	}
+	return l, OK
+}
+
+func (t *LockTable) list(flags uint32) *[]fileLock {
+	if flags&fuse.FUSE_LK_FLOCK != 0 {
+		return &t.flock
+	}
+	return &t.posix
+}
+
+// conflictLocked returns the first lock that conflicts with lk.
+func (t *LockTable) conflictLocked(lk *fileLock, flags uint32) *fileLock {
+	locks := *t.list(flags)
+	for i := range locks {
+		if locks[i].conflicts(lk) {
+			return &locks[i]
+		}
+	}
+	return nil
+}
+
+func (t *LockTable) Getlk(ctx context.Context, owner uint64, lk *fuse.FileLock, flags uint32, out *fuse.FileLock) syscall.Errno {
+	l, errno := newFileLock(owner, lk, flags)
+	if errno != 0 {
+		return errno
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c := t.conflictLocked(&l, flags); c != nil {
+		*out = c.FileLock
+	} else {
+		*out = *lk
+		out.Typ = syscall.F_UNLCK
+	}
+	return OK
+}
+
+func (t *LockTable) Setlk(ctx context.Context, owner uint64, lk *fuse.FileLock, flags uint32) syscall.Errno {
+	l, errno := newFileLock(owner, lk, flags)
+	if errno != 0 {
+		return errno
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if l.Typ != syscall.F_UNLCK && t.conflictLocked(&l, flags) != nil {
+		return syscall.EAGAIN
+	}
+	t.setLocked(&l, flags)
+	return OK
+}
+
+func (t *LockTable) Setlkw(ctx context.Context, owner uint64, lk *fuse.FileLock, flags uint32) syscall.Errno {
+	l, errno := newFileLock(owner, lk, flags)
+	if errno != 0 {
+		return errno
+	}
+	posix := flags&fuse.FUSE_LK_FLOCK == 0
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for l.Typ != syscall.F_UNLCK && t.conflictLocked(&l, flags) != nil {
+		if posix && t.deadlockLocked(&l) {
+			return syscall.EDEADLK
+		}
+		if t.wake == nil {
+			t.wake = make(chan struct{})
+		}
+		wake := t.wake
+		var w *fileLock
+		if posix {
+			if t.waiters == nil {
+				t.waiters = map[uint64][]*fileLock{}
+			}
+			w = &l
+			t.waiters[owner] = append(t.waiters[owner], w)
+		}
+
+		t.mu.Unlock()
+		select {
+		case <-wake:
+		case <-ctx.Done():
+		}
+		t.mu.Lock()
+
+		if w != nil {
+			ws := slices.DeleteFunc(t.waiters[owner], func(x *fileLock) bool { return x == w })
+			if len(ws) == 0 {
+				delete(t.waiters, owner)
+			} else {
+				t.waiters[owner] = ws
+			}
+		}
+		if ctx.Err() != nil {
+			return syscall.EINTR
+		}
+	}
+	t.setLocked(&l, flags)
+	return OK
+}
+
+// deadlockLocked returns true if the owners holding locks that
+// conflict with lk are, directly or indirectly, waiting in t for the
+// owner of lk.
+func (t *LockTable) deadlockLocked(lk *fileLock) bool {
+	seen := map[uint64]bool{}
+	var waitsForOwner func(*fileLock) bool
+	waitsForOwner = func(want *fileLock) bool {
+		for i := range t.posix {
+			h := &t.posix[i]
+			if !h.conflicts(want) {
+				continue
+			}
+			if h.owner == lk.owner {
+				return true
+			}
+			if seen[h.owner] {
+				continue
+			}
+			seen[h.owner] = true
+			for _, w := range t.waiters[h.owner] {
+				if waitsForOwner(w) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return waitsForOwner(lk)
+}
+
+// setLocked takes or releases the lock. For POSIX locks, the
+// owner's locks in the range are replaced, and adjacent locks of the
+// same type are merged.
+func (t *LockTable) setLocked(lk *fileLock, flags uint32) {
+	locks := t.list(flags)
+	merged := *lk
+	released := false
+	var result []fileLock
+	for _, l := range *locks {
+		if l.owner != lk.owner || !(l.overlaps(lk) || l.touches(lk) || lk.touches(&l)) {
+			result = append(result, l)
+			continue
+		}
+		if l.Typ == lk.Typ {
+			merged.Start = min(merged.Start, l.Start)
+			merged.End = max(merged.End, l.End)
+			continue
+		}
+		if !l.overlaps(lk) {
+			result = append(result, l)
+			continue
+		}
+		if l.Typ == syscall.F_WRLCK || lk.Typ == syscall.F_UNLCK {
+			released = true
+		}
+		if l.Start < lk.Start {
+			before := l
+			before.End = lk.Start - 1
+			result = append(result, before)
+		}
+		if l.End > lk.End {
+			after := l
+			after.Start = lk.End + 1
+			result = append(result, after)
+		}
+	}
+	if lk.Typ != syscall.F_UNLCK {
+		result = append(result, merged)
+	}
+	slices.SortFunc(result, func(a, b fileLock) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+	*locks = result
+	if released {
+		t.wakeLocked()
+	}
+}
+
+func (t *LockTable) wakeLocked() {
+	if t.wake != nil {
+		close(t.wake)
+		t.wake = nil
+	}
+}
+
+// Unlock releases all POSIX locks of the owner, or its flock lock if
+// flags has fuse.FUSE_LK_FLOCK set.
+func (t *LockTable) Unlock(ctx context.Context, owner uint64, flags uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	locks := t.list(flags)
+	n := len(*locks)
+	*locks = slices.DeleteFunc(*locks, func(l fileLock) bool { return l.owner == owner })
+	if len(*locks) != n {
+		t.wakeLocked()
+	}
+}
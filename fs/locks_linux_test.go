@@ -0,0 +1,110 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"golang.org/x/sys/unix"
+)
+
+type lockingFile struct {
+	MemRegularFile
+	locks LockTable
+}
+
+type lockingHandle struct {
+	*LockTable
+}
+
+func (f *lockingFile) Open(ctx context.Context, flags uint32) (FileHandle, uint32, syscall.Errno) {
+	return &lockingHandle{&f.locks}, 0, OK
+}
+
+func TestLockTableMount(t *testing.T) {
+	root := &Inode{}
+	mnt, _ := testMount(t, root, &Options{
+		MountOptions: fuse.MountOptions{EnableLocks: true},
+		OnAdd: func(ctx context.Context) {
+			ch := root.NewPersistentInode(ctx, &lockingFile{}, StableAttr{})
+			root.AddChild("file", ch, false)
+		},
+	})
+
+	f1, err := os.OpenFile(mnt+"/file", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f1.Close()
+	f2, err := os.OpenFile(mnt+"/file", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f2.Close()
+
+	wlk := unix.Flock_t{Type: unix.F_WRLCK, Start: 0, Len: 100}
+	if err := unix.FcntlFlock(f1.Fd(), unix.F_SETLK, &wlk); err != nil {
+		t.Fatalf("F_SETLK: %v", err)
+	}
+
+	// OFD locks belong to the open file, so f2 conflicts with the
+	// lock that the process holds through f1.
+	lk := unix.Flock_t{Type: unix.F_RDLCK}
+	if err := unix.FcntlFlock(f2.Fd(), unix.F_OFD_GETLK, &lk); err != nil {
+		t.Fatalf("F_OFD_GETLK: %v", err)
+	}
+	if lk.Type != unix.F_WRLCK || lk.Start != 0 || lk.Len != 100 {
+		t.Errorf("F_OFD_GETLK: got %+v", lk)
+	}
+	rlk := unix.Flock_t{Type: unix.F_RDLCK, Start: 50, Len: 10}
+	if err := unix.FcntlFlock(f2.Fd(), unix.F_OFD_SETLK, &rlk); err != unix.EAGAIN {
+		t.Errorf("F_OFD_SETLK: got %v, want EAGAIN", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- unix.FcntlFlock(f2.Fd(), unix.F_OFD_SETLKW, &rlk)
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("F_OFD_SETLKW returned %v while the lock was held", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Closing any descriptor drops the POSIX locks of the process.
+	f1.Close()
+	if err := <-done; err != nil {
+		t.Errorf("F_OFD_SETLKW: %v", err)
+	}
+
+	f3, err := os.Open(mnt + "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f3.Close()
+	if err := unix.Flock(int(f2.Fd()), unix.LOCK_EX); err != nil {
+		t.Fatalf("flock: %v", err)
+	}
+	if err := unix.Flock(int(f3.Fd()), unix.LOCK_SH|unix.LOCK_NB); err != unix.EWOULDBLOCK {
+		t.Errorf("flock: got %v, want EWOULDBLOCK", err)
+	}
+	f2.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := unix.Flock(int(f3.Fd()), unix.LOCK_SH|unix.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("flock not released on close: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
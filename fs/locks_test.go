@@ -0,0 +1,205 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func lockRange(typ uint32, start, end uint64) *fuse.FileLock {
+	return &fuse.FileLock{Typ: typ, Start: start, End: end}
+}
+
+func TestLockTableSplitMerge(t *testing.T) {
+	var tab LockTable
+	ctx := context.Background()
+	for _, lk := range []*fuse.FileLock{
+		lockRange(syscall.F_RDLCK, 0, 9),
+		lockRange(syscall.F_RDLCK, 10, 19),
+		lockRange(syscall.F_WRLCK, 5, 14),
+		lockRange(syscall.F_UNLCK, 7, 8),
+	} {
+		if errno := tab.Setlk(ctx, 1, lk, 0); errno != 0 {
+			t.Fatalf("Setlk(%v): %v", lk, errno)
+		}
+	}
+
+	var got []fuse.FileLock
+	for _, l := range tab.posix {
+		got = append(got, l.FileLock)
+	}
+	want := []fuse.FileLock{
+		*lockRange(syscall.F_RDLCK, 0, 4),
+		*lockRange(syscall.F_WRLCK, 5, 6),
+		*lockRange(syscall.F_WRLCK, 9, 14),
+		*lockRange(syscall.F_RDLCK, 15, 19),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	var out fuse.FileLock
+	if errno := tab.Getlk(ctx, 2, lockRange(syscall.F_RDLCK, 6, 100), 0, &out); errno != 0 {
+		t.Fatal(errno)
+	}
+	if out != *lockRange(syscall.F_WRLCK, 5, 6) {
+		t.Errorf("Getlk: got %v", out)
+	}
+	if errno := tab.Getlk(ctx, 2, lockRange(syscall.F_RDLCK, 7, 8), 0, &out); errno != 0 || out.Typ != syscall.F_UNLCK {
+		t.Errorf("Getlk on unlocked range: got %v, %v", out, errno)
+	}
+	if errno := tab.Setlk(ctx, 2, lockRange(syscall.F_RDLCK, 15, 30), 0); errno != 0 {
+		t.Errorf("shared lock: %v", errno)
+	}
+	if errno := tab.Setlk(ctx, 2, lockRange(syscall.F_WRLCK, 0, 0), 0); errno != syscall.EAGAIN {
+		t.Errorf("conflicting lock: got %v, want EAGAIN", errno)
+	}
+
+	tab.Unlock(ctx, 1, 0)
+	if errno := tab.Setlk(ctx, 2, lockRange(syscall.F_WRLCK, 0, math.MaxInt64), 0); errno != 0 {
+		t.Errorf("after Unlock: %v", errno)
+	}
+}
+
+func TestLockTableFlock(t *testing.T) {
+	var tab LockTable
+	ctx := context.Background()
+	if errno := tab.Setlk(ctx, 1, lockRange(syscall.F_RDLCK, 0, 0), fuse.FUSE_LK_FLOCK); errno != 0 {
+		t.Fatal(errno)
+	}
+	if errno := tab.Setlk(ctx, 2, lockRange(syscall.F_RDLCK, 0, 0), fuse.FUSE_LK_FLOCK); errno != 0 {
+		t.Fatal(errno)
+	}
+	if errno := tab.Setlk(ctx, 2, lockRange(syscall.F_WRLCK, 0, 0), fuse.FUSE_LK_FLOCK); errno != syscall.EAGAIN {
+		t.Errorf("upgrade with other reader: got %v, want EAGAIN", errno)
+	}
+
+	// flock and POSIX locks don't interact.
+	if errno := tab.Setlk(ctx, 3, lockRange(syscall.F_WRLCK, 0, 100), 0); errno != 0 {
+		t.Errorf("POSIX lock: %v", errno)
+	}
+
+	tab.Unlock(ctx, 1, fuse.FUSE_LK_FLOCK)
+	if errno := tab.Setlk(ctx, 2, lockRange(syscall.F_WRLCK, 0, 0), fuse.FUSE_LK_FLOCK); errno != 0 {
+		t.Errorf("upgrade: %v", errno)
+	}
+}
+
+func TestLockTableSetlkw(t *testing.T) {
+	var tab LockTable
+	ctx := context.Background()
+	if errno := tab.Setlk(ctx, 1, lockRange(syscall.F_WRLCK, 0, 9), 0); errno != 0 {
+		t.Fatal(errno)
+	}
+
+	done := make(chan syscall.Errno, 1)
+	go func() {
+		done <- tab.Setlkw(ctx, 2, lockRange(syscall.F_WRLCK, 5, 5), 0)
+	}()
+	select {
+	case errno := <-done:
+		t.Fatalf("Setlkw returned %v while the lock was held", errno)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if errno := tab.Setlk(ctx, 1, lockRange(syscall.F_UNLCK, 5, 5), 0); errno != 0 {
+		t.Fatal(errno)
+	}
+	if errno := <-done; errno != 0 {
+		t.Errorf("Setlkw: %v", errno)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	go func() {
+		done <- tab.Setlkw(cctx, 3, lockRange(syscall.F_RDLCK, 0, 0), 0)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if errno := <-done; errno != syscall.EINTR {
+		t.Errorf("canceled Setlkw: got %v, want EINTR", errno)
+	}
+}
+
+// waitLockWaiter waits until owner is blocked in Setlkw on t.
+func waitLockWaiter(t *LockTable, owner uint64) {
+	for {
+		t.mu.Lock()
+		n := len(t.waiters[owner])
+		t.mu.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLockTableDeadlock(t *testing.T) {
+	var tab LockTable
+	ctx := context.Background()
+	if errno := tab.Setlk(ctx, 1, lockRange(syscall.F_WRLCK, 0, 0), 0); errno != 0 {
+		t.Fatal(errno)
+	}
+	if errno := tab.Setlk(ctx, 2, lockRange(syscall.F_WRLCK, 1, 1), 0); errno != 0 {
+		t.Fatal(errno)
+	}
+
+	done := make(chan syscall.Errno, 1)
+	go func() {
+		done <- tab.Setlkw(ctx, 1, lockRange(syscall.F_WRLCK, 1, 1), 0)
+	}()
+	waitLockWaiter(&tab, 1)
+
+	if errno := tab.Setlkw(ctx, 2, lockRange(syscall.F_WRLCK, 0, 0), 0); errno != syscall.EDEADLK {
+		t.Errorf("got %v, want EDEADLK", errno)
+	}
+	tab.Unlock(ctx, 2, 0)
+	if errno := <-done; errno != 0 {
+		t.Errorf("Setlkw: %v", errno)
+	}
+}
+
+// Owners of different tables, e.g. of different mounts, are
+// unrelated, even if their IDs are equal.
+func TestLockTableDeadlockSeparateTables(t *testing.T) {
+	var a, b LockTable
+	ctx := context.Background()
+	if errno := a.Setlk(ctx, 1, lockRange(syscall.F_WRLCK, 0, 0), 0); errno != 0 {
+		t.Fatal(errno)
+	}
+	if errno := b.Setlk(ctx, 2, lockRange(syscall.F_WRLCK, 0, 0), 0); errno != 0 {
+		t.Fatal(errno)
+	}
+
+	doneA := make(chan syscall.Errno, 1)
+	go func() {
+		doneA <- a.Setlkw(ctx, 2, lockRange(syscall.F_WRLCK, 0, 0), 0)
+	}()
+	waitLockWaiter(&a, 2)
+
+	doneB := make(chan syscall.Errno, 1)
+	go func() {
+		doneB <- b.Setlkw(ctx, 1, lockRange(syscall.F_WRLCK, 0, 0), 0)
+	}()
+	select {
+	case errno := <-doneB:
+		t.Fatalf("Setlkw returned %v while the lock was held", errno)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	a.Unlock(ctx, 1, 0)
+	b.Unlock(ctx, 2, 0)
+	if errno := <-doneA; errno != 0 {
+		t.Errorf("Setlkw a: %v", errno)
+	}
+	if errno := <-doneB; errno != 0 {
+		t.Errorf("Setlkw b: %v", errno)
+	}
+}
@@ -6,8 +6,11 @@ package fs
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/hanwen/go-fuse/v2/fuse"
@@ -38,9 +41,50 @@ type LoopbackRoot struct {
 	// the Loopback file system is not the root of the FUSE
 	// mount. It is set automatically by NewLoopbackRoot.
 	RootNode InodeEmbedder
+
+	// KeepFds, if set, makes each node other than the root keep an
+	// O_PATH file descriptor for its file, and perform all
+	// operations relative to it. Nodes then keep working if their
+	// files are renamed or moved in the underlying file system,
+	// and symlinks swapped in for directories are never followed.
+	// The descriptors are closed when the kernel forgets the
+	// nodes. This is only supported on Linux.
+	KeepFds bool
+
+	// Impersonate, if set, performs each node operation with the
+	// file system user and group IDs and the supplementary groups
+	// of the caller. The underlying file system then checks
+	// permissions as it would for the caller, and new files are
+	// owned by the caller from the start. The IDs are mapped by
+	// Ownership. This needs the CAP_SETUID and CAP_SETGID
+	// capabilities, and is only supported on Linux. For mounts
+	// used by several users, also set fuse.MountOptions.AllowOther.
+	Impersonate bool
+
+	// Ownership, if set, changes the ownership and permissions
+	// presented by the file system.
+	Ownership *LoopbackOwnership
+
+	// handles maps inode numbers to file handles of the
+	// underlying files, so nodes can be found again for NFS
+	// clients. It is only filled if
+	// fuse.MountOptions.EnableExportSupport is set. Entries are
+	// dropped when their files are removed.
+	handlesMu sync.Mutex
+	handles   map[uint64]backingHandle
+
+	// watcher is set while Watch is active.
+	watcher atomic.Pointer[LoopbackWatcher]
 }
 
 func (r *LoopbackRoot) newNode(parent *Inode, name string, st *syscall.Stat_t) InodeEmbedder {
+	if parent != nil && parent.bridge != nil && parent.bridge.options.EnableExportSupport {
+		dir := filepath.Join(r.Path, parent.Path(r.rootInode(parent)))
+		if lb, ok := parent.Operations().(loopbackNodeEmbedder); ok {
+			dir = lb.loopbackNode().path()
+		}
+		r.rememberHandle(filepath.Join(dir, name), st)
+	}
 	if r.NewNode != nil {
 		return r.NewNode(r, parent, name, st)
 	}
@@ -77,6 +121,10 @@ type LoopbackNode struct {
 
 	// RootData points back to the root of the loopback filesystem.
 	RootData *LoopbackRoot
+
+	// fd is the O_PATH file descriptor of the node if
+	// LoopbackRoot.KeepFds is set.
+	fd *os.File
 }
 
 // loopbackNodeEmbedder can only be implemented by the LoopbackNode
@@ -92,6 +140,11 @@ func (n *LoopbackNode) loopbackNode() *LoopbackNode {
 var _ = (NodeStatfser)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Statfs(ctx context.Context, out *fuse.StatfsOut) syscall.Errno {
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return errno
+	}
+	defer restore()
 	s := syscall.Statfs_t{}
 	err := syscall.Statfs(n.path(), &s)
 	if err != nil {
@@ -104,14 +157,15 @@ func (n *LoopbackNode) Statfs(ctx context.Context, out *fuse.StatfsOut) syscall.
 // path returns the full path to the file in the underlying file
 // system.
 func (n *LoopbackNode) root() *Inode {
-	var rootNode *Inode
-	if n.RootData.RootNode != nil {
-		rootNode = n.RootData.RootNode.EmbeddedInode()
-	} else {
-		rootNode = n.Root()
-	}
+	return n.RootData.rootInode(&n.Inode)
+}
 
-	return rootNode
+// rootInode returns the root of the loopback tree containing n.
+func (r *LoopbackRoot) rootInode(n *Inode) *Inode {
+	if r.RootNode != nil {
+		return r.RootNode.EmbeddedInode()
+	}
+	return n.Root()
 }
 
 // relativePath returns the path the node, relative to to the root directory
@@ -119,60 +173,194 @@ func (n *LoopbackNode) relativePath() string {
 	return n.Path(n.root())
 }
 
-// path returns the absolute path to the node
+// path returns the absolute path to the node. For nodes with a file
+// descriptor, this is its /proc/self/fd/ path, which refers to the
+// file itself, even if it is a symlink.
 func (n *LoopbackNode) path() string {
+	if n.fd != nil {
+		return fmt.Sprintf("/proc/self/fd/%d", n.fd.Fd())
+	}
 	return filepath.Join(n.RootData.Path, n.relativePath())
 }
 
+// noFollow returns the flag to pass to *at calls on path() that should
+// not follow symlinks. The /proc/self/fd/ path of a node is a link to
+// the node itself that must be followed.
+func (n *LoopbackNode) noFollow() int {
+	if n.fd != nil {
+		return 0
+	}
+	return unix.AT_SYMLINK_NOFOLLOW
+}
+
+// lstat returns the attributes of the node, without following
+// symlinks.
+func (n *LoopbackNode) lstat(st *syscall.Stat_t) error {
+	if n.fd != nil {
+		return syscall.Fstat(int(n.fd.Fd()), st)
+	}
+	return syscall.Lstat(n.path(), st)
+}
+
+// lstatChild returns the attributes of the file at p, which is a
+// child of n. If the root keeps file descriptors, it opens one for
+// the file, so the attributes and the descriptor are for the same
+// file.
+func (n *LoopbackNode) lstatChild(p string, st *syscall.Stat_t) (*os.File, error) {
+	if n.RootData.KeepFds {
+		f, err := openNodeFd(p, false)
+		if err != nil {
+			return nil, err
+		}
+		if f != nil {
+			if err := syscall.Fstat(int(f.Fd()), st); err != nil {
+				f.Close()
+				return nil, err
+			}
+			return f, nil
+		}
+	}
+	return nil, syscall.Lstat(p, st)
+}
+
+// newChild creates the node for the child name with attributes st and
+// file descriptor f.
+func (n *LoopbackNode) newChild(ctx context.Context, name string, st *syscall.Stat_t, f *os.File) *Inode {
+	node := n.RootData.newNode(n.EmbeddedInode(), name, st)
+	if lb, ok := node.(loopbackNodeEmbedder); ok && f != nil {
+		lb.loopbackNode().fd = f
+	} else if f != nil {
+		f.Close()
+	}
+	return n.NewInode(ctx, node, n.RootData.idFromStat(st))
+}
+
+var _ = (nodeDiscarder)((*LoopbackNode)(nil))
+
+// discard closes the file descriptor of a node that the bridge
+// dropped in favor of an existing node for the same file.
+func (n *LoopbackNode) discard() {
+	if n.fd != nil {
+		n.fd.Close()
+		n.fd = nil
+	}
+}
+
+// watch makes an active LoopbackWatcher watch the directory n, before
+// the kernel caches its entries.
+func (n *LoopbackNode) watch() {
+	if w := n.RootData.watcher.Load(); w != nil {
+		w.add(n)
+	}
+}
+
+var _ = (NodeOnForgetter)((*LoopbackNode)(nil))
+
+// OnForget closes the file descriptor of the node, if it has one,
+// stops watching it, and drops its file handle if the file was
+// deleted. The root is only forgotten when the file system is
+// unmounted; the nodes that the kernel still knows are not forgotten
+// then, so their descriptors are closed too.
+func (n *LoopbackNode) OnForget() {
+	if &n.Inode == n.root() {
+		n.closeTree()
+		return
+	}
+	if w := n.RootData.watcher.Load(); w != nil {
+		w.remove(&n.Inode)
+	}
+	if n.fd != nil {
+		n.fd.Close()
+	}
+	n.RootData.dropStaleHandle(n.StableAttr().Ino)
+}
+
+// closeTree stops an active LoopbackWatcher, and closes the file
+// descriptors of all nodes below n.
+func (n *LoopbackNode) closeTree() {
+	if w := n.RootData.watcher.Load(); w != nil {
+		w.Close()
+	}
+	seen := map[*Inode]bool{}
+	todo := []*Inode{&n.Inode}
+	for len(todo) > 0 {
+		ch := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if seen[ch] {
+			continue
+		}
+		seen[ch] = true
+		if lb, ok := ch.Operations().(loopbackNodeEmbedder); ok && lb.loopbackNode().fd != nil {
+			lb.loopbackNode().fd.Close()
+		}
+		for _, c := range ch.Children() {
+			todo = append(todo, c)
+		}
+	}
+}
+
 var _ = (NodeLookuper)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	n.watch()
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return nil, errno
+	}
+	defer restore()
 	p := filepath.Join(n.path(), name)
 
 	st := syscall.Stat_t{}
-	err := syscall.Lstat(p, &st)
+	f, err := n.lstatChild(p, &st)
 	if err != nil {
 		return nil, ToErrno(err)
 	}
 
 	out.Attr.FromStat(&st)
-	node := n.RootData.newNode(n.EmbeddedInode(), name, &st)
-	ch := n.NewInode(ctx, node, n.RootData.idFromStat(&st))
+	n.RootData.Ownership.attr(&out.Attr)
+	ch := n.newChild(ctx, name, &st, f)
 	return ch, 0
 }
 
 // preserveOwner sets uid and gid of `path` according to the caller information
 // in `ctx`.
 func (n *LoopbackNode) preserveOwner(ctx context.Context, path string) error {
-	if os.Getuid() != 0 {
+	if os.Getuid() != 0 || n.RootData.Impersonate {
 		return nil
 	}
 	caller, ok := fuse.FromContext(ctx)
 	if !ok {
 		return nil
 	}
-	return syscall.Lchown(path, int(caller.Uid), int(caller.Gid))
+	o := n.RootData.Ownership
+	return syscall.Lchown(path, int(o.backingUID(caller.Uid)), int(o.backingGID(caller.Gid)))
 }
 
 var _ = (NodeMknoder)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Mknod(ctx context.Context, name string, mode, rdev uint32, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return nil, errno
+	}
+	defer restore()
 	p := filepath.Join(n.path(), name)
-	err := syscall.Mknod(p, mode, intDev(rdev))
+	err := syscall.Mknod(p, n.RootData.Ownership.setMode(mode), intDev(rdev))
 	if err != nil {
 		return nil, ToErrno(err)
 	}
 	n.preserveOwner(ctx, p)
 	st := syscall.Stat_t{}
-	if err := syscall.Lstat(p, &st); err != nil {
+	f, err := n.lstatChild(p, &st)
+	if err != nil {
 		syscall.Rmdir(p)
 		return nil, ToErrno(err)
 	}
 
 	out.Attr.FromStat(&st)
+	n.RootData.Ownership.attr(&out.Attr)
 
-	node := n.RootData.newNode(n.EmbeddedInode(), name, &st)
-	ch := n.NewInode(ctx, node, n.RootData.idFromStat(&st))
+	ch := n.newChild(ctx, name, &st, f)
 
 	return ch, 0
 }
@@ -180,22 +368,28 @@ func (n *LoopbackNode) Mknod(ctx context.Context, name string, mode, rdev uint32
 var _ = (NodeMkdirer)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return nil, errno
+	}
+	defer restore()
 	p := filepath.Join(n.path(), name)
-	err := os.Mkdir(p, os.FileMode(mode))
+	err := os.Mkdir(p, os.FileMode(n.RootData.Ownership.setMode(mode)))
 	if err != nil {
 		return nil, ToErrno(err)
 	}
 	n.preserveOwner(ctx, p)
 	st := syscall.Stat_t{}
-	if err := syscall.Lstat(p, &st); err != nil {
+	f, err := n.lstatChild(p, &st)
+	if err != nil {
 		syscall.Rmdir(p)
 		return nil, ToErrno(err)
 	}
 
 	out.Attr.FromStat(&st)
+	n.RootData.Ownership.attr(&out.Attr)
 
-	node := n.RootData.newNode(n.EmbeddedInode(), name, &st)
-	ch := n.NewInode(ctx, node, n.RootData.idFromStat(&st))
+	ch := n.newChild(ctx, name, &st, f)
 
 	return ch, 0
 }
@@ -203,22 +397,45 @@ func (n *LoopbackNode) Mkdir(ctx context.Context, name string, mode uint32, out
 var _ = (NodeRmdirer)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Rmdir(ctx context.Context, name string) syscall.Errno {
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return errno
+	}
+	defer restore()
 	p := filepath.Join(n.path(), name)
+	st := n.RootData.statHandle(p)
 	err := syscall.Rmdir(p)
+	if err == nil {
+		n.RootData.dropHandle(st)
+	}
 	return ToErrno(err)
 }
 
 var _ = (NodeUnlinker)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Unlink(ctx context.Context, name string) syscall.Errno {
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return errno
+	}
+	defer restore()
 	p := filepath.Join(n.path(), name)
+	st := n.RootData.statHandle(p)
 	err := syscall.Unlink(p)
+	if err == nil {
+		n.RootData.dropHandle(st)
+	}
 	return ToErrno(err)
 }
 
 var _ = (NodeRenamer)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Rename(ctx context.Context, name string, newParent InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return errno
+	}
+	defer restore()
 	e2, ok := newParent.(loopbackNodeEmbedder)
 	if !ok {
 		return syscall.EXDEV
@@ -235,16 +452,25 @@ func (n *LoopbackNode) Rename(ctx context.Context, name string, newParent InodeE
 	p1 := filepath.Join(n.path(), name)
 	p2 := filepath.Join(e2.loopbackNode().path(), newName)
 
+	st := n.RootData.statHandle(p2)
 	err := syscall.Rename(p1, p2)
+	if err == nil {
+		n.RootData.dropHandle(st)
+	}
 	return ToErrno(err)
 }
 
 var _ = (NodeCreater)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (inode *Inode, fh FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return nil, nil, 0, errno
+	}
+	defer restore()
 	p := filepath.Join(n.path(), name)
 	flags = flags &^ syscall.O_APPEND
-	fd, err := syscall.Open(p, int(flags)|os.O_CREATE, mode)
+	fd, err := syscall.Open(p, int(flags)|os.O_CREATE, n.RootData.Ownership.setMode(mode))
 	if err != nil {
 		return nil, nil, 0, ToErrno(err)
 	}
@@ -255,11 +481,19 @@ func (n *LoopbackNode) Create(ctx context.Context, name string, flags uint32, mo
 		return nil, nil, 0, ToErrno(err)
 	}
 
-	node := n.RootData.newNode(n.EmbeddedInode(), name, &st)
-	ch := n.NewInode(ctx, node, n.RootData.idFromStat(&st))
+	var nodeFd *os.File
+	if n.RootData.KeepFds {
+		nodeFd, err = openNodeFd(fmt.Sprintf("/proc/self/fd/%d", fd), true)
+		if err != nil {
+			syscall.Close(fd)
+			return nil, nil, 0, ToErrno(err)
+		}
+	}
+	ch := n.newChild(ctx, name, &st, nodeFd)
 	lf := NewLoopbackFile(fd)
 
 	out.FromStat(&st)
+	n.RootData.Ownership.attr(&out.Attr)
 	return ch, lf, 0, 0
 }
 
@@ -299,6 +533,11 @@ func (n *LoopbackNode) rename2(name string, newParent *LoopbackNode, newName str
 var _ = (NodeSymlinker)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Symlink(ctx context.Context, target, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return nil, errno
+	}
+	defer restore()
 	p := filepath.Join(n.path(), name)
 	err := syscall.Symlink(target, p)
 	if err != nil {
@@ -306,46 +545,69 @@ func (n *LoopbackNode) Symlink(ctx context.Context, target, name string, out *fu
 	}
 	n.preserveOwner(ctx, p)
 	st := syscall.Stat_t{}
-	if err := syscall.Lstat(p, &st); err != nil {
+	f, err := n.lstatChild(p, &st)
+	if err != nil {
 		syscall.Unlink(p)
 		return nil, ToErrno(err)
 	}
-	node := n.RootData.newNode(n.EmbeddedInode(), name, &st)
-	ch := n.NewInode(ctx, node, n.RootData.idFromStat(&st))
+	ch := n.newChild(ctx, name, &st, f)
 
 	out.Attr.FromStat(&st)
+	n.RootData.Ownership.attr(&out.Attr)
 	return ch, 0
 }
 
 var _ = (NodeLinker)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Link(ctx context.Context, target InodeEmbedder, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return nil, errno
+	}
+	defer restore()
 
 	p := filepath.Join(n.path(), name)
-	err := syscall.Link(filepath.Join(n.RootData.Path, target.EmbeddedInode().Path(nil)), p)
+	var err error
+	if lb, ok := target.(loopbackNodeEmbedder); ok && lb.loopbackNode().fd != nil {
+		err = unix.Linkat(unix.AT_FDCWD, lb.loopbackNode().path(), unix.AT_FDCWD, p, unix.AT_SYMLINK_FOLLOW)
+	} else {
+		err = syscall.Link(filepath.Join(n.RootData.Path, target.EmbeddedInode().Path(nil)), p)
+	}
 	if err != nil {
 		return nil, ToErrno(err)
 	}
 	st := syscall.Stat_t{}
-	if err := syscall.Lstat(p, &st); err != nil {
+	f, err := n.lstatChild(p, &st)
+	if err != nil {
 		syscall.Unlink(p)
 		return nil, ToErrno(err)
 	}
-	node := n.RootData.newNode(n.EmbeddedInode(), name, &st)
-	ch := n.NewInode(ctx, node, n.RootData.idFromStat(&st))
+	ch := n.newChild(ctx, name, &st, f)
 
 	out.Attr.FromStat(&st)
+	n.RootData.Ownership.attr(&out.Attr)
 	return ch, 0
 }
 
 var _ = (NodeReadlinker)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return nil, errno
+	}
+	defer restore()
 	p := n.path()
 
 	for l := 256; ; l *= 2 {
 		buf := make([]byte, l)
-		sz, err := syscall.Readlink(p, buf)
+		var sz int
+		var err error
+		if n.fd != nil {
+			sz, err = unix.Readlinkat(int(n.fd.Fd()), "", buf)
+		} else {
+			sz, err = syscall.Readlink(p, buf)
+		}
 		if err != nil {
 			return nil, ToErrno(err)
 		}
@@ -360,9 +622,20 @@ var _ = (NodeOpener)((*LoopbackNode)(nil))
 
 // Symlink-safe through use of OpenSymlinkAware.
 func (n *LoopbackNode) Open(ctx context.Context, flags uint32) (fh FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return nil, 0, errno
+	}
+	defer restore()
 	flags = flags &^ (syscall.O_APPEND | fuse.FMODE_EXEC)
 
-	f, err := openat.OpenSymlinkAware(n.RootData.Path, n.relativePath(), int(flags), 0)
+	var f int
+	var err error
+	if n.fd != nil {
+		f, err = syscall.Open(n.path(), int(flags)&^syscall.O_NOFOLLOW, 0)
+	} else {
+		f, err = openat.OpenSymlinkAware(n.RootData.Path, n.relativePath(), int(flags), 0)
+	}
 	if err != nil {
 		return nil, 0, ToErrno(err)
 	}
@@ -373,6 +646,12 @@ func (n *LoopbackNode) Open(ctx context.Context, flags uint32) (fh FileHandle, f
 var _ = (NodeOpendirHandler)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) OpendirHandle(ctx context.Context, flags uint32) (FileHandle, uint32, syscall.Errno) {
+	n.watch()
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return nil, 0, errno
+	}
+	defer restore()
 	ds, errno := NewLoopbackDirStream(n.path())
 	if errno != 0 {
 		return nil, 0, errno
@@ -383,38 +662,56 @@ func (n *LoopbackNode) OpendirHandle(ctx context.Context, flags uint32) (FileHan
 var _ = (NodeReaddirer)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Readdir(ctx context.Context) (DirStream, syscall.Errno) {
+	n.watch()
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return nil, errno
+	}
+	defer restore()
 	return NewLoopbackDirStream(n.path())
 }
 
 var _ = (NodeGetattrer)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Getattr(ctx context.Context, f FileHandle, out *fuse.AttrOut) syscall.Errno {
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return errno
+	}
+	defer restore()
 	if f != nil {
 		if fga, ok := f.(FileGetattrer); ok {
-			return fga.Getattr(ctx, out)
+			errno := fga.Getattr(ctx, out)
+			n.RootData.Ownership.attr(&out.Attr)
+			return errno
 		}
 	}
 
-	p := n.path()
-
 	var err error
 	st := syscall.Stat_t{}
 	if &n.Inode == n.Root() {
-		err = syscall.Stat(p, &st)
+		err = syscall.Stat(n.path(), &st)
 	} else {
-		err = syscall.Lstat(p, &st)
+		err = n.lstat(&st)
 	}
 
 	if err != nil {
 		return ToErrno(err)
 	}
 	out.FromStat(&st)
+	n.RootData.Ownership.attr(&out.Attr)
 	return OK
 }
 
 var _ = (NodeSetattrer)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Setattr(ctx context.Context, f FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return errno
+	}
+	defer restore()
+	in = n.RootData.Ownership.setattr(in)
 	p := n.path()
 	fsa, ok := f.(FileSetattrer)
 	if ok && fsa != nil {
@@ -437,7 +734,7 @@ func (n *LoopbackNode) Setattr(ctx context.Context, f FileHandle, in *fuse.SetAt
 			if gok {
 				sgid = int(gid)
 			}
-			if err := unix.Fchownat(unix.AT_FDCWD, p, suid, sgid, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			if err := unix.Fchownat(unix.AT_FDCWD, p, suid, sgid, n.noFollow()); err != nil {
 				return ToErrno(err)
 			}
 		}
@@ -462,7 +759,7 @@ func (n *LoopbackNode) Setattr(ctx context.Context, f FileHandle, in *fuse.SetAt
 				}
 			}
 			ts := []unix.Timespec{ta, tm}
-			if err := unix.UtimesNanoAt(unix.AT_FDCWD, p, ts, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			if err := unix.UtimesNanoAt(unix.AT_FDCWD, p, ts, n.noFollow()); err != nil {
 				return ToErrno(err)
 			}
 		}
@@ -479,33 +776,72 @@ func (n *LoopbackNode) Setattr(ctx context.Context, f FileHandle, in *fuse.SetAt
 		fga.Getattr(ctx, out)
 	} else {
 		st := syscall.Stat_t{}
-		err := syscall.Lstat(p, &st)
+		err := n.lstat(&st)
 		if err != nil {
 			return ToErrno(err)
 		}
 		out.FromStat(&st)
 	}
+	n.RootData.Ownership.attr(&out.Attr)
 	return OK
 }
 
 var _ = (NodeGetxattrer)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Getxattr(ctx context.Context, attr string, dest []byte) (uint32, syscall.Errno) {
-	sz, err := unix.Lgetxattr(n.path(), attr, dest)
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return 0, errno
+	}
+	defer restore()
+	var sz int
+	var err error
+	if n.fd != nil {
+		sz, err = unix.Getxattr(n.path(), attr, dest)
+	} else {
+		sz, err = unix.Lgetxattr(n.path(), attr, dest)
+	}
+	if err == nil && sz <= len(dest) && isACLXattr(attr) {
+		n.RootData.Ownership.aclToMount(dest[:sz])
+	}
 	return uint32(sz), ToErrno(err)
 }
 
 var _ = (NodeSetxattrer)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Setxattr(ctx context.Context, attr string, data []byte, flags uint32) syscall.Errno {
-	err := unix.Lsetxattr(n.path(), attr, data, int(flags))
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return errno
+	}
+	defer restore()
+	if o := n.RootData.Ownership; o != nil && isACLXattr(attr) {
+		data = append([]byte(nil), data...)
+		o.aclToBacking(data)
+	}
+	var err error
+	if n.fd != nil {
+		err = unix.Setxattr(n.path(), attr, data, int(flags))
+	} else {
+		err = unix.Lsetxattr(n.path(), attr, data, int(flags))
+	}
 	return ToErrno(err)
 }
 
 var _ = (NodeRemovexattrer)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Removexattr(ctx context.Context, attr string) syscall.Errno {
-	err := unix.Lremovexattr(n.path(), attr)
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return errno
+	}
+	defer restore()
+	var err error
+	if n.fd != nil {
+		err = unix.Removexattr(n.path(), attr)
+	} else {
+		err = unix.Lremovexattr(n.path(), attr)
+	}
 	return ToErrno(err)
 }
 
@@ -8,6 +8,8 @@
 package fs
 
 import (
+	"context"
+	"os"
 	"syscall"
 	"time"
 )
@@ -34,3 +36,44 @@ func doCopyFileRange(fdIn int, offIn int64, fdOut int, offOut int64,
 func intDev(dev uint32) int {
 	return int(dev)
 }
+
+// openNodeFd returns nil: O_PATH file descriptors are only supported
+// on Linux.
+func openNodeFd(p string, follow bool) (*os.File, error) {
+	return nil, nil
+}
+
+// backingHandle is not used: file handles are only supported on
+// Linux.
+type backingHandle struct{}
+
+func (r *LoopbackRoot) rememberHandle(path string, st *syscall.Stat_t) {}
+
+func (r *LoopbackRoot) statHandle(path string) *syscall.Stat_t { return nil }
+
+func (r *LoopbackRoot) dropHandle(st *syscall.Stat_t) {}
+
+func (r *LoopbackRoot) dropStaleHandle(ino uint64) {}
+
+// LoopbackWatcher is not supported: it uses inotify(7), which is
+// Linux only.
+type LoopbackWatcher struct{}
+
+// Watch returns ENOTSUP.
+func (r *LoopbackRoot) Watch() (*LoopbackWatcher, error) {
+	return nil, syscall.ENOTSUP
+}
+
+// Close does nothing.
+func (w *LoopbackWatcher) Close() error {
+	return nil
+}
+
+func (w *LoopbackWatcher) add(n *LoopbackNode) {}
+
+func (w *LoopbackWatcher) remove(n *Inode) {}
+
+// asCaller does nothing: impersonation is only supported on Linux.
+func (n *LoopbackNode) asCaller(ctx context.Context) (func(), syscall.Errno) {
+	return func() {}, 0
+}
@@ -6,6 +6,7 @@ package fs
 
 import (
 	"context"
+	"os"
 	"syscall"
 
 	"github.com/hanwen/go-fuse/v2/internal/xattr"
@@ -53,6 +54,11 @@ func rebuildAttrBuf(attrList [][]byte) []byte {
 var _ = (NodeListxattrer)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Listxattr(ctx context.Context, dest []byte) (uint32, syscall.Errno) {
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return 0, errno
+	}
+	defer restore()
 	// In order to simulate same data format as Linux does,
 	// and the size of returned buf is required to match, we must
 	// call unix.Llistxattr twice.
@@ -78,3 +84,44 @@ func (n *LoopbackNode) Listxattr(ctx context.Context, dest []byte) (uint32, sysc
 	}
 	return uint32(sz), ToErrno(err)
 }
+
+// openNodeFd returns nil: O_PATH file descriptors are only supported
+// on Linux.
+func openNodeFd(p string, follow bool) (*os.File, error) {
+	return nil, nil
+}
+
+// backingHandle is not used: file handles are only supported on
+// Linux.
+type backingHandle struct{}
+
+func (r *LoopbackRoot) rememberHandle(path string, st *syscall.Stat_t) {}
+
+func (r *LoopbackRoot) statHandle(path string) *syscall.Stat_t { return nil }
+
+func (r *LoopbackRoot) dropHandle(st *syscall.Stat_t) {}
+
+func (r *LoopbackRoot) dropStaleHandle(ino uint64) {}
+
+// LoopbackWatcher is not supported: it uses inotify(7), which is
+// Linux only.
+type LoopbackWatcher struct{}
+
+// Watch returns ENOTSUP.
+func (r *LoopbackRoot) Watch() (*LoopbackWatcher, error) {
+	return nil, syscall.ENOTSUP
+}
+
+// Close does nothing.
+func (w *LoopbackWatcher) Close() error {
+	return nil
+}
+
+func (w *LoopbackWatcher) add(n *LoopbackNode) {}
+
+func (w *LoopbackWatcher) remove(n *Inode) {}
+
+// asCaller does nothing: impersonation is only supported on Linux.
+func (n *LoopbackNode) asCaller(ctx context.Context) (func(), syscall.Errno) {
+	return func() {}, 0
+}
@@ -0,0 +1,82 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"runtime"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"golang.org/x/sys/unix"
+)
+
+// asCaller makes the OS thread of the calling goroutine access files
+// with the credentials of the caller in ctx, if LoopbackRoot.Impersonate
+// is set. The returned function restores the credentials of the
+// server, and must be called before returning. If the credentials
+// cannot be switched, asCaller returns EPERM, and the operation must
+// not be run.
+func (n *LoopbackNode) asCaller(ctx context.Context) (func(), syscall.Errno) {
+	if !n.RootData.Impersonate {
+		return func() {}, 0
+	}
+	caller, ok := fuse.FromContext(ctx)
+	if !ok {
+		return func() {}, 0
+	}
+	o := n.RootData.Ownership
+	var groups []int
+	for _, g := range callerGroups(caller) {
+		groups = append(groups, int(o.backingGID(g)))
+	}
+	uid := int(o.backingUID(caller.Uid))
+	gid := int(o.backingGID(caller.Gid))
+
+	// The credentials are per thread, so the goroutine must not
+	// move to another thread until they are restored.
+	runtime.LockOSThread()
+	oldGroups, err := unix.Getgroups()
+	if err != nil {
+		runtime.UnlockOSThread()
+		return nil, syscall.EPERM
+	}
+	oldGid := -1
+	oldUid := -1
+	restore := func() {
+		// setfsuid(2) and setfsgid(2) ignore -1, so they
+		// are only undone if they were done.
+		if oldUid != -1 {
+			unix.Setfsuid(oldUid)
+		}
+		if oldGid != -1 {
+			unix.Setfsgid(oldGid)
+		}
+		if err := unix.Setgroups(oldGroups); err != nil {
+			// Keep the thread locked, so it is discarded
+			// rather than reused with the wrong groups.
+			return
+		}
+		runtime.UnlockOSThread()
+	}
+
+	if err := unix.Setgroups(groups); err != nil {
+		restore()
+		return nil, syscall.EPERM
+	}
+	// setfsgid(2) and setfsuid(2) do not report errors, so the
+	// switch is checked by querying the current value with -1.
+	oldGid, _ = unix.SetfsgidRetGid(gid)
+	if cur, _ := unix.SetfsgidRetGid(-1); cur != gid {
+		restore()
+		return nil, syscall.EPERM
+	}
+	oldUid, _ = unix.SetfsuidRetUid(uid)
+	if cur, _ := unix.SetfsuidRetUid(-1); cur != uid {
+		restore()
+		return nil, syscall.EPERM
+	}
+	return restore, 0
+}
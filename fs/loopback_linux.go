@@ -8,7 +8,12 @@
 package fs
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"syscall"
 
 	"github.com/hanwen/go-fuse/v2/fuse"
@@ -32,19 +37,203 @@ var _ = (NodeStatxer)((*LoopbackNode)(nil))
 func (n *LoopbackNode) Statx(ctx context.Context, f FileHandle,
 	flags uint32, mask uint32,
 	out *fuse.StatxOut) syscall.Errno {
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return errno
+	}
+	defer restore()
 	if f != nil {
 		if fga, ok := f.(FileStatxer); ok {
-			return fga.Statx(ctx, flags, mask, out)
+			errno := fga.Statx(ctx, flags, mask, out)
+			n.RootData.Ownership.statx(&out.Statx)
+			return errno
 		}
 	}
 
-	p := n.path()
-
 	st := unix.Statx_t{}
-	err := unix.Statx(unix.AT_FDCWD, p, int(flags), int(mask), &st)
+	var err error
+	if n.fd != nil {
+		err = unix.Statx(int(n.fd.Fd()), "", int(flags)|unix.AT_EMPTY_PATH, int(mask), &st)
+	} else {
+		err = unix.Statx(unix.AT_FDCWD, n.path(), int(flags), int(mask), &st)
+	}
 	if err != nil {
 		return ToErrno(err)
 	}
 	out.FromStatx(&st)
+	n.RootData.Ownership.statx(&out.Statx)
 	return OK
 }
+
+// openNodeFd opens an O_PATH file descriptor for the file at p. A
+// symlink at p is only followed if follow is set.
+func openNodeFd(p string, follow bool) (*os.File, error) {
+	flags := unix.O_PATH | syscall.O_CLOEXEC
+	if !follow {
+		flags |= syscall.O_NOFOLLOW
+	}
+	fd, err := syscall.Open(p, flags, 0)
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), p), nil
+}
+
+// backingHandle identifies a file of the underlying file system
+// independently of its path.
+type backingHandle = unix.FileHandle
+
+// rememberHandle records the file handle of the file at path, whose
+// attributes are st.
+func (r *LoopbackRoot) rememberHandle(path string, st *syscall.Stat_t) {
+	h, _, err := unix.NameToHandleAt(unix.AT_FDCWD, path, 0)
+	if err != nil {
+		return
+	}
+	ino := r.idFromStat(st).Ino
+
+	r.handlesMu.Lock()
+	defer r.handlesMu.Unlock()
+	if r.handles == nil {
+		r.handles = map[uint64]backingHandle{}
+	}
+	r.handles[ino] = h
+}
+
+// statHandle returns the attributes of the file at path, which is
+// about to be removed or replaced, if file handles are recorded. The
+// result should be passed to dropHandle once the file is gone.
+func (r *LoopbackRoot) statHandle(path string) *syscall.Stat_t {
+	r.handlesMu.Lock()
+	n := len(r.handles)
+	r.handlesMu.Unlock()
+	if n == 0 {
+		return nil
+	}
+	var st syscall.Stat_t
+	if err := syscall.Lstat(path, &st); err != nil {
+		return nil
+	}
+	return &st
+}
+
+// dropHandle forgets the file handle of a removed file, whose
+// attributes before the removal were st, unless other links to it
+// remain.
+func (r *LoopbackRoot) dropHandle(st *syscall.Stat_t) {
+	if st == nil || (st.Mode&syscall.S_IFMT != syscall.S_IFDIR && st.Nlink > 1) {
+		return
+	}
+	ino := r.idFromStat(st).Ino
+	r.handlesMu.Lock()
+	defer r.handlesMu.Unlock()
+	delete(r.handles, ino)
+}
+
+// dropStaleHandle forgets the file handle for ino if the file no
+// longer exists, eg. because it was deleted outside of the mount.
+func (r *LoopbackRoot) dropStaleHandle(ino uint64) {
+	r.handlesMu.Lock()
+	h, ok := r.handles[ino]
+	r.handlesMu.Unlock()
+	if !ok {
+		return
+	}
+
+	mountFd, err := syscall.Open(r.Path, syscall.O_DIRECTORY|syscall.O_RDONLY, 0)
+	if err != nil {
+		return
+	}
+	defer syscall.Close(mountFd)
+	fd, err := unix.OpenByHandleAt(mountFd, h, unix.O_PATH)
+	if err == nil {
+		var st syscall.Stat_t
+		err = syscall.Fstat(fd, &st)
+		syscall.Close(fd)
+		if err != nil || st.Nlink > 0 {
+			return
+		}
+	} else if err != syscall.ESTALE {
+		return
+	}
+
+	r.handlesMu.Lock()
+	defer r.handlesMu.Unlock()
+	if cur, ok := r.handles[ino]; ok && bytes.Equal(cur.Bytes(), h.Bytes()) {
+		delete(r.handles, ino)
+	}
+}
+
+var _ = (NodeIDLookuper)((*LoopbackNode)(nil))
+
+// LookupID finds a node that the kernel has forgotten by opening the
+// underlying file with open_by_handle_at(2), which needs the
+// CAP_DAC_READ_SEARCH capability. The node is added to the tree
+// under the current path of the file.
+func (n *LoopbackNode) LookupID(ctx context.Context, ino uint64, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	r := n.RootData
+	r.handlesMu.Lock()
+	h, ok := r.handles[ino]
+	r.handlesMu.Unlock()
+	if !ok {
+		return nil, syscall.ESTALE
+	}
+
+	mountFd, err := syscall.Open(r.Path, syscall.O_DIRECTORY|syscall.O_RDONLY, 0)
+	if err != nil {
+		return nil, ToErrno(err)
+	}
+	defer syscall.Close(mountFd)
+	fd, err := unix.OpenByHandleAt(mountFd, h, unix.O_PATH|syscall.O_NOFOLLOW)
+	if err != nil {
+		return nil, syscall.ESTALE
+	}
+	defer syscall.Close(fd)
+
+	p, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fd))
+	if err != nil {
+		return nil, ToErrno(err)
+	}
+	rel, err := filepath.Rel(r.Path, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") || strings.HasSuffix(rel, " (deleted)") {
+		return nil, syscall.ESTALE
+	}
+
+	ch := n.root()
+	if rel != "." {
+		for _, name := range strings.Split(rel, "/") {
+			next := ch.GetChild(name)
+			if next == nil {
+				lu, ok := ch.Operations().(NodeLookuper)
+				if !ok {
+					return nil, syscall.ESTALE
+				}
+				var childOut fuse.EntryOut
+				var errno syscall.Errno
+				next, errno = lu.Lookup(ctx, name, &childOut)
+				if errno != 0 {
+					return nil, errno
+				}
+				if !ch.AddChild(name, next, false) {
+					discardNode(next)
+					next = ch.GetChild(name)
+				}
+			}
+			if next == nil {
+				return nil, syscall.ESTALE
+			}
+			ch = next
+		}
+	}
+
+	st := syscall.Stat_t{}
+	if err := syscall.Fstat(fd, &st); err != nil {
+		return nil, ToErrno(err)
+	}
+	if r.idFromStat(&st).Ino != ino || ch.StableAttr().Ino != ino {
+		return nil, syscall.ESTALE
+	}
+	out.Attr.FromStat(&st)
+	r.Ownership.attr(&out.Attr)
+	return ch, OK
+}
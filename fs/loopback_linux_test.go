@@ -6,6 +6,7 @@ package fs
 
 import (
 	"bytes"
+	"context"
 	"os"
 	"path/filepath"
 	"sync"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/hanwen/go-fuse/v2/fuse"
 	"github.com/hanwen/go-fuse/v2/internal/testutil"
+	"github.com/hanwen/go-fuse/v2/posixtest"
 	"golang.org/x/sys/unix"
 )
 
@@ -432,3 +434,364 @@ func TestMknod(t *testing.T) {
 		})
 	}
 }
+
+func TestLoopbackLookupID(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("open_by_handle_at needs CAP_DAC_READ_SEARCH")
+	}
+	dir := t.TempDir()
+	if err := os.Mkdir(dir+"/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/sub/file", []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := unix.NameToHandleAt(unix.AT_FDCWD, dir+"/sub/file", 0); err != nil {
+		t.Skipf("name_to_handle_at: %v", err)
+	}
+
+	root, err := NewLoopbackRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &Options{}
+	opts.EnableExportSupport = true
+	rb := NewNodeFS(root, opts).(*rawBridge)
+
+	lookup := func(nodeId uint64, name string) fuse.EntryOut {
+		t.Helper()
+		var out fuse.EntryOut
+		if st := rb.Lookup(nil, &fuse.InHeader{NodeId: nodeId}, name, &out); !st.Ok() {
+			t.Fatalf("Lookup(%d, %q): %v", nodeId, name, st)
+		}
+		return out
+	}
+
+	sub := lookup(1, "sub")
+	file := lookup(sub.NodeId, "file")
+	if file.NodeId != file.Ino {
+		t.Errorf("got node ID %d, want inode number %d", file.NodeId, file.Ino)
+	}
+	if got := lookup(file.NodeId, "."); got.NodeId != file.NodeId {
+		t.Errorf(`"." got node %d, want %d`, got.NodeId, file.NodeId)
+	}
+	if got := lookup(file.NodeId, ".."); got.NodeId != sub.NodeId {
+		t.Errorf(`".." got node %d, want %d`, got.NodeId, sub.NodeId)
+	}
+
+	rb.Forget(file.NodeId, 2)
+	rb.Forget(sub.NodeId, 2)
+	rb.mu.Lock()
+	l := len(rb.kernelNodeIds)
+	rb.mu.Unlock()
+	if l != 1 {
+		t.Fatalf("got %d live nodes, want 1", l)
+	}
+
+	got := lookup(file.NodeId, ".")
+	if got.NodeId != file.NodeId || got.Generation != file.Generation || got.Size != 5 {
+		t.Errorf("resurrected node: got %v, want %v", got, file)
+	}
+	if got := lookup(file.NodeId, ".."); got.Ino != sub.Ino {
+		t.Errorf(`".." got inode %d, want %d`, got.Ino, sub.Ino)
+	}
+
+	var out fuse.EntryOut
+	if st := rb.Lookup(nil, &fuse.InHeader{NodeId: 1 << 40}, ".", &out); st != fuse.Status(syscall.ESTALE) {
+		t.Errorf("unknown node: got %v, want ESTALE", st)
+	}
+
+	// A node ID that was handed out sequentially resolves to its
+	// own node while the kernel knows it, and is free for the file
+	// with that inode number once it is forgotten.
+	if err := os.WriteFile(dir+"/other", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	other := lookup(1, "other")
+	rb.Forget(other.NodeId, 1)
+	rb.mu.Lock()
+	rb.nextNodeId = other.Ino
+	rb.mu.Unlock()
+	clash := rb.root.NewInode(context.Background(), &Inode{}, StableAttr{Mode: syscall.S_IFREG, Ino: got.Ino, Gen: 7})
+	clash, _ = rb.addNewChild(rb.root, "clash", clash, nil, 0, &out)
+	if clash.nodeId != other.Ino {
+		t.Fatalf("clashing node got ID %d, want %d", clash.nodeId, other.Ino)
+	}
+	if got := lookup(other.Ino, "."); got.Ino != clash.stableAttr.Ino || got.Generation != 7 {
+		t.Errorf("sequential node ID: got ino %d gen %d, want ino %d gen 7", got.Ino, got.Generation, clash.stableAttr.Ino)
+	}
+	rb.Forget(clash.nodeId, 2)
+	rb.mu.Lock()
+	_, seq := rb.seqNodeIds[other.Ino]
+	rb.mu.Unlock()
+	if seq {
+		t.Errorf("forgotten sequential node ID %d was kept", other.Ino)
+	}
+	if got := lookup(other.Ino, "."); got.NodeId != other.Ino || got.Ino != other.Ino {
+		t.Errorf("reused node ID: got node %d ino %d, want %d", got.NodeId, got.Ino, other.Ino)
+	}
+
+	// Removing a file drops its handle.
+	if st := rb.Unlink(nil, &fuse.InHeader{NodeId: sub.NodeId}, "file"); !st.Ok() {
+		t.Fatalf("Unlink: %v", st)
+	}
+	r := root.(*LoopbackNode).RootData
+	r.handlesMu.Lock()
+	_, ok := r.handles[file.Ino]
+	r.handlesMu.Unlock()
+	if ok {
+		t.Errorf("handle of unlinked file was kept")
+	}
+}
+
+func TestLoopbackLookupIDOwnership(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("open_by_handle_at needs CAP_DAC_READ_SEARCH")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/file", []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := unix.NameToHandleAt(unix.AT_FDCWD, dir+"/file", 0); err != nil {
+		t.Skipf("name_to_handle_at: %v", err)
+	}
+
+	root, err := NewLoopbackRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.(*LoopbackNode).RootData.Ownership = &LoopbackOwnership{
+		UIDMap: map[uint32]uint32{0: 1000},
+		GIDMap: map[uint32]uint32{0: 2000},
+	}
+	opts := &Options{}
+	opts.EnableExportSupport = true
+	rb := NewNodeFS(root, opts).(*rawBridge)
+
+	var file fuse.EntryOut
+	if st := rb.Lookup(nil, &fuse.InHeader{NodeId: 1}, "file", &file); !st.Ok() {
+		t.Fatalf("Lookup: %v", st)
+	}
+	rb.Forget(file.NodeId, 1)
+
+	// The node is gone, so "." goes through LookupID.
+	var got fuse.EntryOut
+	if st := rb.Lookup(nil, &fuse.InHeader{NodeId: file.NodeId}, ".", &got); !st.Ok() {
+		t.Fatalf("Lookup(.): %v", st)
+	}
+	if got.Uid != 1000 || got.Gid != 2000 {
+		t.Errorf("got owner %d:%d, want 1000:2000", got.Uid, got.Gid)
+	}
+}
+
+func TestPosixKeepFds(t *testing.T) {
+	for nm, fn := range posixtest.All {
+		t.Run(nm, func(t *testing.T) {
+			tc := newTestCase(t, &testOptions{
+				attrCache:   true,
+				entryCache:  true,
+				enableLocks: true,
+				keepFds:     true,
+			})
+			fn(t, tc.mntDir)
+		})
+	}
+}
+
+func TestLoopbackKeepFdsRename(t *testing.T) {
+	tc := newTestCase(t, &testOptions{
+		attrCache:  true,
+		entryCache: true,
+		keepFds:    true,
+	})
+	if err := os.Mkdir(tc.origDir+"/dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	tc.writeOrig("dir/file", "hello", 0644)
+	if _, err := os.Stat(tc.mntDir + "/dir/file"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Move the directory behind the kernel's back. The cached
+	// nodes must keep referring to the same files.
+	if err := os.Rename(tc.origDir+"/dir", tc.origDir+"/moved"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(tc.origDir+"/dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	tc.writeOrig("moved/new", "new", 0644)
+
+	if got, err := os.ReadFile(tc.mntDir + "/dir/file"); err != nil || string(got) != "hello" {
+		t.Errorf("ReadFile: got %q, %v, want %q", got, err, "hello")
+	}
+	if got, err := os.ReadFile(tc.mntDir + "/dir/new"); err != nil || string(got) != "new" {
+		t.Errorf("ReadFile: got %q, %v, want %q", got, err, "new")
+	}
+	if err := os.WriteFile(tc.mntDir+"/dir/created", []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tc.origDir + "/moved/created"); err != nil {
+		t.Errorf("created file not in moved directory: %v", err)
+	}
+}
+
+// countFds returns the number of open file descriptors of the
+// process.
+func countFds(t *testing.T) int {
+	t.Helper()
+	es, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(es)
+}
+
+func TestLoopbackKeepFdsLookupLeak(t *testing.T) {
+	tc := newTestCase(t, &testOptions{keepFds: true})
+	tc.writeOrig("file", "hello", 0644)
+
+	// Without entry caching, each Lstat looks up the file again,
+	// and the bridge keeps the node it already has.
+	var st syscall.Stat_t
+	if err := syscall.Lstat(tc.mntDir+"/file", &st); err != nil {
+		t.Fatal(err)
+	}
+	before := countFds(t)
+	for i := 0; i < 200; i++ {
+		if err := syscall.Lstat(tc.mntDir+"/file", &st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after := countFds(t); after > before+10 {
+		t.Errorf("got %d open fds after lookups, had %d before", after, before)
+	}
+}
+
+func TestLoopbackWatch(t *testing.T) {
+	orig := t.TempDir()
+	if err := os.Mkdir(orig+"/dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(orig+"/dir/file", []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := NewLoopbackRoot(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ttl := 100 * time.Second
+	mnt, _ := testMount(t, root, &Options{
+		EntryTimeout:    &ttl,
+		AttrTimeout:     &ttl,
+		NegativeTimeout: &ttl,
+	})
+	w, err := root.(*LoopbackNode).RootData.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// Populate the caches.
+	if _, err := os.ReadFile(mnt + "/dir/file"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(mnt + "/dir/new"); !os.IsNotExist(err) {
+		t.Fatalf("Stat: got %v, want ENOENT", err)
+	}
+
+	waitFor := func(what string, ok func() bool) {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			if ok() {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Errorf("timed out waiting for %s", what)
+	}
+
+	if err := os.WriteFile(orig+"/dir/file", []byte("hello world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("new content", func() bool {
+		got, _ := os.ReadFile(mnt + "/dir/file")
+		return string(got) == "hello world"
+	})
+
+	if err := os.WriteFile(orig+"/dir/new", []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("created file", func() bool {
+		_, err := os.Stat(mnt + "/dir/new")
+		return err == nil
+	})
+
+	if err := os.Rename(orig+"/dir/file", orig+"/dir/renamed"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("renamed file", func() bool {
+		_, err1 := os.Stat(mnt + "/dir/file")
+		_, err2 := os.Stat(mnt + "/dir/renamed")
+		return os.IsNotExist(err1) && err2 == nil
+	})
+
+	if err := os.Chmod(orig+"/dir/new", 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("mode change", func() bool {
+		fi, err := os.Stat(mnt + "/dir/new")
+		return err == nil && fi.Mode().Perm() == 0600
+	})
+}
+
+func TestLoopbackImpersonate(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("needs root to change credentials")
+	}
+	orig := t.TempDir()
+	if err := os.Chmod(orig, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(orig+"/private", 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(orig+"/private/file", []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := NewLoopbackRoot(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.(*LoopbackNode).RootData.Impersonate = true
+	opts := &Options{}
+	opts.AllowOther = true
+	mnt, _ := testMount(t, root, opts)
+	allowOthers(t, mnt)
+
+	const uid, gid = 4321, 4322
+	cred := &syscall.Credential{Uid: uid, Gid: gid}
+	run := func(script string) error {
+		return runAs(t, cred, script)
+	}
+
+	if err := run("echo hello > " + mnt + "/new"); err != nil {
+		t.Fatalf("creating file: %v", err)
+	}
+	var st syscall.Stat_t
+	if err := syscall.Lstat(orig+"/new", &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Uid != uid || st.Gid != gid {
+		t.Errorf("new file owned by %d:%d, want %d:%d", st.Uid, st.Gid, uid, gid)
+	}
+
+	if err := run("cat " + mnt + "/private/file"); err == nil {
+		t.Errorf("reading private directory succeeded")
+	}
+	if got, err := os.ReadFile(mnt + "/private/file"); err != nil || string(got) != "secret" {
+		t.Errorf("ReadFile as root: got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// IDSquash selects which user and group IDs LoopbackOwnership
+// replaces when they are stored in the underlying file system.
+type IDSquash int
+
+const (
+	// SquashNone stores IDs as they are.
+	SquashNone IDSquash = iota
+
+	// SquashRoot stores user and group ID 0 as the squash IDs.
+	SquashRoot
+
+	// SquashAll stores all IDs as the squash IDs.
+	SquashAll
+)
+
+// LoopbackOwnership changes the ownership and permissions that a
+// loopback file system presents, like bindfs(1). It applies to
+// attributes returned by Lookup, Getattr, Statx, Setattr and the
+// operations creating files, to ownership changes and the ownership of
+// new files, and to the IDs in POSIX ACL extended attributes.
+//
+// The zero value changes nothing. The fields must not be changed
+// after the file system is mounted.
+type LoopbackOwnership struct {
+	// UIDMap maps user IDs of the underlying file system to user
+	// IDs in the mount. The inverse mapping is applied to IDs
+	// passed into the file system, so the map should be one to
+	// one. IDs that are not in the map are not changed.
+	UIDMap map[uint32]uint32
+
+	// GIDMap is like UIDMap, for group IDs.
+	GIDMap map[uint32]uint32
+
+	// ForceUID, if set, presents all files as owned by this user.
+	// Requests to change the owner are then ignored.
+	ForceUID *uint32
+
+	// ForceGID, if set, presents all files as owned by this
+	// group. Requests to change the group are then ignored.
+	ForceGID *uint32
+
+	// Squash selects the IDs of the mount that are stored as
+	// SquashUID and SquashGID, instead of being mapped. As with
+	// the NFS root_squash option, this is typically used to
+	// store files of root as owned by nobody (65534).
+	Squash    IDSquash
+	SquashUID uint32
+	SquashGID uint32
+
+	// ClearMode holds permission bits that are cleared from the
+	// presented modes, and SetMode bits that are added. For
+	// example, ClearMode 0022 hides write permissions for group
+	// and others.
+	ClearMode uint32
+	SetMode   uint32
+
+	// ChmodFilter holds permission bits that are cleared from
+	// modes set with chmod, and from the modes of new files.
+	ChmodFilter uint32
+
+	// IgnoreChmod, if set, ignores requests to change the mode.
+	IgnoreChmod bool
+
+	once    sync.Once
+	uidBack map[uint32]uint32
+	gidBack map[uint32]uint32
+}
+
+func (o *LoopbackOwnership) init() {
+	o.uidBack = make(map[uint32]uint32, len(o.UIDMap))
+	for k, v := range o.UIDMap {
+		o.uidBack[v] = k
+	}
+	o.gidBack = make(map[uint32]uint32, len(o.GIDMap))
+	for k, v := range o.GIDMap {
+		o.gidBack[v] = k
+	}
+}
+
+// uid returns the user ID presented for the underlying user ID id.
+func (o *LoopbackOwnership) uid(id uint32) uint32 {
+	if o == nil {
+		return id
+	}
+	if o.ForceUID != nil {
+		return *o.ForceUID
+	}
+	if m, ok := o.UIDMap[id]; ok {
+		return m
+	}
+	return id
+}
+
+// gid returns the group ID presented for the underlying group ID id.
+func (o *LoopbackOwnership) gid(id uint32) uint32 {
+	if o == nil {
+		return id
+	}
+	if o.ForceGID != nil {
+		return *o.ForceGID
+	}
+	if m, ok := o.GIDMap[id]; ok {
+		return m
+	}
+	return id
+}
+
+// backingUID returns the user ID to store for the user ID id of the
+// mount.
+func (o *LoopbackOwnership) backingUID(id uint32) uint32 {
+	if o == nil {
+		return id
+	}
+	if o.Squash == SquashAll || (o.Squash == SquashRoot && id == 0) {
+		return o.SquashUID
+	}
+	o.once.Do(o.init)
+	if m, ok := o.uidBack[id]; ok {
+		return m
+	}
+	return id
+}
+
+// backingGID returns the group ID to store for the group ID id of the
+// mount.
+func (o *LoopbackOwnership) backingGID(id uint32) uint32 {
+	if o == nil {
+		return id
+	}
+	if o.Squash == SquashAll || (o.Squash == SquashRoot && id == 0) {
+		return o.SquashGID
+	}
+	o.once.Do(o.init)
+	if m, ok := o.gidBack[id]; ok {
+		return m
+	}
+	return id
+}
+
+// mode returns the presented mode for the underlying mode m.
+func (o *LoopbackOwnership) mode(m uint32) uint32 {
+	if o == nil {
+		return m
+	}
+	return m&^07777 | (m&07777&^o.ClearMode | o.SetMode&07777)
+}
+
+// setMode returns the mode to store for the mode m of chmod or a new
+// file.
+func (o *LoopbackOwnership) setMode(m uint32) uint32 {
+	if o == nil {
+		return m
+	}
+	return m &^ (o.ChmodFilter & 07777)
+}
+
+// attr changes the attributes of the underlying file into those
+// presented in the mount.
+func (o *LoopbackOwnership) attr(a *fuse.Attr) {
+	if o == nil {
+		return
+	}
+	a.Uid = o.uid(a.Uid)
+	a.Gid = o.gid(a.Gid)
+	a.Mode = o.mode(a.Mode)
+}
+
+// statx is like attr, for statx results.
+func (o *LoopbackOwnership) statx(s *fuse.Statx) {
+	if o == nil {
+		return
+	}
+	s.Uid = o.uid(s.Uid)
+	s.Gid = o.gid(s.Gid)
+	s.Mode = uint16(o.mode(uint32(s.Mode)))
+}
+
+// setattr changes a Setattr request in the mount into one for the
+// underlying file. It returns a modified copy of in.
+func (o *LoopbackOwnership) setattr(in *fuse.SetAttrIn) *fuse.SetAttrIn {
+	if o == nil {
+		return in
+	}
+	c := *in
+	if c.Valid&fuse.FATTR_MODE != 0 {
+		if o.IgnoreChmod {
+			c.Valid &^= fuse.FATTR_MODE
+		} else {
+			c.Mode = o.setMode(c.Mode)
+		}
+	}
+	if c.Valid&fuse.FATTR_UID != 0 {
+		if o.ForceUID != nil {
+			c.Valid &^= fuse.FATTR_UID
+		} else {
+			c.Uid = o.backingUID(c.Uid)
+		}
+	}
+	if c.Valid&fuse.FATTR_GID != 0 {
+		if o.ForceGID != nil {
+			c.Valid &^= fuse.FATTR_GID
+		} else {
+			c.Gid = o.backingGID(c.Gid)
+		}
+	}
+	return &c
+}
+
+// aclToMount maps the IDs in the POSIX ACL xattr value data of an
+// underlying file to those of the mount. Forced IDs do not apply.
+func (o *LoopbackOwnership) aclToMount(data []byte) {
+	if o == nil {
+		return
+	}
+	mapACL(data, func(id uint32) uint32 {
+		if m, ok := o.UIDMap[id]; ok {
+			return m
+		}
+		return id
+	}, func(id uint32) uint32 {
+		if m, ok := o.GIDMap[id]; ok {
+			return m
+		}
+		return id
+	})
+}
+
+// aclToBacking maps the IDs in the POSIX ACL xattr value data, as
+// set in the mount, to those of the underlying file system.
+func (o *LoopbackOwnership) aclToBacking(data []byte) {
+	if o == nil {
+		return
+	}
+	mapACL(data, o.backingUID, o.backingGID)
+}
+
+// mapACL maps the IDs of named users and groups in the POSIX ACL
+// xattr value data in place. The value consists of a version header,
+// followed by entries of a 16-bit tag, 16-bit permissions and a 32-bit
+// ID, all little-endian.
+func mapACL(data []byte, uid, gid func(uint32) uint32) {
+	if len(data) < 4 || binary.LittleEndian.Uint32(data) != aclVersion {
+		return
+	}
+	for e := data[4:]; len(e) >= 8; e = e[8:] {
+		id := binary.LittleEndian.Uint32(e[4:])
+		switch binary.LittleEndian.Uint16(e) {
+		case ACL_USER:
+			binary.LittleEndian.PutUint32(e[4:], uid(id))
+		case ACL_GROUP:
+			binary.LittleEndian.PutUint32(e[4:], gid(id))
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestLoopbackOwnership(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("needs root to change ownership")
+	}
+	orig := t.TempDir()
+	if err := os.WriteFile(orig+"/file", []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(orig+"/file", 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := NewLoopbackRoot(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.(*LoopbackNode).RootData.Ownership = &LoopbackOwnership{
+		UIDMap:      map[uint32]uint32{0: 1000},
+		GIDMap:      map[uint32]uint32{0: 2000},
+		Squash:      SquashRoot,
+		SquashUID:   65534,
+		SquashGID:   65533,
+		ClearMode:   0022,
+		ChmodFilter: 0002,
+	}
+	mnt, _ := testMount(t, root, &Options{})
+
+	stat := func(p string) *fuse.Attr {
+		t.Helper()
+		var st syscall.Stat_t
+		if err := syscall.Lstat(p, &st); err != nil {
+			t.Fatal(err)
+		}
+		var a fuse.Attr
+		a.FromStat(&st)
+		return &a
+	}
+	check := func(p string, uid, gid, perm uint32) {
+		t.Helper()
+		st := stat(p)
+		if st.Uid != uid || st.Gid != gid || st.Mode&07777 != perm {
+			t.Errorf("%s: got %d:%d %o, want %d:%d %o", p, st.Uid, st.Gid, st.Mode&07777, uid, gid, perm)
+		}
+	}
+
+	check(mnt+"/file", 1000, 2000, 0644)
+
+	// Mapped IDs are stored as the original ones.
+	if err := os.Chown(mnt+"/file", 1000, 2000); err != nil {
+		t.Fatal(err)
+	}
+	check(orig+"/file", 0, 0, 0666)
+
+	// Unmapped root is squashed.
+	if err := os.Chown(mnt+"/file", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	check(orig+"/file", 65534, 65533, 0666)
+	check(mnt+"/file", 65534, 65533, 0644)
+
+	if err := os.Chmod(mnt+"/file", 0777); err != nil {
+		t.Fatal(err)
+	}
+	check(orig+"/file", 65534, 65533, 0775)
+	check(mnt+"/file", 65534, 65533, 0755)
+
+	// New files are owned by the squashed caller.
+	if err := os.Mkdir(mnt+"/dir", 0777); err != nil {
+		t.Fatal(err)
+	}
+	st := stat(orig + "/dir")
+	if st.Uid != 65534 || st.Gid != 65533 || st.Mode&0002 != 0 {
+		t.Errorf("new directory: got %d:%d %o", st.Uid, st.Gid, st.Mode&07777)
+	}
+}
+
+func TestMapACL(t *testing.T) {
+	acl := func(entries ...uint32) []byte {
+		b := binary.LittleEndian.AppendUint32(nil, aclVersion)
+		for i := 0; i < len(entries); i += 2 {
+			b = binary.LittleEndian.AppendUint16(b, uint16(entries[i]))
+			b = binary.LittleEndian.AppendUint16(b, 6)
+			b = binary.LittleEndian.AppendUint32(b, entries[i+1])
+		}
+		return b
+	}
+	o := &LoopbackOwnership{
+		UIDMap: map[uint32]uint32{1: 1001},
+		GIDMap: map[uint32]uint32{2: 2002},
+	}
+
+	data := acl(ACL_USER_OBJ, 1, ACL_USER, 1, ACL_USER, 3, ACL_GROUP_OBJ, 2, ACL_GROUP, 2, ACL_OTHER, 1)
+	o.aclToMount(data)
+	if want := acl(ACL_USER_OBJ, 1, ACL_USER, 1001, ACL_USER, 3, ACL_GROUP_OBJ, 2, ACL_GROUP, 2002, ACL_OTHER, 1); !bytes.Equal(data, want) {
+		t.Errorf("aclToMount: got %x, want %x", data, want)
+	}
+	o.aclToBacking(data)
+	if want := acl(ACL_USER_OBJ, 1, ACL_USER, 1, ACL_USER, 3, ACL_GROUP_OBJ, 2, ACL_GROUP, 2, ACL_OTHER, 1); !bytes.Equal(data, want) {
+		t.Errorf("aclToBacking: got %x, want %x", data, want)
+	}
+}
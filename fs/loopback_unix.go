@@ -15,6 +15,17 @@ import (
 var _ = (NodeListxattrer)((*LoopbackNode)(nil))
 
 func (n *LoopbackNode) Listxattr(ctx context.Context, dest []byte) (uint32, syscall.Errno) {
-	sz, err := unix.Llistxattr(n.path(), dest)
+	restore, errno := n.asCaller(ctx)
+	if errno != 0 {
+		return 0, errno
+	}
+	defer restore()
+	var sz int
+	var err error
+	if n.fd != nil {
+		sz, err = unix.Listxattr(n.path(), dest)
+	} else {
+		sz, err = unix.Llistxattr(n.path(), dest)
+	}
 	return uint32(sz), ToErrno(err)
 }
@@ -0,0 +1,222 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// LoopbackWatcher invalidates the kernel caches of a loopback file
+// system when its underlying files change. See LoopbackRoot.Watch.
+type LoopbackWatcher struct {
+	root *LoopbackRoot
+
+	// fd is the inotify descriptor, which f wraps. f.Fd() is not
+	// used, as it would make reads block Close.
+	fd int
+	f  *os.File
+
+	mu     sync.Mutex
+	closed bool
+	dirs   map[int32]*Inode
+	wds    map[*Inode]int32
+}
+
+const loopbackWatchMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM |
+	unix.IN_MOVED_TO | unix.IN_MODIFY | unix.IN_ATTRIB | unix.IN_CLOSE_WRITE |
+	unix.IN_ONLYDIR | unix.IN_EXCL_UNLINK
+
+// Watch starts watching the underlying directories with inotify(7),
+// and translates changes into NotifyEntry, NotifyDelete and
+// NotifyContent calls on the affected nodes. This makes it safe to use
+// long cache timeouts while other processes modify the underlying
+// file system.
+//
+// Directories are watched from the moment the kernel looks up
+// entries in them, until they are forgotten. Content changes only
+// invalidate attributes, except when a writer closes the file; the
+// kernel drops cached data if it sees the size or mtime change.
+// Changes made through the mount are seen too, and cause some
+// needless invalidations. Inotify limits the number of watches, see
+// /proc/sys/fs/inotify/max_user_watches.
+//
+// Watch must be called after mounting, and RootNode must be set. Call
+// Close on the result to stop watching.
+func (r *LoopbackRoot) Watch() (*LoopbackWatcher, error) {
+	if r.RootNode == nil {
+		return nil, syscall.EINVAL
+	}
+	fd, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	w := &LoopbackWatcher{
+		root: r,
+		fd:   fd,
+		f:    os.NewFile(uintptr(fd), "inotify"),
+		dirs: map[int32]*Inode{},
+		wds:  map[*Inode]int32{},
+	}
+	if !r.watcher.CompareAndSwap(nil, w) {
+		w.f.Close()
+		return nil, syscall.EBUSY
+	}
+
+	// The kernel may already have cached entries of the known
+	// directories.
+	todo := []*Inode{r.RootNode.EmbeddedInode()}
+	for len(todo) > 0 {
+		n := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if lb, ok := n.Operations().(loopbackNodeEmbedder); ok {
+			w.add(lb.loopbackNode())
+		}
+		for _, ch := range n.Children() {
+			if ch.IsDir() {
+				todo = append(todo, ch)
+			}
+		}
+	}
+
+	go w.loop()
+	return w, nil
+}
+
+// Close stops watching.
+func (w *LoopbackWatcher) Close() error {
+	w.root.watcher.CompareAndSwap(w, nil)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.dirs = nil
+	w.wds = nil
+	return w.f.Close()
+}
+
+// add starts watching the directory n, if it is not watched yet.
+func (w *LoopbackWatcher) add(n *LoopbackNode) {
+	node := &n.Inode
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	if _, ok := w.wds[node]; ok {
+		return
+	}
+	wd, err := unix.InotifyAddWatch(w.fd, n.path(), loopbackWatchMask)
+	if err != nil {
+		return
+	}
+	// A directory that is looked up again after being forgotten
+	// gets a new node, but keeps its watch descriptor.
+	if old := w.dirs[int32(wd)]; old != nil {
+		delete(w.wds, old)
+	}
+	w.dirs[int32(wd)] = node
+	w.wds[node] = int32(wd)
+}
+
+// remove stops watching the directory n.
+func (w *LoopbackWatcher) remove(n *Inode) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	wd, ok := w.wds[n]
+	if !ok {
+		return
+	}
+	delete(w.wds, n)
+	delete(w.dirs, wd)
+	unix.InotifyRmWatch(w.fd, uint32(wd))
+}
+
+func (w *LoopbackWatcher) loop() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			return
+		}
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			off += unix.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[off:off+int(ev.Len)], "\x00"))
+			off += int(ev.Len)
+			w.handle(ev.Wd, ev.Mask, name)
+		}
+	}
+}
+
+func (w *LoopbackWatcher) handle(wd int32, mask uint32, name string) {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		w.invalidateAll()
+		return
+	}
+
+	w.mu.Lock()
+	dir := w.dirs[wd]
+	if dir != nil && mask&unix.IN_IGNORED != 0 {
+		delete(w.dirs, wd)
+		delete(w.wds, dir)
+	}
+	w.mu.Unlock()
+	if dir == nil {
+		return
+	}
+
+	if name == "" {
+		if mask&unix.IN_ATTRIB != 0 {
+			dir.NotifyContent(-1, 0)
+		}
+		return
+	}
+
+	switch {
+	case mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+		dir.NotifyEntry(name)
+	case mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+		if ch := dir.GetChild(name); ch != nil {
+			dir.NotifyDelete(name, ch)
+		} else {
+			dir.NotifyEntry(name)
+		}
+	case mask&unix.IN_CLOSE_WRITE != 0:
+		if ch := dir.GetChild(name); ch != nil {
+			ch.NotifyContent(0, 0)
+		}
+	case mask&(unix.IN_MODIFY|unix.IN_ATTRIB) != 0:
+		if ch := dir.GetChild(name); ch != nil {
+			ch.NotifyContent(-1, 0)
+		}
+	}
+}
+
+// invalidateAll invalidates everything below the watched directories,
+// for when inotify has dropped events.
+func (w *LoopbackWatcher) invalidateAll() {
+	w.mu.Lock()
+	var dirs []*Inode
+	for _, dir := range w.dirs {
+		dirs = append(dirs, dir)
+	}
+	w.mu.Unlock()
+
+	for _, dir := range dirs {
+		dir.NotifyContent(-1, 0)
+		for name, ch := range dir.Children() {
+			dir.NotifyEntry(name)
+			ch.NotifyContent(0, 0)
+		}
+	}
+}
@@ -12,14 +12,25 @@ import (
 	"github.com/hanwen/go-fuse/v2/fuse"
 )
 
-// MemRegularFile is a filesystem node that holds a data
-// slice in memory.
+// MemRegularFile is a filesystem node that holds file contents in
+// memory. The contents are stored in pages of 4096 bytes, which are
+// only allocated once written, so sparse files are cheap. Readers do
+// not block each other.
 type MemRegularFile struct {
 	Inode
 
-	mu   sync.Mutex
+	// Data holds the contents of the file when it is first used.
+	// It is copied into the page storage then, so the file never
+	// writes to the caller's slice. Use Bytes to read the contents
+	// after the file was changed.
 	Data []byte
+
+	// mu protects Attr.
+	mu   sync.Mutex
 	Attr fuse.Attr
+
+	init  sync.Once
+	pages memPages
 }
 
 var _ = (NodeOpener)((*MemRegularFile)(nil))
@@ -28,22 +39,24 @@ var _ = (NodeWriter)((*MemRegularFile)(nil))
 var _ = (NodeSetattrer)((*MemRegularFile)(nil))
 var _ = (NodeFlusher)((*MemRegularFile)(nil))
 var _ = (NodeAllocater)((*MemRegularFile)(nil))
+var _ = (NodeLseeker)((*MemRegularFile)(nil))
+
+// content returns the page storage, initializing it from Data on
+// first use.
+func (f *MemRegularFile) content() *memPages {
+	f.init.Do(func() {
+		f.pages.setBytes(f.Data)
+	})
+	return &f.pages
+}
+
+// Bytes returns a copy of the current contents of the file.
+func (f *MemRegularFile) Bytes() []byte {
+	return f.content().bytes()
+}
 
 func (f *MemRegularFile) Allocate(ctx context.Context, fh FileHandle, off uint64, size uint64, mode uint32) syscall.Errno {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	oldSz := len(f.Data)
-	if uint64(cap(f.Data)) < off+size {
-		n := make([]byte, off+size)
-		copy(n, f.Data)
-		f.Data = n
-	}
-	if keepSizeMode(mode) {
-		f.Data = f.Data[:oldSz]
-	} else if len(f.Data) < int(off+size) {
-		f.Data = f.Data[:off+size]
-	}
-	return 0
+	return f.content().fallocate(int64(off), int64(size), mode)
 }
 
 func (f *MemRegularFile) Open(ctx context.Context, flags uint32) (fh FileHandle, fuseFlags uint32, errno syscall.Errno) {
@@ -51,38 +64,32 @@ func (f *MemRegularFile) Open(ctx context.Context, flags uint32) (fh FileHandle,
 }
 
 func (f *MemRegularFile) Write(ctx context.Context, fh FileHandle, data []byte, off int64) (uint32, syscall.Errno) {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	end := int64(len(data)) + off
-	if int64(len(f.Data)) < end {
-		n := make([]byte, end)
-		copy(n, f.Data)
-		f.Data = n
+	if errno := f.content().writeAt(data, off); errno != 0 {
+		return 0, errno
 	}
-
-	copy(f.Data[off:off+int64(len(data))], data)
-
 	return uint32(len(data)), 0
 }
 
 var _ = (NodeGetattrer)((*MemRegularFile)(nil))
 
 func (f *MemRegularFile) Getattr(ctx context.Context, fh FileHandle, out *fuse.AttrOut) syscall.Errno {
+	p := f.content()
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	out.Attr = f.Attr
-	out.Attr.Size = uint64(len(f.Data))
+	p.fillAttr(&out.Attr)
 	return OK
 }
 
 func (f *MemRegularFile) Setattr(ctx context.Context, fh FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	p := f.content()
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	if sz, ok := in.GetSize(); ok {
-		f.Data = f.Data[:sz]
+		p.truncate(int64(sz))
 	}
 	out.Attr = f.Attr
-	out.Size = uint64(len(f.Data))
+	p.fillAttr(&out.Attr)
 	return OK
 }
 
@@ -91,13 +98,13 @@ func (f *MemRegularFile) Flush(ctx context.Context, fh FileHandle) syscall.Errno
 }
 
 func (f *MemRegularFile) Read(ctx context.Context, fh FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	end := int(off) + len(dest)
-	if end > len(f.Data) {
-		end = len(f.Data)
-	}
-	return fuse.ReadResultData(f.Data[off:end]), OK
+	n := f.content().readAt(dest, off)
+	return fuse.ReadResultData(dest[:n]), OK
+}
+
+func (f *MemRegularFile) Lseek(ctx context.Context, fh FileHandle, off uint64, whence uint32) (uint64, syscall.Errno) {
+	res, errno := f.content().seek(int64(off), whence)
+	return uint64(res), errno
 }
 
 // MemSymlink is an inode holding a symlink in memory.
@@ -6,6 +6,11 @@ package fs
 
 import "golang.org/x/sys/unix"
 
-func keepSizeMode(mode uint32) bool {
-	return mode&unix.FALLOC_FL_KEEP_SIZE != 0
-}
+const (
+	fallocKeepSize  = unix.FALLOC_FL_KEEP_SIZE
+	fallocPunchHole = unix.FALLOC_FL_PUNCH_HOLE
+	fallocZeroRange = unix.FALLOC_FL_ZERO_RANGE
+
+	xattrCreate  = unix.XATTR_CREATE
+	xattrReplace = unix.XATTR_REPLACE
+)
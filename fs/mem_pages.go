@@ -0,0 +1,248 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"bytes"
+	"sync"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// memPageSize is the granularity of in-memory file storage.
+const memPageSize = 4096
+
+var zeroPage [memPageSize]byte
+
+// memPages stores file contents as a sparse set of pages. Pages
+// that were never written, or were punched out, are holes that read
+// as zeros. Readers share the lock, so they don't block each other.
+type memPages struct {
+	mu    sync.RWMutex
+	size  int64
+	pages map[int64][]byte
+
+	// reserve, if set, is called with the lock held before pages
+	// are added (delta > 0), and after they are dropped (delta <
+	// 0). It can refuse growth by returning ENOSPC.
+	reserve func(delta int64) syscall.Errno
+}
+
+// setBytes replaces the contents with a copy of data. Pages that
+// only hold zeros become holes.
+func (p *memPages) setBytes(data []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pages = make(map[int64][]byte, (len(data)+memPageSize-1)/memPageSize)
+	p.size = int64(len(data))
+	for off := 0; off < len(data); off += memPageSize {
+		src := data[off:min(off+memPageSize, len(data))]
+		if bytes.Equal(src, zeroPage[:len(src)]) {
+			continue
+		}
+		pg := make([]byte, memPageSize)
+		copy(pg, src)
+		p.pages[int64(off/memPageSize)] = pg
+	}
+}
+
+// fillAttr sets the size and block count.
+func (p *memPages) fillAttr(a *fuse.Attr) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	a.Size = uint64(p.size)
+	a.Blksize = memPageSize
+	a.Blocks = uint64(len(p.pages)) * (memPageSize / 512)
+}
+
+func (p *memPages) fileSize() int64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.size
+}
+
+// readAt reads into dest, and returns the number of bytes read.
+func (p *memPages) readAt(dest []byte, off int64) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if off >= p.size {
+		return 0
+	}
+	n := int(min(int64(len(dest)), p.size-off))
+	for done := 0; done < n; {
+		pos := off + int64(done)
+		idx, po := pos/memPageSize, int(pos%memPageSize)
+		chunk := dest[done:min(n, done+memPageSize-po)]
+		if pg, ok := p.pages[idx]; ok {
+			copy(chunk, pg[po:])
+		} else {
+			clear(chunk)
+		}
+		done += len(chunk)
+	}
+	return n
+}
+
+// bytes returns a copy of the contents.
+func (p *memPages) bytes() []byte {
+	buf := make([]byte, p.fileSize())
+	n := p.readAt(buf, 0)
+	return buf[:n]
+}
+
+// forRangeLocked calls fn for the stored pages overlapping [off,
+// end), visiting whichever is smaller: the range or the map.
+func (p *memPages) forRangeLocked(off, end int64, fn func(idx int64, pg []byte)) {
+	if off >= end {
+		return
+	}
+	first, last := off/memPageSize, (end-1)/memPageSize
+	if last-first >= int64(len(p.pages)) {
+		for idx, pg := range p.pages {
+			if idx >= first && idx <= last {
+				fn(idx, pg)
+			}
+		}
+		return
+	}
+	for idx := first; idx <= last; idx++ {
+		if pg, ok := p.pages[idx]; ok {
+			fn(idx, pg)
+		}
+	}
+}
+
+// materializeLocked makes sure all pages overlapping [off, end) are
+// stored.
+func (p *memPages) materializeLocked(off, end int64) syscall.Errno {
+	if off >= end {
+		return OK
+	}
+	first, last := off/memPageSize, (end-1)/memPageSize
+	present := int64(0)
+	p.forRangeLocked(off, end, func(int64, []byte) { present++ })
+	missing := last - first + 1 - present
+	if missing == 0 {
+		return OK
+	}
+	if p.reserve != nil {
+		if errno := p.reserve(missing); errno != 0 {
+			return errno
+		}
+	}
+	if p.pages == nil {
+		p.pages = map[int64][]byte{}
+	}
+	for idx := first; idx <= last; idx++ {
+		if _, ok := p.pages[idx]; !ok {
+			p.pages[idx] = make([]byte, memPageSize)
+		}
+	}
+	return OK
+}
+
+func (p *memPages) writeAt(data []byte, off int64) syscall.Errno {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	end := off + int64(len(data))
+	if errno := p.materializeLocked(off, end); errno != 0 {
+		return errno
+	}
+	for done := 0; done < len(data); {
+		pos := off + int64(done)
+		done += copy(p.pages[pos/memPageSize][pos%memPageSize:], data[done:])
+	}
+	p.size = max(p.size, end)
+	return OK
+}
+
+// zeroLocked clears [off, end), dropping the pages that lie
+// entirely inside the range. Ranges reaching the end of the file
+// extend to the end of its last page.
+func (p *memPages) zeroLocked(off, end int64) {
+	if end >= p.size {
+		end = (p.size + memPageSize - 1) / memPageSize * memPageSize
+	}
+	dropped := int64(0)
+	p.forRangeLocked(off, end, func(idx int64, pg []byte) {
+		start := idx * memPageSize
+		if off <= start && start+memPageSize <= end {
+			delete(p.pages, idx)
+			dropped++
+			return
+		}
+		clear(pg[max(off, start)-start : min(end, start+memPageSize)-start])
+	})
+	if dropped > 0 && p.reserve != nil {
+		p.reserve(-dropped)
+	}
+}
+
+func (p *memPages) truncate(size int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.zeroLocked(size, p.size)
+	p.size = size
+}
+
+// fallocate implements fallocate(2). Allocating stores zeroed pages,
+// so later writes to the range cannot run out of space. Punching
+// holes and zeroing ranges drop the pages inside the range.
+func (p *memPages) fallocate(off, length int64, mode uint32) syscall.Errno {
+	keepSize := mode&fallocKeepSize != 0
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	end := off + length
+	switch mode &^ fallocKeepSize {
+	case 0:
+		if errno := p.materializeLocked(off, end); errno != 0 {
+			return errno
+		}
+	case fallocPunchHole:
+		if !keepSize {
+			return syscall.EOPNOTSUPP
+		}
+		p.zeroLocked(off, end)
+	case fallocZeroRange:
+		p.zeroLocked(off, end)
+	default:
+		return syscall.EOPNOTSUPP
+	}
+	if !keepSize {
+		p.size = max(p.size, end)
+	}
+	return OK
+}
+
+// seek implements SEEK_DATA and SEEK_HOLE. The end of the file
+// counts as a hole.
+func (p *memPages) seek(off int64, whence uint32) (int64, syscall.Errno) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if off < 0 || off >= p.size {
+		return 0, syscall.ENXIO
+	}
+	switch whence {
+	case _SEEK_DATA:
+		first := int64(-1)
+		p.forRangeLocked(off, p.size, func(idx int64, pg []byte) {
+			if first < 0 || idx < first {
+				first = idx
+			}
+		})
+		if first < 0 {
+			return 0, syscall.ENXIO
+		}
+		return max(off, first*memPageSize), OK
+	case _SEEK_HOLE:
+		idx := off / memPageSize
+		for p.pages[idx] != nil {
+			idx++
+		}
+		return min(max(off, idx*memPageSize), p.size), OK
+	}
+	return 0, syscall.EINVAL
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"bytes"
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestMemPagesSparse(t *testing.T) {
+	var reserved int64
+	p := memPages{
+		reserve: func(delta int64) syscall.Errno {
+			reserved += delta
+			return 0
+		},
+	}
+
+	const off = 1 << 30
+	if errno := p.writeAt([]byte("hello"), off); errno != 0 {
+		t.Fatal(errno)
+	}
+	if errno := p.writeAt([]byte("x"), 10); errno != 0 {
+		t.Fatal(errno)
+	}
+	var a fuse.Attr
+	p.fillAttr(&a)
+	if a.Size != off+5 || a.Blocks != 16 || reserved != 2 {
+		t.Errorf("got size %d, blocks %d, reserved %d", a.Size, a.Blocks, reserved)
+	}
+
+	buf := make([]byte, 2*memPageSize)
+	if n := p.readAt(buf, off-memPageSize); n != memPageSize+5 {
+		t.Errorf("readAt: got %d bytes", n)
+	}
+	if want := append(make([]byte, memPageSize), "hello"...); !bytes.Equal(buf[:len(want)], want) {
+		t.Errorf("readAt: got %q", buf[memPageSize:memPageSize+5])
+	}
+
+	for _, c := range []struct {
+		off    int64
+		whence uint32
+		want   int64
+		errno  syscall.Errno
+	}{
+		{0, _SEEK_DATA, 0, 0},
+		{11, _SEEK_DATA, 11, 0},
+		{memPageSize, _SEEK_DATA, off, 0},
+		{0, _SEEK_HOLE, memPageSize, 0},
+		{off + 1, _SEEK_HOLE, off + 5, 0},
+		{off + 5, _SEEK_HOLE, 0, syscall.ENXIO},
+	} {
+		got, errno := p.seek(c.off, c.whence)
+		if got != c.want || errno != c.errno {
+			t.Errorf("seek(%d, %d): got %d, %v, want %d, %v", c.off, c.whence, got, errno, c.want, c.errno)
+		}
+	}
+
+	if errno := p.fallocate(0, memPageSize, fallocPunchHole); errno != syscall.EOPNOTSUPP {
+		t.Errorf("punch hole without keep size: got %v", errno)
+	}
+	if errno := p.fallocate(0, off, fallocPunchHole|fallocKeepSize); errno != 0 {
+		t.Fatal(errno)
+	}
+	if got, errno := p.seek(0, _SEEK_DATA); got != off || errno != 0 {
+		t.Errorf("seek after punch: got %d, %v", got, errno)
+	}
+
+	p.truncate(0)
+	if reserved != 0 {
+		t.Errorf("after truncate: got %d pages reserved", reserved)
+	}
+}
+
+func TestMemPagesZeroRange(t *testing.T) {
+	var p memPages
+	p.setBytes(bytes.Repeat([]byte{'x'}, 3*memPageSize))
+	if errno := p.fallocate(100, 2*memPageSize, fallocZeroRange); errno != 0 {
+		t.Fatal(errno)
+	}
+	var a fuse.Attr
+	p.fillAttr(&a)
+	if a.Blocks != 16 {
+		t.Errorf("got %d blocks, want 16", a.Blocks)
+	}
+
+	buf := make([]byte, 3*memPageSize)
+	p.readAt(buf, 0)
+	want := bytes.Repeat([]byte{'x'}, 3*memPageSize)
+	clear(want[100 : 100+2*memPageSize])
+	if !bytes.Equal(buf, want) {
+		t.Errorf("zero range: contents mismatch")
+	}
+}
+
+func TestMemPagesReserve(t *testing.T) {
+	p := memPages{
+		reserve: func(delta int64) syscall.Errno {
+			if delta > 1 {
+				return syscall.ENOSPC
+			}
+			return 0
+		},
+	}
+	if errno := p.writeAt(make([]byte, 2*memPageSize), 0); errno != syscall.ENOSPC {
+		t.Errorf("got %v, want ENOSPC", errno)
+	}
+	if got := p.fileSize(); got != 0 {
+		t.Errorf("failed write changed size to %d", got)
+	}
+}
+
+func TestMemRegularFileBytes(t *testing.T) {
+	data := bytes.Repeat([]byte("hello"), memPageSize)
+	f := &MemRegularFile{Data: data}
+	if _, errno := f.Write(context.Background(), nil, []byte("J"), 0); errno != 0 {
+		t.Fatal(errno)
+	}
+	if got := f.Bytes(); string(got[:5]) != "Jello" || len(got) != len(data) {
+		t.Errorf("Bytes: got %q...", got[:5])
+	}
+	if string(f.Data[:5]) != "hello" || &f.Data[0] != &data[0] {
+		t.Errorf("write changed Data to %q...", f.Data[:5])
+	}
+}
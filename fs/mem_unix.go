@@ -6,6 +6,13 @@
 
 package fs
 
-func keepSizeMode(mode uint32) bool {
-	return false
-}
+const (
+	// Only Linux passes a mode to fallocate; these are its values.
+	fallocKeepSize  = 0x1
+	fallocPunchHole = 0x2
+	fallocZeroRange = 0x10
+
+	// Flags for setxattr are not interpreted on other platforms.
+	xattrCreate  = 0
+	xattrReplace = 0
+)
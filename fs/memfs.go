@@ -0,0 +1,780 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+const (
+	// memfsBlockSize is the unit of space accounting in MemFS.
+	memfsBlockSize = memPageSize
+
+	// memfsNameMax is the maximum length of a file name.
+	memfsNameMax = 255
+)
+
+// MemFSOptions configures a MemFS.
+type MemFSOptions struct {
+	// Capacity is the maximum number of bytes of file data,
+	// counted in whole pages of 4096 bytes. Holes in sparse files
+	// take no space. If zero, the size is unlimited.
+	Capacity uint64
+
+	// MaxInodes is the maximum number of inodes, including the
+	// root directory. If zero, the number is unlimited.
+	MaxInodes uint64
+
+	// Mode holds the permission bits of the root directory. If
+	// zero, 0755 is used.
+	Mode uint32
+}
+
+// MemFS is an in-memory file system, similar to tmpfs. It supports
+// directories, regular files, symlinks, hard links, device nodes,
+// FIFOs, sockets, extended attributes and rename flags, and
+// maintains link counts and timestamps. It should be mounted as the
+// root of the file system:
+//
+//	root := fs.NewMemFS(&fs.MemFSOptions{Capacity: 1 << 30})
+//	server, err := fs.Mount(dir, root, &fs.Options{})
+//
+// The root directory is owned by the uid and gid of the process;
+// other nodes are owned by the caller creating them. Contents are
+// lost when the file system is unmounted.
+type MemFS struct {
+	memfsDir
+
+	opts MemFSOptions
+
+	// mu protects the fields below. It may be acquired while
+	// holding the lock of a node, but not the other way around.
+	mu      sync.Mutex
+	lastIno uint64
+	inodes  uint64
+	blocks  uint64
+}
+
+// NewMemFS returns the root of a new, empty MemFS. The options may
+// be nil.
+func NewMemFS(opts *MemFSOptions) *MemFS {
+	root := &MemFS{}
+	if opts != nil {
+		root.opts = *opts
+	}
+	mode := root.opts.Mode & 07777
+	if mode == 0 {
+		mode = 0755
+	}
+	root.fs = root
+	root.lastIno = 1
+	root.inodes = 1
+	root.initAttr(fuse.S_IFDIR|mode, uint32(os.Getuid()), uint32(os.Getgid()))
+	root.attr.Ino = 1
+	return root
+}
+
+// allocIno reserves an inode.
+func (fs *MemFS) allocIno() (uint64, syscall.Errno) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.opts.MaxInodes > 0 && fs.inodes >= fs.opts.MaxInodes {
+		return 0, syscall.ENOSPC
+	}
+	fs.inodes++
+	fs.lastIno++
+	return fs.lastIno, OK
+}
+
+// hasRoomLocked returns if the file system can grow by the given number
+// of blocks. It must be called with fs.mu held.
+func (fs *MemFS) hasRoomLocked(blocks uint64) bool {
+	return fs.opts.Capacity == 0 || (fs.blocks+blocks)*memfsBlockSize <= fs.opts.Capacity
+}
+
+// reserve accounts for file data growing or shrinking by delta
+// blocks.
+func (fs *MemFS) reserve(delta int64) syscall.Errno {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if delta > 0 && !fs.hasRoomLocked(uint64(delta)) {
+		return syscall.ENOSPC
+	}
+	fs.blocks = uint64(int64(fs.blocks) + delta)
+	return OK
+}
+
+func (fs *MemFS) statfs(out *fuse.StatfsOut) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	out.Bsize = memfsBlockSize
+	out.Frsize = memfsBlockSize
+	out.NameLen = memfsNameMax
+	if fs.opts.Capacity > 0 {
+		out.Blocks = fs.opts.Capacity / memfsBlockSize
+		out.Bfree = out.Blocks - min(fs.blocks, out.Blocks)
+		out.Bavail = out.Bfree
+	}
+	if fs.opts.MaxInodes > 0 {
+		out.Files = fs.opts.MaxInodes
+		out.Ffree = fs.opts.MaxInodes - min(fs.inodes, fs.opts.MaxInodes)
+	}
+}
+
+// memfsNode holds the state common to all nodes of a MemFS. By
+// itself, it is used for device nodes, FIFOs and sockets.
+type memfsNode struct {
+	Inode
+
+	fs *MemFS
+
+	mu     sync.Mutex
+	attr   fuse.Attr
+	xattrs map[string][]byte
+
+	// released is set once a deleted node has returned its
+	// resources to the file system.
+	released bool
+}
+
+// memfsNoder is implemented by all node types of MemFS.
+type memfsNoder interface {
+	InodeEmbedder
+	node() *memfsNode
+}
+
+func (n *memfsNode) node() *memfsNode {
+	return n
+}
+
+// memfsNodeOf returns the MemFS node for an Inode, or nil if the
+// Inode was added to the tree by other means.
+func memfsNodeOf(ch *Inode) *memfsNode {
+	if mn, ok := ch.Operations().(memfsNoder); ok {
+		return mn.node()
+	}
+	return nil
+}
+
+func (n *memfsNode) initAttr(mode uint32, uid, gid uint32) {
+	n.attr.Mode = mode
+	n.attr.Uid = uid
+	n.attr.Gid = gid
+	n.attr.Nlink = 1
+	if mode&syscall.S_IFMT == syscall.S_IFDIR {
+		n.attr.Nlink = 2
+	}
+	now := time.Now()
+	n.attr.SetTimes(&now, &now, &now)
+}
+
+const (
+	memfsAtime = 1 << iota
+	memfsMtime
+	memfsCtime
+)
+
+// touchLocked sets the selected timestamps to the current time. It
+// must be called with n.mu held.
+func (n *memfsNode) touchLocked(which int) {
+	now := time.Now()
+	var a, m, c *time.Time
+	if which&memfsAtime != 0 {
+		a = &now
+	}
+	if which&memfsMtime != 0 {
+		m = &now
+	}
+	if which&memfsCtime != 0 {
+		c = &now
+	}
+	n.attr.SetTimes(a, m, c)
+}
+
+func (n *memfsNode) touch(which int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.touchLocked(which)
+}
+
+// addLinks changes the link count of a directory when
+// subdirectories come and go.
+func (n *memfsNode) addLinks(delta int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.attr.Nlink = uint32(int(n.attr.Nlink) + delta)
+}
+
+// dropLink removes a directory entry for n. Once there are no
+// entries left, the node goes away as soon as the kernel forgets it.
+func (n *memfsNode) dropLink() {
+	n.mu.Lock()
+	if n.IsDir() || n.attr.Nlink <= 1 {
+		n.attr.Nlink = 0
+	} else {
+		n.attr.Nlink--
+	}
+	n.touchLocked(memfsCtime)
+	gone := n.attr.Nlink == 0
+	n.mu.Unlock()
+
+	if gone {
+		n.ForgetPersistent()
+	}
+}
+
+func (n *memfsNode) fillEntry(out *fuse.EntryOut) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	out.Attr = n.attr
+}
+
+// release returns the resources of a deleted node, calling free with
+// n.mu held.
+func (n *memfsNode) release(free func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.attr.Nlink > 0 || n.released {
+		return
+	}
+	n.released = true
+	if free != nil {
+		free()
+	}
+	n.fs.mu.Lock()
+	n.fs.inodes--
+	n.fs.mu.Unlock()
+}
+
+var _ = (NodeOnForgetter)((*memfsNode)(nil))
+
+func (n *memfsNode) OnForget() {
+	n.release(nil)
+}
+
+var _ = (NodeGetattrer)((*memfsNode)(nil))
+
+func (n *memfsNode) Getattr(ctx context.Context, f FileHandle, out *fuse.AttrOut) syscall.Errno {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	out.Attr = n.attr
+	return OK
+}
+
+var _ = (NodeSetattrer)((*memfsNode)(nil))
+
+func (n *memfsNode) Setattr(ctx context.Context, f FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if _, ok := in.GetSize(); ok {
+		if n.IsDir() {
+			return syscall.EISDIR
+		}
+		return syscall.EINVAL
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.setattrLocked(in)
+	out.Attr = n.attr
+	return OK
+}
+
+// setattrLocked applies everything but the size.
+func (n *memfsNode) setattrLocked(in *fuse.SetAttrIn) {
+	if mode, ok := in.GetMode(); ok {
+		n.attr.Mode = n.attr.Mode&syscall.S_IFMT | mode&07777
+	}
+	if uid, ok := in.GetUID(); ok {
+		n.attr.Uid = uid
+	}
+	if gid, ok := in.GetGID(); ok {
+		n.attr.Gid = gid
+	}
+	if atime, ok := in.GetATime(); ok {
+		n.attr.SetTimes(&atime, nil, nil)
+	}
+	if mtime, ok := in.GetMTime(); ok {
+		n.attr.SetTimes(nil, &mtime, nil)
+	}
+	if ctime, ok := in.GetCTime(); ok {
+		n.attr.SetTimes(nil, nil, &ctime)
+	} else {
+		n.touchLocked(memfsCtime)
+	}
+}
+
+var _ = (NodeGetxattrer)((*memfsNode)(nil))
+
+func (n *memfsNode) Getxattr(ctx context.Context, attr string, dest []byte) (uint32, syscall.Errno) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	val, ok := n.xattrs[attr]
+	if !ok {
+		return 0, ENOATTR
+	}
+	if len(dest) == 0 {
+		return uint32(len(val)), OK
+	}
+	if len(dest) < len(val) {
+		return uint32(len(val)), syscall.ERANGE
+	}
+	return uint32(copy(dest, val)), OK
+}
+
+var _ = (NodeSetxattrer)((*memfsNode)(nil))
+
+func (n *memfsNode) Setxattr(ctx context.Context, attr string, data []byte, flags uint32) syscall.Errno {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, ok := n.xattrs[attr]
+	if ok && flags&xattrCreate != 0 {
+		return syscall.EEXIST
+	}
+	if !ok && flags&xattrReplace != 0 {
+		return ENOATTR
+	}
+	if n.xattrs == nil {
+		n.xattrs = map[string][]byte{}
+	}
+	n.xattrs[attr] = bytes.Clone(data)
+	n.touchLocked(memfsCtime)
+	return OK
+}
+
+var _ = (NodeRemovexattrer)((*memfsNode)(nil))
+
+func (n *memfsNode) Removexattr(ctx context.Context, attr string) syscall.Errno {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.xattrs[attr]; !ok {
+		return ENOATTR
+	}
+	delete(n.xattrs, attr)
+	n.touchLocked(memfsCtime)
+	return OK
+}
+
+var _ = (NodeListxattrer)((*memfsNode)(nil))
+
+func (n *memfsNode) Listxattr(ctx context.Context, dest []byte) (uint32, syscall.Errno) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	names := make([]string, 0, len(n.xattrs))
+	sz := 0
+	for k := range n.xattrs {
+		names = append(names, k)
+		sz += len(k) + 1
+	}
+	if len(dest) == 0 {
+		return uint32(sz), OK
+	}
+	if len(dest) < sz {
+		return uint32(sz), syscall.ERANGE
+	}
+	sort.Strings(names)
+	dest = dest[:0]
+	for _, k := range names {
+		dest = append(dest, k...)
+		dest = append(dest, 0)
+	}
+	return uint32(sz), OK
+}
+
+var _ = (NodeStatfser)((*memfsNode)(nil))
+
+func (n *memfsNode) Statfs(ctx context.Context, out *fuse.StatfsOut) syscall.Errno {
+	n.fs.statfs(out)
+	return OK
+}
+
+var _ = (NodeFsyncer)((*memfsNode)(nil))
+
+func (n *memfsNode) Fsync(ctx context.Context, f FileHandle, flags uint32) syscall.Errno {
+	return OK
+}
+
+// memfsDir is a directory. Its entries are the children of the
+// Inode.
+type memfsDir struct {
+	memfsNode
+}
+
+func (d *memfsDir) dir() *memfsDir {
+	return d
+}
+
+// newChild sets up a new node in the directory. The bridge adds it
+// to the tree once the operation succeeds.
+func (d *memfsDir) newChild(ctx context.Context, name string, mode uint32, ops memfsNoder) (*Inode, syscall.Errno) {
+	if len(name) > memfsNameMax {
+		return nil, syscall.ENAMETOOLONG
+	}
+	if d.GetChild(name) != nil {
+		return nil, syscall.EEXIST
+	}
+	ino, errno := d.fs.allocIno()
+	if errno != 0 {
+		return nil, errno
+	}
+
+	uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+	if caller, ok := fuse.FromContext(ctx); ok {
+		uid, gid = caller.Uid, caller.Gid
+	}
+	d.mu.Lock()
+	if d.attr.Mode&syscall.S_ISGID != 0 {
+		gid = d.attr.Gid
+		if mode&syscall.S_IFMT == syscall.S_IFDIR {
+			mode |= syscall.S_ISGID
+		}
+	}
+	d.touchLocked(memfsMtime | memfsCtime)
+	d.mu.Unlock()
+
+	n := ops.node()
+	n.fs = d.fs
+	n.initAttr(mode, uid, gid)
+	n.attr.Ino = ino
+	return d.NewPersistentInode(ctx, ops, StableAttr{Mode: mode & syscall.S_IFMT, Ino: ino}), OK
+}
+
+var _ = (NodeMkdirer)((*memfsDir)(nil))
+
+func (d *memfsDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	ch := &memfsDir{}
+	n, errno := d.newChild(ctx, name, fuse.S_IFDIR|mode&07777, ch)
+	if errno != 0 {
+		return nil, errno
+	}
+	d.addLinks(1)
+	ch.fillEntry(out)
+	return n, OK
+}
+
+var _ = (NodeCreater)((*memfsDir)(nil))
+
+func (d *memfsDir) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*Inode, FileHandle, uint32, syscall.Errno) {
+	ch := d.newFile()
+	n, errno := d.newChild(ctx, name, fuse.S_IFREG|mode&07777, ch)
+	if errno != 0 {
+		return nil, nil, 0, errno
+	}
+	ch.fillEntry(out)
+	return n, nil, 0, OK
+}
+
+var _ = (NodeMknoder)((*memfsDir)(nil))
+
+func (d *memfsDir) Mknod(ctx context.Context, name string, mode uint32, dev uint32, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	var ch memfsNoder
+	switch mode & syscall.S_IFMT {
+	case 0:
+		mode |= syscall.S_IFREG
+		ch = d.newFile()
+	case syscall.S_IFREG:
+		ch = d.newFile()
+	case syscall.S_IFIFO, syscall.S_IFSOCK, syscall.S_IFCHR, syscall.S_IFBLK:
+		ch = &memfsNode{}
+	default:
+		return nil, syscall.EINVAL
+	}
+	n, errno := d.newChild(ctx, name, mode&(syscall.S_IFMT|07777), ch)
+	if errno != 0 {
+		return nil, errno
+	}
+	ch.node().attr.Rdev = dev
+	ch.node().fillEntry(out)
+	return n, OK
+}
+
+var _ = (NodeSymlinker)((*memfsDir)(nil))
+
+func (d *memfsDir) Symlink(ctx context.Context, target, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	ch := &memfsSymlink{target: []byte(target)}
+	n, errno := d.newChild(ctx, name, fuse.S_IFLNK|0777, ch)
+	if errno != 0 {
+		return nil, errno
+	}
+	ch.attr.Size = uint64(len(target))
+	ch.fillEntry(out)
+	return n, OK
+}
+
+var _ = (NodeLinker)((*memfsDir)(nil))
+
+func (d *memfsDir) Link(ctx context.Context, target InodeEmbedder, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	t, ok := target.(memfsNoder)
+	if !ok || t.node().fs != d.fs {
+		return nil, syscall.EXDEV
+	}
+	n := t.node()
+	if n.IsDir() {
+		return nil, syscall.EPERM
+	}
+	if len(name) > memfsNameMax {
+		return nil, syscall.ENAMETOOLONG
+	}
+	if d.GetChild(name) != nil {
+		return nil, syscall.EEXIST
+	}
+
+	n.mu.Lock()
+	if n.attr.Nlink == 0 {
+		n.mu.Unlock()
+		return nil, syscall.ENOENT
+	}
+	n.attr.Nlink++
+	n.touchLocked(memfsCtime)
+	out.Attr = n.attr
+	n.mu.Unlock()
+
+	d.touch(memfsMtime | memfsCtime)
+	return n.EmbeddedInode(), OK
+}
+
+var _ = (NodeUnlinker)((*memfsDir)(nil))
+
+func (d *memfsDir) Unlink(ctx context.Context, name string) syscall.Errno {
+	ch := d.GetChild(name)
+	if ch == nil {
+		return syscall.ENOENT
+	}
+	if ch.IsDir() {
+		return syscall.EISDIR
+	}
+	if n := memfsNodeOf(ch); n != nil {
+		n.dropLink()
+	}
+	d.touch(memfsMtime | memfsCtime)
+	return OK
+}
+
+var _ = (NodeRmdirer)((*memfsDir)(nil))
+
+func (d *memfsDir) Rmdir(ctx context.Context, name string) syscall.Errno {
+	ch := d.GetChild(name)
+	if ch == nil {
+		return syscall.ENOENT
+	}
+	if !ch.IsDir() {
+		return syscall.ENOTDIR
+	}
+	if len(ch.Children()) > 0 {
+		return syscall.ENOTEMPTY
+	}
+	if n := memfsNodeOf(ch); n != nil {
+		n.dropLink()
+	}
+	d.addLinks(-1)
+	d.touch(memfsMtime | memfsCtime)
+	return OK
+}
+
+var _ = (NodeRenamer)((*memfsDir)(nil))
+
+func (d *memfsDir) Rename(ctx context.Context, name string, newParent InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	if flags&^(RENAME_NOREPLACE|RENAME_EXCHANGE) != 0 || flags == RENAME_NOREPLACE|RENAME_EXCHANGE {
+		return syscall.EINVAL
+	}
+	np, ok := newParent.(interface{ dir() *memfsDir })
+	if !ok || np.dir().fs != d.fs {
+		return syscall.EXDEV
+	}
+	nd := np.dir()
+	if len(newName) > memfsNameMax {
+		return syscall.ENAMETOOLONG
+	}
+
+	src := d.GetChild(name)
+	if src == nil {
+		return syscall.ENOENT
+	}
+	dst := nd.GetChild(newName)
+
+	if flags&RENAME_EXCHANGE != 0 {
+		if dst == nil {
+			return syscall.ENOENT
+		}
+		if d != nd && src.IsDir() != dst.IsDir() {
+			// A subdirectory trades places with a non-directory.
+			delta := 1
+			if dst.IsDir() {
+				delta = -1
+			}
+			d.addLinks(-delta)
+			nd.addLinks(delta)
+		}
+		for _, ch := range []*Inode{src, dst} {
+			if n := memfsNodeOf(ch); n != nil {
+				n.touch(memfsCtime)
+			}
+		}
+	} else {
+		if dst != nil {
+			if flags&RENAME_NOREPLACE != 0 {
+				return syscall.EEXIST
+			}
+			if src.IsDir() && !dst.IsDir() {
+				return syscall.ENOTDIR
+			}
+			if !src.IsDir() && dst.IsDir() {
+				return syscall.EISDIR
+			}
+			if dst.IsDir() {
+				if len(dst.Children()) > 0 {
+					return syscall.ENOTEMPTY
+				}
+				nd.addLinks(-1)
+			}
+			if n := memfsNodeOf(dst); n != nil {
+				n.dropLink()
+			}
+		}
+		if src.IsDir() && d != nd {
+			d.addLinks(-1)
+			nd.addLinks(1)
+		}
+		if n := memfsNodeOf(src); n != nil {
+			n.touch(memfsCtime)
+		}
+	}
+
+	d.touch(memfsMtime | memfsCtime)
+	if nd != d {
+		nd.touch(memfsMtime | memfsCtime)
+	}
+	return OK
+}
+
+// memfsFile is a regular file.
+type memfsFile struct {
+	memfsNode
+	pages memPages
+}
+
+func (d *memfsDir) newFile() *memfsFile {
+	f := &memfsFile{}
+	f.pages.reserve = d.fs.reserve
+	return f
+}
+
+var _ = (NodeOnForgetter)((*memfsFile)(nil))
+
+func (f *memfsFile) OnForget() {
+	f.release(func() {
+		f.pages.truncate(0)
+	})
+}
+
+// updateLocked refreshes the size and block count after a change to
+// the contents, and touches the given times. It must be called with
+// f.mu held.
+func (f *memfsFile) updateLocked(what int) {
+	f.pages.fillAttr(&f.attr)
+	f.touchLocked(what)
+}
+
+var _ = (NodeSetattrer)((*memfsFile)(nil))
+
+func (f *memfsFile) Setattr(ctx context.Context, fh FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if sz, ok := in.GetSize(); ok {
+		f.pages.truncate(int64(sz))
+		f.updateLocked(memfsMtime | memfsCtime)
+	}
+	f.setattrLocked(in)
+	out.Attr = f.attr
+	return OK
+}
+
+var _ = (NodeOpener)((*memfsFile)(nil))
+
+func (f *memfsFile) Open(ctx context.Context, flags uint32) (FileHandle, uint32, syscall.Errno) {
+	return nil, fuse.FOPEN_KEEP_CACHE, OK
+}
+
+var _ = (NodeReader)((*memfsFile)(nil))
+
+func (f *memfsFile) Read(ctx context.Context, fh FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	n := f.pages.readAt(dest, off)
+	f.touch(memfsAtime)
+	return fuse.ReadResultData(dest[:n]), OK
+}
+
+var _ = (NodeWriter)((*memfsFile)(nil))
+
+func (f *memfsFile) Write(ctx context.Context, fh FileHandle, data []byte, off int64) (uint32, syscall.Errno) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if errno := f.pages.writeAt(data, off); errno != 0 {
+		return 0, errno
+	}
+	f.updateLocked(memfsMtime | memfsCtime)
+	return uint32(len(data)), OK
+}
+
+var _ = (NodeFlusher)((*memfsFile)(nil))
+
+func (f *memfsFile) Flush(ctx context.Context, fh FileHandle) syscall.Errno {
+	return OK
+}
+
+var _ = (NodeAllocater)((*memfsFile)(nil))
+
+func (f *memfsFile) Allocate(ctx context.Context, fh FileHandle, off uint64, size uint64, mode uint32) syscall.Errno {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if errno := f.pages.fallocate(int64(off), int64(size), mode); errno != 0 {
+		return errno
+	}
+	f.updateLocked(memfsMtime | memfsCtime)
+	return OK
+}
+
+var _ = (NodeLseeker)((*memfsFile)(nil))
+
+func (f *memfsFile) Lseek(ctx context.Context, fh FileHandle, off uint64, whence uint32) (uint64, syscall.Errno) {
+	res, errno := f.pages.seek(int64(off), whence)
+	return uint64(res), errno
+}
+
+var _ = (NodeCopyFileRanger)((*memfsFile)(nil))
+
+func (f *memfsFile) CopyFileRange(ctx context.Context, fhIn FileHandle, offIn uint64, out *Inode, fhOut FileHandle, offOut uint64, sz uint64, flags uint64) (uint32, syscall.Errno) {
+	dst, ok := out.Operations().(*memfsFile)
+	if !ok || dst.fs != f.fs {
+		return 0, syscall.EXDEV
+	}
+	avail := f.pages.fileSize() - int64(offIn)
+	if avail <= 0 {
+		return 0, OK
+	}
+	data := make([]byte, min(int64(sz), avail))
+	n := f.pages.readAt(data, int64(offIn))
+	if n == 0 {
+		return 0, OK
+	}
+	return dst.Write(ctx, fhOut, data[:n], int64(offOut))
+}
+
+// memfsSymlink is a symbolic link.
+type memfsSymlink struct {
+	memfsNode
+	target []byte
+}
+
+var _ = (NodeReadlinker)((*memfsSymlink)(nil))
+
+func (l *memfsSymlink) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
+	l.touch(memfsAtime)
+	return l.target, OK
+}
@@ -0,0 +1,132 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestMemFSRenameFlags(t *testing.T) {
+	mnt, _ := testMount(t, NewMemFS(nil), &Options{})
+	if err := os.WriteFile(mnt+"/file", []byte("file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(mnt+"/dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(mnt+"/dir/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	err := unix.Renameat2(unix.AT_FDCWD, mnt+"/file", unix.AT_FDCWD, mnt+"/dir", unix.RENAME_NOREPLACE)
+	if err != unix.EEXIST {
+		t.Errorf("RENAME_NOREPLACE: got %v, want EEXIST", err)
+	}
+	if err := syscall.Rename(mnt+"/file", mnt+"/dir"); err != syscall.EISDIR {
+		t.Errorf("Rename file over dir: got %v, want EISDIR", err)
+	}
+
+	err = unix.Renameat2(unix.AT_FDCWD, mnt+"/file", unix.AT_FDCWD, mnt+"/dir/sub", unix.RENAME_EXCHANGE)
+	if err != nil {
+		t.Fatalf("RENAME_EXCHANGE: %v", err)
+	}
+	if fi, err := os.Lstat(mnt + "/file"); err != nil || !fi.IsDir() {
+		t.Errorf("file should be a directory now: %v, %v", fi, err)
+	}
+	if got, err := os.ReadFile(mnt + "/dir/sub"); err != nil || string(got) != "file" {
+		t.Errorf("ReadFile: %q, %v", got, err)
+	}
+	if got := memfsNlink(t, mnt+"/dir"); got != 2 {
+		t.Errorf("dir: got nlink %d, want 2", got)
+	}
+	if got := memfsNlink(t, mnt); got != 4 {
+		t.Errorf("root: got nlink %d, want 4", got)
+	}
+}
+
+func TestMemFSXAttrFlags(t *testing.T) {
+	mnt, _ := testMount(t, NewMemFS(nil), &Options{})
+	fn := mnt + "/file"
+	if err := os.WriteFile(fn, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Setxattr(fn, "user.a", []byte("1"), unix.XATTR_REPLACE); err != unix.ENODATA {
+		t.Errorf("XATTR_REPLACE: got %v, want ENODATA", err)
+	}
+	if err := unix.Setxattr(fn, "user.a", []byte("1"), unix.XATTR_CREATE); err != nil {
+		t.Fatalf("XATTR_CREATE: %v", err)
+	}
+	if err := unix.Setxattr(fn, "user.a", []byte("2"), unix.XATTR_CREATE); err != unix.EEXIST {
+		t.Errorf("XATTR_CREATE: got %v, want EEXIST", err)
+	}
+	if err := unix.Setxattr(fn, "user.b", []byte("22"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1)
+	if _, err := unix.Getxattr(fn, "user.b", buf); err != unix.ERANGE {
+		t.Errorf("Getxattr with short buffer: got %v, want ERANGE", err)
+	}
+	buf = make([]byte, 64)
+	if n, err := unix.Listxattr(fn, buf); err != nil || string(buf[:n]) != "user.a\x00user.b\x00" {
+		t.Errorf("Listxattr: %q, %v", buf[:n], err)
+	}
+	if err := unix.Removexattr(fn, "user.a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unix.Getxattr(fn, "user.a", buf); err != unix.ENODATA {
+		t.Errorf("Getxattr after remove: got %v, want ENODATA", err)
+	}
+}
+
+func TestMemFSPunchHole(t *testing.T) {
+	mnt, _ := testMount(t, NewMemFS(&MemFSOptions{Capacity: 4 * memfsBlockSize}), &Options{})
+	f, err := os.Create(mnt + "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// A sparse file larger than the capacity fits, as holes take no
+	// space.
+	if _, err := f.WriteAt([]byte("data"), 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Fallocate(int(f.Fd()), 0, 2*memfsBlockSize, memfsBlockSize); err != nil {
+		t.Fatal(err)
+	}
+
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(f.Fd()), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Size != 1<<20+4 || st.Blocks != 16 {
+		t.Errorf("got size %d, blocks %d, want %d, 16", st.Size, st.Blocks, 1<<20+4)
+	}
+
+	if err := unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, 2*memfsBlockSize, memfsBlockSize); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Fstat(int(f.Fd()), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Blocks != 8 {
+		t.Errorf("after punching hole: got %d blocks, want 8", st.Blocks)
+	}
+	if off, err := unix.Seek(int(f.Fd()), 0, unix.SEEK_DATA); err != nil || off != 1<<20 {
+		t.Errorf("SEEK_DATA: got %d, %v, want %d", off, err, 1<<20)
+	}
+	if off, err := unix.Seek(int(f.Fd()), 1<<20, unix.SEEK_HOLE); err != nil || off != 1<<20+4 {
+		t.Errorf("SEEK_HOLE: got %d, %v, want %d", off, err, 1<<20+4)
+	}
+	buf := make([]byte, 4)
+	if _, err := f.ReadAt(buf, 1<<20); err != nil || string(buf) != "data" {
+		t.Errorf("ReadAt: got %q, %v", buf, err)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/posixtest"
+)
+
+func TestMemFSPosix(t *testing.T) {
+	for nm, fn := range posixtest.All {
+		if nm == "FcntlFlockLocksFile" {
+			// Expects two descriptors of one process to
+			// conflict, which only holds for OFD locks. See
+			// posixtest.TestAll.
+			continue
+		}
+		t.Run(nm, func(t *testing.T) {
+			mnt, _ := testMount(t, NewMemFS(nil), &Options{})
+			fn(t, mnt)
+		})
+	}
+}
+
+func memfsNlink(t *testing.T, path string) uint64 {
+	t.Helper()
+	var st syscall.Stat_t
+	if err := syscall.Lstat(path, &st); err != nil {
+		t.Fatalf("Lstat(%q): %v", path, err)
+	}
+	return uint64(st.Nlink)
+}
+
+func TestMemFSNlink(t *testing.T) {
+	mnt, _ := testMount(t, NewMemFS(nil), &Options{})
+
+	for _, d := range []string{"a", "a/sub", "b"} {
+		if err := os.Mkdir(filepath.Join(mnt, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(mnt+"/a/file", []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(mnt+"/a/file", mnt+"/b/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		path string
+		want uint64
+	}{
+		{"", 4},
+		{"a", 3},
+		{"a/sub", 2},
+		{"b", 2},
+		{"a/file", 2},
+	} {
+		if got := memfsNlink(t, filepath.Join(mnt, c.path)); got != c.want {
+			t.Errorf("%q: got nlink %d, want %d", c.path, got, c.want)
+		}
+	}
+
+	if err := os.Rename(mnt+"/a/sub", mnt+"/b/sub"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(mnt + "/a/file"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		path string
+		want uint64
+	}{
+		{"a", 2},
+		{"b", 3},
+		{"b/link", 1},
+	} {
+		if got := memfsNlink(t, filepath.Join(mnt, c.path)); got != c.want {
+			t.Errorf("%q: got nlink %d, want %d", c.path, got, c.want)
+		}
+	}
+	if got, err := os.ReadFile(mnt + "/b/link"); err != nil || string(got) != "hello" {
+		t.Errorf("ReadFile: %q, %v", got, err)
+	}
+
+	if err := syscall.Rmdir(mnt + "/b"); err != syscall.ENOTEMPTY {
+		t.Errorf("Rmdir: got %v, want ENOTEMPTY", err)
+	}
+}
+
+func TestMemFSTimes(t *testing.T) {
+	mnt, _ := testMount(t, NewMemFS(nil), &Options{})
+	stat := func(p string) (a fuse.Attr) {
+		t.Helper()
+		var st syscall.Stat_t
+		if err := syscall.Lstat(p, &st); err != nil {
+			t.Fatal(err)
+		}
+		a.FromStat(&st)
+		return a
+	}
+
+	before := stat(mnt)
+	time.Sleep(10 * time.Millisecond)
+	if err := os.WriteFile(mnt+"/file", []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	after := stat(mnt)
+	if after.ModTime().Equal(before.ModTime()) || after.ChangeTime().Equal(before.ChangeTime()) {
+		t.Errorf("directory times unchanged after create: %v -> %v", before.ModTime(), after.ModTime())
+	}
+
+	before = stat(mnt + "/file")
+	time.Sleep(10 * time.Millisecond)
+	if err := os.Chmod(mnt+"/file", 0600); err != nil {
+		t.Fatal(err)
+	}
+	after = stat(mnt + "/file")
+	if after.ChangeTime().Equal(before.ChangeTime()) {
+		t.Errorf("ctime unchanged after chmod")
+	}
+	if !after.ModTime().Equal(before.ModTime()) {
+		t.Errorf("mtime changed after chmod")
+	}
+}
+
+func TestMemFSCapacity(t *testing.T) {
+	root := NewMemFS(&MemFSOptions{Capacity: 2 * memfsBlockSize, MaxInodes: 3})
+	mnt, _ := testMount(t, root, &Options{})
+
+	statfs := func() (st syscall.Statfs_t) {
+		t.Helper()
+		if err := syscall.Statfs(mnt, &st); err != nil {
+			t.Fatal(err)
+		}
+		return st
+	}
+	if st := statfs(); st.Blocks != 2 || st.Bfree != 2 || st.Files != 3 || st.Ffree != 2 {
+		t.Errorf("got statfs %+v", st)
+	}
+
+	data := bytes.Repeat([]byte{'x'}, 2*memfsBlockSize)
+	if err := os.WriteFile(mnt+"/a", data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if st := statfs(); st.Bfree != 0 || st.Ffree != 1 {
+		t.Errorf("got statfs %+v", st)
+	}
+	if err := os.WriteFile(mnt+"/b", []byte("x"), 0644); !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("write to full fs: got %v, want ENOSPC", err)
+	}
+	if err := os.Mkdir(mnt+"/c", 0755); !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("Mkdir with no inodes left: got %v, want ENOSPC", err)
+	}
+
+	// Deleted files release their space once the kernel forgets them.
+	if err := os.Remove(mnt + "/a"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for statfs().Bfree != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("space not released: %+v", statfs())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// NotifyError describes an asynchronous notification that failed.
+// See Options.AsyncNotify.
+type NotifyError struct {
+	// Op is one of "entry", "delete", "content" or "prune".
+	Op string
+
+	// NodeId is the node the notification was for: the parent
+	// directory for "entry" and "delete". It is zero for "prune".
+	NodeId uint64
+
+	// Name is the entry name for "entry" and "delete".
+	Name string
+
+	Errno syscall.Errno
+}
+
+func (e *NotifyError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("notify %s n%d %q: %v", e.Op, e.NodeId, e.Name, e.Errno)
+	}
+	return fmt.Sprintf("notify %s n%d: %v", e.Op, e.NodeId, e.Errno)
+}
+
+type notifyOp int
+
+const (
+	notifyEntry = notifyOp(iota)
+	notifyDelete
+	notifyContent
+)
+
+var notifyOpNames = [...]string{
+	notifyEntry:   "entry",
+	notifyDelete:  "delete",
+	notifyContent: "content",
+}
+
+// notifyKey identifies notifications that can be coalesced.
+type notifyKey struct {
+	op    notifyOp
+	node  *Inode
+	child uint64
+	name  string
+}
+
+// notifyItem is a queued notification. For content notifications,
+// off < 0 invalidates attributes only, and end < 0 means until the
+// end of the file.
+type notifyItem struct {
+	notifyKey
+	off, end int64
+}
+
+// pruneBatchSize bounds the size of a single NOTIFY_PRUNE message.
+const pruneBatchSize = 512
+
+// notifier sends notifications from a separate goroutine, so they
+// can be issued from within operation handlers without deadlocking
+// against the kernel.
+type notifier struct {
+	bridge  *rawBridge
+	onError func(*NotifyError)
+
+	mu      sync.Mutex
+	cond    sync.Cond
+	queue   []*notifyItem
+	pending map[notifyKey]*notifyItem
+	prune   []*Inode
+	pruned  map[*Inode]bool
+	running bool
+	closed  bool
+
+	// busy is set while the worker sends a batch.
+	busy bool
+}
+
+func newNotifier(b *rawBridge, onError func(*NotifyError)) *notifier {
+	nt := &notifier{
+		bridge:  b,
+		onError: onError,
+		pending: map[notifyKey]*notifyItem{},
+		pruned:  map[*Inode]bool{},
+	}
+	nt.cond.L = &nt.mu
+	return nt
+}
+
+// enqueue adds a notification, merging it with a pending one if
+// possible.
+func (nt *notifier) enqueue(it *notifyItem) syscall.Errno {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	if nt.closed {
+		return syscall.ENOTCONN
+	}
+	if old := nt.pending[it.notifyKey]; old != nil {
+		if it.op == notifyContent {
+			old.merge(it)
+		}
+		return OK
+	}
+	nt.pending[it.notifyKey] = it
+	nt.queue = append(nt.queue, it)
+	nt.startLocked()
+	return OK
+}
+
+func (nt *notifier) enqueuePrune(nodes []*Inode) syscall.Errno {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	if nt.closed {
+		return syscall.ENOTCONN
+	}
+	for _, n := range nodes {
+		if !nt.pruned[n] {
+			nt.pruned[n] = true
+			nt.prune = append(nt.prune, n)
+		}
+	}
+	nt.startLocked()
+	return OK
+}
+
+// merge widens the content range of it to include o.
+func (it *notifyItem) merge(o *notifyItem) {
+	if o.off < 0 {
+		return
+	}
+	if it.off < 0 {
+		it.off, it.end = o.off, o.end
+		return
+	}
+	it.off = min(it.off, o.off)
+	if it.end >= 0 && (o.end < 0 || o.end > it.end) {
+		it.end = o.end
+	}
+}
+
+func (nt *notifier) startLocked() {
+	if !nt.running {
+		nt.running = true
+		go nt.loop()
+	}
+	nt.cond.Broadcast()
+}
+
+func (nt *notifier) loop() {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	for {
+		for !nt.closed && len(nt.queue) == 0 && len(nt.prune) == 0 {
+			nt.cond.Wait()
+		}
+		if nt.closed {
+			nt.running = false
+			nt.cond.Broadcast()
+			return
+		}
+
+		queue, prune := nt.queue, nt.prune
+		nt.queue, nt.prune = nil, nil
+		clear(nt.pending)
+		clear(nt.pruned)
+		nt.busy = true
+		nt.mu.Unlock()
+
+		nt.send(queue, prune)
+
+		nt.mu.Lock()
+		nt.busy = false
+		nt.cond.Broadcast()
+	}
+}
+
+// flush waits until all queued notifications have been sent.
+func (nt *notifier) flush() {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	for nt.running && (nt.busy || len(nt.queue) > 0 || len(nt.prune) > 0) {
+		nt.cond.Wait()
+	}
+}
+
+// close discards pending notifications and stops the worker.
+func (nt *notifier) close() {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	nt.closed = true
+	nt.queue, nt.prune = nil, nil
+	nt.cond.Broadcast()
+	for nt.running {
+		nt.cond.Wait()
+	}
+}
+
+// known returns the node ID of n if the kernel still has a
+// reference to it.
+func (nt *notifier) known(n *Inode) (uint64, bool) {
+	b := nt.bridge
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := n.nodeId
+	return id, b.kernelNodeIds[id] == n
+}
+
+func (nt *notifier) send(queue []*notifyItem, prune []*Inode) {
+	server := nt.bridge.server
+	for _, it := range queue {
+		id, ok := nt.known(it.node)
+		if !ok {
+			continue
+		}
+		var st fuse.Status
+		switch it.op {
+		case notifyEntry:
+			st = server.EntryNotify(id, it.name)
+		case notifyDelete:
+			st = server.DeleteNotify(id, it.child, it.name)
+		case notifyContent:
+			length := int64(0)
+			if it.off >= 0 && it.end >= 0 {
+				length = it.end - it.off
+			}
+			st = server.InodeNotify(id, it.off, length)
+		}
+		nt.report(notifyOpNames[it.op], id, it.name, st)
+	}
+
+	var ids []uint64
+	for _, n := range prune {
+		if id, ok := nt.known(n); ok {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	pruner, ok := server.(interface {
+		PruneNotify([]uint64) fuse.Status
+	})
+	if !ok {
+		nt.report("prune", 0, "", fuse.ENOSYS)
+		return
+	}
+	for len(ids) > 0 {
+		batch := ids[:min(len(ids), pruneBatchSize)]
+		ids = ids[len(batch):]
+		nt.report("prune", 0, "", pruner.PruneNotify(batch))
+	}
+}
+
+// report passes errors to the callback. ENOENT is not an error: it
+// means the kernel had nothing cached for the notification.
+func (nt *notifier) report(op string, id uint64, name string, st fuse.Status) {
+	if st.Ok() || st == fuse.ENOENT {
+		return
+	}
+	e := &NotifyError{Op: op, NodeId: id, Name: name, Errno: syscall.Errno(st)}
+	if nt.onError != nil {
+		nt.onError(e)
+	} else {
+		nt.bridge.logf("%v", e)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// recordingCallbacks records notifications. If block is set, the
+// first notification waits for it to be closed.
+type recordingCallbacks struct {
+	mu      sync.Mutex
+	calls   []string
+	started chan struct{}
+	block   chan struct{}
+	status  fuse.Status
+}
+
+func (r *recordingCallbacks) record(s string) fuse.Status {
+	r.mu.Lock()
+	r.calls = append(r.calls, s)
+	first := len(r.calls) == 1
+	r.mu.Unlock()
+	if first && r.block != nil {
+		close(r.started)
+		<-r.block
+	}
+	return r.status
+}
+
+func (r *recordingCallbacks) DeleteNotify(parent uint64, child uint64, name string) fuse.Status {
+	return r.record(fmt.Sprintf("delete %d %d %s", parent, child, name))
+}
+
+func (r *recordingCallbacks) EntryNotify(parent uint64, name string) fuse.Status {
+	return r.record(fmt.Sprintf("entry %d %s", parent, name))
+}
+
+func (r *recordingCallbacks) InodeNotify(node uint64, off int64, length int64) fuse.Status {
+	return r.record(fmt.Sprintf("content %d %d %d", node, off, length))
+}
+
+func (r *recordingCallbacks) InodeRetrieveCache(node uint64, offset int64, dest []byte) (n int, st fuse.Status) {
+	return 0, fuse.ENOSYS
+}
+
+func (r *recordingCallbacks) InodeNotifyStoreCache(node uint64, offset int64, data []byte) fuse.Status {
+	return fuse.ENOSYS
+}
+
+func (r *recordingCallbacks) PruneNotify(nodes []uint64) fuse.Status {
+	return r.record(fmt.Sprintf("prune %v", nodes))
+}
+
+func (r *recordingCallbacks) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.calls...)
+}
+
+type notifyRoot struct {
+	Inode
+}
+
+func (r *notifyRoot) OnAdd(ctx context.Context) {
+	for _, name := range []string{"a", "b"} {
+		r.AddChild(name, r.NewPersistentInode(ctx, &Inode{}, StableAttr{}), false)
+	}
+}
+
+// newNotifyBridge returns an unmounted bridge with async
+// notifications, where the kernel has looked up "a" and "b".
+func newNotifyBridge(t *testing.T, cb *recordingCallbacks, onError func(*NotifyError)) (*rawBridge, *notifyRoot) {
+	root := &notifyRoot{}
+	b := NewNodeFS(root, &Options{
+		ServerCallbacks: cb,
+		AsyncNotify:     true,
+		OnNotifyError:   onError,
+	}).(*rawBridge)
+	t.Cleanup(b.OnUnmount)
+	for _, name := range []string{"a", "b"} {
+		var out fuse.EntryOut
+		if st := b.Lookup(nil, &fuse.InHeader{NodeId: 1}, name, &out); !st.Ok() {
+			t.Fatalf("Lookup(%q): %v", name, st)
+		}
+	}
+	return b, root
+}
+
+func TestAsyncNotifyCoalesce(t *testing.T) {
+	cb := &recordingCallbacks{
+		started: make(chan struct{}),
+		block:   make(chan struct{}),
+	}
+	b, root := newNotifyBridge(t, cb, nil)
+	a, bb := root.GetChild("a"), root.GetChild("b")
+
+	// The first notification blocks the worker, so the rest
+	// is queued up.
+	root.NotifyEntry("x")
+	<-cb.started
+
+	root.NotifyEntry("a")
+	root.NotifyEntry("a")
+	a.NotifyContent(100, 10)
+	a.NotifyContent(0, 10)
+	a.NotifyContent(-1, 0)
+	bb.NotifyContent(4096, 0)
+	bb.NotifyContent(0, 10)
+	root.NotifyDelete("b", bb)
+	root.NotifyPrune([]*Inode{a, bb})
+	root.NotifyPrune([]*Inode{a})
+
+	close(cb.block)
+	b.notifier.flush()
+
+	want := []string{
+		"entry 1 x",
+		"entry 1 a",
+		fmt.Sprintf("content %d 0 110", a.nodeId),
+		fmt.Sprintf("content %d 0 0", bb.nodeId),
+		fmt.Sprintf("delete 1 %d b", bb.nodeId),
+		fmt.Sprintf("prune [%d %d]", a.nodeId, bb.nodeId),
+	}
+	if got := cb.get(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAsyncNotifyForgotten(t *testing.T) {
+	cb := &recordingCallbacks{
+		started: make(chan struct{}),
+		block:   make(chan struct{}),
+	}
+	b, root := newNotifyBridge(t, cb, nil)
+	a := root.GetChild("a")
+
+	root.NotifyEntry("x")
+	<-cb.started
+	a.NotifyContent(0, 10)
+	root.NotifyPrune([]*Inode{a})
+	b.Forget(a.nodeId, 1)
+	close(cb.block)
+	b.notifier.flush()
+
+	if got, want := cb.get(), []string{"entry 1 x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAsyncNotifyError(t *testing.T) {
+	var errs []*NotifyError
+	cb := &recordingCallbacks{status: fuse.EIO}
+	b, root := newNotifyBridge(t, cb, func(err *NotifyError) {
+		errs = append(errs, err)
+	})
+
+	if errno := root.NotifyEntry("a"); errno != 0 {
+		t.Fatalf("NotifyEntry: %v", errno)
+	}
+	b.notifier.flush()
+
+	want := []*NotifyError{{Op: "entry", NodeId: 1, Name: "a", Errno: syscall.EIO}}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("got %v, want %v", errs, want)
+	}
+
+	b.OnUnmount()
+	if errno := root.NotifyEntry("a"); errno != syscall.ENOTCONN {
+		t.Errorf("NotifyEntry after unmount: got %v, want ENOTCONN", errno)
+	}
+}
+
+type notifyMkdirRoot struct {
+	Inode
+}
+
+func (r *notifyMkdirRoot) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	// The kernel holds the directory lock while we run, so a
+	// synchronous notification for this directory deadlocks.
+	if errno := r.NotifyEntry(name); errno != 0 {
+		return nil, errno
+	}
+	return r.NewInode(ctx, &Inode{}, StableAttr{Mode: fuse.S_IFDIR}), 0
+}
+
+func TestAsyncNotifyFromHandler(t *testing.T) {
+	root := &notifyMkdirRoot{}
+	mnt, _ := testMount(t, root, &Options{AsyncNotify: true})
+	if err := os.Mkdir(mnt+"/dir", 0755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	root.bridge.notifier.flush()
+}
@@ -0,0 +1,420 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"path"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/internal"
+)
+
+// PathFileSystem is a file system that is expressed in path names
+// rather than nodes. Paths are relative to the root of the file
+// system, without leading slash; the root itself is "". Use
+// NewPathRoot to mount it.
+//
+// Getattr is the only required method: it is how the file system
+// reports which files exist. The other operations are optional, and
+// are described by the PathXxxxer interfaces, which take a path in
+// addition to the arguments of the corresponding NodeXxxxer method.
+// Operations on open files, such as reading, writing, locking,
+// lseek, copy_file_range and passthrough, are implemented by the
+// FileHandle returned from Open or Create, using the FileXxxxer
+// interfaces.
+//
+// When a file that is open is unlinked, the file system can no
+// longer be asked about it by path. Getattr and Setattr receive the
+// file handle, if there is one, so they can use it instead.
+type PathFileSystem interface {
+	Getattr(ctx context.Context, path string, f FileHandle, out *fuse.AttrOut) syscall.Errno
+}
+
+// See NodeSetattrer.
+type PathSetattrer interface {
+	Setattr(ctx context.Context, path string, f FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno
+}
+
+// See NodeStatxer.
+type PathStatxer interface {
+	Statx(ctx context.Context, path string, f FileHandle, flags uint32, mask uint32, out *fuse.StatxOut) syscall.Errno
+}
+
+// See NodeAccesser. If not implemented, the permission bits from
+// Getattr are checked.
+type PathAccesser interface {
+	Access(ctx context.Context, path string, mask uint32) syscall.Errno
+}
+
+// See NodeStatfser.
+type PathStatfser interface {
+	Statfs(ctx context.Context, path string, out *fuse.StatfsOut) syscall.Errno
+}
+
+// See NodeReaddirer.
+type PathReaddirer interface {
+	Readdir(ctx context.Context, path string) (DirStream, syscall.Errno)
+}
+
+// See NodeOpener.
+type PathOpener interface {
+	Open(ctx context.Context, path string, flags uint32) (fh FileHandle, fuseFlags uint32, errno syscall.Errno)
+}
+
+// See NodeCreater. The attributes of the new file are obtained with
+// Getattr.
+type PathCreater interface {
+	Create(ctx context.Context, path string, flags uint32, mode uint32) (fh FileHandle, fuseFlags uint32, errno syscall.Errno)
+}
+
+// See NodeMkdirer.
+type PathMkdirer interface {
+	Mkdir(ctx context.Context, path string, mode uint32) syscall.Errno
+}
+
+// See NodeMknoder.
+type PathMknoder interface {
+	Mknod(ctx context.Context, path string, mode uint32, dev uint32) syscall.Errno
+}
+
+// See NodeLinker.
+type PathLinker interface {
+	Link(ctx context.Context, oldPath string, newPath string) syscall.Errno
+}
+
+// See NodeSymlinker.
+type PathSymlinker interface {
+	Symlink(ctx context.Context, target string, path string) syscall.Errno
+}
+
+// See NodeReadlinker.
+type PathReadlinker interface {
+	Readlink(ctx context.Context, path string) ([]byte, syscall.Errno)
+}
+
+// See NodeUnlinker.
+type PathUnlinker interface {
+	Unlink(ctx context.Context, path string) syscall.Errno
+}
+
+// See NodeRmdirer.
+type PathRmdirer interface {
+	Rmdir(ctx context.Context, path string) syscall.Errno
+}
+
+// See NodeRenamer.
+type PathRenamer interface {
+	Rename(ctx context.Context, oldPath string, newPath string, flags uint32) syscall.Errno
+}
+
+// See NodeGetxattrer.
+type PathGetxattrer interface {
+	Getxattr(ctx context.Context, path string, attr string, dest []byte) (uint32, syscall.Errno)
+}
+
+// See NodeSetxattrer.
+type PathSetxattrer interface {
+	Setxattr(ctx context.Context, path string, attr string, data []byte, flags uint32) syscall.Errno
+}
+
+// See NodeRemovexattrer.
+type PathRemovexattrer interface {
+	Removexattr(ctx context.Context, path string, attr string) syscall.Errno
+}
+
+// See NodeListxattrer.
+type PathListxattrer interface {
+	Listxattr(ctx context.Context, path string, dest []byte) (uint32, syscall.Errno)
+}
+
+// PathOptions configures NewPathRoot.
+type PathOptions struct {
+	// ClientInodes makes the inode numbers returned by Getattr
+	// identify files. Paths with the same inode number are
+	// served by the same node, so hard links share their
+	// kernel cache. If unset, inode numbers are assigned
+	// automatically, and only links made through this mount are
+	// known to be the same file.
+	ClientInodes bool
+}
+
+// NewPathRoot returns a root node that serves fsys. The path of
+// each node is computed with Inode.Path when an operation is
+// executed, so nodes follow renames.
+func NewPathRoot(fsys PathFileSystem, opts *PathOptions) InodeEmbedder {
+	r := &pathRoot{fsys: fsys}
+	if opts != nil {
+		r.opts = *opts
+	}
+	return &pathNode{root: r}
+}
+
+type pathRoot struct {
+	fsys PathFileSystem
+	opts PathOptions
+}
+
+// pathNode is a file or directory of a PathFileSystem.
+type pathNode struct {
+	Inode
+
+	root *pathRoot
+}
+
+var _ = (NodeLookuper)((*pathNode)(nil))
+var _ = (NodeGetattrer)((*pathNode)(nil))
+var _ = (NodeSetattrer)((*pathNode)(nil))
+var _ = (NodeStatxer)((*pathNode)(nil))
+var _ = (NodeAccesser)((*pathNode)(nil))
+var _ = (NodeStatfser)((*pathNode)(nil))
+var _ = (NodeReaddirer)((*pathNode)(nil))
+var _ = (NodeOpener)((*pathNode)(nil))
+var _ = (NodeCreater)((*pathNode)(nil))
+var _ = (NodeMkdirer)((*pathNode)(nil))
+var _ = (NodeMknoder)((*pathNode)(nil))
+var _ = (NodeLinker)((*pathNode)(nil))
+var _ = (NodeSymlinker)((*pathNode)(nil))
+var _ = (NodeReadlinker)((*pathNode)(nil))
+var _ = (NodeUnlinker)((*pathNode)(nil))
+var _ = (NodeRmdirer)((*pathNode)(nil))
+var _ = (NodeRenamer)((*pathNode)(nil))
+var _ = (NodeGetxattrer)((*pathNode)(nil))
+var _ = (NodeSetxattrer)((*pathNode)(nil))
+var _ = (NodeRemovexattrer)((*pathNode)(nil))
+var _ = (NodeListxattrer)((*pathNode)(nil))
+
+func (n *pathNode) path() string {
+	return n.Path(n.Root())
+}
+
+func (n *pathNode) childPath(name string) string {
+	return path.Join(n.path(), name)
+}
+
+// child returns the node for the file at p, which is called name in
+// n. It reuses the existing child if it is still the same file.
+func (n *pathNode) child(ctx context.Context, name, p string, fh FileHandle, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	var a fuse.AttrOut
+	if errno := n.root.fsys.Getattr(ctx, p, fh, &a); errno != 0 {
+		return nil, errno
+	}
+	out.Attr = a.Attr
+	id := StableAttr{Mode: a.Mode & syscall.S_IFMT}
+	if n.root.opts.ClientInodes {
+		id.Ino = a.Ino
+	}
+	if ch := n.GetChild(name); ch != nil {
+		st := ch.StableAttr()
+		if st.Mode == id.Mode && (id.Ino == 0 || st.Ino == id.Ino) {
+			return ch, OK
+		}
+	}
+	return n.NewInode(ctx, &pathNode{root: n.root}, id), OK
+}
+
+func (n *pathNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	return n.child(ctx, name, n.childPath(name), nil, out)
+}
+
+func (n *pathNode) Getattr(ctx context.Context, f FileHandle, out *fuse.AttrOut) syscall.Errno {
+	return n.root.fsys.Getattr(ctx, n.path(), f, out)
+}
+
+func (n *pathNode) Setattr(ctx context.Context, f FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if sa, ok := n.root.fsys.(PathSetattrer); ok {
+		return sa.Setattr(ctx, n.path(), f, in, out)
+	}
+	return syscall.ENOTSUP
+}
+
+func (n *pathNode) Statx(ctx context.Context, f FileHandle, flags uint32, mask uint32, out *fuse.StatxOut) syscall.Errno {
+	if sx, ok := n.root.fsys.(PathStatxer); ok {
+		return sx.Statx(ctx, n.path(), f, flags, mask, out)
+	}
+	// The kernel falls back to GETATTR.
+	return syscall.ENOSYS
+}
+
+func (n *pathNode) Access(ctx context.Context, mask uint32) syscall.Errno {
+	if a, ok := n.root.fsys.(PathAccesser); ok {
+		return a.Access(ctx, n.path(), mask)
+	}
+	caller, ok := fuse.FromContext(ctx)
+	if !ok {
+		return OK
+	}
+	var out fuse.AttrOut
+	if errno := n.Getattr(ctx, nil, &out); errno != 0 {
+		return errno
+	}
+	if !internal.HasAccess(caller.Uid, caller.Gid, out.Uid, out.Gid, out.Mode, mask) {
+		return syscall.EACCES
+	}
+	return OK
+}
+
+func (n *pathNode) Statfs(ctx context.Context, out *fuse.StatfsOut) syscall.Errno {
+	if sf, ok := n.root.fsys.(PathStatfser); ok {
+		return sf.Statfs(ctx, n.path(), out)
+	}
+	return OK
+}
+
+func (n *pathNode) Readdir(ctx context.Context) (DirStream, syscall.Errno) {
+	if rd, ok := n.root.fsys.(PathReaddirer); ok {
+		return rd.Readdir(ctx, n.path())
+	}
+	return nil, syscall.ENOTSUP
+}
+
+func (n *pathNode) Open(ctx context.Context, flags uint32) (FileHandle, uint32, syscall.Errno) {
+	if op, ok := n.root.fsys.(PathOpener); ok {
+		return op.Open(ctx, n.path(), flags)
+	}
+	return nil, 0, syscall.ENOTSUP
+}
+
+func (n *pathNode) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*Inode, FileHandle, uint32, syscall.Errno) {
+	cr, ok := n.root.fsys.(PathCreater)
+	if !ok {
+		return nil, nil, 0, syscall.ENOTSUP
+	}
+	p := n.childPath(name)
+	fh, fuseFlags, errno := cr.Create(ctx, p, flags, mode)
+	if errno != 0 {
+		return nil, nil, 0, errno
+	}
+	ch, errno := n.child(ctx, name, p, fh, out)
+	if errno != 0 {
+		if r, ok := fh.(FileReleaser); ok {
+			r.Release(ctx)
+		}
+		return nil, nil, 0, errno
+	}
+	return ch, fh, fuseFlags, OK
+}
+
+func (n *pathNode) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	mk, ok := n.root.fsys.(PathMkdirer)
+	if !ok {
+		return nil, syscall.ENOTSUP
+	}
+	p := n.childPath(name)
+	if errno := mk.Mkdir(ctx, p, mode); errno != 0 {
+		return nil, errno
+	}
+	return n.child(ctx, name, p, nil, out)
+}
+
+func (n *pathNode) Mknod(ctx context.Context, name string, mode uint32, dev uint32, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	mk, ok := n.root.fsys.(PathMknoder)
+	if !ok {
+		return nil, syscall.ENOTSUP
+	}
+	p := n.childPath(name)
+	if errno := mk.Mknod(ctx, p, mode, dev); errno != 0 {
+		return nil, errno
+	}
+	return n.child(ctx, name, p, nil, out)
+}
+
+func (n *pathNode) Link(ctx context.Context, target InodeEmbedder, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	ln, ok := n.root.fsys.(PathLinker)
+	if !ok {
+		return nil, syscall.ENOTSUP
+	}
+	tn, ok := target.(*pathNode)
+	if !ok || tn.root != n.root {
+		return nil, syscall.EXDEV
+	}
+	p := n.childPath(name)
+	if errno := ln.Link(ctx, tn.path(), p); errno != 0 {
+		return nil, errno
+	}
+	var a fuse.AttrOut
+	if errno := n.root.fsys.Getattr(ctx, p, nil, &a); errno != 0 {
+		return nil, errno
+	}
+	out.Attr = a.Attr
+
+	// The new name refers to the same file, so it gets the same
+	// node.
+	return tn.EmbeddedInode(), OK
+}
+
+func (n *pathNode) Symlink(ctx context.Context, target, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	sl, ok := n.root.fsys.(PathSymlinker)
+	if !ok {
+		return nil, syscall.ENOTSUP
+	}
+	p := n.childPath(name)
+	if errno := sl.Symlink(ctx, target, p); errno != 0 {
+		return nil, errno
+	}
+	return n.child(ctx, name, p, nil, out)
+}
+
+func (n *pathNode) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
+	if rl, ok := n.root.fsys.(PathReadlinker); ok {
+		return rl.Readlink(ctx, n.path())
+	}
+	return nil, syscall.ENOTSUP
+}
+
+func (n *pathNode) Unlink(ctx context.Context, name string) syscall.Errno {
+	if ul, ok := n.root.fsys.(PathUnlinker); ok {
+		return ul.Unlink(ctx, n.childPath(name))
+	}
+	return syscall.ENOTSUP
+}
+
+func (n *pathNode) Rmdir(ctx context.Context, name string) syscall.Errno {
+	if rd, ok := n.root.fsys.(PathRmdirer); ok {
+		return rd.Rmdir(ctx, n.childPath(name))
+	}
+	return syscall.ENOTSUP
+}
+
+func (n *pathNode) Rename(ctx context.Context, name string, newParent InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	rn, ok := n.root.fsys.(PathRenamer)
+	if !ok {
+		return syscall.ENOTSUP
+	}
+	np, ok := newParent.(*pathNode)
+	if !ok || np.root != n.root {
+		return syscall.EXDEV
+	}
+	return rn.Rename(ctx, n.childPath(name), np.childPath(newName), flags)
+}
+
+func (n *pathNode) Getxattr(ctx context.Context, attr string, dest []byte) (uint32, syscall.Errno) {
+	if x, ok := n.root.fsys.(PathGetxattrer); ok {
+		return x.Getxattr(ctx, n.path(), attr, dest)
+	}
+	return 0, ENOATTR
+}
+
+func (n *pathNode) Setxattr(ctx context.Context, attr string, data []byte, flags uint32) syscall.Errno {
+	if x, ok := n.root.fsys.(PathSetxattrer); ok {
+		return x.Setxattr(ctx, n.path(), attr, data, flags)
+	}
+	return syscall.ENOTSUP
+}
+
+func (n *pathNode) Removexattr(ctx context.Context, attr string) syscall.Errno {
+	if x, ok := n.root.fsys.(PathRemovexattrer); ok {
+		return x.Removexattr(ctx, n.path(), attr)
+	}
+	return syscall.ENOTSUP
+}
+
+func (n *pathNode) Listxattr(ctx context.Context, dest []byte) (uint32, syscall.Errno) {
+	if x, ok := n.root.fsys.(PathListxattrer); ok {
+		return x.Listxattr(ctx, n.path(), dest)
+	}
+	return 0, OK
+}
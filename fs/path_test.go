@@ -0,0 +1,140 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// pathLoopback is a minimal PathFileSystem that serves a directory.
+type pathLoopback struct {
+	dir string
+}
+
+var _ = (PathOpener)((*pathLoopback)(nil))
+var _ = (PathCreater)((*pathLoopback)(nil))
+var _ = (PathReaddirer)((*pathLoopback)(nil))
+var _ = (PathMkdirer)((*pathLoopback)(nil))
+var _ = (PathLinker)((*pathLoopback)(nil))
+var _ = (PathUnlinker)((*pathLoopback)(nil))
+var _ = (PathRenamer)((*pathLoopback)(nil))
+
+func (fs *pathLoopback) Getattr(ctx context.Context, path string, f FileHandle, out *fuse.AttrOut) syscall.Errno {
+	if fga, ok := f.(FileGetattrer); ok {
+		return fga.Getattr(ctx, out)
+	}
+	var st syscall.Stat_t
+	if err := syscall.Lstat(filepath.Join(fs.dir, path), &st); err != nil {
+		return ToErrno(err)
+	}
+	out.FromStat(&st)
+	return OK
+}
+
+func (fs *pathLoopback) Open(ctx context.Context, path string, flags uint32) (FileHandle, uint32, syscall.Errno) {
+	fd, err := syscall.Open(filepath.Join(fs.dir, path), int(flags), 0)
+	if err != nil {
+		return nil, 0, ToErrno(err)
+	}
+	return NewLoopbackFile(fd), 0, OK
+}
+
+func (fs *pathLoopback) Create(ctx context.Context, path string, flags uint32, mode uint32) (FileHandle, uint32, syscall.Errno) {
+	fd, err := syscall.Open(filepath.Join(fs.dir, path), int(flags)|syscall.O_CREAT, mode)
+	if err != nil {
+		return nil, 0, ToErrno(err)
+	}
+	return NewLoopbackFile(fd), 0, OK
+}
+
+func (fs *pathLoopback) Readdir(ctx context.Context, path string) (DirStream, syscall.Errno) {
+	return NewLoopbackDirStream(filepath.Join(fs.dir, path))
+}
+
+func (fs *pathLoopback) Mkdir(ctx context.Context, path string, mode uint32) syscall.Errno {
+	return ToErrno(syscall.Mkdir(filepath.Join(fs.dir, path), mode))
+}
+
+func (fs *pathLoopback) Link(ctx context.Context, oldPath string, newPath string) syscall.Errno {
+	return ToErrno(syscall.Link(filepath.Join(fs.dir, oldPath), filepath.Join(fs.dir, newPath)))
+}
+
+func (fs *pathLoopback) Unlink(ctx context.Context, path string) syscall.Errno {
+	return ToErrno(syscall.Unlink(filepath.Join(fs.dir, path)))
+}
+
+func (fs *pathLoopback) Rename(ctx context.Context, oldPath string, newPath string, flags uint32) syscall.Errno {
+	if flags != 0 {
+		return syscall.ENOTSUP
+	}
+	return ToErrno(syscall.Rename(filepath.Join(fs.dir, oldPath), filepath.Join(fs.dir, newPath)))
+}
+
+func TestPathRoot(t *testing.T) {
+	for _, clientInodes := range []bool{false, true} {
+		t.Run(map[bool]string{false: "auto", true: "client"}[clientInodes], func(t *testing.T) {
+			orig := t.TempDir()
+			root := NewPathRoot(&pathLoopback{orig}, &PathOptions{ClientInodes: clientInodes})
+			mnt, _ := testMount(t, root, &Options{})
+
+			if err := os.Mkdir(mnt+"/dir", 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(mnt+"/dir/file", []byte("hello"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Link(mnt+"/dir/file", mnt+"/link"); err != nil {
+				t.Fatal(err)
+			}
+			var st1, st2 syscall.Stat_t
+			if err := syscall.Stat(mnt+"/dir/file", &st1); err != nil {
+				t.Fatal(err)
+			}
+			if err := syscall.Stat(mnt+"/link", &st2); err != nil {
+				t.Fatal(err)
+			}
+			if st1.Ino != st2.Ino || st2.Nlink != 2 {
+				t.Errorf("link: got ino %d nlink %d, want ino %d nlink 2", st2.Ino, st2.Nlink, st1.Ino)
+			}
+
+			// Nodes follow renames of their parents.
+			if err := os.Rename(mnt+"/dir", mnt+"/renamed"); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(mnt+"/renamed/new", []byte("new"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := os.ReadFile(orig + "/renamed/new"); err != nil || string(got) != "new" {
+				t.Errorf("ReadFile: got %q, %v", got, err)
+			}
+
+			// Open files can still be inspected after they lose
+			// their name.
+			f, err := os.Open(mnt + "/renamed/file")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if err := os.Remove(mnt + "/renamed/file"); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Remove(mnt + "/link"); err != nil {
+				t.Fatal(err)
+			}
+			if fi, err := f.Stat(); err != nil || fi.Size() != 5 {
+				t.Errorf("Stat after unlink: %v, %v", fi, err)
+			}
+			if got, err := os.ReadFile(mnt + "/renamed/new"); err != nil || string(got) != "new" {
+				t.Errorf("ReadFile: got %q, %v", got, err)
+			}
+		})
+	}
+}
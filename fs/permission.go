@@ -0,0 +1,278 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// permCaller is the caller of a request whose permissions are
+// checked. The supplementary groups are only read when needed.
+type permCaller struct {
+	*fuse.Caller
+	groups []uint32
+	loaded bool
+}
+
+func (c *permCaller) inGroup(gid uint32) bool {
+	if gid == c.Gid {
+		return true
+	}
+	if !c.loaded {
+		c.groups = callerGroups(c.Caller)
+		c.loaded = true
+	}
+	for _, g := range c.groups {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// getACL reads the ACL stored in the extended attribute attr of n. It
+// returns nil if there is none, or if it cannot be parsed.
+func (b *rawBridge) getACL(ctx context.Context, n *Inode, attr string) ACL {
+	xops, ok := asNode[NodeGetxattrer](n.ops)
+	if !ok {
+		return nil
+	}
+	buf := make([]byte, 256)
+	for {
+		sz, errno := xops.Getxattr(ctx, attr, buf)
+		if errno == syscall.ERANGE && int(sz) > len(buf) {
+			buf = make([]byte, sz)
+			continue
+		}
+		if errno != 0 || int(sz) > len(buf) {
+			return nil
+		}
+		acl, err := ParseACL(buf[:sz])
+		if err != nil {
+			return nil
+		}
+		return acl
+	}
+}
+
+// access checks if the caller may access n in mode mask. A
+// NodeAccesser implements its own policy. Otherwise, the mode bits and
+// the access ACL of n are evaluated.
+func (b *rawBridge) access(ctx *fuse.Context, n *Inode, mask uint32) syscall.Errno {
+	if a, ok := asNode[NodeAccesser](n.ops); ok {
+		return a.Access(ctx, mask)
+	}
+	var out fuse.AttrOut
+	if errno := b.getattr(ctx, n, nil, &out); errno != 0 {
+		return errno
+	}
+	return b.accessAttr(ctx, n, &out.Attr, mask)
+}
+
+// accessAttr is like access, for a node with attributes attr.
+func (b *rawBridge) accessAttr(ctx *fuse.Context, n *Inode, attr *fuse.Attr, mask uint32) syscall.Errno {
+	mask &= fuse.R_OK | fuse.W_OK | fuse.X_OK
+	if mask == 0 {
+		return OK
+	}
+	if ctx.Uid == 0 {
+		// Root may execute only files that someone may
+		// execute.
+		if mask&fuse.X_OK == 0 || attr.Mode&syscall.S_IFMT == syscall.S_IFDIR || attr.Mode&0111 != 0 {
+			return OK
+		}
+		return syscall.EACCES
+	}
+
+	acl := ACLFromMode(attr.Mode)
+	if stored := b.getACL(ctx, n, aclXattrAccess); stored != nil {
+		// The mode bits override the entries they are
+		// equivalent to, as chmod updates both.
+		acl = stored.withMode(attr.Mode)
+	}
+	c := &permCaller{Caller: &ctx.Caller}
+	if !acl.permits(ctx.Uid, attr.Uid, attr.Gid, c.inGroup, mask) {
+		return syscall.EACCES
+	}
+	return OK
+}
+
+// checkAccess checks access to n if Options.CheckPermissions is set.
+func (b *rawBridge) checkAccess(ctx *fuse.Context, n *Inode, mask uint32) syscall.Errno {
+	if !n.mount.options.CheckPermissions {
+		return OK
+	}
+	return b.access(ctx, n, mask)
+}
+
+// checkOpen checks if the caller may open n with the given open(2)
+// flags.
+func (b *rawBridge) checkOpen(ctx *fuse.Context, n *Inode, flags uint32) syscall.Errno {
+	var mask uint32
+	switch flags & syscall.O_ACCMODE {
+	case syscall.O_RDONLY:
+		mask = fuse.R_OK
+	case syscall.O_WRONLY:
+		mask = fuse.W_OK
+	default:
+		mask = fuse.R_OK | fuse.W_OK
+	}
+	if flags&syscall.O_TRUNC != 0 {
+		mask |= fuse.W_OK
+	}
+	return b.checkAccess(ctx, n, mask)
+}
+
+// checkRemove checks if the caller may remove or replace the entry
+// name of dir. This requires write access to dir, and if dir is
+// sticky, owning dir or the entry.
+func (b *rawBridge) checkRemove(ctx *fuse.Context, dir *Inode, name string) syscall.Errno {
+	if !dir.mount.options.CheckPermissions {
+		return OK
+	}
+	if errno := b.access(ctx, dir, fuse.W_OK|fuse.X_OK); errno != 0 {
+		return errno
+	}
+	if ctx.Uid == 0 {
+		return OK
+	}
+	var out fuse.AttrOut
+	if errno := b.getattr(ctx, dir, nil, &out); errno != 0 {
+		return errno
+	}
+	if out.Mode&syscall.S_ISVTX == 0 || out.Uid == ctx.Uid {
+		return OK
+	}
+	// The kernel looks up the entry before removing it, so it
+	// should be known.
+	child := dir.GetChild(name)
+	if child == nil {
+		return OK
+	}
+	if errno := b.getattr(ctx, child, nil, &out); errno != 0 {
+		return errno
+	}
+	if out.Uid != ctx.Uid {
+		return syscall.EPERM
+	}
+	return OK
+}
+
+// checkSetattr checks if the caller may change the attributes of n as
+// requested. It returns the request to pass on, which differs from in
+// if the set-group-ID bit must be cleared.
+func (b *rawBridge) checkSetattr(ctx *fuse.Context, n *Inode, in *fuse.SetAttrIn) (*fuse.SetAttrIn, syscall.Errno) {
+	if !n.mount.options.CheckPermissions || ctx.Uid == 0 {
+		return in, OK
+	}
+	var out fuse.AttrOut
+	if errno := b.getattr(ctx, n, nil, &out); errno != 0 {
+		return nil, errno
+	}
+	owner := out.Uid == ctx.Uid
+	c := &permCaller{Caller: &ctx.Caller}
+
+	if in.Valid&(fuse.FATTR_MODE|fuse.FATTR_UID|fuse.FATTR_GID) != 0 && !owner {
+		return nil, syscall.EPERM
+	}
+	if in.Valid&fuse.FATTR_UID != 0 && in.Uid != out.Uid {
+		return nil, syscall.EPERM
+	}
+	if in.Valid&fuse.FATTR_GID != 0 && in.Gid != out.Gid && !c.inGroup(in.Gid) {
+		return nil, syscall.EPERM
+	}
+	if in.Valid&fuse.FATTR_MODE != 0 && in.Mode&syscall.S_ISGID != 0 {
+		gid := out.Gid
+		if in.Valid&fuse.FATTR_GID != 0 {
+			gid = in.Gid
+		}
+		if !c.inGroup(gid) {
+			cp := *in
+			cp.Mode &^= syscall.S_ISGID
+			in = &cp
+		}
+	}
+
+	if in.Valid&fuse.FATTR_SIZE != 0 && in.Valid&fuse.FATTR_FH == 0 {
+		if errno := b.accessAttr(ctx, n, &out.Attr, fuse.W_OK); errno != 0 {
+			return nil, errno
+		}
+	}
+
+	// Setting the current time needs write access, but setting
+	// other times needs ownership.
+	if times := in.Valid & (fuse.FATTR_ATIME | fuse.FATTR_MTIME); times != 0 && !owner {
+		now := in.Valid & (fuse.FATTR_ATIME_NOW | fuse.FATTR_MTIME_NOW)
+		if times<<3 != now {
+			return nil, syscall.EPERM
+		}
+		if errno := b.accessAttr(ctx, n, &out.Attr, fuse.W_OK); errno != 0 {
+			return nil, errno
+		}
+	}
+	return in, OK
+}
+
+// checkXattr checks if the caller may change the extended attribute
+// attr of n. Only ACLs are restricted, to the owner.
+func (b *rawBridge) checkXattr(ctx *fuse.Context, n *Inode, attr string) syscall.Errno {
+	if !n.mount.options.CheckPermissions || ctx.Uid == 0 || !isACLXattr(attr) {
+		return OK
+	}
+	var out fuse.AttrOut
+	if errno := b.getattr(ctx, n, nil, &out); errno != 0 {
+		return errno
+	}
+	if out.Uid != ctx.Uid {
+		return syscall.EPERM
+	}
+	return OK
+}
+
+// createMode returns the mode for a new file in dir, for a request
+// with mode and umask. If dir has a default ACL, the permissions
+// are limited by it rather than the umask, and it is returned for
+// inheritACL.
+func (b *rawBridge) createMode(ctx *fuse.Context, dir *Inode, mode, umask uint32) (uint32, ACL) {
+	if !dir.mount.options.CheckPermissions {
+		return mode, nil
+	}
+	def := b.getACL(ctx, dir, aclXattrDefault)
+	if def == nil {
+		// The kernel only leaves applying the umask to us if
+		// asked to.
+		if dir.mount.options.ExtraCapabilities&fuse.CAP_DONT_MASK != 0 {
+			mode &^= umask
+		}
+		return mode, nil
+	}
+	_, mode = def.inherit(mode)
+	return mode, def
+}
+
+// inheritACL stores the ACLs that a new node with permissions mode
+// inherits from the default ACL def of its directory.
+func (b *rawBridge) inheritACL(ctx *fuse.Context, n *Inode, def ACL, mode uint32) {
+	if def == nil {
+		return
+	}
+	xops, ok := asNode[NodeSetxattrer](n.ops)
+	if !ok {
+		return
+	}
+	if acl, _ := def.inherit(mode); !acl.IsMinimal() {
+		if errno := xops.Setxattr(ctx, aclXattrAccess, acl.Bytes(), 0); errno != 0 {
+			b.logf("inheritACL: Setxattr(%q): %v", aclXattrAccess, errno)
+		}
+	}
+	if n.IsDir() {
+		if errno := xops.Setxattr(ctx, aclXattrDefault, def.Bytes(), 0); errno != 0 {
+			b.logf("inheritACL: Setxattr(%q): %v", aclXattrDefault, errno)
+		}
+	}
+}
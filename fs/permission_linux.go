@@ -0,0 +1,41 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// callerGroups returns the supplementary groups of the calling
+// process. If they cannot be determined, it returns no groups.
+func callerGroups(caller *fuse.Caller) []uint32 {
+	if caller.Pid == 0 {
+		return nil
+	}
+	status, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", caller.Pid))
+	if err != nil {
+		return nil
+	}
+	for _, l := range bytes.Split(status, []byte("\n")) {
+		rest, ok := bytes.CutPrefix(l, []byte("Groups:"))
+		if !ok {
+			continue
+		}
+		var groups []uint32
+		for _, f := range bytes.Fields(rest) {
+			g, err := strconv.ParseUint(string(f), 10, 32)
+			if err == nil {
+				groups = append(groups, uint32(g))
+			}
+		}
+		return groups
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// allowOthers makes the directories above the mount point mnt
+// accessible to other users.
+func allowOthers(t *testing.T, mnt string) {
+	t.Helper()
+	for d := filepath.Dir(mnt); d != os.TempDir(); d = filepath.Dir(d) {
+		if err := os.Chmod(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// runAs runs a shell script with the given credentials. The process
+// starts outside of the mount: the forked child would otherwise
+// enter it before exec, which can deadlock the server.
+func runAs(t *testing.T, cred *syscall.Credential, script string) error {
+	t.Helper()
+	cmd := exec.Command("/bin/sh", "-c", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: cred}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Logf("%s: %s", script, out)
+	}
+	return err
+}
+
+func TestCheckPermissions(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("needs root to change credentials")
+	}
+	t.Run("memfs", func(t *testing.T) {
+		testCheckPermissions(t, NewMemFS(nil))
+	})
+	t.Run("wrapped", func(t *testing.T) {
+		var log opLog
+		testCheckPermissions(t, Wrap(NewMemFS(nil), log.intercept))
+	})
+}
+
+func testCheckPermissions(t *testing.T, root InodeEmbedder) {
+	opts := &Options{CheckPermissions: true}
+	opts.AllowOther = true
+	mnt, _ := testMount(t, root, opts)
+	allowOthers(t, mnt)
+
+	const uid, gid, group = 4321, 4322, 4400
+	cred := &syscall.Credential{Uid: uid, Gid: gid, Groups: []uint32{group}}
+
+	setACL := func(p, attr string, acl ACL) {
+		t.Helper()
+		if err := syscall.Setxattr(p, attr, acl.Bytes(), 0); err != nil {
+			t.Fatalf("Setxattr(%q, %q): %v", p, attr, err)
+		}
+	}
+	for _, d := range []struct {
+		name string
+		mode os.FileMode
+	}{
+		{"private", 0700},
+		{"acl", 0700},
+		{"sticky", 0777 | os.ModeSticky},
+		{"inherit", 0777},
+	} {
+		if err := os.Mkdir(mnt+"/"+d.name, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(mnt+"/"+d.name, d.mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"private/file", "group", "sticky/file"} {
+		if err := os.WriteFile(mnt+"/"+f, []byte("hello"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(mnt+"/sticky/file", 0666); err != nil {
+		t.Fatal(err)
+	}
+	setACL(mnt+"/acl", aclXattrAccess, ACL{
+		{Tag: ACL_USER_OBJ, Perm: 7},
+		{Tag: ACL_USER, Perm: 5, ID: uid},
+		{Tag: ACL_GROUP_OBJ, Perm: 0},
+		{Tag: ACL_MASK, Perm: 5},
+		{Tag: ACL_OTHER, Perm: 0},
+	})
+	if err := os.Chmod(mnt+"/acl", 0750); err != nil {
+		t.Fatal(err)
+	}
+	setACL(mnt+"/group", aclXattrAccess, ACL{
+		{Tag: ACL_USER_OBJ, Perm: 6},
+		{Tag: ACL_GROUP_OBJ, Perm: 0},
+		{Tag: ACL_GROUP, Perm: 6, ID: group},
+		{Tag: ACL_MASK, Perm: 6},
+		{Tag: ACL_OTHER, Perm: 0},
+	})
+	if err := os.Chmod(mnt+"/group", 0660); err != nil {
+		t.Fatal(err)
+	}
+	setACL(mnt+"/inherit", aclXattrDefault, ACL{
+		{Tag: ACL_USER_OBJ, Perm: 7},
+		{Tag: ACL_USER, Perm: 7, ID: 1234},
+		{Tag: ACL_GROUP_OBJ, Perm: 5},
+		{Tag: ACL_MASK, Perm: 7},
+		{Tag: ACL_OTHER, Perm: 5},
+	})
+
+	for _, c := range []struct {
+		script string
+		ok     bool
+	}{
+		{"cat private/file", false},
+		{"ls private", false},
+		{"ls acl", true},
+		{"touch acl/new", false},
+		{"echo hello > group", true},
+		{"chmod 0666 group", false},
+		{"chown 4321 group", false},
+		{"rm sticky/file", false},
+		{"touch sticky/mine && rm sticky/mine", true},
+		{"umask 022 && touch inherit/new && mkdir inherit/dir", true},
+	} {
+		if err := runAs(t, cred, "cd "+mnt+" && "+c.script); (err == nil) != c.ok {
+			t.Errorf("%q: got %v, want success %v", c.script, err, c.ok)
+		}
+	}
+
+	var st syscall.Stat_t
+	if err := syscall.Stat(mnt+"/inherit/new", &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode&07777 != 0644 || st.Uid != uid {
+		t.Errorf("inherited file: got mode %o uid %d, want 0644 uid %d", st.Mode&07777, st.Uid, uid)
+	}
+	buf := make([]byte, 1024)
+	sz, err := syscall.Getxattr(mnt+"/inherit/new", aclXattrAccess, buf)
+	if err != nil {
+		t.Fatalf("Getxattr: %v", err)
+	}
+	if acl, err := ParseACL(buf[:sz]); err != nil || acl.find(ACL_USER) < 0 || acl[acl.find(ACL_MASK)].Perm != 4 {
+		t.Errorf("inherited ACL: got %v, %v", acl, err)
+	}
+	if _, err := syscall.Getxattr(mnt+"/inherit/dir", aclXattrDefault, buf); err != nil {
+		t.Errorf("Getxattr default ACL of inheriting directory: %v", err)
+	}
+}
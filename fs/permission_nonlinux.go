@@ -0,0 +1,31 @@
+//go:build !linux
+
+package fs
+
+import (
+	"os/user"
+	"strconv"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// callerGroups returns the groups that the user database lists for
+// the caller, as the groups of a process cannot be read.
+func callerGroups(caller *fuse.Caller) []uint32 {
+	u, err := user.LookupId(strconv.Itoa(int(caller.Uid)))
+	if err != nil {
+		return nil
+	}
+	gs, err := u.GroupIds()
+	if err != nil {
+		return nil
+	}
+	var groups []uint32
+	for _, s := range gs {
+		g, err := strconv.ParseUint(s, 10, 32)
+		if err == nil {
+			groups = append(groups, uint32(g))
+		}
+	}
+	return groups
+}
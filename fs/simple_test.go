@@ -63,6 +63,7 @@ type testOptions struct {
 	directMountStrict bool // sets MountOptions.DirectMountStrict
 	disableSplice     bool // sets MountOptions.DisableSplice
 	idMappedMount     bool // sets MountOptions.IDMappedMount
+	keepFds           bool // sets LoopbackRoot.KeepFds
 }
 
 // newTestCase creates the directories `orig` and `mnt` inside a temporary
@@ -92,6 +93,7 @@ func newTestCase(t *testing.T, opts *testOptions) *testCase {
 	if err != nil {
 		t.Fatalf("NewLoopback: %v", err)
 	}
+	tc.loopback.(*LoopbackNode).RootData.KeepFds = opts.keepFds
 
 	oneSec := time.Second
 
@@ -0,0 +1,167 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"syscall"
+)
+
+// MountChild mounts the tree of root as the child name of n, with its
+// own options. This is how a single FUSE server can serve several
+// independent file systems.
+//
+// Of opts, EntryTimeout, AttrTimeout, NegativeTimeout,
+// FirstAutomaticIno, NullPermissions, UID, GID, RootStableAttr and
+// OnAdd apply to the new tree; the other options are those of the
+// FUSE server. If opts is nil, the timeouts, NullPermissions, UID and
+// GID of the tree containing n are used.
+//
+// The new tree has its own inode number space: automatic inode
+// numbers start at FirstAutomaticIno, and hard links are only
+// detected between nodes of the same tree. Links and renames across
+// mount points fail with EXDEV. For nodes in the new tree, Root
+// returns root.
+//
+// The mount point hides any entry called name that n's Lookup
+// returns, and cannot be removed or renamed. If the kernel has
+// cached the entry, it is invalidated, so this must not be called
+// from an operation handler unless Options.AsyncNotify is set.
+//
+// MountChild returns EBUSY if n already has a child called name,
+// and ENOTDIR if n is not a directory.
+func (n *Inode) MountChild(ctx context.Context, name string, root InodeEmbedder, opts *Options) syscall.Errno {
+	if !n.IsDir() {
+		return syscall.ENOTDIR
+	}
+	if root.embed().bridge != nil {
+		return syscall.EINVAL
+	}
+	if n.GetChild(name) != nil {
+		return syscall.EBUSY
+	}
+	if opts == nil {
+		parent := n.mount.options
+		opts = &Options{
+			EntryTimeout:    parent.EntryTimeout,
+			AttrTimeout:     parent.AttrTimeout,
+			NegativeTimeout: parent.NegativeTimeout,
+			NullPermissions: parent.NullPermissions,
+			UID:             parent.UID,
+			GID:             parent.GID,
+		}
+	}
+
+	b := n.bridge
+	m := newFSMount(opts)
+	id := StableAttr{
+		Ino:  root.embed().StableAttr().Ino,
+		Mode: syscall.S_IFDIR,
+	}
+	if opts.RootStableAttr != nil {
+		id.Ino = opts.RootStableAttr.Ino
+		id.Gen = opts.RootStableAttr.Gen
+	}
+
+	b.mu.Lock()
+	if id.Ino == 0 {
+		id.Ino = m.newIno(id)
+	}
+	initInode(root.embed(), root, id, b, m, true, b.newNodeId(id.Ino))
+	m.root = root.embed()
+	b.mounts[m] = struct{}{}
+	b.mu.Unlock()
+
+	if !n.AddChild(name, m.root, false) {
+		b.mu.Lock()
+		delete(b.mounts, m)
+		b.mu.Unlock()
+		m.root.ForgetPersistent()
+		return syscall.EBUSY
+	}
+
+	if opts.OnAdd != nil {
+		opts.OnAdd(ctx)
+	} else if oa, ok := root.(NodeOnAdder); ok {
+		oa.OnAdd(ctx)
+	}
+
+	n.NotifyEntry(name)
+	return OK
+}
+
+// UnmountChild detaches the tree that was mounted as the child name
+// of n with MountChild. It returns EINVAL if the child is not a mount
+// point, and EBUSY if files in the tree are open, or other trees are
+// mounted in it. Files opened without a file handle are not counted.
+//
+// The kernel is told to forget the mount point, but nodes that it
+// still references, such as the working directory of a process,
+// keep working until they are forgotten.
+func (n *Inode) UnmountChild(name string) syscall.Errno {
+	ch := n.GetChild(name)
+	if ch == nil {
+		return syscall.ENOENT
+	}
+	if !ch.isMountRoot() {
+		return syscall.EINVAL
+	}
+	if ch.mountBusy() {
+		return syscall.EBUSY
+	}
+
+	b := n.bridge
+	ch.ForgetPersistent()
+	n.RmChild(name)
+
+	b.mu.Lock()
+	delete(b.mounts, ch.mount)
+	b.mu.Unlock()
+
+	n.NotifyDelete(name, ch)
+	return OK
+}
+
+// isMountRoot returns true for the root of a tree added with
+// MountChild.
+func (n *Inode) isMountRoot() bool {
+	return n.mount.root == n && n != n.bridge.root
+}
+
+// hasMountChild returns true if the child called name is a mount
+// point.
+func (n *Inode) hasMountChild(name string) bool {
+	ch := n.GetChild(name)
+	return ch != nil && ch.isMountRoot()
+}
+
+// mountBusy returns true if nodes of the tree rooted at n have open
+// files, or contain other mounts.
+func (n *Inode) mountBusy() bool {
+	seen := map[*Inode]bool{n: true}
+	todo := []*Inode{n}
+	for len(todo) > 0 {
+		p := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		for _, ch := range p.Children() {
+			if ch.mount != n.mount {
+				return true
+			}
+			if !seen[ch] {
+				seen[ch] = true
+				todo = append(todo, ch)
+			}
+		}
+	}
+
+	n.bridge.mu.Lock()
+	defer n.bridge.mu.Unlock()
+	for ch := range seen {
+		if len(ch.openFiles) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// submountTestRoot is a directory with a single file.
+type submountTestRoot struct {
+	Inode
+}
+
+func (r *submountTestRoot) OnAdd(ctx context.Context) {
+	file := r.NewPersistentInode(ctx, &MemRegularFile{
+		Data: []byte("hello"),
+		Attr: fuse.Attr{Nlink: 1},
+	}, StableAttr{})
+	r.AddChild("file", file, false)
+}
+
+func TestMountChild(t *testing.T) {
+	orig := t.TempDir()
+	if err := os.Mkdir(orig+"/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(orig+"/sub/hidden", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewLoopbackRoot(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnt, _ := testMount(t, root, &Options{})
+
+	sec := time.Second
+	if errno := root.EmbeddedInode().MountChild(context.Background(), "sub", &submountTestRoot{}, &Options{
+		AttrTimeout:       &sec,
+		EntryTimeout:      &sec,
+		FirstAutomaticIno: 5000,
+		UID:               1234,
+		GID:               5678,
+	}); errno != 0 {
+		t.Fatalf("MountChild: %v", errno)
+	}
+
+	var st syscall.Stat_t
+	if err := syscall.Stat(mnt+"/sub/file", &st); err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if st.Uid != 1234 || st.Gid != 5678 || st.Mode != syscall.S_IFREG|0644 || st.Ino < 5000 {
+		t.Errorf("got uid %d gid %d mode %o ino %d, want 1234, 5678, %o, >= 5000",
+			st.Uid, st.Gid, st.Mode, st.Ino, syscall.S_IFREG|0644)
+	}
+	if _, err := os.Stat(mnt + "/sub/hidden"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Stat(hidden): got %v, want ENOENT", err)
+	}
+	if got, err := os.ReadFile(mnt + "/sub/file"); err != nil || string(got) != "hello" {
+		t.Errorf("ReadFile: %q, %v", got, err)
+	}
+
+	// The mount point cannot be removed through the parent.
+	if err := syscall.Rmdir(mnt + "/sub"); err != syscall.EBUSY {
+		t.Errorf("Rmdir: got %v, want EBUSY", err)
+	}
+	if err := syscall.Rename(mnt+"/sub", mnt+"/other"); err != syscall.EBUSY {
+		t.Errorf("Rename: got %v, want EBUSY", err)
+	}
+	if err := syscall.Link(mnt+"/sub/file", mnt+"/link"); err != syscall.EXDEV {
+		t.Errorf("Link: got %v, want EXDEV", err)
+	}
+
+	if errno := root.EmbeddedInode().UnmountChild("sub"); errno != 0 {
+		t.Fatalf("UnmountChild: %v", errno)
+	}
+
+	// The kernel sees the directory of the loopback again.
+	if _, err := os.Stat(mnt + "/sub/hidden"); err != nil {
+		t.Errorf("Stat(hidden) after unmount: %v", err)
+	}
+}
+
+func TestMountChildLoopback(t *testing.T) {
+	root := &Inode{}
+	mnt, _ := testMount(t, root, &Options{})
+
+	orig := t.TempDir()
+	loop, err := NewLoopbackRoot(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errno := root.MountChild(context.Background(), "loop", loop, nil); errno != 0 {
+		t.Fatalf("MountChild: %v", errno)
+	}
+	if errno := root.MountChild(context.Background(), "loop", &Inode{}, nil); errno != syscall.EBUSY {
+		t.Errorf("MountChild twice: got %v, want EBUSY", errno)
+	}
+
+	// Paths in the loopback are relative to its own root.
+	if err := os.MkdirAll(mnt+"/loop/dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mnt+"/loop/dir/file", []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(orig + "/dir/file"); err != nil || string(got) != "data" {
+		t.Errorf("ReadFile: %q, %v", got, err)
+	}
+	if !loop.EmbeddedInode().GetChild("dir").IsDir() || loop.EmbeddedInode().GetChild("dir").Root() != loop.EmbeddedInode() {
+		t.Errorf("Root of child is not the mount root")
+	}
+
+	f, err := os.Open(mnt + "/loop/dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errno := root.UnmountChild("loop"); errno != syscall.EBUSY {
+		t.Errorf("UnmountChild with open file: got %v, want EBUSY", errno)
+	}
+	f.Close()
+
+	// The kernel sends RELEASE asynchronously.
+	errno := root.UnmountChild("loop")
+	for i := 0; errno == syscall.EBUSY && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		errno = root.UnmountChild("loop")
+	}
+	if errno != 0 {
+		t.Fatalf("UnmountChild: %v", errno)
+	}
+	if _, err := os.Stat(mnt + "/loop"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Stat after unmount: got %v, want ENOENT", err)
+	}
+}
@@ -0,0 +1,688 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// Op describes an operation intercepted by a Middleware.
+type Op struct {
+	// Name is the method name from the NodeXxxxer or FileXxxxer
+	// interface, eg. "Lookup", "Read" or "Readdirent". Operations
+	// on open files have the same name, whether they are
+	// implemented by the node or by the file handle.
+	Name string
+
+	// Node is the node the operation is executed on.
+	Node *Inode
+
+	// File is the file handle of the operation, or nil.
+	File FileHandle
+
+	// Args holds the other arguments of the method, in order.
+	// Output arguments such as *fuse.AttrOut are filled in by
+	// next. Replacing elements of Args has no effect.
+	Args []any
+}
+
+// Middleware intercepts the operations on a tree wrapped with
+// Wrap. It executes the operation by calling next, possibly with a
+// different context. It can also return an error without calling
+// next, or inspect and change the outputs after next returns. The
+// result of next is the errno returned to the kernel.
+//
+// Operations that have no errno result, such as Releasedir, ignore
+// the return value.
+type Middleware func(ctx context.Context, op *Op, next func(ctx context.Context) syscall.Errno) syscall.Errno
+
+// Wrap returns root with the middleware applied to all of its
+// operations. The first middleware is the outermost one. For
+// example, a file system can be made read-only with:
+//
+//	fs.Wrap(root, func(ctx context.Context, op *fs.Op, next func(context.Context) syscall.Errno) syscall.Errno {
+//		switch op.Name {
+//		case "Write", "Create", "Mkdir", "Unlink", ...:
+//			return syscall.EROFS
+//		}
+//		return next(ctx)
+//	})
+//
+// All children of the wrapped node, whether they are created with
+// NewInode or NewPersistentInode, are wrapped too, using the
+// NodeWrapChilder mechanism. Inode.Operations returns the original
+// node, so the wrapped file system can still find its own nodes.
+//
+// The wrapped node only provides the NodeXxxxer interfaces that the
+// original node implements, and the file handles it returns only
+// provide the FileXxxxer interfaces of the original handles. For
+// methods that neither implements, the bridge applies its default
+// behavior, without calling the middleware.
+func Wrap(root InodeEmbedder, mw ...Middleware) InodeEmbedder {
+	return &wrapNode{inner: root, mw: mw}
+}
+
+// wrapNode runs the middleware for an inner node. It has the methods
+// of all node interfaces, but the bridge only uses those that the
+// inner node implements; see asNode.
+type wrapNode struct {
+	inner InodeEmbedder
+	mw    []Middleware
+}
+
+var _ = (NodeWrapChilder)((*wrapNode)(nil))
+var _ = (NodeOnAdder)((*wrapNode)(nil))
+var _ = (NodeOnForgetter)((*wrapNode)(nil))
+var _ = (NodeLookuper)((*wrapNode)(nil))
+var _ = (NodeIDLookuper)((*wrapNode)(nil))
+var _ = (NodeGetattrer)((*wrapNode)(nil))
+var _ = (NodeSetattrer)((*wrapNode)(nil))
+var _ = (NodeStatxer)((*wrapNode)(nil))
+var _ = (NodeAccesser)((*wrapNode)(nil))
+var _ = (NodeStatfser)((*wrapNode)(nil))
+var _ = (NodeOpendirHandler)((*wrapNode)(nil))
+var _ = (NodeOpendirer)((*wrapNode)(nil))
+var _ = (NodeReaddirer)((*wrapNode)(nil))
+var _ = (NodeOpener)((*wrapNode)(nil))
+var _ = (NodeCreater)((*wrapNode)(nil))
+var _ = (NodeMkdirer)((*wrapNode)(nil))
+var _ = (NodeMknoder)((*wrapNode)(nil))
+var _ = (NodeLinker)((*wrapNode)(nil))
+var _ = (NodeSymlinker)((*wrapNode)(nil))
+var _ = (NodeReadlinker)((*wrapNode)(nil))
+var _ = (NodeUnlinker)((*wrapNode)(nil))
+var _ = (NodeRmdirer)((*wrapNode)(nil))
+var _ = (NodeRenamer)((*wrapNode)(nil))
+var _ = (NodeGetxattrer)((*wrapNode)(nil))
+var _ = (NodeSetxattrer)((*wrapNode)(nil))
+var _ = (NodeRemovexattrer)((*wrapNode)(nil))
+var _ = (NodeListxattrer)((*wrapNode)(nil))
+var _ = (NodeReader)((*wrapNode)(nil))
+var _ = (NodeWriter)((*wrapNode)(nil))
+var _ = (NodeFlusher)((*wrapNode)(nil))
+var _ = (NodeReleaser)((*wrapNode)(nil))
+var _ = (NodeFsyncer)((*wrapNode)(nil))
+var _ = (NodeAllocater)((*wrapNode)(nil))
+var _ = (NodeGetlker)((*wrapNode)(nil))
+var _ = (NodeSetlker)((*wrapNode)(nil))
+var _ = (NodeSetlkwer)((*wrapNode)(nil))
+var _ = (NodeCopyFileRanger)((*wrapNode)(nil))
+var _ = (NodeIoctler)((*wrapNode)(nil))
+var _ = (NodeLseeker)((*wrapNode)(nil))
+
+// asNode returns ops as T. A node created by Wrap only counts as T
+// if the node it wraps implements T.
+func asNode[T any](ops InodeEmbedder) (T, bool) {
+	if w, ok := ops.(*wrapNode); ok {
+		if _, ok := asNode[T](w.inner); !ok {
+			var zero T
+			return zero, false
+		}
+	}
+	t, ok := ops.(T)
+	return t, ok
+}
+
+// asFile returns f as T. A handle wrapped by Wrap only counts as T
+// if the handle it wraps implements T.
+func asFile[T any](f FileHandle) (T, bool) {
+	if w, ok := f.(*wrapFile); ok {
+		if _, ok := asFile[T](w.inner); !ok {
+			var zero T
+			return zero, false
+		}
+	}
+	t, ok := f.(T)
+	return t, ok
+}
+
+func (w *wrapNode) embed() *Inode {
+	return w.inner.embed()
+}
+
+func (w *wrapNode) EmbeddedInode() *Inode {
+	return w.inner.EmbeddedInode()
+}
+
+// unwrapOps returns the node that ops wraps.
+func unwrapOps(ops InodeEmbedder) InodeEmbedder {
+	for {
+		w, ok := ops.(*wrapNode)
+		if !ok {
+			return ops
+		}
+		ops = w.inner
+	}
+}
+
+// unwrapFile returns the handle that f wraps. Node methods get the
+// handles of the inner node.
+func unwrapFile(f FileHandle) FileHandle {
+	if w, ok := f.(*wrapFile); ok {
+		return w.inner
+	}
+	return f
+}
+
+// wrapHandle wraps a handle returned by the inner node.
+func (w *wrapNode) wrapHandle(f FileHandle) FileHandle {
+	if f == nil {
+		return nil
+	}
+	return &wrapFile{node: w, inner: f}
+}
+
+// call runs fn through the middleware.
+func (w *wrapNode) call(ctx context.Context, name string, f FileHandle, fn func(ctx context.Context) syscall.Errno, args ...any) syscall.Errno {
+	op := &Op{Name: name, Node: w.EmbeddedInode(), File: f, Args: args}
+	return w.run(ctx, op, 0, fn)
+}
+
+func (w *wrapNode) run(ctx context.Context, op *Op, i int, fn func(ctx context.Context) syscall.Errno) syscall.Errno {
+	if i == len(w.mw) {
+		return fn(ctx)
+	}
+	return w.mw[i](ctx, op, func(ctx context.Context) syscall.Errno {
+		return w.run(ctx, op, i+1, fn)
+	})
+}
+
+func (w *wrapNode) WrapChild(ctx context.Context, ops InodeEmbedder) InodeEmbedder {
+	if wc, ok := w.inner.(NodeWrapChilder); ok {
+		ops = wc.WrapChild(ctx, ops)
+	}
+	return &wrapNode{inner: ops, mw: w.mw}
+}
+
+func (w *wrapNode) OnAdd(ctx context.Context) {
+	if oa, ok := w.inner.(NodeOnAdder); ok {
+		oa.OnAdd(ctx)
+	}
+}
+
+func (w *wrapNode) OnForget() {
+	if of, ok := w.inner.(NodeOnForgetter); ok {
+		of.OnForget()
+	}
+}
+
+func (w *wrapNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (child *Inode, errno syscall.Errno) {
+	errno = w.call(ctx, "Lookup", nil, func(ctx context.Context) syscall.Errno {
+		child, errno = w.inner.(NodeLookuper).Lookup(ctx, name, out)
+		return errno
+	}, name, out)
+	if errno != 0 {
+		child = nil
+	}
+	return child, errno
+}
+
+func (w *wrapNode) LookupID(ctx context.Context, ino uint64, out *fuse.EntryOut) (child *Inode, errno syscall.Errno) {
+	errno = w.call(ctx, "LookupID", nil, func(ctx context.Context) syscall.Errno {
+		child, errno = w.inner.(NodeIDLookuper).LookupID(ctx, ino, out)
+		return errno
+	}, ino, out)
+	if errno != 0 {
+		child = nil
+	}
+	return child, errno
+}
+
+func (w *wrapNode) Getattr(ctx context.Context, f FileHandle, out *fuse.AttrOut) syscall.Errno {
+	f = unwrapFile(f)
+	return w.call(ctx, "Getattr", f, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeGetattrer).Getattr(ctx, f, out)
+	}, out)
+}
+
+func (w *wrapNode) Setattr(ctx context.Context, f FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	f = unwrapFile(f)
+	return w.call(ctx, "Setattr", f, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeSetattrer).Setattr(ctx, f, in, out)
+	}, in, out)
+}
+
+func (w *wrapNode) Statx(ctx context.Context, f FileHandle, flags uint32, mask uint32, out *fuse.StatxOut) syscall.Errno {
+	f = unwrapFile(f)
+	return w.call(ctx, "Statx", f, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeStatxer).Statx(ctx, f, flags, mask, out)
+	}, flags, mask, out)
+}
+
+func (w *wrapNode) Access(ctx context.Context, mask uint32) syscall.Errno {
+	return w.call(ctx, "Access", nil, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeAccesser).Access(ctx, mask)
+	}, mask)
+}
+
+func (w *wrapNode) Statfs(ctx context.Context, out *fuse.StatfsOut) syscall.Errno {
+	return w.call(ctx, "Statfs", nil, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeStatfser).Statfs(ctx, out)
+	}, out)
+}
+
+func (w *wrapNode) OpendirHandle(ctx context.Context, flags uint32) (fh FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = w.call(ctx, "OpendirHandle", nil, func(ctx context.Context) syscall.Errno {
+		fh, fuseFlags, errno = w.inner.(NodeOpendirHandler).OpendirHandle(ctx, flags)
+		return errno
+	}, flags)
+	if errno != 0 {
+		return nil, 0, errno
+	}
+	return w.wrapHandle(fh), fuseFlags, OK
+}
+
+func (w *wrapNode) Opendir(ctx context.Context) syscall.Errno {
+	return w.call(ctx, "Opendir", nil, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeOpendirer).Opendir(ctx)
+	})
+}
+
+func (w *wrapNode) Readdir(ctx context.Context) (ds DirStream, errno syscall.Errno) {
+	errno = w.call(ctx, "Readdir", nil, func(ctx context.Context) syscall.Errno {
+		ds, errno = w.inner.(NodeReaddirer).Readdir(ctx)
+		return errno
+	})
+	if errno != 0 {
+		ds = nil
+	}
+	return ds, errno
+}
+
+func (w *wrapNode) Open(ctx context.Context, flags uint32) (fh FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = w.call(ctx, "Open", nil, func(ctx context.Context) syscall.Errno {
+		fh, fuseFlags, errno = w.inner.(NodeOpener).Open(ctx, flags)
+		return errno
+	}, flags)
+	if errno != 0 {
+		return nil, 0, errno
+	}
+	return w.wrapHandle(fh), fuseFlags, OK
+}
+
+func (w *wrapNode) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (node *Inode, fh FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = w.call(ctx, "Create", nil, func(ctx context.Context) syscall.Errno {
+		node, fh, fuseFlags, errno = w.inner.(NodeCreater).Create(ctx, name, flags, mode, out)
+		return errno
+	}, name, flags, mode, out)
+	if errno != 0 {
+		return nil, nil, 0, errno
+	}
+	if node != nil {
+		if cw, ok := node.ops.(*wrapNode); ok {
+			return node, cw.wrapHandle(fh), fuseFlags, OK
+		}
+	}
+	return node, w.wrapHandle(fh), fuseFlags, OK
+}
+
+// newEntry runs an operation that returns a new child.
+func (w *wrapNode) newEntry(ctx context.Context, name string, fn func(ctx context.Context) (*Inode, syscall.Errno), args ...any) (*Inode, syscall.Errno) {
+	var child *Inode
+	errno := w.call(ctx, name, nil, func(ctx context.Context) syscall.Errno {
+		var errno syscall.Errno
+		child, errno = fn(ctx)
+		return errno
+	}, args...)
+	if errno != 0 {
+		return nil, errno
+	}
+	return child, OK
+}
+
+func (w *wrapNode) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	return w.newEntry(ctx, "Mkdir", func(ctx context.Context) (*Inode, syscall.Errno) {
+		return w.inner.(NodeMkdirer).Mkdir(ctx, name, mode, out)
+	}, name, mode, out)
+}
+
+func (w *wrapNode) Mknod(ctx context.Context, name string, mode uint32, dev uint32, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	return w.newEntry(ctx, "Mknod", func(ctx context.Context) (*Inode, syscall.Errno) {
+		return w.inner.(NodeMknoder).Mknod(ctx, name, mode, dev, out)
+	}, name, mode, dev, out)
+}
+
+func (w *wrapNode) Link(ctx context.Context, target InodeEmbedder, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	target = unwrapOps(target)
+	return w.newEntry(ctx, "Link", func(ctx context.Context) (*Inode, syscall.Errno) {
+		return w.inner.(NodeLinker).Link(ctx, target, name, out)
+	}, target, name, out)
+}
+
+func (w *wrapNode) Symlink(ctx context.Context, target, name string, out *fuse.EntryOut) (*Inode, syscall.Errno) {
+	return w.newEntry(ctx, "Symlink", func(ctx context.Context) (*Inode, syscall.Errno) {
+		return w.inner.(NodeSymlinker).Symlink(ctx, target, name, out)
+	}, target, name, out)
+}
+
+func (w *wrapNode) Readlink(ctx context.Context) (target []byte, errno syscall.Errno) {
+	errno = w.call(ctx, "Readlink", nil, func(ctx context.Context) syscall.Errno {
+		target, errno = w.inner.(NodeReadlinker).Readlink(ctx)
+		return errno
+	})
+	if errno != 0 {
+		return nil, errno
+	}
+	return target, OK
+}
+
+func (w *wrapNode) Unlink(ctx context.Context, name string) syscall.Errno {
+	return w.call(ctx, "Unlink", nil, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeUnlinker).Unlink(ctx, name)
+	}, name)
+}
+
+func (w *wrapNode) Rmdir(ctx context.Context, name string) syscall.Errno {
+	return w.call(ctx, "Rmdir", nil, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeRmdirer).Rmdir(ctx, name)
+	}, name)
+}
+
+func (w *wrapNode) Rename(ctx context.Context, name string, newParent InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	newParent = unwrapOps(newParent)
+	return w.call(ctx, "Rename", nil, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeRenamer).Rename(ctx, name, newParent, newName, flags)
+	}, name, newParent, newName, flags)
+}
+
+func (w *wrapNode) Getxattr(ctx context.Context, attr string, dest []byte) (sz uint32, errno syscall.Errno) {
+	errno = w.call(ctx, "Getxattr", nil, func(ctx context.Context) syscall.Errno {
+		sz, errno = w.inner.(NodeGetxattrer).Getxattr(ctx, attr, dest)
+		return errno
+	}, attr, dest)
+	return sz, errno
+}
+
+func (w *wrapNode) Setxattr(ctx context.Context, attr string, data []byte, flags uint32) syscall.Errno {
+	return w.call(ctx, "Setxattr", nil, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeSetxattrer).Setxattr(ctx, attr, data, flags)
+	}, attr, data, flags)
+}
+
+func (w *wrapNode) Removexattr(ctx context.Context, attr string) syscall.Errno {
+	return w.call(ctx, "Removexattr", nil, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeRemovexattrer).Removexattr(ctx, attr)
+	}, attr)
+}
+
+func (w *wrapNode) Listxattr(ctx context.Context, dest []byte) (sz uint32, errno syscall.Errno) {
+	errno = w.call(ctx, "Listxattr", nil, func(ctx context.Context) syscall.Errno {
+		sz, errno = w.inner.(NodeListxattrer).Listxattr(ctx, dest)
+		return errno
+	}, dest)
+	return sz, errno
+}
+
+func (w *wrapNode) Read(ctx context.Context, f FileHandle, dest []byte, off int64) (res fuse.ReadResult, errno syscall.Errno) {
+	f = unwrapFile(f)
+	errno = w.call(ctx, "Read", f, func(ctx context.Context) syscall.Errno {
+		res, errno = w.inner.(NodeReader).Read(ctx, f, dest, off)
+		return errno
+	}, dest, off)
+	return res, errno
+}
+
+func (w *wrapNode) Write(ctx context.Context, f FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	f = unwrapFile(f)
+	errno = w.call(ctx, "Write", f, func(ctx context.Context) syscall.Errno {
+		written, errno = w.inner.(NodeWriter).Write(ctx, f, data, off)
+		return errno
+	}, data, off)
+	return written, errno
+}
+
+func (w *wrapNode) Flush(ctx context.Context, f FileHandle) syscall.Errno {
+	f = unwrapFile(f)
+	return w.call(ctx, "Flush", f, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeFlusher).Flush(ctx, f)
+	})
+}
+
+func (w *wrapNode) Release(ctx context.Context, f FileHandle) syscall.Errno {
+	f = unwrapFile(f)
+	return w.call(ctx, "Release", f, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeReleaser).Release(ctx, f)
+	})
+}
+
+func (w *wrapNode) Fsync(ctx context.Context, f FileHandle, flags uint32) syscall.Errno {
+	f = unwrapFile(f)
+	return w.call(ctx, "Fsync", f, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeFsyncer).Fsync(ctx, f, flags)
+	}, flags)
+}
+
+func (w *wrapNode) Allocate(ctx context.Context, f FileHandle, off uint64, size uint64, mode uint32) syscall.Errno {
+	f = unwrapFile(f)
+	return w.call(ctx, "Allocate", f, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeAllocater).Allocate(ctx, f, off, size, mode)
+	}, off, size, mode)
+}
+
+func (w *wrapNode) Getlk(ctx context.Context, f FileHandle, owner uint64, lk *fuse.FileLock, flags uint32, out *fuse.FileLock) syscall.Errno {
+	f = unwrapFile(f)
+	return w.call(ctx, "Getlk", f, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeGetlker).Getlk(ctx, f, owner, lk, flags, out)
+	}, owner, lk, flags, out)
+}
+
+func (w *wrapNode) Setlk(ctx context.Context, f FileHandle, owner uint64, lk *fuse.FileLock, flags uint32) syscall.Errno {
+	f = unwrapFile(f)
+	return w.call(ctx, "Setlk", f, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeSetlker).Setlk(ctx, f, owner, lk, flags)
+	}, owner, lk, flags)
+}
+
+func (w *wrapNode) Setlkw(ctx context.Context, f FileHandle, owner uint64, lk *fuse.FileLock, flags uint32) syscall.Errno {
+	f = unwrapFile(f)
+	return w.call(ctx, "Setlkw", f, func(ctx context.Context) syscall.Errno {
+		return w.inner.(NodeSetlkwer).Setlkw(ctx, f, owner, lk, flags)
+	}, owner, lk, flags)
+}
+
+func (w *wrapNode) CopyFileRange(ctx context.Context, fhIn FileHandle, offIn uint64, out *Inode, fhOut FileHandle, offOut uint64, len uint64, flags uint64) (sz uint32, errno syscall.Errno) {
+	fhIn, fhOut = unwrapFile(fhIn), unwrapFile(fhOut)
+	errno = w.call(ctx, "CopyFileRange", fhIn, func(ctx context.Context) syscall.Errno {
+		sz, errno = w.inner.(NodeCopyFileRanger).CopyFileRange(ctx, fhIn, offIn, out, fhOut, offOut, len, flags)
+		return errno
+	}, offIn, out, fhOut, offOut, len, flags)
+	return sz, errno
+}
+
+func (w *wrapNode) Ioctl(ctx context.Context, f FileHandle, cmd uint32, arg uint64, input []byte, output []byte) (result int32, errno syscall.Errno) {
+	f = unwrapFile(f)
+	errno = w.call(ctx, "Ioctl", f, func(ctx context.Context) syscall.Errno {
+		result, errno = w.inner.(NodeIoctler).Ioctl(ctx, f, cmd, arg, input, output)
+		return errno
+	}, cmd, arg, input, output)
+	return result, errno
+}
+
+func (w *wrapNode) Lseek(ctx context.Context, f FileHandle, off uint64, whence uint32) (result uint64, errno syscall.Errno) {
+	f = unwrapFile(f)
+	errno = w.call(ctx, "Lseek", f, func(ctx context.Context) syscall.Errno {
+		result, errno = w.inner.(NodeLseeker).Lseek(ctx, f, off, whence)
+		return errno
+	}, off, whence)
+	return result, errno
+}
+
+// wrapFile runs the middleware for a file handle. Like wrapNode, it
+// has the methods of all file interfaces, but the bridge only uses
+// those that the inner handle implements; see asFile.
+type wrapFile struct {
+	node  *wrapNode
+	inner FileHandle
+}
+
+var _ = (FilePassthroughFder)((*wrapFile)(nil))
+var _ = (FileReleaser)((*wrapFile)(nil))
+var _ = (FileGetattrer)((*wrapFile)(nil))
+var _ = (FileStatxer)((*wrapFile)(nil))
+var _ = (FileReader)((*wrapFile)(nil))
+var _ = (FileWriter)((*wrapFile)(nil))
+var _ = (FileGetlker)((*wrapFile)(nil))
+var _ = (FileSetlker)((*wrapFile)(nil))
+var _ = (FileSetlkwer)((*wrapFile)(nil))
+var _ = (FileUnlocker)((*wrapFile)(nil))
+var _ = (FileLseeker)((*wrapFile)(nil))
+var _ = (FileFlusher)((*wrapFile)(nil))
+var _ = (FileFsyncer)((*wrapFile)(nil))
+var _ = (FileSetattrer)((*wrapFile)(nil))
+var _ = (FileAllocater)((*wrapFile)(nil))
+var _ = (FileIoctler)((*wrapFile)(nil))
+var _ = (FileReaddirenter)((*wrapFile)(nil))
+var _ = (FileLookuper)((*wrapFile)(nil))
+var _ = (FileFsyncdirer)((*wrapFile)(nil))
+var _ = (FileSeekdirer)((*wrapFile)(nil))
+var _ = (FileReleasedirer)((*wrapFile)(nil))
+
+func (f *wrapFile) call(ctx context.Context, name string, fn func(ctx context.Context) syscall.Errno, args ...any) syscall.Errno {
+	return f.node.call(ctx, name, f.inner, fn, args...)
+}
+
+func (f *wrapFile) PassthroughFd() (int, bool) {
+	return f.inner.(FilePassthroughFder).PassthroughFd()
+}
+
+func (f *wrapFile) Release(ctx context.Context) syscall.Errno {
+	return f.call(ctx, "Release", func(ctx context.Context) syscall.Errno {
+		return f.inner.(FileReleaser).Release(ctx)
+	})
+}
+
+func (f *wrapFile) Getattr(ctx context.Context, out *fuse.AttrOut) syscall.Errno {
+	return f.call(ctx, "Getattr", func(ctx context.Context) syscall.Errno {
+		return f.inner.(FileGetattrer).Getattr(ctx, out)
+	}, out)
+}
+
+func (f *wrapFile) Statx(ctx context.Context, flags uint32, mask uint32, out *fuse.StatxOut) syscall.Errno {
+	return f.call(ctx, "Statx", func(ctx context.Context) syscall.Errno {
+		return f.inner.(FileStatxer).Statx(ctx, flags, mask, out)
+	}, flags, mask, out)
+}
+
+func (f *wrapFile) Read(ctx context.Context, dest []byte, off int64) (res fuse.ReadResult, errno syscall.Errno) {
+	errno = f.call(ctx, "Read", func(ctx context.Context) syscall.Errno {
+		res, errno = f.inner.(FileReader).Read(ctx, dest, off)
+		return errno
+	}, dest, off)
+	return res, errno
+}
+
+func (f *wrapFile) Write(ctx context.Context, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	errno = f.call(ctx, "Write", func(ctx context.Context) syscall.Errno {
+		written, errno = f.inner.(FileWriter).Write(ctx, data, off)
+		return errno
+	}, data, off)
+	return written, errno
+}
+
+func (f *wrapFile) Getlk(ctx context.Context, owner uint64, lk *fuse.FileLock, flags uint32, out *fuse.FileLock) syscall.Errno {
+	return f.call(ctx, "Getlk", func(ctx context.Context) syscall.Errno {
+		return f.inner.(FileGetlker).Getlk(ctx, owner, lk, flags, out)
+	}, owner, lk, flags, out)
+}
+
+func (f *wrapFile) Setlk(ctx context.Context, owner uint64, lk *fuse.FileLock, flags uint32) syscall.Errno {
+	return f.call(ctx, "Setlk", func(ctx context.Context) syscall.Errno {
+		return f.inner.(FileSetlker).Setlk(ctx, owner, lk, flags)
+	}, owner, lk, flags)
+}
+
+func (f *wrapFile) Setlkw(ctx context.Context, owner uint64, lk *fuse.FileLock, flags uint32) syscall.Errno {
+	return f.call(ctx, "Setlkw", func(ctx context.Context) syscall.Errno {
+		return f.inner.(FileSetlkwer).Setlkw(ctx, owner, lk, flags)
+	}, owner, lk, flags)
+}
+
+func (f *wrapFile) Unlock(ctx context.Context, owner uint64, flags uint32) {
+	f.call(ctx, "Unlock", func(ctx context.Context) syscall.Errno {
+		f.inner.(FileUnlocker).Unlock(ctx, owner, flags)
+		return OK
+	}, owner, flags)
+}
+
+func (f *wrapFile) Lseek(ctx context.Context, off uint64, whence uint32) (result uint64, errno syscall.Errno) {
+	errno = f.call(ctx, "Lseek", func(ctx context.Context) syscall.Errno {
+		result, errno = f.inner.(FileLseeker).Lseek(ctx, off, whence)
+		return errno
+	}, off, whence)
+	return result, errno
+}
+
+func (f *wrapFile) Flush(ctx context.Context) syscall.Errno {
+	return f.call(ctx, "Flush", func(ctx context.Context) syscall.Errno {
+		return f.inner.(FileFlusher).Flush(ctx)
+	})
+}
+
+func (f *wrapFile) Fsync(ctx context.Context, flags uint32) syscall.Errno {
+	return f.call(ctx, "Fsync", func(ctx context.Context) syscall.Errno {
+		return f.inner.(FileFsyncer).Fsync(ctx, flags)
+	}, flags)
+}
+
+func (f *wrapFile) Setattr(ctx context.Context, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	return f.call(ctx, "Setattr", func(ctx context.Context) syscall.Errno {
+		return f.inner.(FileSetattrer).Setattr(ctx, in, out)
+	}, in, out)
+}
+
+func (f *wrapFile) Allocate(ctx context.Context, off uint64, size uint64, mode uint32) syscall.Errno {
+	return f.call(ctx, "Allocate", func(ctx context.Context) syscall.Errno {
+		return f.inner.(FileAllocater).Allocate(ctx, off, size, mode)
+	}, off, size, mode)
+}
+
+func (f *wrapFile) Ioctl(ctx context.Context, cmd uint32, arg uint64, input []byte, output []byte) (result int32, errno syscall.Errno) {
+	errno = f.call(ctx, "Ioctl", func(ctx context.Context) syscall.Errno {
+		result, errno = f.inner.(FileIoctler).Ioctl(ctx, cmd, arg, input, output)
+		return errno
+	}, cmd, arg, input, output)
+	return result, errno
+}
+
+func (f *wrapFile) Readdirent(ctx context.Context) (de *fuse.DirEntry, errno syscall.Errno) {
+	errno = f.call(ctx, "Readdirent", func(ctx context.Context) syscall.Errno {
+		de, errno = f.inner.(FileReaddirenter).Readdirent(ctx)
+		return errno
+	})
+	return de, errno
+}
+
+func (f *wrapFile) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (child *Inode, errno syscall.Errno) {
+	errno = f.call(ctx, "Lookup", func(ctx context.Context) syscall.Errno {
+		child, errno = f.inner.(FileLookuper).Lookup(ctx, name, out)
+		return errno
+	}, name, out)
+	if errno != 0 {
+		child = nil
+	}
+	return child, errno
+}
+
+func (f *wrapFile) Fsyncdir(ctx context.Context, flags uint32) syscall.Errno {
+	return f.call(ctx, "Fsyncdir", func(ctx context.Context) syscall.Errno {
+		return f.inner.(FileFsyncdirer).Fsyncdir(ctx, flags)
+	}, flags)
+}
+
+func (f *wrapFile) Seekdir(ctx context.Context, off uint64) syscall.Errno {
+	return f.call(ctx, "Seekdir", func(ctx context.Context) syscall.Errno {
+		return f.inner.(FileSeekdirer).Seekdir(ctx, off)
+	}, off)
+}
+
+func (f *wrapFile) Releasedir(ctx context.Context, releaseFlags uint32) {
+	f.call(ctx, "Releasedir", func(ctx context.Context) syscall.Errno {
+		f.inner.(FileReleasedirer).Releasedir(ctx, releaseFlags)
+		return OK
+	}, releaseFlags)
+}
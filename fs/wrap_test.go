@@ -0,0 +1,187 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/posixtest"
+)
+
+// opLog is a Middleware that records the operations it sees.
+type opLog struct {
+	mu  sync.Mutex
+	ops map[string]int
+}
+
+func (l *opLog) intercept(ctx context.Context, op *Op, next func(context.Context) syscall.Errno) syscall.Errno {
+	l.mu.Lock()
+	if l.ops == nil {
+		l.ops = map[string]int{}
+	}
+	l.ops[op.Name]++
+	l.mu.Unlock()
+	return next(ctx)
+}
+
+func (l *opLog) count(name string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ops[name]
+}
+
+// total returns the number of operations seen.
+func (l *opLog) total() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, c := range l.ops {
+		n += c
+	}
+	return n
+}
+
+func wrapReadOnly(ctx context.Context, op *Op, next func(context.Context) syscall.Errno) syscall.Errno {
+	switch op.Name {
+	case "Setattr", "Create", "Mkdir", "Mknod", "Link", "Symlink", "Unlink", "Rmdir",
+		"Rename", "Setxattr", "Removexattr", "Write", "Allocate", "CopyFileRange":
+		return syscall.EROFS
+	case "Open":
+		if op.Args[0].(uint32)&(syscall.O_WRONLY|syscall.O_RDWR|syscall.O_TRUNC) != 0 {
+			return syscall.EROFS
+		}
+	}
+	return next(ctx)
+}
+
+func TestWrapPosix(t *testing.T) {
+	roots := map[string]func(t *testing.T) InodeEmbedder{
+		"memfs": func(t *testing.T) InodeEmbedder { return NewMemFS(nil) },
+		"loopback": func(t *testing.T) InodeEmbedder {
+			root, err := NewLoopbackRoot(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return root
+		},
+	}
+	for rootName, newRoot := range roots {
+		for nm, fn := range posixtest.All {
+			if nm == "FcntlFlockLocksFile" {
+				// See TestMemFSPosix.
+				continue
+			}
+			t.Run(rootName+"/"+nm, func(t *testing.T) {
+				var log opLog
+				mnt, _ := testMount(t, Wrap(newRoot(t), log.intercept), &Options{})
+				fn(t, mnt)
+				// memfs directories use the bridge defaults for
+				// Lookup and Readdir, which the middleware does not see.
+				if rootName == "loopback" && log.total() == 0 {
+					t.Errorf("no operations intercepted")
+				}
+			})
+		}
+	}
+}
+
+type wrapTestRoot struct {
+	Inode
+}
+
+func (r *wrapTestRoot) OnAdd(ctx context.Context) {
+	dir := r.NewPersistentInode(ctx, &Inode{}, StableAttr{Mode: syscall.S_IFDIR})
+	r.AddChild("dir", dir, false)
+	file := dir.NewPersistentInode(ctx, &MemRegularFile{
+		Data: []byte("hello"),
+		Attr: fuse.Attr{Mode: 0644},
+	}, StableAttr{})
+	dir.AddChild("file", file, false)
+}
+
+func TestWrapReadOnly(t *testing.T) {
+	var log opLog
+	root := &wrapTestRoot{}
+	mnt, _ := testMount(t, Wrap(root, log.intercept, wrapReadOnly), &Options{})
+
+	if got, err := os.ReadFile(mnt + "/dir/file"); err != nil || string(got) != "hello" {
+		t.Errorf("ReadFile: %q, %v", got, err)
+	}
+	if err := os.WriteFile(mnt+"/dir/file", []byte("bye"), 0644); !errors.Is(err, syscall.EROFS) {
+		t.Errorf("WriteFile: got %v, want EROFS", err)
+	}
+	// The directory does not implement NodeMkdirer, so the bridge
+	// rejects the call before the middleware sees it.
+	if err := os.Mkdir(mnt+"/dir/sub", 0755); !errors.Is(err, syscall.ENOTSUP) {
+		t.Errorf("Mkdir: got %v, want ENOTSUP", err)
+	}
+	if es, err := os.ReadDir(mnt + "/dir"); err != nil || len(es) != 1 || es[0].Name() != "file" {
+		t.Errorf("ReadDir: %v, %v", es, err)
+	}
+
+	// The persistent children were wrapped too.
+	for _, nm := range []string{"Open", "Read"} {
+		if log.count(nm) == 0 {
+			t.Errorf("%s was not intercepted", nm)
+		}
+	}
+	// The directories list and look up their children through the
+	// bridge defaults.
+	for _, nm := range []string{"Lookup", "Readdir", "Readdirent", "Mkdir"} {
+		if c := log.count(nm); c != 0 {
+			t.Errorf("%s was intercepted %d times", nm, c)
+		}
+	}
+
+	ch := root.GetChild("dir").GetChild("file")
+	if _, ok := ch.Operations().(*MemRegularFile); !ok {
+		t.Errorf("Operations: got %T, want *MemRegularFile", ch.Operations())
+	}
+}
+
+func TestWrapInterfaces(t *testing.T) {
+	dir := Wrap(&Inode{}, wrapReadOnly)
+	if _, ok := asNode[NodeLookuper](dir); ok {
+		t.Error("wrapped Inode implements NodeLookuper")
+	}
+	if _, ok := asNode[NodeAccesser](dir); ok {
+		t.Error("wrapped Inode implements NodeAccesser")
+	}
+
+	file := Wrap(&MemRegularFile{}, wrapReadOnly)
+	if _, ok := asNode[NodeOpener](file); !ok {
+		t.Error("wrapped MemRegularFile does not implement NodeOpener")
+	}
+	if _, ok := asNode[NodeReadlinker](file); ok {
+		t.Error("wrapped MemRegularFile implements NodeReadlinker")
+	}
+
+	loop, err := NewLoopbackRoot(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh, _, errno := Wrap(loop, wrapReadOnly).(NodeOpendirHandler).OpendirHandle(context.Background(), 0)
+	if errno != 0 {
+		t.Fatalf("OpendirHandle: %v", errno)
+	}
+	if _, ok := fh.(*wrapFile); !ok {
+		t.Errorf("OpendirHandle: got %T, want *wrapFile", fh)
+	}
+	if _, ok := asFile[FileReaddirenter](fh); !ok {
+		t.Error("wrapped directory handle does not implement FileReaddirenter")
+	}
+	if _, ok := asFile[FileWriter](fh); ok {
+		t.Error("wrapped directory handle implements FileWriter")
+	}
+	if rd, ok := asFile[FileReleasedirer](fh); ok {
+		rd.Releasedir(context.Background(), 0)
+	}
+}
@@ -132,7 +132,10 @@
 // the Dev field in the Stat_t result for a file in the mount.
 package fuse
 
-import "log"
+import (
+	"log"
+	"time"
+)
 
 // Types for users to implement.
 
@@ -178,6 +181,10 @@ type MountOptions struct {
 	// is still allowed through.
 	MaxInflightRequestBytes int
 
+	// Scheduler, if set, enables per-caller fair scheduling of
+	// requests. See SchedulerOptions.
+	Scheduler *SchedulerOptions
+
 	// CongestionThreshold is the in-flight async-request count at which
 	// the kernel marks the FUSE backing-dev as congested, throttling new
 	// submissions. It corresponds to
@@ -348,6 +355,15 @@ type MountOptions struct {
 	// for details.
 	EnableAcl bool
 
+	// EnableExportSupport, if set, tells the kernel that the file
+	// system answers lookups of "." and "..", so the mount can be
+	// exported over NFS. File handles handed out to NFS clients
+	// encode the node ID and generation of a file, and the kernel
+	// looks up "." in a node ID it has forgotten to resolve a
+	// stale handle; the file system must be able to find such
+	// nodes again.
+	EnableExportSupport bool
+
 	// DisableReadDirPlus, if set, disables the ReadDirPlus capability so
 	// ReadDir is used instead. Simple directory queries (i.e. 'ls' without
 	// '-l') can be faster with ReadDir, as no per-file stat calls are needed.
@@ -361,6 +377,18 @@ type MountOptions struct {
 	// to print a stack trace and return EIO.
 	PanicHandler func(any) Status
 
+	// RequestTimeout, if nonzero, is the time a request may take.
+	// When it passes, the request is canceled as if it were
+	// interrupted. Context.Deadline reports the deadline, and
+	// context.Cause returns ErrRequestTimeout.
+	RequestTimeout time.Duration
+
+	// RequestTimeouts overrides RequestTimeout for specific
+	// opcodes. It is keyed by opcode name, as used in
+	// LatencyMap, eg. "READ" or "LOOKUP". A zero value disables
+	// the deadline for that opcode.
+	RequestTimeouts map[string]time.Duration
+
 	// MaxStackDepth is the maximum stacking depth for passthrough files.
 	// If unset, the default is 1.
 	MaxStackDepth int
@@ -6,9 +6,28 @@ package fuse
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 )
 
+// Causes for canceling a request, as returned by context.Cause on a
+// *Context.
+var (
+	// ErrInterrupted means that the kernel interrupted the
+	// request, usually because the calling process received a
+	// signal.
+	ErrInterrupted = fmt.Errorf("fuse: request interrupted: %w", context.Canceled)
+
+	// ErrServerShutdown means that the connection to the kernel
+	// was lost, usually because the file system was unmounted.
+	ErrServerShutdown = fmt.Errorf("fuse: server shut down: %w", context.Canceled)
+
+	// ErrRequestTimeout means that the request took longer than
+	// MountOptions.RequestTimeout.
+	ErrRequestTimeout = fmt.Errorf("fuse: request timed out: %w", context.DeadlineExceeded)
+)
+
 // Context passes along cancelation signal and request data (PID, GID,
 // UID).  The name of this class predates the standard "context"
 // package from Go, but it does implement the context.Context
@@ -16,12 +35,55 @@ import (
 //
 // When a FUSE request is canceled, and the file system chooses to honor
 // the cancellation, the response should be EINTR.
+//
+// For a Context returned by Server.RequestContext, while the request
+// is in flight, Deadline returns the deadline set through
+// MountOptions.RequestTimeout, and context.Cause tells why the
+// request was canceled: ErrInterrupted, ErrServerShutdown or
+// ErrRequestTimeout.
 type Context struct {
 	Caller
 	Cancel <-chan struct{}
+
+	// server and unique identify the request, for contexts
+	// returned by Server.RequestContext.
+	server *protocolServer
+	unique uint64
+}
+
+// RequestContext returns the Context for a request, given the
+// header and cancel channel passed to the RawFileSystem. Unlike a
+// Context literal, it also reports the deadline and cancellation
+// cause of the request while it is in flight.
+func (ms *Server) RequestContext(header *InHeader, cancel <-chan struct{}) *Context {
+	return &Context{
+		Caller: header.Caller,
+		Cancel: cancel,
+		server: &ms.protocolServer,
+		unique: header.Unique,
+	}
+}
+
+// inflightLocked returns the request of c, or nil if it is no longer
+// in flight. It must be called under interruptMu.
+func (c *Context) inflightLocked() *request {
+	for _, req := range c.server.reqInflight {
+		if req.inHeader().Unique == c.unique {
+			return req
+		}
+	}
+	return nil
 }
 
 func (c *Context) Deadline() (time.Time, bool) {
+	if c.server == nil {
+		return time.Time{}, false
+	}
+	c.server.interruptMu.Lock()
+	defer c.server.interruptMu.Unlock()
+	if req := c.inflightLocked(); req != nil && !req.deadline.IsZero() {
+		return req.deadline, true
+	}
 	return time.Time{}, false
 }
 
@@ -32,12 +94,29 @@ func (c *Context) Done() <-chan struct{} {
 func (c *Context) Err() error {
 	select {
 	case <-c.Cancel:
+		if cause := c.cause(); cause != nil && errors.Is(context.Cause(cause), context.DeadlineExceeded) {
+			return context.DeadlineExceeded
+		}
 		return context.Canceled
 	default:
 		return nil
 	}
 }
 
+// cause returns a canceled context carrying the cancellation cause
+// of the request, or nil if it is unknown.
+func (c *Context) cause() context.Context {
+	if c.server == nil {
+		return nil
+	}
+	c.server.interruptMu.Lock()
+	defer c.server.interruptMu.Unlock()
+	if req := c.inflightLocked(); req != nil {
+		return req.cause
+	}
+	return nil
+}
+
 type callerKeyType struct{}
 
 var callerKey callerKeyType
@@ -51,11 +130,83 @@ func NewContext(ctx context.Context, caller *Caller) context.Context {
 	return context.WithValue(ctx, callerKey, caller)
 }
 
+// ToContext returns ctx as a *Context. If ctx is not a *Context, eg.
+// because middleware wrapped it, the result takes the caller stored
+// with NewContext and the cancellation of ctx.
+func ToContext(ctx context.Context) *Context {
+	if c, ok := ctx.(*Context); ok {
+		return c
+	}
+	c := &Context{Cancel: ctx.Done()}
+	if caller, ok := FromContext(ctx); ok {
+		c.Caller = *caller
+	}
+	return c
+}
+
 func (c *Context) Value(key interface{}) interface{} {
 	if key == callerKey {
 		return &c.Caller
 	}
+	if cause := c.cause(); cause != nil {
+		return cause.Value(key)
+	}
 	return nil
 }
 
 var _ = context.Context((*Context)(nil))
+
+// requestTimeout returns the timeout for the given opcode, or zero
+// if there is none.
+func (o *MountOptions) requestTimeout(op uint32) time.Duration {
+	if d, ok := o.RequestTimeouts[operationName(op)]; ok {
+		return d
+	}
+	return o.RequestTimeout
+}
+
+// startDeadline sets the deadline for the request, and arms a
+// timer to cancel it. It must be called under interruptMu.
+func (ms *protocolServer) startDeadline(req *request) {
+	d := ms.opts.requestTimeout(req.inHeader().Opcode)
+	if d <= 0 {
+		return
+	}
+	req.deadline = time.Now().Add(d)
+	unique := req.inHeader().Unique
+	req.deadlineTimer = time.AfterFunc(d, func() {
+		ms.interruptMu.Lock()
+		defer ms.interruptMu.Unlock()
+		for _, inflight := range ms.reqInflight {
+			if inflight.inHeader().Unique == unique {
+				ms.cancelLocked(inflight, ErrRequestTimeout)
+				return
+			}
+		}
+	})
+}
+
+// stopDeadline undoes startDeadline and cancelLocked. It must be
+// called under interruptMu.
+func (ms *protocolServer) stopDeadline(req *request) {
+	if req.deadlineTimer != nil {
+		req.deadlineTimer.Stop()
+		req.deadlineTimer = nil
+	}
+	req.deadline = time.Time{}
+	req.cause = nil
+}
+
+// cancelLocked closes the cancel channel of the request, recording
+// cause. It must be called under interruptMu.
+func (ms *protocolServer) cancelLocked(req *request, cause error) {
+	if req.interrupted {
+		return
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(cause)
+	req.cause = ctx
+
+	close(req.cancel)
+	req.interrupted = true
+}
@@ -183,3 +183,22 @@ func (l *DirEntryList) FixMode(mode uint32) {
 func (l *DirEntryList) bytes() []byte {
 	return l.buf
 }
+
+// Entries decodes the entries added with AddDirEntry. It lets
+// callers consume the output of RawFileSystem.ReadDir without a
+// kernel.
+func (l *DirEntryList) Entries() []DirEntry {
+	var r []DirEntry
+	for buf := l.buf; len(buf) >= direntSize; {
+		de := (*_Dirent)(unsafe.Pointer(&buf[0]))
+		end := direntSize + int(de.NameLen)
+		r = append(r, DirEntry{
+			Ino:  de.Ino,
+			Off:  de.Off,
+			Mode: de.Typ << 12,
+			Name: string(buf[direntSize:end]),
+		})
+		buf = buf[min(len(buf), (end+7)&^7):]
+	}
+	return r
+}
@@ -87,7 +87,10 @@ const (
 ////////////////////////////////////////////////////////////////
 
 func doInit(server *protocolServer, req *request) {
-	input := (*InitIn)(req.inData())
+	// Older kernels send a shorter InitIn; leave the missing
+	// fields zero.
+	input := &InitIn{}
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(input)), unsafe.Sizeof(*input)), req.inputBuf)
 	if input.Major != _FUSE_KERNEL_VERSION {
 		log.Printf("Major versions does not match. Given %d, want %d\n", input.Major, _FUSE_KERNEL_VERSION)
 		req.status = EIO
@@ -114,6 +117,9 @@ func doInit(server *protocolServer, req *request) {
 	if server.opts.EnableAcl {
 		kernelFlags |= input.Flags64() & CAP_POSIX_ACL
 	}
+	if server.opts.EnableExportSupport {
+		kernelFlags |= input.Flags64() & CAP_EXPORT_SUPPORT
+	}
 
 	if server.opts.ExplicitDataCacheControl {
 		// we don't want CAP_AUTO_INVAL_DATA even if we cannot go into fully explicit mode
@@ -308,19 +314,18 @@ func doForget(server *protocolServer, req *request) {
 
 // doBatchForget - forget a list of NodeIds
 func doBatchForget(server *protocolServer, req *request) {
-	in := (*_BatchForgetIn)(req.inData())
-	gotCount := len(req.inPayload) / int(unsafe.Sizeof(_ForgetOne{}))
-	if int(in.Count) > gotCount {
-		// We have no return value to complain, so log an error.
-		server.opts.Logger.Printf("Too few bytes for batch forget. Got %d bytes enough for %d entries (want %d entries)",
-			len(req.inPayload), gotCount, in.Count)
-	}
-	in.Count = uint32(gotCount)
-	if in.Count == 0 {
+	// checkInput has trimmed the payload to the announced count.
+	count := len(req.inPayload) / int(unsafe.Sizeof(_ForgetOne{}))
+	if count == 0 {
 		return
 	}
 
-	forgets := unsafe.Slice((*_ForgetOne)(unsafe.Pointer(&req.inPayload[0])), in.Count)
+	payload := req.inPayload
+	if uintptr(unsafe.Pointer(&payload[0]))%unsafe.Alignof(_ForgetOne{}) != 0 {
+		// Payloads passed to ProtocolServer may have any alignment.
+		payload = append([]byte(nil), payload...)
+	}
+	forgets := unsafe.Slice((*_ForgetOne)(unsafe.Pointer(&payload[0])), count)
 	for i, f := range forgets {
 		if server.opts.Debug {
 			server.opts.Logger.Printf("doBatchForget: rx %d %d/%d: FORGET n%d {Nlookup=%d}",
@@ -0,0 +1,336 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pathfs
+
+import (
+	"context"
+	"strings"
+	"syscall"
+	"time"
+
+	gofs "github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+)
+
+// NewPathFileSystem adapts fs to the path API of the fs package, so
+// it can be served by the new bridge:
+//
+//	root := gofs.NewPathRoot(pathfs.NewPathFileSystem(myFS), &gofs.PathOptions{ClientInodes: true})
+//	server, err := gofs.Mount(dir, root, nil)
+//
+// The returned file system calls neither OnMount nor OnUnmount, and
+// nodefs.File.SetInode is not called on opened files.
+func NewPathFileSystem(fs FileSystem) gofs.PathFileSystem {
+	return &fsAdapter{fs: fs}
+}
+
+type fsAdapter struct {
+	fs FileSystem
+}
+
+var _ = (gofs.PathSetattrer)((*fsAdapter)(nil))
+var _ = (gofs.PathAccesser)((*fsAdapter)(nil))
+var _ = (gofs.PathStatfser)((*fsAdapter)(nil))
+var _ = (gofs.PathReaddirer)((*fsAdapter)(nil))
+var _ = (gofs.PathOpener)((*fsAdapter)(nil))
+var _ = (gofs.PathCreater)((*fsAdapter)(nil))
+var _ = (gofs.PathMkdirer)((*fsAdapter)(nil))
+var _ = (gofs.PathMknoder)((*fsAdapter)(nil))
+var _ = (gofs.PathLinker)((*fsAdapter)(nil))
+var _ = (gofs.PathSymlinker)((*fsAdapter)(nil))
+var _ = (gofs.PathReadlinker)((*fsAdapter)(nil))
+var _ = (gofs.PathUnlinker)((*fsAdapter)(nil))
+var _ = (gofs.PathRmdirer)((*fsAdapter)(nil))
+var _ = (gofs.PathRenamer)((*fsAdapter)(nil))
+var _ = (gofs.PathGetxattrer)((*fsAdapter)(nil))
+var _ = (gofs.PathSetxattrer)((*fsAdapter)(nil))
+var _ = (gofs.PathRemovexattrer)((*fsAdapter)(nil))
+var _ = (gofs.PathListxattrer)((*fsAdapter)(nil))
+
+// adaptedFile returns the nodefs.File inside a handle returned by
+// Open or Create.
+func adaptedFile(fh gofs.FileHandle) nodefs.File {
+	if f, ok := fh.(*fileAdapter); ok {
+		return f.file
+	}
+	return nil
+}
+
+func (a *fsAdapter) Getattr(ctx context.Context, path string, fh gofs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	if f := adaptedFile(fh); f != nil {
+		if code := f.GetAttr(&out.Attr); code != fuse.ENOSYS {
+			return syscall.Errno(code)
+		}
+	}
+	fi, code := a.fs.GetAttr(path, fuse.ToContext(ctx))
+	if !code.Ok() {
+		return syscall.Errno(code)
+	}
+	if fi == nil {
+		return syscall.EIO
+	}
+	out.Attr = *fi
+	// Help filesystems that forget to set Nlink.
+	if !fi.IsDir() && fi.Nlink == 0 {
+		out.Nlink = 1
+	}
+	return 0
+}
+
+func (a *fsAdapter) Setattr(ctx context.Context, path string, fh gofs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	fctx := fuse.ToContext(ctx)
+	f := adaptedFile(fh)
+
+	// Each change is tried on the open file first, like in
+	// PathNodeFs.
+	code := fuse.OK
+	if mode, ok := in.GetMode(); ok {
+		if f != nil {
+			code = f.Chmod(mode)
+		}
+		if f == nil || code == fuse.ENOSYS {
+			code = a.fs.Chmod(path, mode, fctx)
+		}
+	}
+	uid, uok := in.GetUID()
+	gid, gok := in.GetGID()
+	if code.Ok() && (uok || gok) {
+		if !uok || !gok {
+			var cur fuse.AttrOut
+			if errno := a.Getattr(ctx, path, fh, &cur); errno != 0 {
+				return errno
+			}
+			if !uok {
+				uid = cur.Uid
+			}
+			if !gok {
+				gid = cur.Gid
+			}
+		}
+		if f != nil {
+			code = f.Chown(uid, gid)
+		}
+		if f == nil || code == fuse.ENOSYS {
+			code = a.fs.Chown(path, uid, gid, fctx)
+		}
+	}
+	if sz, ok := in.GetSize(); code.Ok() && ok {
+		if f != nil {
+			code = f.Truncate(sz)
+		}
+		if f == nil || code == fuse.ENOSYS {
+			code = a.fs.Truncate(path, sz, fctx)
+		}
+	}
+	atime, aok := in.GetATime()
+	mtime, mok := in.GetMTime()
+	if code.Ok() && (aok || mok) {
+		var at, mt *time.Time
+		if aok {
+			at = &atime
+		}
+		if mok {
+			mt = &mtime
+		}
+		if f != nil {
+			code = f.Utimens(at, mt)
+		}
+		if f == nil || code == fuse.ENOSYS {
+			code = a.fs.Utimens(path, at, mt, fctx)
+		}
+	}
+	if !code.Ok() {
+		return syscall.Errno(code)
+	}
+	return a.Getattr(ctx, path, fh, out)
+}
+
+func (a *fsAdapter) Access(ctx context.Context, path string, mask uint32) syscall.Errno {
+	return syscall.Errno(a.fs.Access(path, mask, fuse.ToContext(ctx)))
+}
+
+func (a *fsAdapter) Statfs(ctx context.Context, path string, out *fuse.StatfsOut) syscall.Errno {
+	s := a.fs.StatFs(path)
+	if s == nil {
+		return syscall.ENOSYS
+	}
+	*out = *s
+	return 0
+}
+
+func (a *fsAdapter) Readdir(ctx context.Context, path string) (gofs.DirStream, syscall.Errno) {
+	entries, code := a.fs.OpenDir(path, fuse.ToContext(ctx))
+	if !code.Ok() {
+		return nil, syscall.Errno(code)
+	}
+	return gofs.NewListDirStream(entries), 0
+}
+
+func (a *fsAdapter) Open(ctx context.Context, path string, flags uint32) (gofs.FileHandle, uint32, syscall.Errno) {
+	f, code := a.fs.Open(path, flags, fuse.ToContext(ctx))
+	if !code.Ok() {
+		return nil, 0, syscall.Errno(code)
+	}
+	fh, fuseFlags := newFileAdapter(f)
+	return fh, fuseFlags, 0
+}
+
+func (a *fsAdapter) Create(ctx context.Context, path string, flags uint32, mode uint32) (gofs.FileHandle, uint32, syscall.Errno) {
+	f, code := a.fs.Create(path, flags, mode, fuse.ToContext(ctx))
+	if !code.Ok() {
+		return nil, 0, syscall.Errno(code)
+	}
+	fh, fuseFlags := newFileAdapter(f)
+	return fh, fuseFlags, 0
+}
+
+func (a *fsAdapter) Mkdir(ctx context.Context, path string, mode uint32) syscall.Errno {
+	return syscall.Errno(a.fs.Mkdir(path, mode, fuse.ToContext(ctx)))
+}
+
+func (a *fsAdapter) Mknod(ctx context.Context, path string, mode uint32, dev uint32) syscall.Errno {
+	return syscall.Errno(a.fs.Mknod(path, mode, dev, fuse.ToContext(ctx)))
+}
+
+func (a *fsAdapter) Link(ctx context.Context, oldPath string, newPath string) syscall.Errno {
+	return syscall.Errno(a.fs.Link(oldPath, newPath, fuse.ToContext(ctx)))
+}
+
+func (a *fsAdapter) Symlink(ctx context.Context, target string, path string) syscall.Errno {
+	return syscall.Errno(a.fs.Symlink(target, path, fuse.ToContext(ctx)))
+}
+
+func (a *fsAdapter) Readlink(ctx context.Context, path string) ([]byte, syscall.Errno) {
+	target, code := a.fs.Readlink(path, fuse.ToContext(ctx))
+	if !code.Ok() {
+		return nil, syscall.Errno(code)
+	}
+	return []byte(target), 0
+}
+
+func (a *fsAdapter) Unlink(ctx context.Context, path string) syscall.Errno {
+	return syscall.Errno(a.fs.Unlink(path, fuse.ToContext(ctx)))
+}
+
+func (a *fsAdapter) Rmdir(ctx context.Context, path string) syscall.Errno {
+	return syscall.Errno(a.fs.Rmdir(path, fuse.ToContext(ctx)))
+}
+
+func (a *fsAdapter) Rename(ctx context.Context, oldPath string, newPath string, flags uint32) syscall.Errno {
+	if flags != 0 {
+		// FileSystem has no way to exchange files, or to refuse
+		// overwriting atomically.
+		return syscall.ENOTSUP
+	}
+	return syscall.Errno(a.fs.Rename(oldPath, newPath, fuse.ToContext(ctx)))
+}
+
+// copyXAttr copies data to dest, or returns ERANGE if it does not
+// fit. An empty dest asks for the size.
+func copyXAttr(data []byte, dest []byte) (uint32, syscall.Errno) {
+	if len(dest) < len(data) {
+		if len(dest) == 0 {
+			return uint32(len(data)), 0
+		}
+		return uint32(len(data)), syscall.ERANGE
+	}
+	return uint32(copy(dest, data)), 0
+}
+
+func (a *fsAdapter) Getxattr(ctx context.Context, path string, attr string, dest []byte) (uint32, syscall.Errno) {
+	data, code := a.fs.GetXAttr(path, attr, fuse.ToContext(ctx))
+	if !code.Ok() {
+		return 0, syscall.Errno(code)
+	}
+	return copyXAttr(data, dest)
+}
+
+func (a *fsAdapter) Setxattr(ctx context.Context, path string, attr string, data []byte, flags uint32) syscall.Errno {
+	return syscall.Errno(a.fs.SetXAttr(path, attr, data, int(flags), fuse.ToContext(ctx)))
+}
+
+func (a *fsAdapter) Removexattr(ctx context.Context, path string, attr string) syscall.Errno {
+	return syscall.Errno(a.fs.RemoveXAttr(path, attr, fuse.ToContext(ctx)))
+}
+
+func (a *fsAdapter) Listxattr(ctx context.Context, path string, dest []byte) (uint32, syscall.Errno) {
+	attrs, code := a.fs.ListXAttr(path, fuse.ToContext(ctx))
+	if !code.Ok() {
+		return 0, syscall.Errno(code)
+	}
+	var data []byte
+	if len(attrs) > 0 {
+		data = []byte(strings.Join(attrs, "\x00") + "\x00")
+	}
+	return copyXAttr(data, dest)
+}
+
+// fileAdapter serves a nodefs.File as a fs.FileHandle.
+type fileAdapter struct {
+	file nodefs.File
+}
+
+var _ = (gofs.FileReader)((*fileAdapter)(nil))
+var _ = (gofs.FileWriter)((*fileAdapter)(nil))
+var _ = (gofs.FileGetlker)((*fileAdapter)(nil))
+var _ = (gofs.FileSetlker)((*fileAdapter)(nil))
+var _ = (gofs.FileSetlkwer)((*fileAdapter)(nil))
+var _ = (gofs.FileFlusher)((*fileAdapter)(nil))
+var _ = (gofs.FileReleaser)((*fileAdapter)(nil))
+var _ = (gofs.FileFsyncer)((*fileAdapter)(nil))
+var _ = (gofs.FileAllocater)((*fileAdapter)(nil))
+
+// newFileAdapter wraps f, and returns the FOPEN flags it asks for.
+func newFileAdapter(f nodefs.File) (gofs.FileHandle, uint32) {
+	if f == nil {
+		return nil, 0
+	}
+	var fuseFlags uint32
+	if wf, ok := f.(*nodefs.WithFlags); ok {
+		fuseFlags = wf.FuseFlags
+		f = wf.File
+	}
+	return &fileAdapter{file: f}, fuseFlags
+}
+
+func (f *fileAdapter) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	res, code := f.file.Read(dest, off)
+	return res, syscall.Errno(code)
+}
+
+func (f *fileAdapter) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	n, code := f.file.Write(data, off)
+	return n, syscall.Errno(code)
+}
+
+func (f *fileAdapter) Getlk(ctx context.Context, owner uint64, lk *fuse.FileLock, flags uint32, out *fuse.FileLock) syscall.Errno {
+	return syscall.Errno(f.file.GetLk(owner, lk, flags, out))
+}
+
+func (f *fileAdapter) Setlk(ctx context.Context, owner uint64, lk *fuse.FileLock, flags uint32) syscall.Errno {
+	return syscall.Errno(f.file.SetLk(owner, lk, flags))
+}
+
+func (f *fileAdapter) Setlkw(ctx context.Context, owner uint64, lk *fuse.FileLock, flags uint32) syscall.Errno {
+	return syscall.Errno(f.file.SetLkw(owner, lk, flags))
+}
+
+func (f *fileAdapter) Flush(ctx context.Context) syscall.Errno {
+	return syscall.Errno(f.file.Flush())
+}
+
+func (f *fileAdapter) Release(ctx context.Context) syscall.Errno {
+	f.file.Release()
+	return 0
+}
+
+func (f *fileAdapter) Fsync(ctx context.Context, flags uint32) syscall.Errno {
+	return syscall.Errno(f.file.Fsync(int(flags)))
+}
+
+func (f *fileAdapter) Allocate(ctx context.Context, off uint64, size uint64, mode uint32) syscall.Errno {
+	return syscall.Errno(f.file.Allocate(off, size, mode))
+}
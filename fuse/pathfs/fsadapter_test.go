@@ -0,0 +1,42 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pathfs
+
+import (
+	"testing"
+
+	gofs "github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/internal/testutil"
+	"github.com/hanwen/go-fuse/v2/posixtest"
+)
+
+func TestPathFileSystemAdapter(t *testing.T) {
+	for nm, fn := range posixtest.All {
+		switch nm {
+		case "RenameOpenDir":
+			// os.Rename, used by the loopback, refuses to
+			// replace directories.
+			continue
+		case "OpenSymlinkRace":
+			// The loopback opens files by path.
+			continue
+		}
+		t.Run(nm, func(t *testing.T) {
+			orig := t.TempDir()
+			mnt := t.TempDir()
+			root := gofs.NewPathRoot(NewPathFileSystem(NewLoopbackFileSystem(orig)),
+				&gofs.PathOptions{ClientInodes: true})
+			opts := &gofs.Options{}
+			opts.Debug = testutil.VerboseTest()
+			opts.EnableLocks = true
+			server, err := gofs.Mount(mnt, root, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { server.Unmount() })
+			fn(t, mnt)
+		})
+	}
+}
@@ -19,6 +19,11 @@ type protocolServer struct {
 	reqInflight    []*request
 	connectionDead bool
 
+	// interruptQueued, if set, drops a request that has not been
+	// started yet from its queue. It returns false if the
+	// request is not queued.
+	interruptQueued func(unique uint64) bool
+
 	latencies LatencyMap
 
 	kernelSettings InitIn
@@ -96,11 +101,13 @@ func (ms *protocolServer) addInflight(req *request) {
 	defer ms.interruptMu.Unlock()
 	req.inflightIndex = len(ms.reqInflight)
 	ms.reqInflight = append(ms.reqInflight, req)
+	ms.startDeadline(req)
 }
 
 func (ms *protocolServer) dropInflight(req *request) {
 	ms.interruptMu.Lock()
 	defer ms.interruptMu.Unlock()
+	ms.stopDeadline(req)
 	this := req.inflightIndex
 	last := len(ms.reqInflight) - 1
 	if last != this {
@@ -117,11 +124,13 @@ func (ms *protocolServer) interruptRequest(unique uint64) Status {
 	// This is slow, but this operation is rare.
 	for _, inflight := range ms.reqInflight {
 		if unique == inflight.inHeader().Unique && !inflight.interrupted {
-			close(inflight.cancel)
-			inflight.interrupted = true
+			ms.cancelLocked(inflight, ErrInterrupted)
 			return OK
 		}
 	}
+	if ms.interruptQueued != nil && ms.interruptQueued(unique) {
+		return OK
+	}
 
 	return EAGAIN
 }
@@ -131,10 +140,7 @@ func (ms *protocolServer) cancelAll() {
 	defer ms.interruptMu.Unlock()
 	ms.connectionDead = true
 	for _, req := range ms.reqInflight {
-		if !req.interrupted {
-			close(req.cancel)
-			req.interrupted = true
-		}
+		ms.cancelLocked(req, ErrServerShutdown)
 	}
 	// Leave ms.reqInflight alone, or dropInflight will barf.
 }
@@ -179,6 +185,10 @@ func iovLen(iov [][]byte) int {
 // input/output IOVs should follow conventions used by virtiofs.
 // The return value is the number of bytes written.
 //
+// The input is not trusted: malformed requests are answered with
+// EPROTO or EINVAL rather than passed to the file system. A non-OK
+// status is only returned if no reply could be written at all.
+//
 // EXPERIMENTAL: not subject to API stability.
 func (ps *ProtocolServer) HandleRequest(in [][]byte, out [][]byte) (int, Status) {
 	// for virtiofs, we get
@@ -191,13 +201,16 @@ func (ps *ProtocolServer) HandleRequest(in [][]byte, out [][]byte) (int, Status)
 	//
 	// Our input data types have the InHeader embedded in the FooIn
 	// types, so we can never fully avoid copying.
+	if len(in) == 0 {
+		return 0, EPROTO
+	}
 	inTogether := make([]byte, iovLen(in[:min(2, len(in))]))
 	copy(inTogether, in[0])
 	if len(in) > 1 {
 		copy(inTogether[len(in[0]):], in[1])
 	}
-	h, inSize, outSize, outPayloadSize, errno := parseRequest(inTogether, &ps.kernelSettings)
-	if errno != 0 {
+	h, inSize, outSize, outPayloadSize, errno := parseRequest(inTogether, &ps.kernelSettings, maxPayloadSize(ps.opts))
+	if h == nil {
 		return 0, errno
 	}
 	req := request{
@@ -211,11 +224,20 @@ func (ps *ProtocolServer) HandleRequest(in [][]byte, out [][]byte) (int, Status)
 	} else {
 		req.inPayload = inTogether[inSize:]
 	}
+	if errno.Ok() {
+		errno = req.checkInput()
+	}
+	if !errno.Ok() {
+		ps.opts.Logger.Printf("op %v: invalid request: %v", h.Name, errno)
+	}
+	req.status = errno
 
 	startOut := out
 	if !h.SuppressReply {
-		// validate the shape of the output iov. If we fail any of this, it's probably a programming error on our side, but
-		// since we can't trust the guest, be paranoid and return EIO instead.
+		// validate the shape of the output iov. Without room
+		// for the header, we cannot reply at all. Other
+		// mismatches get an EPROTO reply, as the client cannot
+		// be trusted.
 		if len(out) > 0 && len(out[0]) == int(sizeOfOutHeader) {
 			req.outHeaderBuf = out[0]
 			out = out[1:]
@@ -224,33 +246,45 @@ func (ps *ProtocolServer) HandleRequest(in [][]byte, out [][]byte) (int, Status)
 			return 0, EIO
 		}
 
+		if !req.status.Ok() {
+			outSize, outPayloadSize = 0, 0
+			out = nil
+		}
+
 		if outSize > 0 {
 			if len(out) > 0 && len(out[0]) == outSize {
 				req.outDataBuf = out[0]
 				out = out[1:]
 			} else {
 				ps.opts.Logger.Printf("op %v: got %v, outData iov should have %d bytes", h.Name, iovLens(startOut), outSize)
-				return 0, EIO
+				req.status = EPROTO
+				out = nil
 			}
 		}
 
 		if len(out) > 0 {
 			if len(out[0]) < outPayloadSize {
 				ps.opts.Logger.Printf("op %s: got %v, payload iov should have %d bytes", h.Name, iovLens(startOut), outPayloadSize)
-				return 0, EIO
+				req.status = EPROTO
+			} else {
+				req.outPayload = out[0]
 			}
-			req.outPayload = out[0]
-			out = out[1:]
-		} else if outPayloadSize != 0 {
+		} else if req.status.Ok() && outPayloadSize != 0 {
 			ps.opts.Logger.Printf("op %s: got %v, payload iov should have %d bytes", h.Name, iovLens(startOut), outPayloadSize)
-			return 0, EIO
+			req.status = EPROTO
 		}
 	}
 
 	beforePayload := req.outPayload
 	ps.protocolServer.handleRequest(h, &req)
-	if len(req.outPayload) > 0 && len(beforePayload) > 0 &&
-		&beforePayload[0] != &req.outPayload[0] {
+	if len(req.outPayload) > len(beforePayload) && !req.suppressReply {
+		// The file system returned more data than the client
+		// made room for.
+		ps.opts.Logger.Printf("op %s: got %v, payload of %d bytes does not fit", h.Name, iovLens(startOut), len(req.outPayload))
+		req.status = ERANGE
+		req.outPayload = nil
+		req.serializeHeader(0)
+	} else if len(req.outPayload) > 0 && &beforePayload[0] != &req.outPayload[0] {
 		n := copy(beforePayload, req.outPayload)
 		req.outPayload = beforePayload[:n]
 	}
@@ -7,8 +7,10 @@ package fuse
 import (
 	"bytes"
 	"encoding/binary"
+	"io"
 	"log"
 	"testing"
+	"unsafe"
 )
 
 func TestProtocolServerParse(t *testing.T) {
@@ -46,3 +48,256 @@ func TestProtocolServerParse(t *testing.T) {
 		t.Errorf("OutHeader.Status = %d, want %d", gotStatus, -int32(ENOSYS))
 	}
 }
+
+// fuzzFS answers most operations successfully, so fuzzed requests
+// exercise the reply paths too.
+type fuzzFS struct {
+	defaultRawFileSystem
+}
+
+func (fs *fuzzFS) Lookup(cancel <-chan struct{}, header *InHeader, name string, out *EntryOut) Status {
+	out.NodeId = 2
+	out.Attr = Attr{Ino: 2, Mode: S_IFREG | 0644, Nlink: 1}
+	return OK
+}
+
+func (fs *fuzzFS) GetAttr(cancel <-chan struct{}, input *GetAttrIn, out *AttrOut) Status {
+	out.Attr = Attr{Ino: input.NodeId, Mode: S_IFDIR | 0755, Nlink: 1}
+	return OK
+}
+
+func (fs *fuzzFS) Readlink(cancel <-chan struct{}, header *InHeader) ([]byte, Status) {
+	return []byte("target"), OK
+}
+
+func (fs *fuzzFS) Read(cancel <-chan struct{}, input *ReadIn, buf []byte) (ReadResult, Status) {
+	return ReadResultData(bytes.Repeat([]byte{'x'}, int(input.Size))), OK
+}
+
+func (fs *fuzzFS) Write(cancel <-chan struct{}, input *WriteIn, data []byte) (uint32, Status) {
+	return uint32(len(data)), OK
+}
+
+func (fs *fuzzFS) ReadDir(cancel <-chan struct{}, input *ReadIn, out *DirEntryList) Status {
+	for i := 0; out.AddDirEntry(DirEntry{Name: "entry", Mode: S_IFREG, Ino: uint64(i + 2)}); i++ {
+	}
+	return OK
+}
+
+func (fs *fuzzFS) ReadDirPlus(cancel <-chan struct{}, input *ReadIn, out *DirEntryList) Status {
+	for i := 0; ; i++ {
+		e := out.AddDirLookupEntry(DirEntry{Name: "entry", Mode: S_IFREG, Ino: uint64(i + 2)})
+		if e == nil {
+			return OK
+		}
+		e.NodeId = uint64(i + 2)
+	}
+}
+
+func (fs *fuzzFS) GetXAttr(cancel <-chan struct{}, header *InHeader, attr string, dest []byte) (uint32, Status) {
+	val := []byte("value")
+	if len(dest) < len(val) {
+		return uint32(len(val)), ERANGE
+	}
+	return uint32(copy(dest, val)), OK
+}
+
+func (fs *fuzzFS) ListXAttr(cancel <-chan struct{}, header *InHeader, dest []byte) (uint32, Status) {
+	return fs.GetXAttr(cancel, header, "", dest)
+}
+
+func (fs *fuzzFS) Ioctl(cancel <-chan struct{}, input *IoctlIn, inbuf []byte, output *IoctlOut, outbuf []byte) Status {
+	copy(outbuf, inbuf)
+	return OK
+}
+
+// newInitializedProtocolServer returns a ProtocolServer that has
+// completed the INIT handshake.
+func newInitializedProtocolServer(t testing.TB, fs RawFileSystem) *ProtocolServer {
+	opts := MountOptions{
+		Logger: log.New(io.Discard, "", 0),
+		PanicHandler: func(obj any) Status {
+			t.Fatalf("panic in handler: %v", obj)
+			return EIO
+		},
+	}
+	ps := NewProtocolServer(fs, &opts)
+	in := InitIn{
+		Major: _FUSE_KERNEL_VERSION,
+		Minor: _OUR_MINOR_VERSION,
+		Flags: CAP_READDIRPLUS,
+	}
+	var out InitOut
+	status := handleRaw(ps, _OP_INIT, 0, &in, unsafe.Sizeof(in), nil, [][]byte{make([]byte, sizeOfOutHeader), structBytes(unsafe.Pointer(&out), unsafe.Sizeof(out))})
+	if status != 0 {
+		t.Fatalf("INIT: %v", status)
+	}
+	return ps
+}
+
+func structBytes(p unsafe.Pointer, sz uintptr) []byte {
+	return unsafe.Slice((*byte)(p), sz)
+}
+
+// handleRaw sends a request with a correct header, and returns the
+// status of the reply.
+func handleRaw(ps *ProtocolServer, op uint32, nodeID uint64, in *InitIn, inSize uintptr, payload []byte, out [][]byte) Status {
+	hdr := (*InHeader)(unsafe.Pointer(in))
+	hdr.Opcode = op
+	hdr.NodeId = nodeID
+	hdr.Unique = 1
+	hdr.Length = uint32(int(inSize) + len(payload))
+	n, status := ps.HandleRequest([][]byte{structBytes(unsafe.Pointer(in), inSize), payload}, out)
+	if status != OK {
+		return status
+	}
+	if n < int(sizeOfOutHeader) {
+		return EIO
+	}
+	return Status(-(*OutHeader)(unsafe.Pointer(&out[0][0])).Status)
+}
+
+// request builds the input iov for a request with the given input
+// struct bytes (excluding the InHeader) and payload.
+func fuzzRequest(op uint32, nodeID uint64, body, payload []byte, lengthDelta int8, split bool) [][]byte {
+	hdr := make([]byte, unsafe.Sizeof(InHeader{}))
+	h := (*InHeader)(unsafe.Pointer(&hdr[0]))
+	h.Opcode = op
+	h.NodeId = nodeID
+	h.Unique = 42
+	h.Length = uint32(len(hdr) + len(body) + len(payload) + int(lengthDelta))
+	if split {
+		return [][]byte{hdr, body, payload}
+	}
+	return [][]byte{append(append(hdr, body...), payload...)}
+}
+
+func TestProtocolServerMalformed(t *testing.T) {
+	forgetOne := structBytes(unsafe.Pointer(&_ForgetOne{NodeId: 2, Nlookup: 1}), unsafe.Sizeof(_ForgetOne{}))
+	u32 := func(vals ...uint32) []byte {
+		var b []byte
+		for _, v := range vals {
+			b = binary.LittleEndian.AppendUint32(b, v)
+		}
+		return b
+	}
+	body := func(op uint32) []byte {
+		return make([]byte, int(getHandler(op).InputSize-unsafe.Sizeof(InHeader{})))
+	}
+	writeIn := func(off uint64, size uint32) []byte {
+		b := body(_OP_WRITE)
+		binary.LittleEndian.PutUint64(b[8:], off)
+		binary.LittleEndian.PutUint32(b[16:], size)
+		return b
+	}
+	for _, tc := range []struct {
+		name string
+		in   [][]byte
+		want Status
+	}{
+		{"unknown opcode", fuzzRequest(1000, 1, nil, nil, 0, false), ENOSYS},
+		{"bad length", fuzzRequest(_OP_GETATTR, 1, body(_OP_GETATTR), nil, 1, false), EPROTO},
+		{"short struct", fuzzRequest(_OP_GETATTR, 1, body(_OP_GETATTR)[:3], nil, 0, false), EPROTO},
+		{"node 0", fuzzRequest(_OP_GETATTR, 0, body(_OP_GETATTR), nil, 0, false), EINVAL},
+		{"name without NUL", fuzzRequest(_OP_LOOKUP, 1, nil, []byte("file"), 0, false), EPROTO},
+		{"empty name", fuzzRequest(_OP_LOOKUP, 1, nil, []byte("\x00"), 0, false), EINVAL},
+		{"name with slash", fuzzRequest(_OP_LOOKUP, 1, nil, []byte("a/b\x00"), 0, false), EINVAL},
+		{"long name", fuzzRequest(_OP_LOOKUP, 1, nil, append(bytes.Repeat([]byte("x"), 256), 0), 0, true), EINVAL},
+		{"mkdir dotdot", fuzzRequest(_OP_MKDIR, 1, body(_OP_MKDIR), []byte("..\x00"), 0, false), EINVAL},
+		{"rename one name", fuzzRequest(_OP_RENAME, 1, body(_OP_RENAME), []byte("a\x00"), 0, false), EPROTO},
+		{"symlink no target", fuzzRequest(_OP_SYMLINK, 1, nil, []byte("a\x00\x00"), 0, false), EINVAL},
+		{"write short payload", fuzzRequest(_OP_WRITE, 2, writeIn(0, 100), []byte("abc"), 0, true), EPROTO},
+		{"write negative offset", fuzzRequest(_OP_WRITE, 2, writeIn(1<<63, 3), []byte("abc"), 0, true), EINVAL},
+		{"setxattr short value", fuzzRequest(_OP_SETXATTR, 1, u32(10, 0), []byte("user.a\x00abc"), 0, false), EPROTO},
+		{"getxattr huge", fuzzRequest(_OP_GETXATTR, 1, u32(1<<20, 0), []byte("user.a\x00"), 0, false), EINVAL},
+		{"read huge", fuzzRequest(_OP_READ, 2, func() []byte {
+			b := body(_OP_READ)
+			binary.LittleEndian.PutUint32(b[16:], 1<<30)
+			return b
+		}(), nil, 0, false), EINVAL},
+		{"batch forget count", fuzzRequest(_OP_BATCH_FORGET, 0, u32(2, 0), forgetOne, 0, true), EPROTO},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ps := newInitializedProtocolServer(t, &fuzzFS{})
+			var got Status
+			if h := getHandler(binary.LittleEndian.Uint32(tc.in[0][4:])); h != nil && h.SuppressReply {
+				req := request{inputBuf: append(append([]byte(nil), tc.in[0]...), tc.in[1]...), inPayload: tc.in[2]}
+				got = req.checkInput()
+			} else {
+				out := [][]byte{make([]byte, sizeOfOutHeader), make([]byte, 4096)}
+				n, status := ps.HandleRequest(tc.in, out)
+				if status != OK {
+					t.Fatalf("HandleRequest: no reply, status %v", status)
+				}
+				if n != int(sizeOfOutHeader) {
+					t.Errorf("got %d bytes, want header only", n)
+				}
+				hdr := (*OutHeader)(unsafe.Pointer(&out[0][0]))
+				if hdr.Unique != 42 {
+					t.Errorf("got unique %d, want 42", hdr.Unique)
+				}
+				got = Status(-hdr.Status)
+			}
+			if got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// FuzzHandleRequest feeds requests with a valid header shape but
+// arbitrary contents to HandleRequest. The seed corpus has a request
+// for every implemented opcode.
+func FuzzHandleRequest(f *testing.F) {
+	for op, h := range operationHandlers {
+		if h.Func == nil {
+			continue
+		}
+		var body []byte
+		if h.InputSize > 0 {
+			body = make([]byte, h.InputSize-unsafe.Sizeof(InHeader{}))
+		}
+		var payload []byte
+		for i := 0; i < h.FileNames; i++ {
+			payload = append(payload, "name\x00"...)
+		}
+		f.Add(uint32(op), uint64(1), body, payload, uint16(4096), int8(0), false)
+		f.Add(uint32(op), uint64(2), body, payload, uint16(0), int8(0), true)
+	}
+
+	f.Fuzz(func(t *testing.T, op uint32, nodeID uint64, body, payload []byte, outPayloadLen uint16, lengthDelta int8, split bool) {
+		ps := newInitializedProtocolServer(t, &fuzzFS{})
+		in := fuzzRequest(op, nodeID, body, payload, lengthDelta, split)
+
+		// Shape the output like virtiofs would, based on our
+		// own reading of the request.
+		var kernelSettings InitIn
+		h, _, outSize, _, _ := parseRequest(in[0], &kernelSettings, 1<<20)
+		out := [][]byte{make([]byte, sizeOfOutHeader)}
+		if h != nil && !h.SuppressReply && outSize > 0 {
+			out = append(out, make([]byte, outSize))
+		}
+		out = append(out, make([]byte, outPayloadLen))
+
+		n, status := ps.HandleRequest(in, out)
+		if status != OK {
+			if n != 0 {
+				t.Fatalf("status %v with %d bytes written", status, n)
+			}
+			return
+		}
+		if h != nil && h.SuppressReply {
+			return
+		}
+		if n < int(sizeOfOutHeader) || n > iovLen(out) {
+			t.Fatalf("got %d bytes written for iov %v", n, iovLens(out))
+		}
+		hdr := (*OutHeader)(unsafe.Pointer(&out[0][0]))
+		if int(hdr.Length) != n {
+			t.Fatalf("OutHeader.Length = %d, want %d", hdr.Length, n)
+		}
+		if hdr.Status > 0 {
+			t.Fatalf("positive status %d", hdr.Status)
+		}
+	})
+}
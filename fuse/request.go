@@ -6,8 +6,8 @@ package fuse
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"log"
 	"reflect"
 	"strings"
 	"time"
@@ -26,6 +26,17 @@ type request struct {
 	// written under Server.interruptMu
 	interrupted bool
 
+	// dequeued is set if the request was interrupted while it
+	// waited in a scheduler queue. It is answered with EINTR.
+	dequeued bool
+
+	// The deadline, and a canceled context carrying the
+	// cancellation cause, for Context. Written under
+	// Server.interruptMu.
+	deadline      time.Time
+	deadlineTimer *time.Timer
+	cause         context.Context
+
 	// inHeader + opcode specific data
 	inputBuf []byte
 
@@ -82,6 +93,7 @@ func (r *request) outHeader() *OutHeader {
 // TODO - benchmark to see if this is necessary?
 func (r *request) clear() {
 	r.suppressReply = false
+	r.dequeued = false
 	r.inputBuf = nil
 	r.outHeaderBuf = nil
 	r.outDataBuf = nil
@@ -113,7 +125,7 @@ func (r *request) InputDebug() string {
 	if h.FileNames == 1 {
 		name := r.filename()
 
-		rest := r.inPayload[len(name)+1:]
+		rest := r.inPayload[min(len(name)+1, len(r.inPayload)):]
 		names = fmt.Sprintf(" %q %s", name, summarizePayload(rest))
 	} else if h.FileNames == 2 {
 		n1, n2 := r.filenames()
@@ -205,18 +217,31 @@ func (r *request) inData() unsafe.Pointer {
 	return unsafe.Pointer(&r.inputBuf[0])
 }
 
+// parseRequest decodes the header of the request in `in`, and
+// computes the sizes of the input struct, the output struct and the
+// output payload. If h is nil, the request is unreadable and cannot
+// be answered. Otherwise, a non-OK errno should be sent as the reply,
+// and the output sizes are zero. maxPayload bounds the output payload
+// that a request may ask for.
+//
 // note: outSize is without OutHeader
-func parseRequest(in []byte, kernelSettings *InitIn) (h *operationHandler, inSize, outSize, outPayloadSize int, errno Status) {
+func parseRequest(in []byte, kernelSettings *InitIn, maxPayload int) (h *operationHandler, inSize, outSize, outPayloadSize int, errno Status) {
+	defer func() {
+		if !errno.Ok() {
+			outSize, outPayloadSize = 0, 0
+		}
+	}()
+
 	inSize = int(unsafe.Sizeof(InHeader{}))
 	if len(in) < inSize {
-		errno = EIO
+		errno = EPROTO
 		return
 	}
 	inData := unsafe.Pointer(&in[0])
 	hdr := (*InHeader)(inData)
 	h = getHandler(hdr.Opcode)
 	if h == nil {
-		log.Printf("Unknown opcode %d", hdr.Opcode)
+		h = unknownOpcodeHandler
 		errno = ENOSYS
 		return
 	}
@@ -229,10 +254,14 @@ func parseRequest(in []byte, kernelSettings *InitIn) (h *operationHandler, inSiz
 	if hdr.Opcode == _OP_INIT && inSize > len(in) {
 		// Minor version 36 extended the size of InitIn struct
 		inSize = len(in)
+		if inSize < int(unsafe.Offsetof(InitIn{}.Flags2)) {
+			errno = EPROTO
+			return
+		}
 	}
 	if len(in) < inSize {
-		log.Printf("Short read for %v: %q", h.Name, in)
-		errno = EIO
+		inSize = len(in)
+		errno = EPROTO
 		return
 	}
 
@@ -246,13 +275,19 @@ func parseRequest(in []byte, kernelSettings *InitIn) (h *operationHandler, inSiz
 		// structured GetXAttrOut, no flat data) and get/list xattr data
 		// (return no structured data, but only flat data)
 		outPayloadSize = int(((*GetXAttrIn)(inData)).Size)
+		if outPayloadSize > _XATTR_SIZE_MAX {
+			errno = EINVAL
+			return
+		}
 		if outPayloadSize > 0 {
 			outSize = 0
 		}
 	case _OP_IOCTL:
 		outPayloadSize = int(((*IoctlIn)(inData)).OutSize)
 	}
-
+	if outPayloadSize > maxPayload {
+		errno = EINVAL
+	}
 	return
 }
 
@@ -275,9 +310,12 @@ func (r *request) filenames() (string, string) {
 	if i1 < 0 || i1+1 >= len(r.inPayload) {
 		return "", ""
 	}
-	s1 := string(r.inPayload[:i1])
-	s2 := string(r.inPayload[i1+1 : len(r.inPayload)-1])
-	return s1, s2
+	rest := r.inPayload[i1+1:]
+	i2 := bytes.IndexByte(rest, 0)
+	if i2 < 0 {
+		i2 = len(rest)
+	}
+	return string(r.inPayload[:i1]), string(rest[:i2])
 }
 
 // serializeHeader serializes the response header. The header points
@@ -293,7 +331,7 @@ func (r *request) serializeHeader(outPayloadSize int) {
 	// The InitOut structure has 24 bytes (ie. TimeGran and
 	// further fields not available) in fuse version <= 22.
 	// https://john-millikin.com/the-fuse-protocol#FUSE_INIT
-	if r.inHeader().Opcode == _OP_INIT {
+	if r.inHeader().Opcode == _OP_INIT && len(r.outDataBuf) > 0 {
 		out := (*InitOut)(r.outData())
 		if out.Minor <= 22 {
 			r.outDataBuf = r.outDataBuf[:24]
@@ -0,0 +1,183 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"bytes"
+	"math"
+	"syscall"
+	"unsafe"
+)
+
+// Limits that the kernel enforces before sending requests. Requests
+// exceeding them can only come from a misbehaving client, for example
+// a virtiofs guest.
+const (
+	// NAME_MAX
+	_NAME_MAX = 255
+	// PATH_MAX, including the terminating NUL.
+	_PATH_MAX = 4096
+	// XATTR_NAME_MAX
+	_XATTR_NAME_MAX = 255
+	// XATTR_SIZE_MAX, also XATTR_LIST_MAX
+	_XATTR_SIZE_MAX = 65536
+)
+
+// unknownOpcodeHandler is used to reply ENOSYS to opcodes outside
+// the handler table.
+var unknownOpcodeHandler = &operationHandler{Name: "UNKNOWN"}
+
+// maxPayloadSize returns the largest READ, READDIR or IOCTL reply
+// that a client may ask for. This is the number of pages announced
+// in INIT, but no less than the kernel's historic maximum of
+// _FUSE_MAX_MAX_PAGES pages.
+func maxPayloadSize(opts *MountOptions) int {
+	page := syscall.Getpagesize()
+	pages := (opts.MaxWrite-1)/page + 1
+	return max(pages, _FUSE_MAX_MAX_PAGES) * page
+}
+
+// checkInput validates the input of a request against the
+// guarantees the kernel gives us: NUL-terminated names of valid
+// length, payloads that match the sizes in the input struct, and
+// offsets that fit in an int64. It may shorten r.inPayload to the
+// size announced in the input struct. It returns EPROTO for
+// malformed messages, and EINVAL for invalid arguments.
+func (r *request) checkInput() Status {
+	hdr := r.inHeader()
+	if int(hdr.Length) != len(r.inputBuf)+len(r.inPayload) {
+		return EPROTO
+	}
+
+	switch hdr.Opcode {
+	case _OP_INIT, _OP_DESTROY, _OP_INTERRUPT, _OP_BATCH_FORGET, _OP_NOTIFY_REPLY:
+	default:
+		if hdr.NodeId == 0 {
+			return EINVAL
+		}
+	}
+
+	switch hdr.Opcode {
+	case _OP_LOOKUP:
+		// "." and ".." are looked up for NFS exports.
+		return r.checkNames(checkName)
+	case _OP_CREATE, _OP_MKDIR, _OP_MKNOD, _OP_UNLINK, _OP_RMDIR, _OP_LINK:
+		return r.checkNames(checkEntryName)
+	case _OP_RENAME, _OP_RENAME2:
+		return r.checkNames(checkEntryName, checkEntryName)
+	case _OP_SYMLINK:
+		return r.checkNames(checkEntryName, checkSymlinkTarget)
+	case _OP_GETXATTR, _OP_REMOVEXATTR:
+		return r.checkNames(checkXAttrName)
+	case _OP_SETXATTR:
+		if st := r.checkNames(checkXAttrName); !st.Ok() {
+			return st
+		}
+		in := (*SetXAttrIn)(r.inData())
+		nameLen := bytes.IndexByte(r.inPayload, 0) + 1
+		if in.Size > _XATTR_SIZE_MAX {
+			return EINVAL
+		}
+		if int(in.Size) > len(r.inPayload)-nameLen {
+			return EPROTO
+		}
+		r.inPayload = r.inPayload[:nameLen+int(in.Size)]
+	case _OP_WRITE:
+		in := (*WriteIn)(r.inData())
+		if int(in.Size) > len(r.inPayload) {
+			return EPROTO
+		}
+		r.inPayload = r.inPayload[:in.Size]
+		return checkRange(in.Offset, uint64(in.Size))
+	case _OP_READ:
+		in := (*ReadIn)(r.inData())
+		return checkRange(in.Offset, uint64(in.Size))
+	case _OP_IOCTL:
+		in := (*IoctlIn)(r.inData())
+		if int(in.InSize) > len(r.inPayload) {
+			return EPROTO
+		}
+		r.inPayload = r.inPayload[:in.InSize]
+	case _OP_BATCH_FORGET:
+		in := (*_BatchForgetIn)(r.inData())
+		sz := uint64(in.Count) * uint64(unsafe.Sizeof(_ForgetOne{}))
+		if sz > uint64(len(r.inPayload)) {
+			return EPROTO
+		}
+		r.inPayload = r.inPayload[:sz]
+	case _OP_FALLOCATE:
+		in := (*FallocateIn)(r.inData())
+		return checkRange(in.Offset, in.Length)
+	case _OP_LSEEK:
+		in := (*LseekIn)(r.inData())
+		return checkRange(in.Offset, 0)
+	case _OP_COPY_FILE_RANGE, _OP_COPY_FILE_RANGE_64:
+		in := (*CopyFileRangeIn)(r.inData())
+		if st := checkRange(in.OffIn, in.Len); !st.Ok() {
+			return st
+		}
+		return checkRange(in.OffOut, in.Len)
+	case _OP_SETATTR:
+		in := (*SetAttrIn)(r.inData())
+		if in.Valid&FATTR_SIZE != 0 {
+			return checkRange(in.Size, 0)
+		}
+	}
+	return OK
+}
+
+// checkRange checks that off and off+size are valid file offsets.
+func checkRange(off, size uint64) Status {
+	if off > math.MaxInt64 || size > math.MaxInt64-off {
+		return EINVAL
+	}
+	return OK
+}
+
+// checkNames checks that the payload starts with a NUL-terminated
+// string for each of the checks. Data following the strings is
+// allowed.
+func (r *request) checkNames(checks ...func([]byte) Status) Status {
+	payload := r.inPayload
+	for _, check := range checks {
+		i := bytes.IndexByte(payload, 0)
+		if i < 0 {
+			return EPROTO
+		}
+		if st := check(payload[:i]); !st.Ok() {
+			return st
+		}
+		payload = payload[i+1:]
+	}
+	return OK
+}
+
+func checkName(name []byte) Status {
+	if len(name) == 0 || len(name) > _NAME_MAX || bytes.IndexByte(name, '/') >= 0 {
+		return EINVAL
+	}
+	return OK
+}
+
+func checkEntryName(name []byte) Status {
+	if string(name) == "." || string(name) == ".." {
+		return EINVAL
+	}
+	return checkName(name)
+}
+
+func checkSymlinkTarget(target []byte) Status {
+	if len(target) == 0 || len(target) >= _PATH_MAX {
+		return EINVAL
+	}
+	return OK
+}
+
+func checkXAttrName(name []byte) Status {
+	if len(name) == 0 || len(name) > _XATTR_NAME_MAX {
+		return EINVAL
+	}
+	return OK
+}
@@ -0,0 +1,355 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"sync"
+)
+
+// RequestClass describes how the scheduler should treat a request.
+type RequestClass struct {
+	// Flow identifies the queue for the request. Requests in the
+	// same flow are started in arrival order; flows share the
+	// available concurrency in proportion to their weight.
+	Flow uint64
+
+	// Weight is the relative share of the flow. Values < 1 are
+	// treated as 1.
+	Weight int
+
+	// Priority requests bypass the queues and are started
+	// immediately. They do not count towards the concurrency
+	// limits.
+	Priority bool
+}
+
+// SchedulerOptions configures admission control for requests in
+// Server. If set, requests are started in weighted fair order across
+// flows, so a single busy caller cannot starve others sharing the
+// mount.
+//
+// Requests are only classified after they have been read from the
+// kernel, so MaxInflightRequestBytes should leave room for queued
+// requests.
+type SchedulerOptions struct {
+	// MaxConcurrent is the maximum number of non-priority
+	// requests being handled at the same time. If zero, it
+	// defaults to 32.
+	MaxConcurrent int
+
+	// MaxPerFlow caps the number of requests of a single flow
+	// being handled at the same time. If zero, flows are only
+	// limited by MaxConcurrent.
+	MaxPerFlow int
+
+	// MaxQueued bounds the number of requests waiting in the
+	// queues. Once it is reached, the server stops reading
+	// requests from the kernel until a queued request is started.
+	// If zero, it defaults to 1024.
+	MaxQueued int
+
+	// Classify assigns a request to a flow. If nil,
+	// ClassifyByUid is used.
+	Classify func(header *InHeader) RequestClass
+}
+
+// priorityOpcode returns true for requests that should never wait
+// behind other requests: requests that release kernel or file
+// system resources, INTERRUPT, and SETLKW, which may block until
+// another request releases the lock.
+func priorityOpcode(op uint32) bool {
+	switch op {
+	case _OP_INIT, _OP_DESTROY, _OP_FORGET, _OP_BATCH_FORGET,
+		_OP_INTERRUPT, _OP_RELEASE, _OP_RELEASEDIR, _OP_NOTIFY_REPLY,
+		_OP_SETLKW:
+		return true
+	}
+	return false
+}
+
+// ClassifyByUid puts the requests of each uid in their own flow with
+// weight 1. FORGET, BATCH_FORGET, INTERRUPT, RELEASE, RELEASEDIR and
+// SETLKW are priority requests.
+func ClassifyByUid(header *InHeader) RequestClass {
+	return RequestClass{
+		Flow:     uint64(header.Uid),
+		Weight:   1,
+		Priority: priorityOpcode(header.Opcode),
+	}
+}
+
+// ClassifyByPid is like ClassifyByUid, but uses the pid of the
+// caller as flow. Threads of a process have the same pid (the
+// thread group ID).
+func ClassifyByPid(header *InHeader) RequestClass {
+	return RequestClass{
+		Flow:     uint64(header.Pid),
+		Weight:   1,
+		Priority: priorityOpcode(header.Opcode),
+	}
+}
+
+// FlowStats describes the state of a single flow.
+type FlowStats struct {
+	Running int
+	Queued  int
+}
+
+// SchedulerStats is a snapshot of the request scheduler.
+type SchedulerStats struct {
+	// Running and Queued count non-priority requests.
+	Running int
+	Queued  int
+
+	// Started and Priority are the cumulative numbers of
+	// requests started from the queues and bypassing them.
+	Started  uint64
+	Priority uint64
+
+	// Flows holds the flows that have requests running or
+	// queued.
+	Flows map[uint64]FlowStats
+}
+
+// schedCostUnit is the virtual time consumed by a request of a
+// flow with weight 1.
+const schedCostUnit = 1 << 16
+
+type schedWaiter struct {
+	tag    uint64
+	unique uint64
+	flow   *schedFlow
+
+	// start handles the request. It is called on a new goroutine
+	// once the request may run in flow f, or with interrupted set
+	// if the request was interrupted while it was queued.
+	start func(f *schedFlow, interrupted bool)
+}
+
+type schedFlow struct {
+	key     uint64
+	weight  int
+	running int
+	queue   []*schedWaiter
+
+	// finish is the virtual finish time of the last request
+	// queued for this flow.
+	finish uint64
+}
+
+// scheduler implements start-time fair queuing. Each request gets a
+// virtual start tag of max(vtime, finish of its flow), and the
+// waiting request with the lowest tag is started first.
+type scheduler struct {
+	maxConcurrent int
+	maxPerFlow    int
+	maxQueued     int
+	classify      func(*InHeader) RequestClass
+
+	mu       sync.Mutex
+	vtime    uint64
+	running  int
+	queued   int
+	started  uint64
+	priority uint64
+	flows    map[uint64]*schedFlow
+
+	// waiting holds the queued requests by their unique ID.
+	waiting map[uint64]*schedWaiter
+
+	// room is signaled when a request leaves the queues.
+	room sync.Cond
+}
+
+func newScheduler(opts *SchedulerOptions) *scheduler {
+	s := &scheduler{
+		maxConcurrent: opts.MaxConcurrent,
+		maxPerFlow:    opts.MaxPerFlow,
+		maxQueued:     opts.MaxQueued,
+		classify:      opts.Classify,
+		flows:         map[uint64]*schedFlow{},
+		waiting:       map[uint64]*schedWaiter{},
+	}
+	s.room.L = &s.mu
+	if s.maxConcurrent <= 0 {
+		s.maxConcurrent = 32
+	}
+	if s.maxQueued <= 0 {
+		s.maxQueued = 1024
+	}
+	if s.classify == nil {
+		s.classify = ClassifyByUid
+	}
+	return s
+}
+
+func (s *scheduler) canRunLocked(f *schedFlow) bool {
+	return s.running < s.maxConcurrent &&
+		(s.maxPerFlow <= 0 || f.running < s.maxPerFlow)
+}
+
+func (s *scheduler) startLocked(f *schedFlow, tag uint64) {
+	if tag > s.vtime {
+		s.vtime = tag
+	}
+	s.running++
+	s.started++
+	f.running++
+}
+
+// admit blocks until the request may be handled. The result must
+// be passed to done once the request is finished.
+func (s *scheduler) admit(header *InHeader) *schedFlow {
+	ready := make(chan *schedFlow, 1)
+	f, queued := s.enqueue(header, func(f *schedFlow, interrupted bool) {
+		ready <- f
+	})
+	if queued {
+		f = <-ready
+	}
+	return f
+}
+
+// enqueue is like admit, but does not block. If the request may run
+// right away, it returns its flow. Otherwise, the request is queued,
+// and start is called once it may run, or once it is interrupted.
+func (s *scheduler) enqueue(header *InHeader, start func(f *schedFlow, interrupted bool)) (f *schedFlow, queued bool) {
+	c := s.classify(header)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.Priority {
+		s.priority++
+		return nil, false
+	}
+
+	f = s.flows[c.Flow]
+	if f == nil {
+		f = &schedFlow{key: c.Flow}
+		s.flows[c.Flow] = f
+	}
+	f.weight = max(c.Weight, 1)
+
+	tag := max(s.vtime, f.finish)
+	f.finish = tag + uint64(schedCostUnit/f.weight)
+	if len(f.queue) == 0 && s.canRunLocked(f) {
+		s.startLocked(f, tag)
+		return f, false
+	}
+
+	w := &schedWaiter{tag: tag, unique: header.Unique, flow: f, start: start}
+	f.queue = append(f.queue, w)
+	s.waiting[w.unique] = w
+	s.queued++
+	return nil, true
+}
+
+// waitRoom blocks while the queues are full.
+func (s *scheduler) waitRoom() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.queued >= s.maxQueued {
+		s.room.Wait()
+	}
+}
+
+// interrupt removes the queued request with the given unique ID
+// from its queue, and starts it as interrupted. It returns false if
+// the request is not queued.
+func (s *scheduler) interrupt(unique uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := s.waiting[unique]
+	if w == nil {
+		return false
+	}
+	s.dequeueLocked(w)
+
+	f := w.flow
+	for i, q := range f.queue {
+		if q == w {
+			f.queue = append(f.queue[:i], f.queue[i+1:]...)
+			break
+		}
+	}
+	if f.running == 0 && len(f.queue) == 0 {
+		delete(s.flows, f.key)
+	}
+	go w.start(nil, true)
+	return true
+}
+
+func (s *scheduler) dequeueLocked(w *schedWaiter) {
+	if s.waiting[w.unique] == w {
+		delete(s.waiting, w.unique)
+	}
+	s.queued--
+	s.room.Broadcast()
+}
+
+// done releases the concurrency slot taken by admit, and starts
+// waiting requests.
+func (s *scheduler) done(f *schedFlow) {
+	if f == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running--
+	f.running--
+	s.dispatchLocked()
+	if f.running == 0 && len(f.queue) == 0 {
+		delete(s.flows, f.key)
+	}
+}
+
+func (s *scheduler) dispatchLocked() {
+	for s.running < s.maxConcurrent {
+		var next *schedFlow
+		for _, f := range s.flows {
+			if len(f.queue) == 0 || !s.canRunLocked(f) {
+				continue
+			}
+			if next == nil || f.queue[0].tag < next.queue[0].tag {
+				next = f
+			}
+		}
+		if next == nil {
+			return
+		}
+
+		w := next.queue[0]
+		next.queue[0] = nil
+		next.queue = next.queue[1:]
+		s.dequeueLocked(w)
+		s.startLocked(next, w.tag)
+		go w.start(next, false)
+	}
+}
+
+func (s *scheduler) stats() SchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := SchedulerStats{
+		Running:  s.running,
+		Queued:   s.queued,
+		Started:  s.started,
+		Priority: s.priority,
+		Flows:    make(map[uint64]FlowStats, len(s.flows)),
+	}
+	for k, f := range s.flows {
+		r.Flows[k] = FlowStats{Running: f.running, Queued: len(f.queue)}
+	}
+	return r
+}
+
+// SchedulerStats returns the state of the request scheduler. It
+// returns the zero value if MountOptions.Scheduler is unset.
+func (ms *Server) SchedulerStats() SchedulerStats {
+	if ms.scheduler == nil {
+		return SchedulerStats{}
+	}
+	return ms.scheduler.stats()
+}
@@ -0,0 +1,252 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuse
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func schedHeader(op uint32, uid uint32) *InHeader {
+	h := &InHeader{Opcode: op}
+	h.Uid = uid
+	return h
+}
+
+// admitAsync admits a request in the background, and reports the
+// flow on the returned channel once it starts.
+func admitAsync(s *scheduler, h *InHeader) <-chan *schedFlow {
+	ch := make(chan *schedFlow, 1)
+	go func() { ch <- s.admit(h) }()
+	return ch
+}
+
+func waitQueued(t *testing.T, s *scheduler, want int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for s.stats().Queued != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d queued, want %d", s.stats().Queued, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	s := newScheduler(&SchedulerOptions{MaxConcurrent: 1})
+	f := s.admit(schedHeader(_OP_GETATTR, 1))
+
+	// Concurrency is exhausted, but these should not block.
+	for _, op := range []uint32{_OP_FORGET, _OP_BATCH_FORGET, _OP_INTERRUPT, _OP_RELEASE, _OP_RELEASEDIR, _OP_SETLKW} {
+		if got := s.admit(schedHeader(op, 1)); got != nil {
+			t.Errorf("op %s: got flow %v, want priority", operationName(op), got)
+		}
+		s.done(nil)
+	}
+	s.done(f)
+
+	st := s.stats()
+	if st.Priority != 6 || st.Started != 1 || st.Running != 0 || len(st.Flows) != 0 {
+		t.Errorf("got stats %+v", st)
+	}
+}
+
+func TestSchedulerFairness(t *testing.T) {
+	s := newScheduler(&SchedulerOptions{MaxConcurrent: 1})
+	first := s.admit(schedHeader(_OP_READ, 1))
+
+	// uid 1 queues a backlog, then uid 2 arrives.
+	var busy []<-chan *schedFlow
+	for i := 0; i < 5; i++ {
+		busy = append(busy, admitAsync(s, schedHeader(_OP_READ, 1)))
+		waitQueued(t, s, i+1)
+	}
+	other := admitAsync(s, schedHeader(_OP_READ, 2))
+	waitQueued(t, s, 6)
+
+	want := map[uint64]FlowStats{
+		1: {Running: 1, Queued: 5},
+		2: {Queued: 1},
+	}
+	if got := s.stats().Flows; !reflect.DeepEqual(got, want) {
+		t.Errorf("got flows %v, want %v", got, want)
+	}
+
+	// uid 2 should go before the backlog of uid 1.
+	s.done(first)
+	select {
+	case f := <-other:
+		if f.key != 2 {
+			t.Fatalf("got flow %d", f.key)
+		}
+		s.done(f)
+	case <-time.After(time.Second):
+		t.Fatal("uid 2 starved")
+	}
+
+	for _, ch := range busy {
+		s.done(<-ch)
+	}
+	if st := s.stats(); st.Running != 0 || st.Queued != 0 || len(st.Flows) != 0 {
+		t.Errorf("got stats %+v after draining", st)
+	}
+}
+
+func TestSchedulerWeight(t *testing.T) {
+	s := newScheduler(&SchedulerOptions{
+		MaxConcurrent: 1,
+		Classify: func(h *InHeader) RequestClass {
+			c := ClassifyByUid(h)
+			if h.Uid == 0 {
+				c.Weight = 3
+			}
+			return c
+		},
+	})
+	first := s.admit(schedHeader(_OP_READ, 1))
+
+	started := make(chan *schedFlow, 8)
+	for i := 0; i < 4; i++ {
+		for _, uid := range []uint32{0, 1} {
+			h := schedHeader(_OP_READ, uid)
+			go func() { started <- s.admit(h) }()
+			waitQueued(t, s, 2*i+int(uid)+1)
+		}
+	}
+	s.done(first)
+
+	var order []uint64
+	for len(order) < 8 {
+		f := <-started
+		order = append(order, f.key)
+		s.done(f)
+	}
+
+	// With weight 3, uid 0 should finish its 4 requests before
+	// uid 1 has started its last 2.
+	zeros := 0
+	for _, k := range order[:6] {
+		if k == 0 {
+			zeros++
+		}
+	}
+	if zeros != 4 {
+		t.Errorf("got order %v, want all uid 0 requests in the first 6", order)
+	}
+}
+
+func TestSchedulerMaxPerFlow(t *testing.T) {
+	s := newScheduler(&SchedulerOptions{MaxConcurrent: 10, MaxPerFlow: 1})
+	a := s.admit(schedHeader(_OP_READ, 1))
+	queued := admitAsync(s, schedHeader(_OP_READ, 1))
+	waitQueued(t, s, 1)
+
+	// Another flow is not held up by the cap of uid 1.
+	b := s.admit(schedHeader(_OP_READ, 2))
+	s.done(b)
+
+	s.done(a)
+	s.done(<-queued)
+}
+
+type schedStart struct {
+	f           *schedFlow
+	interrupted bool
+}
+
+// enqueueChan enqueues a request, and reports how it was started on
+// the returned channel.
+func enqueueChan(s *scheduler, h *InHeader) (*schedFlow, bool, <-chan schedStart) {
+	ch := make(chan schedStart, 1)
+	f, queued := s.enqueue(h, func(f *schedFlow, interrupted bool) {
+		ch <- schedStart{f, interrupted}
+	})
+	return f, queued, ch
+}
+
+func TestSchedulerEnqueue(t *testing.T) {
+	s := newScheduler(&SchedulerOptions{MaxConcurrent: 1})
+	a, queued, _ := enqueueChan(s, schedHeader(_OP_READ, 1))
+	if a == nil || queued {
+		t.Fatalf("got %v, %v, want a started request", a, queued)
+	}
+
+	// A full scheduler queues without blocking the caller.
+	_, queued, ready := enqueueChan(s, schedHeader(_OP_READ, 2))
+	if !queued {
+		t.Fatal("request was not queued")
+	}
+	if f, queued, _ := enqueueChan(s, schedHeader(_OP_INTERRUPT, 1)); f != nil || queued {
+		t.Errorf("INTERRUPT: got %v, %v, want priority", f, queued)
+	}
+
+	select {
+	case <-ready:
+		t.Fatal("queued request started early")
+	default:
+	}
+	s.done(a)
+	b := <-ready
+	if b.f == nil || b.interrupted {
+		t.Fatalf("got start %+v", b)
+	}
+	s.done(b.f)
+	if st := s.stats(); st.Running != 0 || st.Queued != 0 {
+		t.Errorf("got stats %+v", st)
+	}
+}
+
+func TestSchedulerInterrupt(t *testing.T) {
+	s := newScheduler(&SchedulerOptions{MaxConcurrent: 1})
+	a := s.admit(schedHeader(_OP_READ, 1))
+
+	h := schedHeader(_OP_READ, 2)
+	h.Unique = 42
+	_, _, ready := enqueueChan(s, h)
+	waitQueued(t, s, 1)
+
+	if s.interrupt(41) {
+		t.Error("interrupt of unknown request succeeded")
+	}
+	if !s.interrupt(42) {
+		t.Fatal("interrupt of queued request failed")
+	}
+	if got := <-ready; got.f != nil || !got.interrupted {
+		t.Errorf("got start %+v, want interrupted", got)
+	}
+	if s.interrupt(42) {
+		t.Error("second interrupt succeeded")
+	}
+	if st := s.stats(); st.Queued != 0 || len(st.Flows) != 1 {
+		t.Errorf("got stats %+v", st)
+	}
+	s.done(a)
+	if st := s.stats(); st.Running != 0 || st.Started != 1 || len(st.Flows) != 0 {
+		t.Errorf("got stats %+v", st)
+	}
+}
+
+func TestSchedulerMaxQueued(t *testing.T) {
+	s := newScheduler(&SchedulerOptions{MaxConcurrent: 1, MaxQueued: 1})
+	a := s.admit(schedHeader(_OP_READ, 1))
+	queued := admitAsync(s, schedHeader(_OP_READ, 2))
+	waitQueued(t, s, 1)
+
+	full := make(chan struct{})
+	go func() {
+		s.waitRoom()
+		close(full)
+	}()
+	select {
+	case <-full:
+		t.Fatal("waitRoom returned with full queues")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	s.done(a)
+	<-full
+	s.done(<-queued)
+}
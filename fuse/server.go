@@ -83,6 +83,9 @@ type Server struct {
 
 	// for implementing single threaded processing.
 	requestProcessingMu sync.Mutex
+
+	// scheduler, if set, decides when requests are handled.
+	scheduler *scheduler
 }
 
 // SetDebug is deprecated. Use MountOptions.Debug instead.
@@ -255,6 +258,10 @@ func NewServer(fs RawFileSystem, mountPoint string, opts *MountOptions) (*Server
 		singleReader:  useSingleReader,
 		ready:         make(chan error, 1),
 	}
+	if o.Scheduler != nil {
+		ms.scheduler = newScheduler(o.Scheduler)
+		ms.protocolServer.interruptQueued = ms.scheduler.interrupt
+	}
 
 	ms.protocolServer.writev = ms.writev
 	ms.reqPool.New = func() interface{} {
@@ -368,7 +375,12 @@ func (ms *Server) DebugData() string {
 	r = ms.reqReaders
 	ms.reqMu.Unlock()
 
-	return fmt.Sprintf("readers: %d", r)
+	if ms.scheduler == nil {
+		return fmt.Sprintf("readers: %d", r)
+	}
+	st := ms.scheduler.stats()
+	return fmt.Sprintf("readers: %d, running: %d, queued: %d, flows: %d",
+		r, st.Running, st.Queued, len(st.Flows))
 }
 
 // handleEINTR retries the given function until it doesn't return syscall.EINTR.
@@ -567,7 +579,8 @@ func (ms *Server) handleInit() Status {
 	if errNo != OK || req == nil {
 		return errNo
 	}
-	if code := ms.handleRequest(req); !code.Ok() {
+	// INIT is a priority request, so it is not scheduled.
+	if code := ms.handleRequest(req, nil); !code.Ok() {
 		return code
 	}
 
@@ -634,28 +647,54 @@ exit:
 			break exit
 		}
 
+		var f *schedFlow
+		if ms.scheduler != nil {
+			// Requests wait for their turn outside the
+			// reader, which must stay free to read the
+			// INTERRUPT and FORGET requests that bypass the
+			// queues. Queued requests do not hold a
+			// goroutine; the scheduler starts them once
+			// they may run. An INTERRUPT for a queued
+			// request drops it from its queue, and it is
+			// answered with EINTR.
+			var queued bool
+			f, queued = ms.scheduler.enqueue(req.inHeader(), func(f *schedFlow, interrupted bool) {
+				req.dequeued = interrupted
+				ms.handleRequest(req, f)
+			})
+			if queued {
+				ms.scheduler.waitRoom()
+				continue
+			}
+		}
+
 		if ms.singleReader {
 			ms.reqMu.Lock()
 			canReserve := ms.canReserveRequestBytes()
 			ms.reqMu.Unlock()
 			if canReserve {
-				go ms.handleRequest(req)
+				go ms.handleRequest(req, f)
 				continue
 			}
 		}
-		ms.handleRequest(req)
+		ms.handleRequest(req, f)
 	}
 }
 
-func (ms *Server) handleRequest(req *requestAlloc) Status {
+// handleRequest handles req, which was admitted by the scheduler in
+// flow f, if there is a scheduler.
+func (ms *Server) handleRequest(req *requestAlloc, f *schedFlow) Status {
 	defer ms.returnRequest(req)
+	if ms.scheduler != nil {
+		defer ms.scheduler.done(f)
+	}
 	if ms.opts.SingleThreaded {
 		ms.requestProcessingMu.Lock()
 		defer ms.requestProcessingMu.Unlock()
 	}
 
-	h, inSize, outSize, outPayloadSize, code := parseRequest(req.inputBuf, &ms.kernelSettings)
-	if !code.Ok() {
+	h, inSize, outSize, outPayloadSize, code := parseRequest(req.inputBuf, &ms.kernelSettings, maxPayloadSize(ms.opts))
+	if h == nil {
 		ms.opts.Logger.Printf("parseRequest: %v", code)
 		return code
 	}
@@ -663,6 +702,17 @@ func (ms *Server) handleRequest(req *requestAlloc) Status {
 	req.suppressReply = h.SuppressReply
 	req.inPayload = req.inputBuf[inSize:]
 	req.inputBuf = req.inputBuf[:inSize]
+	if code.Ok() {
+		code = req.checkInput()
+	}
+	if !code.Ok() {
+		ms.opts.Logger.Printf("op %v: invalid request: %v", h.Name, code)
+		outSize, outPayloadSize = 0, 0
+	} else if req.dequeued {
+		code = EINTR
+		outSize, outPayloadSize = 0, 0
+	}
+	req.status = code
 	req.outHeaderBuf = req.outHeaderInline[:]
 	req.outDataBuf = req.outDataInline[:outSize]
 	clear(req.outHeaderBuf)
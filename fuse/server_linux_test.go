@@ -282,3 +282,71 @@ func waitForReader(t *testing.T, srv *Server) {
 	}
 	t.Fatal("timed out waiting for a request reader")
 }
+
+func TestSchedulerMaxPerFlowMount(t *testing.T) {
+	const maxWrite = 4096
+	fs := newBlockingWriteFS()
+	mnt := t.TempDir()
+	opts := MountOptions{
+		MaxWrite:  maxWrite,
+		Logger:    log.New(io.Discard, "", 0),
+		Scheduler: &SchedulerOptions{MaxPerFlow: 1},
+	}
+	srv, err := NewServer(fs, mnt, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		fs.unblock()
+		if err := srv.Unmount(); err != nil {
+			t.Fatalf("Unmount: %v", err)
+		}
+	})
+	go srv.Serve()
+	if err := srv.WaitMount(); err != nil {
+		t.Fatal(err)
+	}
+
+	fds := make([]int, 2)
+	for i := range fds {
+		fd, err := syscall.Open(mnt+"/file", syscall.O_WRONLY, 0)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		fds[i] = fd
+	}
+
+	payload := bytes.Repeat([]byte("x"), maxWrite)
+	writeResults := make(chan error, len(fds))
+	for i := range fds {
+		fd := fds[i]
+		offset := int64(i * maxWrite)
+		go func() {
+			_, err := syscall.Pwrite(fd, payload, offset)
+			writeResults <- err
+		}()
+	}
+
+	seen := make(map[uint64]bool)
+	waitWriteEnteredSet(t, fs.entered, 1, seen)
+	if got := receiveWriteEntered(fs.entered, 50*time.Millisecond); got != 0 {
+		t.Fatalf("WRITE unique %d entered with MaxPerFlow = 1", got)
+	}
+	st := srv.SchedulerStats()
+	if st.Running != 1 || st.Queued != 1 {
+		t.Errorf("got stats %+v, want 1 running and 1 queued", st)
+	}
+
+	fs.unblock()
+	waitWriteEnteredSet(t, fs.entered, len(fds), seen)
+	for range fds {
+		if err := waitWriteResult(writeResults); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	for _, fd := range fds {
+		if err := syscall.Close(fd); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+}
@@ -70,6 +70,9 @@ const (
 
 	// EROFS Read-only file system
 	EROFS = Status(syscall.EROFS)
+
+	// EPROTO Protocol error
+	EPROTO = Status(syscall.EPROTO)
 )
 
 type ForgetIn struct {
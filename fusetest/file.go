@@ -0,0 +1,391 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fusetest
+
+import (
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// File is an open file or directory.
+type File struct {
+	k      *Kernel
+	nodeID uint64
+	fh     uint64
+	flags  uint32
+	dir    bool
+
+	mu     sync.Mutex
+	dirOff uint64
+	closed bool
+}
+
+// Open opens name. The flags are those of open(2). Like the kernel,
+// O_CREAT is translated into CREATE, and O_TRUNC into a SETATTR
+// after OPEN.
+func (k *Kernel) Open(name string, flags int, mode uint32) (*File, syscall.Errno) {
+	fl := uint32(flags)
+	follow := fl&syscall.O_NOFOLLOW == 0
+	if fl&syscall.O_CREAT != 0 {
+		parent, base, errno := k.resolveParent(name)
+		if errno == syscall.EBUSY {
+			return nil, syscall.EISDIR
+		}
+		if errno != 0 {
+			return nil, errno
+		}
+		_, m, errno := k.lookup(parent, base)
+		if errno == syscall.ENOENT {
+			return k.create(parent, base, fl, mode)
+		}
+		if errno != 0 {
+			return nil, errno
+		}
+		if fl&syscall.O_EXCL != 0 {
+			return nil, syscall.EEXIST
+		}
+		if m&syscall.S_IFMT == syscall.S_IFDIR {
+			return nil, syscall.EISDIR
+		}
+	}
+
+	id, m, errno := k.resolve(name, follow)
+	if errno != 0 {
+		return nil, errno
+	}
+	accMode := fl & syscall.O_ACCMODE
+	switch m & syscall.S_IFMT {
+	case syscall.S_IFLNK:
+		return nil, syscall.ELOOP
+	case syscall.S_IFDIR:
+		if accMode != syscall.O_RDONLY || fl&syscall.O_TRUNC != 0 {
+			return nil, syscall.EISDIR
+		}
+	default:
+		if fl&syscall.O_DIRECTORY != 0 {
+			return nil, syscall.ENOTDIR
+		}
+	}
+
+	dir := m&syscall.S_IFMT == syscall.S_IFDIR
+	op := opOpen
+	if dir {
+		op = opOpendir
+	}
+	in := fuse.OpenIn{
+		Flags: fl &^ (syscall.O_CREAT | syscall.O_EXCL | syscall.O_NOCTTY | syscall.O_TRUNC),
+	}
+	out := fuse.OpenOut{}
+	if _, errno := k.call(op, id, unsafe.Pointer(&in), unsafe.Sizeof(in), nil, unsafe.Pointer(&out), unsafe.Sizeof(out), nil); errno != 0 {
+		return nil, errno
+	}
+	f := k.newFile(id, out.Fh, fl, dir)
+	if fl&syscall.O_TRUNC != 0 && accMode != syscall.O_RDONLY && m&syscall.S_IFMT == syscall.S_IFREG {
+		if errno := f.Truncate(0); errno != 0 {
+			f.Close()
+			return nil, errno
+		}
+	}
+	return f, 0
+}
+
+func (k *Kernel) create(parent uint64, name string, flags, mode uint32) (*File, syscall.Errno) {
+	in := fuse.CreateIn{
+		Flags: flags &^ syscall.O_NOCTTY,
+		Mode:  (mode&07777)&^k.opts.Umask | syscall.S_IFREG,
+		Umask: k.opts.Umask,
+	}
+	out := fuse.CreateOut{}
+	if _, errno := k.call(opCreate, parent, unsafe.Pointer(&in), unsafe.Sizeof(in), cString(name), unsafe.Pointer(&out), unsafe.Sizeof(out), nil); errno != 0 {
+		return nil, errno
+	}
+	if out.NodeId == 0 {
+		return nil, syscall.EIO
+	}
+	k.addEntry(parent, name, &out.EntryOut)
+	k.invalidateAttr(parent)
+	return k.newFile(out.NodeId, out.Fh, flags, false), 0
+}
+
+func (k *Kernel) newFile(nodeID, fh uint64, flags uint32, dir bool) *File {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	ino := k.inodes[nodeID]
+	if ino == nil {
+		// The entry was invalidated while opening. Keep the
+		// inode around, but we hold no lookups for it.
+		ino = &inode{nodeID: nodeID}
+		k.inodes[nodeID] = ino
+	}
+	ino.opens++
+	return &File{
+		k:      k,
+		nodeID: nodeID,
+		fh:     fh,
+		flags:  flags,
+		dir:    dir,
+	}
+}
+
+// NodeID returns the node ID of the open file.
+func (f *File) NodeID() uint64 {
+	return f.nodeID
+}
+
+// Close sends FLUSH and RELEASE for a file, or RELEASEDIR for a
+// directory. It returns the result of FLUSH.
+func (f *File) Close() syscall.Errno {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return syscall.EBADF
+	}
+	f.closed = true
+	f.mu.Unlock()
+
+	k := f.k
+	var errno syscall.Errno
+	op := opReleasedir
+	if !f.dir {
+		op = opRelease
+		in := fuse.FlushIn{Fh: f.fh}
+		_, errno = k.call(opFlush, f.nodeID, unsafe.Pointer(&in), unsafe.Sizeof(in), nil, nil, 0, nil)
+		if errno == syscall.ENOSYS {
+			errno = 0
+		}
+	}
+	in := fuse.ReleaseIn{Fh: f.fh, Flags: f.flags}
+	k.call(op, f.nodeID, unsafe.Pointer(&in), unsafe.Sizeof(in), nil, nil, 0, nil)
+
+	k.mu.Lock()
+	var forgets []forgetOne
+	if ino := k.inodes[f.nodeID]; ino != nil {
+		ino.opens--
+		forgets = k.maybeEvictLocked(ino, nil)
+	}
+	k.mu.Unlock()
+	k.sendForgets(forgets)
+	return errno
+}
+
+// Read reads into dest at the given offset. Reads larger than the
+// negotiated maximum are split up.
+func (f *File) Read(dest []byte, off int64) (int, syscall.Errno) {
+	total := 0
+	for total < len(dest) {
+		chunk := dest[total:min(len(dest), total+f.k.maxWrite)]
+		in := fuse.ReadIn{
+			Fh:     f.fh,
+			Offset: uint64(off) + uint64(total),
+			Size:   uint32(len(chunk)),
+			Flags:  f.flags,
+		}
+		n, errno := f.k.call(opRead, f.nodeID, unsafe.Pointer(&in), unsafe.Sizeof(in), nil, nil, 0, chunk)
+		if errno != 0 {
+			return total, errno
+		}
+		total += n
+		if n < len(chunk) {
+			break
+		}
+	}
+	return total, 0
+}
+
+// Write writes data at the given offset. If the file was opened with
+// O_APPEND, the data is written at the end of the file instead.
+// Writes larger than the negotiated maximum are split up.
+func (f *File) Write(data []byte, off int64) (int, syscall.Errno) {
+	k := f.k
+	if f.flags&syscall.O_APPEND != 0 {
+		k.invalidateAttr(f.nodeID)
+		var attr fuse.Attr
+		if errno := k.getattr(f.nodeID, f.fh, &attr); errno != 0 {
+			return 0, errno
+		}
+		off = int64(attr.Size)
+	}
+	defer k.invalidateAttr(f.nodeID)
+
+	total := 0
+	for total < len(data) {
+		chunk := data[total:min(len(data), total+k.maxWrite)]
+		in := fuse.WriteIn{
+			Fh:     f.fh,
+			Offset: uint64(off) + uint64(total),
+			Size:   uint32(len(chunk)),
+			Flags:  f.flags,
+		}
+		out := fuse.WriteOut{}
+		if _, errno := k.call(opWrite, f.nodeID, unsafe.Pointer(&in), unsafe.Sizeof(in), chunk, unsafe.Pointer(&out), unsafe.Sizeof(out), nil); errno != 0 {
+			return total, errno
+		}
+		total += int(out.Size)
+		if int(out.Size) < len(chunk) {
+			break
+		}
+	}
+	return total, 0
+}
+
+// Stat returns the attributes of the open file.
+func (f *File) Stat(out *fuse.Attr) syscall.Errno {
+	return f.k.getattr(f.nodeID, f.fh, out)
+}
+
+// Setattr changes attributes of the open file. The file handle is
+// passed along with the request.
+func (f *File) Setattr(in *fuse.SetAttrIn, out *fuse.Attr) syscall.Errno {
+	in.Valid |= fuse.FATTR_FH
+	in.Fh = f.fh
+	return f.k.setattr(f.nodeID, in, out)
+}
+
+// Truncate sets the size of the open file.
+func (f *File) Truncate(size uint64) syscall.Errno {
+	in := fuse.SetAttrIn{}
+	in.Valid = fuse.FATTR_SIZE
+	in.Size = size
+	return f.Setattr(&in, nil)
+}
+
+// Fsync sends FSYNC, or FSYNCDIR for directories.
+func (f *File) Fsync(flags uint32) syscall.Errno {
+	op := opFsync
+	if f.dir {
+		op = opFsyncdir
+	}
+	in := fuse.FsyncIn{Fh: f.fh, FsyncFlags: flags}
+	_, errno := f.k.call(op, f.nodeID, unsafe.Pointer(&in), unsafe.Sizeof(in), nil, nil, 0, nil)
+	return errno
+}
+
+// Allocate sends FALLOCATE. The mode is that of fallocate(2).
+func (f *File) Allocate(off uint64, size uint64, mode uint32) syscall.Errno {
+	in := fuse.FallocateIn{Fh: f.fh, Offset: off, Length: size, Mode: mode}
+	_, errno := f.k.call(opFallocate, f.nodeID, unsafe.Pointer(&in), unsafe.Sizeof(in), nil, nil, 0, nil)
+	f.k.invalidateAttr(f.nodeID)
+	return errno
+}
+
+// Lseek sends LSEEK. The kernel only forwards SEEK_DATA and
+// SEEK_HOLE to the file system; other values are sent unchanged.
+func (f *File) Lseek(off int64, whence int) (int64, syscall.Errno) {
+	in := fuse.LseekIn{Fh: f.fh, Offset: uint64(off), Whence: uint32(whence)}
+	out := fuse.LseekOut{}
+	if _, errno := f.k.call(opLseek, f.nodeID, unsafe.Pointer(&in), unsafe.Sizeof(in), nil, unsafe.Pointer(&out), unsafe.Sizeof(out), nil); errno != 0 {
+		return 0, errno
+	}
+	return int64(out.Offset), 0
+}
+
+// Getlk returns a lock conflicting with lk for the given lock owner,
+// or a lock of type F_UNLCK if there is none.
+func (f *File) Getlk(owner uint64, lk *fuse.FileLock, out *fuse.FileLock) syscall.Errno {
+	in := fuse.LkIn{Fh: f.fh, Owner: owner, Lk: *lk}
+	lkOut := fuse.LkOut{}
+	if _, errno := f.k.call(opGetlk, f.nodeID, unsafe.Pointer(&in), unsafe.Sizeof(in), nil, unsafe.Pointer(&lkOut), unsafe.Sizeof(lkOut), nil); errno != 0 {
+		return errno
+	}
+	*out = lkOut.Lk
+	return 0
+}
+
+// Setlk acquires or releases a lock without blocking. Flags can be
+// fuse.LK_FLOCK for BSD-style locks.
+func (f *File) Setlk(owner uint64, lk *fuse.FileLock, flags uint32) syscall.Errno {
+	return f.setlk(opSetlk, owner, lk, flags)
+}
+
+// Setlkw acquires or releases a lock, waiting for conflicting locks
+// to be released.
+func (f *File) Setlkw(owner uint64, lk *fuse.FileLock, flags uint32) syscall.Errno {
+	return f.setlk(opSetlkw, owner, lk, flags)
+}
+
+func (f *File) setlk(op uint32, owner uint64, lk *fuse.FileLock, flags uint32) syscall.Errno {
+	in := fuse.LkIn{Fh: f.fh, Owner: owner, Lk: *lk, LkFlags: flags}
+	_, errno := f.k.call(op, f.nodeID, unsafe.Pointer(&in), unsafe.Sizeof(in), nil, nil, 0, nil)
+	return errno
+}
+
+// dirent mirrors the record header of READDIR replies.
+type dirent struct {
+	Ino     uint64
+	Off     uint64
+	NameLen uint32
+	Typ     uint32
+}
+
+// ReadDir reads the next batch of entries from an open directory,
+// like getdents(2). It returns no entries at the end of the
+// directory. With READDIRPLUS, the returned entries are added to the
+// dentry cache.
+func (f *File) ReadDir() ([]fuse.DirEntry, syscall.Errno) {
+	if !f.dir {
+		return nil, syscall.ENOTDIR
+	}
+	k := f.k
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	op := opReaddir
+	if k.readDirPlus {
+		op = opReaddirplus
+	}
+	in := fuse.ReadIn{Fh: f.fh, Offset: f.dirOff, Size: pageSize, Flags: f.flags}
+	buf := make([]byte, pageSize)
+	n, errno := k.call(op, f.nodeID, unsafe.Pointer(&in), unsafe.Sizeof(in), nil, nil, 0, buf)
+	if errno != 0 {
+		return nil, errno
+	}
+	buf = buf[:n]
+
+	var r []fuse.DirEntry
+	entrySize := int(unsafe.Sizeof(fuse.EntryOut{}))
+	direntSize := int(unsafe.Sizeof(dirent{}))
+	for len(buf) > 0 {
+		var entry *fuse.EntryOut
+		if op == opReaddirplus {
+			if len(buf) < entrySize {
+				return nil, syscall.EIO
+			}
+			entry = (*fuse.EntryOut)(unsafe.Pointer(&buf[0]))
+			buf = buf[entrySize:]
+		}
+		if len(buf) < direntSize {
+			return nil, syscall.EIO
+		}
+		d := (*dirent)(unsafe.Pointer(&buf[0]))
+		end := direntSize + int(d.NameLen)
+		if end > len(buf) {
+			return nil, syscall.EIO
+		}
+		name := string(buf[direntSize:end])
+		r = append(r, fuse.DirEntry{
+			Mode: d.Typ << 12,
+			Name: name,
+			Ino:  d.Ino,
+			Off:  d.Off,
+		})
+		f.dirOff = d.Off
+		if entry != nil && entry.NodeId != 0 && name != "." && name != ".." {
+			k.addEntry(f.nodeID, name, entry)
+		}
+		buf = buf[min(len(buf), (end+7)&^7):]
+	}
+	return r, 0
+}
+
+// Seekdir sets the offset for the next ReadDir call. Offset 0
+// restarts the listing.
+func (f *File) Seekdir(off uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dirOff = off
+}
@@ -0,0 +1,630 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package fusetest provides an in-process emulation of the kernel
+// side of the FUSE protocol.
+//
+// A Kernel drives a fuse.RawFileSystem through a fuse.ProtocolServer,
+// so file systems can be exercised from unit tests without mounting
+// them. It keeps the bookkeeping that the Linux kernel does on behalf
+// of a FUSE mount: lookup counts per node ID, a dentry cache and an
+// attribute cache that honor the timeouts returned by the file
+// system, FORGET messages for evicted inodes, and READDIRPLUS
+// population of the dentry cache.
+//
+// For file systems built with the fs package, pass the Kernel as
+// fs.Options.ServerCallbacks, so cache invalidations issued by the
+// file system reach the emulated caches:
+//
+//	k := fusetest.NewKernel(nil)
+//	raw := fs.NewNodeFS(root, &fs.Options{ServerCallbacks: k})
+//	if err := k.Init(raw); err != nil { ... }
+//	f, errno := k.Open("dir/file", syscall.O_RDONLY, 0)
+//
+// The emulation is single-client and has no page cache: all reads
+// and writes are sent to the file system.
+//
+// EXPERIMENTAL: not subject to API stability.
+package fusetest
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// Options configures the emulated kernel.
+type Options struct {
+	// MountOptions are passed to the fuse.ProtocolServer.
+	fuse.MountOptions
+
+	// Caller is the identity used for requests. If unset, the
+	// effective UID/GID and PID of the current process are used.
+	Caller *fuse.Caller
+
+	// Umask is applied to the mode of newly created nodes, and
+	// sent along with CREATE, MKDIR and MKNOD.
+	Umask uint32
+
+	// DisableReadDirPlus makes the kernel use READDIR even if the
+	// file system supports READDIRPLUS.
+	DisableReadDirPlus bool
+
+	// Now returns the current time, for expiring cache
+	// entries. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Kernel emulates the kernel side of a FUSE mount.
+type Kernel struct {
+	opts   Options
+	fs     fuse.RawFileSystem
+	server *fuse.ProtocolServer
+	unique atomic.Uint64
+
+	// Set by INIT.
+	maxWrite    int
+	readDirPlus bool
+
+	mu        sync.Mutex
+	caller    fuse.Caller
+	inodes    map[uint64]*inode
+	dentries  map[dentryKey]*dentry
+	counts    map[string]int
+	unmounted bool
+}
+
+// inode is the kernel's view of a node ID.
+type inode struct {
+	nodeID  uint64
+	nlookup uint64
+
+	// dentries is the number of names referring to this inode,
+	// children the number of names cached in this directory, and
+	// opens the number of open files. The inode is evicted and
+	// forgotten once all are zero.
+	dentries int
+	children int
+	opens    int
+
+	attr       fuse.Attr
+	attrExpiry time.Time
+}
+
+type dentryKey struct {
+	parent uint64
+	name   string
+}
+
+// dentry is a cached name. A nodeID of 0 marks a negative entry.
+type dentry struct {
+	nodeID uint64
+	expiry time.Time
+}
+
+type forgetOne struct {
+	NodeId  uint64
+	Nlookup uint64
+}
+
+type batchForgetIn struct {
+	fuse.InHeader
+	Count uint32
+	Dummy uint32
+}
+
+const (
+	opLookup      = uint32(1)
+	opForget      = uint32(2)
+	opGetattr     = uint32(3)
+	opSetattr     = uint32(4)
+	opReadlink    = uint32(5)
+	opSymlink     = uint32(6)
+	opMknod       = uint32(8)
+	opMkdir       = uint32(9)
+	opUnlink      = uint32(10)
+	opRmdir       = uint32(11)
+	opRename      = uint32(12)
+	opLink        = uint32(13)
+	opOpen        = uint32(14)
+	opRead        = uint32(15)
+	opWrite       = uint32(16)
+	opStatfs      = uint32(17)
+	opRelease     = uint32(18)
+	opFsync       = uint32(20)
+	opSetxattr    = uint32(21)
+	opGetxattr    = uint32(22)
+	opListxattr   = uint32(23)
+	opRemovexattr = uint32(24)
+	opFlush       = uint32(25)
+	opInit        = uint32(26)
+	opOpendir     = uint32(27)
+	opReaddir     = uint32(28)
+	opReleasedir  = uint32(29)
+	opFsyncdir    = uint32(30)
+	opGetlk       = uint32(31)
+	opSetlk       = uint32(32)
+	opSetlkw      = uint32(33)
+	opAccess      = uint32(34)
+	opCreate      = uint32(35)
+	opBatchForget = uint32(42)
+	opFallocate   = uint32(43)
+	opReaddirplus = uint32(44)
+	opRename2     = uint32(45)
+	opLseek       = uint32(46)
+)
+
+var opNames = map[uint32]string{
+	opLookup:      "LOOKUP",
+	opForget:      "FORGET",
+	opGetattr:     "GETATTR",
+	opSetattr:     "SETATTR",
+	opReadlink:    "READLINK",
+	opSymlink:     "SYMLINK",
+	opMknod:       "MKNOD",
+	opMkdir:       "MKDIR",
+	opUnlink:      "UNLINK",
+	opRmdir:       "RMDIR",
+	opRename:      "RENAME",
+	opLink:        "LINK",
+	opOpen:        "OPEN",
+	opRead:        "READ",
+	opWrite:       "WRITE",
+	opStatfs:      "STATFS",
+	opRelease:     "RELEASE",
+	opFsync:       "FSYNC",
+	opSetxattr:    "SETXATTR",
+	opGetxattr:    "GETXATTR",
+	opListxattr:   "LISTXATTR",
+	opRemovexattr: "REMOVEXATTR",
+	opFlush:       "FLUSH",
+	opInit:        "INIT",
+	opOpendir:     "OPENDIR",
+	opReaddir:     "READDIR",
+	opReleasedir:  "RELEASEDIR",
+	opFsyncdir:    "FSYNCDIR",
+	opGetlk:       "GETLK",
+	opSetlk:       "SETLK",
+	opSetlkw:      "SETLKW",
+	opAccess:      "ACCESS",
+	opCreate:      "CREATE",
+	opBatchForget: "BATCH_FORGET",
+	opFallocate:   "FALLOCATE",
+	opReaddirplus: "READDIRPLUS",
+	opRename2:     "RENAME2",
+	opLseek:       "LSEEK",
+}
+
+// The capabilities offered by the emulated kernel in INIT.
+const kernelCapabilities = fuse.CAP_ASYNC_READ | fuse.CAP_POSIX_LOCKS |
+	fuse.CAP_FILE_OPS | fuse.CAP_BIG_WRITES |
+	fuse.CAP_FLOCK_LOCKS | fuse.CAP_READDIRPLUS |
+	fuse.CAP_READDIRPLUS_AUTO | fuse.CAP_PARALLEL_DIROPS |
+	fuse.CAP_MAX_PAGES | fuse.CAP_CACHE_SYMLINKS | fuse.CAP_EXPORT_SUPPORT
+
+// The protocol version spoken by the emulated kernel.
+const (
+	kernelVersion      = 7
+	kernelMinorVersion = 31
+)
+
+// pageSize is the buffer size for READDIR and READLINK requests.
+const pageSize = 4096
+
+// NewKernel returns an emulated kernel. It must be connected to a
+// file system with Init before use.
+func NewKernel(opts *Options) *Kernel {
+	k := &Kernel{
+		inodes:   map[uint64]*inode{},
+		dentries: map[dentryKey]*dentry{},
+		counts:   map[string]int{},
+	}
+	if opts != nil {
+		k.opts = *opts
+	}
+	if k.opts.Now == nil {
+		k.opts.Now = time.Now
+	}
+	if k.opts.Caller != nil {
+		k.caller = *k.opts.Caller
+	} else {
+		k.caller = fuse.Caller{
+			Owner: fuse.Owner{
+				Uid: uint32(os.Geteuid()),
+				Gid: uint32(os.Getegid()),
+			},
+			Pid: uint32(os.Getpid()),
+		}
+	}
+
+	// The root is looked up implicitly by mounting.
+	k.inodes[fuse.FUSE_ROOT_ID] = &inode{
+		nodeID:   fuse.FUSE_ROOT_ID,
+		nlookup:  1,
+		dentries: 1,
+	}
+	return k
+}
+
+// New returns a Kernel that is connected to the given file system.
+func New(raw fuse.RawFileSystem, opts *Options) (*Kernel, error) {
+	k := NewKernel(opts)
+	if err := k.Init(raw); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Init connects the kernel to the file system, and performs the INIT
+// handshake.
+func (k *Kernel) Init(raw fuse.RawFileSystem) error {
+	if k.server != nil {
+		return fmt.Errorf("fusetest: already initialized")
+	}
+	k.fs = raw
+	k.server = fuse.NewProtocolServer(raw, &k.opts.MountOptions)
+
+	in := fuse.InitIn{
+		Major:        kernelVersion,
+		Minor:        kernelMinorVersion,
+		MaxReadAhead: 128 << 10,
+		Flags:        uint32(kernelCapabilities),
+	}
+	out := fuse.InitOut{}
+	_, errno := k.call(opInit, 0, unsafe.Pointer(&in), unsafe.Sizeof(in), nil,
+		unsafe.Pointer(&out), unsafe.Sizeof(out), nil)
+	if errno != 0 {
+		return fmt.Errorf("fusetest: INIT: %v", errno)
+	}
+	if out.Major != kernelVersion {
+		return fmt.Errorf("fusetest: INIT: major version %d", out.Major)
+	}
+
+	k.maxWrite = int(out.MaxWrite)
+	if k.maxWrite == 0 {
+		k.maxWrite = pageSize
+	}
+	k.readDirPlus = !k.opts.DisableReadDirPlus && out.Flags&fuse.CAP_READDIRPLUS != 0
+	return nil
+}
+
+// SetCaller changes the identity used for subsequent requests.
+func (k *Kernel) SetCaller(c fuse.Caller) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.caller = c
+}
+
+// Counts returns the number of requests sent per opcode, keyed by
+// opcode name (eg. "LOOKUP").
+func (k *Kernel) Counts() map[string]int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	r := make(map[string]int, len(k.counts))
+	for key, v := range k.counts {
+		r[key] = v
+	}
+	return r
+}
+
+// ResetCounts clears the per-opcode request counts.
+func (k *Kernel) ResetCounts() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.counts = map[string]int{}
+}
+
+// LookupCount returns the number of lookups the kernel holds on the
+// node ID, ie. the sum of Nlookup in the FORGET that will be sent
+// when the node is evicted. It returns 0 for unknown nodes.
+func (k *Kernel) LookupCount(nodeID uint64) uint64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if ino := k.inodes[nodeID]; ino != nil {
+		return ino.nlookup
+	}
+	return 0
+}
+
+// NodeID resolves name, and returns its node ID. A trailing symlink
+// is not followed.
+func (k *Kernel) NodeID(name string) (uint64, syscall.Errno) {
+	id, _, errno := k.resolve(name, false)
+	return id, errno
+}
+
+// NodeIDs returns the node IDs that the kernel currently knows
+// about, including the root.
+func (k *Kernel) NodeIDs() []uint64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var r []uint64
+	for id := range k.inodes {
+		r = append(r, id)
+	}
+	return r
+}
+
+// DropCaches evicts all unused dentries and inodes, sending FORGET
+// for the evicted inodes. This is similar to writing 2 to
+// /proc/sys/vm/drop_caches.
+func (k *Kernel) DropCaches() {
+	k.mu.Lock()
+	var forgets []forgetOne
+	for {
+		var unused []dentryKey
+		for key, d := range k.dentries {
+			if d.nodeID == 0 {
+				unused = append(unused, key)
+				continue
+			}
+			ino := k.inodes[d.nodeID]
+			if ino.children == 0 && ino.opens == 0 {
+				unused = append(unused, key)
+			}
+		}
+		if len(unused) == 0 {
+			break
+		}
+		for _, key := range unused {
+			forgets = k.dropDentryLocked(key, forgets)
+		}
+	}
+	k.mu.Unlock()
+	k.sendForgets(forgets)
+}
+
+// Unmount disconnects the file system. Subsequent operations fail
+// with ENOTCONN.
+func (k *Kernel) Unmount() {
+	k.mu.Lock()
+	if k.unmounted {
+		k.mu.Unlock()
+		return
+	}
+	k.unmounted = true
+	k.mu.Unlock()
+	k.fs.OnUnmount()
+}
+
+// call sends a single request. The input struct must start with a
+// fuse.InHeader, which is filled out by call. It returns the number
+// of bytes written into outPayload.
+func (k *Kernel) call(op uint32, nodeID uint64, in unsafe.Pointer, inSize uintptr, inPayload []byte, out unsafe.Pointer, outSize uintptr, outPayload []byte) (int, syscall.Errno) {
+	k.mu.Lock()
+	if k.unmounted {
+		k.mu.Unlock()
+		return 0, syscall.ENOTCONN
+	}
+	k.counts[opNames[op]]++
+	caller := k.caller
+	k.mu.Unlock()
+
+	hdr := (*fuse.InHeader)(in)
+	hdr.Length = uint32(int(inSize) + len(inPayload))
+	hdr.Opcode = op
+	hdr.Unique = k.unique.Add(1)
+	hdr.NodeId = nodeID
+	hdr.Caller = caller
+
+	inIov := [][]byte{unsafe.Slice((*byte)(in), inSize)}
+	if len(inPayload) > 0 {
+		inIov = append(inIov, inPayload)
+	}
+
+	if op == opForget || op == opBatchForget {
+		k.server.HandleRequest(inIov, nil)
+		return 0, 0
+	}
+
+	var outHeader fuse.OutHeader
+	outIov := [][]byte{unsafe.Slice((*byte)(unsafe.Pointer(&outHeader)), unsafe.Sizeof(outHeader))}
+	if outSize > 0 {
+		outIov = append(outIov, unsafe.Slice((*byte)(out), outSize))
+	}
+	if outPayload != nil {
+		outIov = append(outIov, outPayload)
+	}
+
+	_, status := k.server.HandleRequest(inIov, outIov)
+	if status != fuse.OK {
+		return 0, syscall.Errno(status)
+	}
+	if outHeader.Status != 0 {
+		return 0, syscall.Errno(-outHeader.Status)
+	}
+	n := int(outHeader.Length) - int(unsafe.Sizeof(outHeader)) - int(outSize)
+	if n < 0 {
+		n = 0
+	}
+	return n, 0
+}
+
+// callName sends a request whose payload is a single file name.
+func (k *Kernel) callName(op uint32, nodeID uint64, in unsafe.Pointer, inSize uintptr, name string, out unsafe.Pointer, outSize uintptr) syscall.Errno {
+	_, errno := k.call(op, nodeID, in, inSize, cString(name), out, outSize, nil)
+	return errno
+}
+
+func cString(s string) []byte {
+	b := make([]byte, len(s)+1)
+	copy(b, s)
+	return b
+}
+
+func (k *Kernel) sendForgets(forgets []forgetOne) {
+	switch len(forgets) {
+	case 0:
+	case 1:
+		in := fuse.ForgetIn{Nlookup: forgets[0].Nlookup}
+		k.call(opForget, forgets[0].NodeId, unsafe.Pointer(&in), unsafe.Sizeof(in), nil, nil, 0, nil)
+	default:
+		in := batchForgetIn{Count: uint32(len(forgets))}
+		payload := unsafe.Slice((*byte)(unsafe.Pointer(&forgets[0])), uintptr(len(forgets))*unsafe.Sizeof(forgets[0]))
+		k.call(opBatchForget, 0, unsafe.Pointer(&in), unsafe.Sizeof(in), payload, nil, 0, nil)
+	}
+}
+
+// addEntryLocked processes an entry reply for name in parent: it
+// increments the lookup count, caches the attributes and installs
+// the dentry. It returns the inodes that must be forgotten.
+func (k *Kernel) addEntryLocked(parent uint64, name string, out *fuse.EntryOut, forgets []forgetOne) []forgetOne {
+	key := dentryKey{parent, name}
+	now := k.opts.Now()
+	if out.NodeId == 0 {
+		forgets = k.dropDentryLocked(key, forgets)
+		if out.EntryTimeout() > 0 {
+			k.installDentryLocked(key, &dentry{expiry: now.Add(out.EntryTimeout())})
+		}
+		return forgets
+	}
+
+	ino := k.inodes[out.NodeId]
+	if ino == nil {
+		ino = &inode{nodeID: out.NodeId}
+		k.inodes[out.NodeId] = ino
+	}
+	ino.nlookup++
+	ino.attr = out.Attr
+	ino.attrExpiry = now.Add(out.AttrTimeout())
+
+	if old := k.dentries[key]; old != nil && old.nodeID == out.NodeId {
+		old.expiry = now.Add(out.EntryTimeout())
+		return forgets
+	}
+	forgets = k.dropDentryLocked(key, forgets)
+	k.installDentryLocked(key, &dentry{
+		nodeID: out.NodeId,
+		expiry: now.Add(out.EntryTimeout()),
+	})
+	return forgets
+}
+
+func (k *Kernel) installDentryLocked(key dentryKey, d *dentry) {
+	k.dentries[key] = d
+	if p := k.inodes[key.parent]; p != nil {
+		p.children++
+	}
+	if d.nodeID != 0 {
+		k.inodes[d.nodeID].dentries++
+	}
+}
+
+// dropDentryLocked removes a cached name, and evicts inodes that
+// become unused.
+func (k *Kernel) dropDentryLocked(key dentryKey, forgets []forgetOne) []forgetOne {
+	d := k.dentries[key]
+	if d == nil {
+		return forgets
+	}
+	delete(k.dentries, key)
+	if d.nodeID != 0 {
+		ino := k.inodes[d.nodeID]
+		ino.dentries--
+		forgets = k.maybeEvictLocked(ino, forgets)
+	}
+	if p := k.inodes[key.parent]; p != nil {
+		p.children--
+		forgets = k.maybeEvictLocked(p, forgets)
+	}
+	return forgets
+}
+
+func (k *Kernel) maybeEvictLocked(ino *inode, forgets []forgetOne) []forgetOne {
+	if ino.nodeID == fuse.FUSE_ROOT_ID || ino.dentries > 0 || ino.children > 0 || ino.opens > 0 {
+		return forgets
+	}
+	delete(k.inodes, ino.nodeID)
+	if ino.nlookup == 0 {
+		return forgets
+	}
+	return append(forgets, forgetOne{ino.nodeID, ino.nlookup})
+}
+
+// invalidateAttr marks the cached attributes as stale.
+func (k *Kernel) invalidateAttr(nodeIDs ...uint64) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, id := range nodeIDs {
+		if ino := k.inodes[id]; ino != nil {
+			ino.attrExpiry = time.Time{}
+		}
+	}
+}
+
+// The following implement the fs.ServerCallbacks interface, so the
+// kernel can receive notifications from the file system.
+
+// EntryNotify invalidates the cached entry for name in parent.
+func (k *Kernel) EntryNotify(parent uint64, name string) fuse.Status {
+	k.mu.Lock()
+	if k.inodes[parent] == nil {
+		k.mu.Unlock()
+		return fuse.ENOENT
+	}
+	forgets := k.dropDentryLocked(dentryKey{parent, name}, nil)
+	k.mu.Unlock()
+	k.sendForgets(forgets)
+	return fuse.OK
+}
+
+// DeleteNotify invalidates the entry for name in parent, if it
+// points to child.
+func (k *Kernel) DeleteNotify(parent uint64, child uint64, name string) fuse.Status {
+	k.mu.Lock()
+	key := dentryKey{parent, name}
+	d := k.dentries[key]
+	if k.inodes[parent] == nil || d == nil {
+		k.mu.Unlock()
+		return fuse.ENOENT
+	}
+	if d.nodeID != child {
+		k.mu.Unlock()
+		return fuse.OK
+	}
+	forgets := k.dropDentryLocked(key, nil)
+	k.mu.Unlock()
+	k.sendForgets(forgets)
+	return fuse.OK
+}
+
+// InodeNotify invalidates the cached attributes of the node. There is
+// no page cache, so the range is ignored.
+func (k *Kernel) InodeNotify(node uint64, off int64, length int64) fuse.Status {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	ino := k.inodes[node]
+	if ino == nil {
+		return fuse.ENOENT
+	}
+	ino.attrExpiry = time.Time{}
+	return fuse.OK
+}
+
+// InodeRetrieveCache returns no data, because there is no page cache.
+func (k *Kernel) InodeRetrieveCache(node uint64, offset int64, dest []byte) (n int, st fuse.Status) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.inodes[node] == nil {
+		return 0, fuse.ENOENT
+	}
+	return 0, fuse.OK
+}
+
+// InodeNotifyStoreCache discards the data, because there is no page
+// cache.
+func (k *Kernel) InodeNotifyStoreCache(node uint64, offset int64, data []byte) fuse.Status {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.inodes[node] == nil {
+		return fuse.ENOENT
+	}
+	return fuse.OK
+}
@@ -0,0 +1,369 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fusetest
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func newLoopbackKernel(t *testing.T, opts *Options) (*Kernel, string) {
+	dir := t.TempDir()
+	root, err := fs.NewLoopbackRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ttl := time.Hour
+	k := NewKernel(opts)
+	if err := k.Init(fs.NewNodeFS(root, &fs.Options{
+		EntryTimeout:    &ttl,
+		AttrTimeout:     &ttl,
+		ServerCallbacks: k,
+	})); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(k.Unmount)
+	return k, dir
+}
+
+func TestLoopbackFileOps(t *testing.T) {
+	k, _ := newLoopbackKernel(t, nil)
+
+	if errno := k.Mkdir("dir", 0755); errno != 0 {
+		t.Fatalf("Mkdir: %v", errno)
+	}
+	if errno := k.Mkdir("dir", 0755); errno != syscall.EEXIST {
+		t.Fatalf("Mkdir existing: got %v, want EEXIST", errno)
+	}
+
+	f, errno := k.Open("dir/file", syscall.O_CREAT|syscall.O_RDWR, 0644)
+	if errno != 0 {
+		t.Fatalf("Open: %v", errno)
+	}
+	want := bytes.Repeat([]byte("hello"), 100000)
+	if n, errno := f.Write(want, 0); errno != 0 || n != len(want) {
+		t.Fatalf("Write: %d, %v", n, errno)
+	}
+	got := make([]byte, len(want)+10)
+	n, errno := f.Read(got, 0)
+	if errno != 0 {
+		t.Fatalf("Read: %v", errno)
+	}
+	if !bytes.Equal(got[:n], want) {
+		t.Fatalf("Read: got %d bytes, want %d", n, len(want))
+	}
+	var attr fuse.Attr
+	if errno := f.Stat(&attr); errno != 0 || attr.Size != uint64(len(want)) {
+		t.Fatalf("Stat: %v, size %d", errno, attr.Size)
+	}
+	if errno := f.Close(); errno != 0 {
+		t.Fatalf("Close: %v", errno)
+	}
+
+	if _, errno := k.Open("dir/file", syscall.O_CREAT|syscall.O_EXCL|syscall.O_WRONLY, 0644); errno != syscall.EEXIST {
+		t.Fatalf("Open O_EXCL: got %v, want EEXIST", errno)
+	}
+	f, errno = k.Open("dir/file", syscall.O_TRUNC|syscall.O_WRONLY, 0)
+	if errno != 0 {
+		t.Fatalf("Open O_TRUNC: %v", errno)
+	}
+	f.Close()
+	if errno := k.Stat("dir/file", &attr); errno != 0 || attr.Size != 0 {
+		t.Fatalf("Stat after O_TRUNC: %v, size %d", errno, attr.Size)
+	}
+
+	if errno := k.Symlink("dir/file", "link"); errno != 0 {
+		t.Fatalf("Symlink: %v", errno)
+	}
+	if target, errno := k.Readlink("link"); errno != 0 || target != "dir/file" {
+		t.Fatalf("Readlink: %q, %v", target, errno)
+	}
+	if errno := k.Lstat("link", &attr); errno != 0 || !attr.IsSymlink() {
+		t.Fatalf("Lstat: %v, %v", errno, &attr)
+	}
+	if errno := k.Stat("link", &attr); errno != 0 || !attr.IsRegular() {
+		t.Fatalf("Stat: %v, %v", errno, &attr)
+	}
+
+	if errno := k.Link("dir/file", "hardlink"); errno != 0 {
+		t.Fatalf("Link: %v", errno)
+	}
+	if errno := k.Stat("dir/file", &attr); errno != 0 || attr.Nlink != 2 {
+		t.Fatalf("Stat after Link: %v, nlink %d", errno, attr.Nlink)
+	}
+
+	if errno := k.Rename("hardlink", "dir/renamed", 0); errno != 0 {
+		t.Fatalf("Rename: %v", errno)
+	}
+	if errno := k.Lstat("hardlink", &attr); errno != syscall.ENOENT {
+		t.Fatalf("Lstat old name: got %v, want ENOENT", errno)
+	}
+
+	es, errno := k.Readdir("dir")
+	if errno != 0 {
+		t.Fatalf("Readdir: %v", errno)
+	}
+	var names []string
+	for _, e := range es {
+		names = append(names, e.Name)
+	}
+	sort.Strings(names)
+	if got, want := names, []string{".", "..", "file", "renamed"}; !equalStrings(got, want) {
+		t.Fatalf("Readdir: got %v, want %v", got, want)
+	}
+
+	if errno := k.Rmdir("dir"); errno != syscall.ENOTEMPTY {
+		t.Fatalf("Rmdir non-empty: got %v, want ENOTEMPTY", errno)
+	}
+	for _, n := range []string{"dir/file", "dir/renamed", "link"} {
+		if errno := k.Unlink(n); errno != 0 {
+			t.Fatalf("Unlink(%q): %v", n, errno)
+		}
+	}
+	if errno := k.Unlink("dir"); errno != syscall.EISDIR {
+		t.Fatalf("Unlink dir: got %v, want EISDIR", errno)
+	}
+	if errno := k.Rmdir("dir"); errno != 0 {
+		t.Fatalf("Rmdir: %v", errno)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSymlinkLoop(t *testing.T) {
+	k, _ := newLoopbackKernel(t, nil)
+	if errno := k.Symlink("b", "a"); errno != 0 {
+		t.Fatal(errno)
+	}
+	if errno := k.Symlink("/a", "b"); errno != 0 {
+		t.Fatal(errno)
+	}
+	var attr fuse.Attr
+	if errno := k.Stat("a", &attr); errno != syscall.ELOOP {
+		t.Fatalf("got %v, want ELOOP", errno)
+	}
+}
+
+// dynamicNode creates children on lookup, and counts forgotten
+// nodes.
+type dynamicNode struct {
+	fs.Inode
+
+	mu      *sync.Mutex
+	forgets *int
+}
+
+var _ = (fs.NodeLookuper)((*dynamicNode)(nil))
+var _ = (fs.NodeOnForgetter)((*dynamicNode)(nil))
+
+func (n *dynamicNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if ch := n.GetChild(name); ch != nil {
+		return ch, 0
+	}
+	ch := &dynamicNode{mu: n.mu, forgets: n.forgets}
+	return n.NewInode(ctx, ch, fs.StableAttr{Mode: fuse.S_IFDIR}), 0
+}
+
+func (n *dynamicNode) OnForget() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	*n.forgets++
+}
+
+func (n *dynamicNode) forgetCount() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return *n.forgets
+}
+
+func newDynamicKernel(t *testing.T, opts *Options, fsOpts *fs.Options) (*Kernel, *dynamicNode) {
+	root := &dynamicNode{mu: &sync.Mutex{}, forgets: new(int)}
+	k := NewKernel(opts)
+	fsOpts.ServerCallbacks = k
+	if err := k.Init(fs.NewNodeFS(root, fsOpts)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(k.Unmount)
+	return k, root
+}
+
+func TestForgetAfterDropCaches(t *testing.T) {
+	zero := time.Duration(0)
+	k, root := newDynamicKernel(t, nil, &fs.Options{EntryTimeout: &zero, AttrTimeout: &zero})
+
+	var attr fuse.Attr
+	for i := 0; i < 3; i++ {
+		if errno := k.Lstat("a/b", &attr); errno != 0 {
+			t.Fatalf("Lstat: %v", errno)
+		}
+	}
+	// Without caching, every lookup of the path, including the
+	// one by NodeID, revalidates both entries.
+	id, errno := k.NodeID("a/b")
+	if errno != 0 {
+		t.Fatal(errno)
+	}
+	if got := k.LookupCount(id); got != 4 {
+		t.Errorf("LookupCount: got %d, want 4", got)
+	}
+	if got := len(k.NodeIDs()); got != 3 {
+		t.Errorf("NodeIDs: got %d, want 3", got)
+	}
+
+	k.DropCaches()
+	if got := len(k.NodeIDs()); got != 1 {
+		t.Errorf("NodeIDs after DropCaches: got %d, want 1", got)
+	}
+	if got := k.Counts()["BATCH_FORGET"]; got != 1 {
+		t.Errorf("BATCH_FORGET: got %d, want 1", got)
+	}
+	if got := root.forgetCount(); got != 2 {
+		t.Errorf("forgotten nodes: got %d, want 2", got)
+	}
+	if ch := root.GetChild("a"); ch != nil {
+		t.Errorf("child still present after FORGET")
+	}
+}
+
+func TestForgetOpenFile(t *testing.T) {
+	k, _ := newLoopbackKernel(t, nil)
+	f, errno := k.Open("file", syscall.O_CREAT|syscall.O_RDWR, 0644)
+	if errno != 0 {
+		t.Fatal(errno)
+	}
+	id := f.NodeID()
+	k.DropCaches()
+	if k.LookupCount(id) != 1 {
+		t.Fatalf("open file was forgotten")
+	}
+	if errno := k.Unlink("file"); errno != 0 {
+		t.Fatal(errno)
+	}
+	var attr fuse.Attr
+	if errno := f.Stat(&attr); errno != 0 || attr.Nlink != 0 {
+		t.Fatalf("Stat of unlinked file: %v, nlink %d", errno, attr.Nlink)
+	}
+	f.Close()
+	if k.LookupCount(id) != 0 {
+		t.Fatalf("closed file was not forgotten")
+	}
+}
+
+func TestCacheTimeouts(t *testing.T) {
+	now := time.Unix(1e9, 0)
+	entryTTL := 10 * time.Second
+	attrTTL := time.Second
+	k, _ := newDynamicKernel(t, &Options{Now: func() time.Time { return now }},
+		&fs.Options{EntryTimeout: &entryTTL, AttrTimeout: &attrTTL})
+
+	var attr fuse.Attr
+	for i := 0; i < 3; i++ {
+		if errno := k.Lstat("a", &attr); errno != 0 {
+			t.Fatal(errno)
+		}
+	}
+	counts := k.Counts()
+	if counts["LOOKUP"] != 1 || counts["GETATTR"] != 0 {
+		t.Fatalf("cached: got %v", counts)
+	}
+
+	now = now.Add(2 * time.Second)
+	k.ResetCounts()
+	if errno := k.Lstat("a", &attr); errno != 0 {
+		t.Fatal(errno)
+	}
+	counts = k.Counts()
+	if counts["LOOKUP"] != 0 || counts["GETATTR"] != 1 {
+		t.Fatalf("attr expired: got %v", counts)
+	}
+
+	now = now.Add(20 * time.Second)
+	k.ResetCounts()
+	if errno := k.Lstat("a", &attr); errno != 0 {
+		t.Fatal(errno)
+	}
+	if counts := k.Counts(); counts["LOOKUP"] != 1 {
+		t.Fatalf("entry expired: got %v", counts)
+	}
+	id, errno := k.NodeID("a")
+	if errno != 0 {
+		t.Fatal(errno)
+	}
+	if got := k.LookupCount(id); got != 2 {
+		t.Fatalf("LookupCount: got %d, want 2", got)
+	}
+}
+
+func TestReadDirPlusFillsCache(t *testing.T) {
+	for _, plus := range []bool{false, true} {
+		t.Run(map[bool]string{false: "readdir", true: "readdirplus"}[plus], func(t *testing.T) {
+			k, _ := newLoopbackKernel(t, &Options{DisableReadDirPlus: !plus})
+			for _, n := range []string{"a", "b", "c"} {
+				if errno := k.Mkdir(n, 0755); errno != 0 {
+					t.Fatal(errno)
+				}
+			}
+			k.DropCaches()
+
+			if _, errno := k.Readdir(""); errno != 0 {
+				t.Fatal(errno)
+			}
+			k.ResetCounts()
+			var attr fuse.Attr
+			if errno := k.Lstat("b", &attr); errno != 0 {
+				t.Fatal(errno)
+			}
+			want := 1
+			if plus {
+				want = 0
+			}
+			if got := k.Counts()["LOOKUP"]; got != want {
+				t.Errorf("LOOKUP: got %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestNotifyEntry(t *testing.T) {
+	ttl := time.Hour
+	k, root := newDynamicKernel(t, nil, &fs.Options{EntryTimeout: &ttl, AttrTimeout: &ttl})
+
+	var attr fuse.Attr
+	if errno := k.Lstat("a", &attr); errno != 0 {
+		t.Fatal(errno)
+	}
+	if errno := root.NotifyEntry("a"); errno != 0 {
+		t.Fatalf("NotifyEntry: %v", errno)
+	}
+	if got := root.forgetCount(); got != 1 {
+		t.Fatalf("forgotten nodes: got %d, want 1", got)
+	}
+
+	k.ResetCounts()
+	if errno := k.Lstat("a", &attr); errno != 0 {
+		t.Fatal(errno)
+	}
+	if got := k.Counts()["LOOKUP"]; got != 1 {
+		t.Fatalf("LOOKUP after NotifyEntry: got %d, want 1", got)
+	}
+}
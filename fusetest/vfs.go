@@ -0,0 +1,563 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fusetest
+
+import (
+	"path"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// maxSymlinks is the maximum number of symlinks followed while
+// resolving a path, like the kernel's MAXSYMLINKS.
+const maxSymlinks = 40
+
+// Paths passed to the Kernel methods are relative to the root of the
+// file system. They are cleaned lexically, so ".." never leaves the
+// root. Symlinks with absolute targets are resolved relative to the
+// root of the file system.
+
+func splitPath(name string) []string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
+
+// lookup returns the node ID and mode for name in parent, consulting
+// the dentry cache first.
+func (k *Kernel) lookup(parent uint64, name string) (uint64, uint32, syscall.Errno) {
+	if len(name) > 255 {
+		return 0, 0, syscall.ENAMETOOLONG
+	}
+	k.mu.Lock()
+	if d := k.dentries[dentryKey{parent, name}]; d != nil && k.opts.Now().Before(d.expiry) {
+		defer k.mu.Unlock()
+		if d.nodeID == 0 {
+			return 0, 0, syscall.ENOENT
+		}
+		return d.nodeID, k.inodes[d.nodeID].attr.Mode, 0
+	}
+	k.mu.Unlock()
+
+	in := fuse.InHeader{}
+	out := fuse.EntryOut{}
+	errno := k.callName(opLookup, parent, unsafe.Pointer(&in), unsafe.Sizeof(in), name, unsafe.Pointer(&out), unsafe.Sizeof(out))
+	if errno == syscall.ENOENT {
+		k.dropDentry(parent, name)
+		return 0, 0, errno
+	}
+	if errno != 0 {
+		return 0, 0, errno
+	}
+	k.addEntry(parent, name, &out)
+	if out.NodeId == 0 {
+		return 0, 0, syscall.ENOENT
+	}
+	return out.NodeId, out.Attr.Mode, 0
+}
+
+func (k *Kernel) addEntry(parent uint64, name string, out *fuse.EntryOut) {
+	k.mu.Lock()
+	forgets := k.addEntryLocked(parent, name, out, nil)
+	k.mu.Unlock()
+	k.sendForgets(forgets)
+}
+
+func (k *Kernel) dropDentry(parent uint64, name string) {
+	k.mu.Lock()
+	forgets := k.dropDentryLocked(dentryKey{parent, name}, nil)
+	k.mu.Unlock()
+	k.sendForgets(forgets)
+}
+
+// resolve walks the path from the root, and returns the node ID and
+// mode of the last component. If follow is set, a trailing symlink is
+// followed.
+func (k *Kernel) resolve(name string, follow bool) (uint64, uint32, syscall.Errno) {
+	links := 0
+restart:
+	comps := splitPath(name)
+	cur := uint64(fuse.FUSE_ROOT_ID)
+	mode := uint32(syscall.S_IFDIR)
+	for i, c := range comps {
+		if mode&syscall.S_IFMT != syscall.S_IFDIR {
+			return 0, 0, syscall.ENOTDIR
+		}
+		id, m, errno := k.lookup(cur, c)
+		if errno != 0 {
+			return 0, 0, errno
+		}
+		last := i == len(comps)-1
+		if m&syscall.S_IFMT == syscall.S_IFLNK && (follow || !last) {
+			links++
+			if links > maxSymlinks {
+				return 0, 0, syscall.ELOOP
+			}
+			target, errno := k.readlink(id)
+			if errno != 0 {
+				return 0, 0, errno
+			}
+			prefix := strings.Join(comps[:i], "/")
+			if strings.HasPrefix(target, "/") {
+				prefix = ""
+			}
+			name = path.Join(prefix, target, strings.Join(comps[i+1:], "/"))
+			goto restart
+		}
+		cur, mode = id, m
+	}
+	return cur, mode, 0
+}
+
+// resolveParent resolves the directory containing name, and returns
+// its node ID along with the last path component.
+func (k *Kernel) resolveParent(name string) (uint64, string, syscall.Errno) {
+	comps := splitPath(name)
+	if len(comps) == 0 {
+		return 0, "", syscall.EBUSY
+	}
+	base := comps[len(comps)-1]
+	parent, mode, errno := k.resolve(strings.Join(comps[:len(comps)-1], "/"), true)
+	if errno != 0 {
+		return 0, "", errno
+	}
+	if mode&syscall.S_IFMT != syscall.S_IFDIR {
+		return 0, "", syscall.ENOTDIR
+	}
+	return parent, base, 0
+}
+
+// getattr returns the attributes of the node, from the cache if
+// they have not expired. If fh is non-zero, it is passed along with
+// the GETATTR request.
+func (k *Kernel) getattr(nodeID uint64, fh uint64, out *fuse.Attr) syscall.Errno {
+	k.mu.Lock()
+	if ino := k.inodes[nodeID]; ino != nil && k.opts.Now().Before(ino.attrExpiry) {
+		*out = ino.attr
+		k.mu.Unlock()
+		return 0
+	}
+	k.mu.Unlock()
+
+	in := fuse.GetAttrIn{}
+	if fh != 0 {
+		in.Flags_ = fuse.FUSE_GETATTR_FH
+		in.Fh_ = fh
+	}
+	attrOut := fuse.AttrOut{}
+	if _, errno := k.call(opGetattr, nodeID, unsafe.Pointer(&in), unsafe.Sizeof(in), nil, unsafe.Pointer(&attrOut), unsafe.Sizeof(attrOut), nil); errno != 0 {
+		return errno
+	}
+	k.setAttr(nodeID, &attrOut)
+	*out = attrOut.Attr
+	return 0
+}
+
+// setAttr caches attributes returned by GETATTR or SETATTR.
+func (k *Kernel) setAttr(nodeID uint64, out *fuse.AttrOut) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if ino := k.inodes[nodeID]; ino != nil {
+		ino.attr = out.Attr
+		ino.attrExpiry = k.opts.Now().Add(out.Timeout())
+	}
+}
+
+func (k *Kernel) setattr(nodeID uint64, in *fuse.SetAttrIn, out *fuse.Attr) syscall.Errno {
+	attrOut := fuse.AttrOut{}
+	if _, errno := k.call(opSetattr, nodeID, unsafe.Pointer(in), unsafe.Sizeof(*in), nil, unsafe.Pointer(&attrOut), unsafe.Sizeof(attrOut), nil); errno != 0 {
+		return errno
+	}
+	k.setAttr(nodeID, &attrOut)
+	if out != nil {
+		*out = attrOut.Attr
+	}
+	return 0
+}
+
+func (k *Kernel) readlink(nodeID uint64) (string, syscall.Errno) {
+	in := fuse.InHeader{}
+	buf := make([]byte, pageSize)
+	n, errno := k.call(opReadlink, nodeID, unsafe.Pointer(&in), unsafe.Sizeof(in), nil, nil, 0, buf)
+	if errno != 0 {
+		return "", errno
+	}
+	return string(buf[:n]), 0
+}
+
+// Lstat returns the attributes of name, without following a trailing
+// symlink.
+func (k *Kernel) Lstat(name string, out *fuse.Attr) syscall.Errno {
+	id, _, errno := k.resolve(name, false)
+	if errno != 0 {
+		return errno
+	}
+	return k.getattr(id, 0, out)
+}
+
+// Stat returns the attributes of name.
+func (k *Kernel) Stat(name string, out *fuse.Attr) syscall.Errno {
+	id, _, errno := k.resolve(name, true)
+	if errno != 0 {
+		return errno
+	}
+	return k.getattr(id, 0, out)
+}
+
+// Readlink returns the target of the symlink name.
+func (k *Kernel) Readlink(name string) (string, syscall.Errno) {
+	id, mode, errno := k.resolve(name, false)
+	if errno != 0 {
+		return "", errno
+	}
+	if mode&syscall.S_IFMT != syscall.S_IFLNK {
+		return "", syscall.EINVAL
+	}
+	return k.readlink(id)
+}
+
+// Access checks permissions for name with ACCESS. The mask is a
+// combination of fuse.R_OK, fuse.W_OK and fuse.X_OK.
+func (k *Kernel) Access(name string, mask uint32) syscall.Errno {
+	id, _, errno := k.resolve(name, true)
+	if errno != 0 {
+		return errno
+	}
+	in := fuse.AccessIn{Mask: mask}
+	_, errno = k.call(opAccess, id, unsafe.Pointer(&in), unsafe.Sizeof(in), nil, nil, 0, nil)
+	return errno
+}
+
+// Statfs returns file system statistics for the file system
+// containing name.
+func (k *Kernel) Statfs(name string, out *fuse.StatfsOut) syscall.Errno {
+	id, _, errno := k.resolve(name, true)
+	if errno != 0 {
+		return errno
+	}
+	in := fuse.InHeader{}
+	_, errno = k.call(opStatfs, id, unsafe.Pointer(&in), unsafe.Sizeof(in), nil, unsafe.Pointer(out), unsafe.Sizeof(*out), nil)
+	return errno
+}
+
+// Setattr changes the attributes of name, following symlinks. The
+// fields to change are selected by in.Valid. The new attributes are
+// returned in out, if it is non-nil.
+func (k *Kernel) Setattr(name string, in *fuse.SetAttrIn, out *fuse.Attr) syscall.Errno {
+	id, _, errno := k.resolve(name, true)
+	if errno != 0 {
+		return errno
+	}
+	return k.setattr(id, in, out)
+}
+
+// Truncate sets the size of name.
+func (k *Kernel) Truncate(name string, size uint64) syscall.Errno {
+	in := fuse.SetAttrIn{}
+	in.Valid = fuse.FATTR_SIZE
+	in.Size = size
+	return k.Setattr(name, &in, nil)
+}
+
+// Chmod sets the permission bits of name.
+func (k *Kernel) Chmod(name string, mode uint32) syscall.Errno {
+	in := fuse.SetAttrIn{}
+	in.Valid = fuse.FATTR_MODE
+	in.Mode = mode & 07777
+	return k.Setattr(name, &in, nil)
+}
+
+// Chown sets the owner of name.
+func (k *Kernel) Chown(name string, uid, gid uint32) syscall.Errno {
+	in := fuse.SetAttrIn{}
+	in.Valid = fuse.FATTR_UID | fuse.FATTR_GID
+	in.Owner = fuse.Owner{Uid: uid, Gid: gid}
+	return k.Setattr(name, &in, nil)
+}
+
+// newEntry checks that name does not exist yet, and returns the
+// directory to create it in.
+func (k *Kernel) newEntry(name string) (uint64, string, syscall.Errno) {
+	parent, base, errno := k.resolveParent(name)
+	if errno == syscall.EBUSY {
+		return 0, "", syscall.EEXIST
+	}
+	if errno != 0 {
+		return 0, "", errno
+	}
+	_, _, errno = k.lookup(parent, base)
+	if errno == 0 {
+		return 0, "", syscall.EEXIST
+	}
+	if errno != syscall.ENOENT {
+		return 0, "", errno
+	}
+	return parent, base, 0
+}
+
+// entryCall sends a request that creates name in parent, and adds the
+// resulting entry to the cache.
+func (k *Kernel) entryCall(op uint32, parent uint64, in unsafe.Pointer, inSize uintptr, payload []byte, name string) syscall.Errno {
+	out := fuse.EntryOut{}
+	if _, errno := k.call(op, parent, in, inSize, payload, unsafe.Pointer(&out), unsafe.Sizeof(out), nil); errno != 0 {
+		return errno
+	}
+	if out.NodeId == 0 {
+		return syscall.EIO
+	}
+	k.addEntry(parent, name, &out)
+	k.invalidateAttr(parent)
+	return 0
+}
+
+// Mkdir creates a directory.
+func (k *Kernel) Mkdir(name string, mode uint32) syscall.Errno {
+	parent, base, errno := k.newEntry(name)
+	if errno != 0 {
+		return errno
+	}
+	in := fuse.MkdirIn{
+		Mode:  mode & 07777 &^ k.opts.Umask,
+		Umask: k.opts.Umask,
+	}
+	return k.entryCall(opMkdir, parent, unsafe.Pointer(&in), unsafe.Sizeof(in), cString(base), base)
+}
+
+// Mknod creates a special or regular file. The mode includes the
+// file type.
+func (k *Kernel) Mknod(name string, mode uint32, dev uint32) syscall.Errno {
+	parent, base, errno := k.newEntry(name)
+	if errno != 0 {
+		return errno
+	}
+	if mode&syscall.S_IFMT == 0 {
+		mode |= syscall.S_IFREG
+	}
+	in := fuse.MknodIn{
+		Mode:  mode &^ k.opts.Umask,
+		Rdev:  dev,
+		Umask: k.opts.Umask,
+	}
+	return k.entryCall(opMknod, parent, unsafe.Pointer(&in), unsafe.Sizeof(in), cString(base), base)
+}
+
+// Symlink creates a symlink name pointing to target.
+func (k *Kernel) Symlink(target, name string) syscall.Errno {
+	parent, base, errno := k.newEntry(name)
+	if errno != 0 {
+		return errno
+	}
+	in := fuse.InHeader{}
+	payload := append(cString(base), cString(target)...)
+	return k.entryCall(opSymlink, parent, unsafe.Pointer(&in), unsafe.Sizeof(in), payload, base)
+}
+
+// Link creates a hard link newName for oldName.
+func (k *Kernel) Link(oldName, newName string) syscall.Errno {
+	id, mode, errno := k.resolve(oldName, false)
+	if errno != 0 {
+		return errno
+	}
+	if mode&syscall.S_IFMT == syscall.S_IFDIR {
+		return syscall.EPERM
+	}
+	parent, base, errno := k.newEntry(newName)
+	if errno != 0 {
+		return errno
+	}
+	in := fuse.LinkIn{Oldnodeid: id}
+	if errno := k.entryCall(opLink, parent, unsafe.Pointer(&in), unsafe.Sizeof(in), cString(base), base); errno != 0 {
+		return errno
+	}
+	k.invalidateAttr(id)
+	return 0
+}
+
+// remove sends UNLINK or RMDIR for name, after checking its type.
+func (k *Kernel) remove(op uint32, name string) syscall.Errno {
+	parent, base, errno := k.resolveParent(name)
+	if errno != 0 {
+		return errno
+	}
+	id, mode, errno := k.lookup(parent, base)
+	if errno != 0 {
+		return errno
+	}
+	isDir := mode&syscall.S_IFMT == syscall.S_IFDIR
+	if op == opUnlink && isDir {
+		return syscall.EISDIR
+	}
+	if op == opRmdir && !isDir {
+		return syscall.ENOTDIR
+	}
+	in := fuse.InHeader{}
+	if errno := k.callName(op, parent, unsafe.Pointer(&in), unsafe.Sizeof(in), base, nil, 0); errno != 0 {
+		return errno
+	}
+	k.invalidateAttr(id, parent)
+	k.dropDentry(parent, base)
+	return 0
+}
+
+// Unlink removes a non-directory.
+func (k *Kernel) Unlink(name string) syscall.Errno {
+	return k.remove(opUnlink, name)
+}
+
+// Rmdir removes an empty directory.
+func (k *Kernel) Rmdir(name string) syscall.Errno {
+	return k.remove(opRmdir, name)
+}
+
+// Rename renames oldName to newName. The flags are those of
+// renameat2, eg. RENAME_NOREPLACE or RENAME_EXCHANGE from
+// golang.org/x/sys/unix.
+func (k *Kernel) Rename(oldName, newName string, flags uint32) syscall.Errno {
+	const (
+		renameNoReplace = 1 << 0
+		renameExchange  = 1 << 1
+	)
+	oldParent, oldBase, errno := k.resolveParent(oldName)
+	if errno != 0 {
+		return errno
+	}
+	newParent, newBase, errno := k.resolveParent(newName)
+	if errno != 0 {
+		return errno
+	}
+	if _, _, errno := k.lookup(oldParent, oldBase); errno != 0 {
+		return errno
+	}
+	_, _, errno = k.lookup(newParent, newBase)
+	if errno != 0 && errno != syscall.ENOENT {
+		return errno
+	}
+	if flags&renameNoReplace != 0 && errno == 0 {
+		return syscall.EEXIST
+	}
+	if flags&renameExchange != 0 && errno != 0 {
+		return errno
+	}
+
+	payload := append(cString(oldBase), cString(newBase)...)
+	if flags == 0 {
+		in := fuse.Rename1In{Newdir: newParent}
+		_, errno = k.call(opRename, oldParent, unsafe.Pointer(&in), unsafe.Sizeof(in), payload, nil, 0, nil)
+	} else {
+		in := fuse.RenameIn{Newdir: newParent, Flags: flags}
+		_, errno = k.call(opRename2, oldParent, unsafe.Pointer(&in), unsafe.Sizeof(in), payload, nil, 0, nil)
+	}
+	if errno != 0 {
+		return errno
+	}
+
+	oldKey := dentryKey{oldParent, oldBase}
+	newKey := dentryKey{newParent, newBase}
+	k.mu.Lock()
+	var forgets []forgetOne
+	oldD, newD := k.dentries[oldKey], k.dentries[newKey]
+	switch {
+	case flags&renameExchange != 0 && oldD != nil && newD != nil:
+		oldD.nodeID, newD.nodeID = newD.nodeID, oldD.nodeID
+	case flags&renameExchange != 0:
+		forgets = k.dropDentryLocked(oldKey, forgets)
+		forgets = k.dropDentryLocked(newKey, forgets)
+	case oldD != nil && oldKey != newKey:
+		// Install the new name before dropping the old one, so
+		// the inode is not evicted in between.
+		moved := *oldD
+		forgets = k.dropDentryLocked(newKey, forgets)
+		if moved.nodeID != 0 {
+			k.installDentryLocked(newKey, &moved)
+		}
+		forgets = k.dropDentryLocked(oldKey, forgets)
+	}
+	k.mu.Unlock()
+	k.sendForgets(forgets)
+	k.invalidateAttr(oldParent, newParent)
+	return 0
+}
+
+// Readdir returns all entries of the directory name, including "."
+// and "..".
+func (k *Kernel) Readdir(name string) ([]fuse.DirEntry, syscall.Errno) {
+	f, errno := k.Open(name, syscall.O_RDONLY|syscall.O_DIRECTORY, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	defer f.Close()
+
+	var r []fuse.DirEntry
+	for {
+		es, errno := f.ReadDir()
+		if errno != 0 {
+			return nil, errno
+		}
+		if len(es) == 0 {
+			return r, 0
+		}
+		r = append(r, es...)
+	}
+}
+
+// Getxattr reads the extended attribute attr of name into dest. If
+// dest is empty, it returns the size of the attribute.
+func (k *Kernel) Getxattr(name string, attr string, dest []byte) (int, syscall.Errno) {
+	id, _, errno := k.resolve(name, true)
+	if errno != 0 {
+		return 0, errno
+	}
+	return k.getxattr(opGetxattr, id, cString(attr), dest)
+}
+
+// Listxattr reads the NUL-separated list of extended attribute names
+// of name into dest. If dest is empty, it returns the size of the list.
+func (k *Kernel) Listxattr(name string, dest []byte) (int, syscall.Errno) {
+	id, _, errno := k.resolve(name, true)
+	if errno != 0 {
+		return 0, errno
+	}
+	return k.getxattr(opListxattr, id, nil, dest)
+}
+
+func (k *Kernel) getxattr(op uint32, nodeID uint64, payload []byte, dest []byte) (int, syscall.Errno) {
+	in := fuse.GetXAttrIn{Size: uint32(len(dest))}
+	if len(dest) == 0 {
+		out := fuse.GetXAttrOut{}
+		if _, errno := k.call(op, nodeID, unsafe.Pointer(&in), unsafe.Sizeof(in), payload, unsafe.Pointer(&out), unsafe.Sizeof(out), nil); errno != 0 {
+			return 0, errno
+		}
+		return int(out.Size), 0
+	}
+	return k.call(op, nodeID, unsafe.Pointer(&in), unsafe.Sizeof(in), payload, nil, 0, dest)
+}
+
+// Setxattr sets the extended attribute attr of name. The flags are
+// those of setxattr(2).
+func (k *Kernel) Setxattr(name string, attr string, data []byte, flags uint32) syscall.Errno {
+	id, _, errno := k.resolve(name, true)
+	if errno != 0 {
+		return errno
+	}
+	in := fuse.SetXAttrIn{Size: uint32(len(data)), Flags: flags}
+	payload := append(cString(attr), data...)
+	_, errno = k.call(opSetxattr, id, unsafe.Pointer(&in), unsafe.Sizeof(in), payload, nil, 0, nil)
+	return errno
+}
+
+// Removexattr removes the extended attribute attr of name.
+func (k *Kernel) Removexattr(name string, attr string) syscall.Errno {
+	id, _, errno := k.resolve(name, true)
+	if errno != 0 {
+		return errno
+	}
+	in := fuse.InHeader{}
+	return k.callName(opRemovexattr, id, unsafe.Pointer(&in), unsafe.Sizeof(in), attr, nil, 0)
+}
@@ -0,0 +1,110 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unionfs
+
+import (
+	"path/filepath"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"golang.org/x/sys/unix"
+)
+
+// Branch gives read access to a tree of files that is part of a
+// union. Names are slash-separated paths relative to the root of the
+// branch, which itself is "". Symlinks are never followed.
+type Branch interface {
+	// Lstat fills out with the attributes of name.
+	Lstat(name string, out *fuse.Attr) syscall.Errno
+
+	// ReadDir lists the directory name, without "." and "..".
+	ReadDir(name string) ([]fuse.DirEntry, syscall.Errno)
+
+	// Readlink returns the target of the symlink name.
+	Readlink(name string) ([]byte, syscall.Errno)
+
+	// Open opens name with the open(2) flags. The handle must
+	// implement fs.FileReader.
+	Open(name string, flags uint32) (fs.FileHandle, syscall.Errno)
+
+	// Getxattr and Listxattr are as in fs.NodeGetxattrer and
+	// fs.NodeListxattrer.
+	Getxattr(name string, attr string, dest []byte) (uint32, syscall.Errno)
+	Listxattr(name string, dest []byte) (uint32, syscall.Errno)
+}
+
+// NewDirBranch returns a branch for the directory dir.
+func NewDirBranch(dir string) Branch {
+	return dirBranch(dir)
+}
+
+type dirBranch string
+
+var _ = (Branch)(dirBranch(""))
+
+func (b dirBranch) path(name string) string {
+	return filepath.Join(string(b), name)
+}
+
+func (b dirBranch) Lstat(name string, out *fuse.Attr) syscall.Errno {
+	var st syscall.Stat_t
+	if err := syscall.Lstat(b.path(name), &st); err != nil {
+		return err.(syscall.Errno)
+	}
+	out.FromStat(&st)
+	return 0
+}
+
+func (b dirBranch) ReadDir(name string) ([]fuse.DirEntry, syscall.Errno) {
+	ds, errno := fs.NewLoopbackDirStream(b.path(name))
+	if errno != 0 {
+		return nil, errno
+	}
+	defer ds.Close()
+	var result []fuse.DirEntry
+	for ds.HasNext() {
+		e, errno := ds.Next()
+		if errno != 0 {
+			return nil, errno
+		}
+		if e.Name == "." || e.Name == ".." {
+			continue
+		}
+		result = append(result, e)
+	}
+	return result, 0
+}
+
+func (b dirBranch) Readlink(name string) ([]byte, syscall.Errno) {
+	for l := 256; ; l *= 2 {
+		buf := make([]byte, l)
+		sz, err := syscall.Readlink(b.path(name), buf)
+		if err != nil {
+			return nil, err.(syscall.Errno)
+		}
+		if sz < len(buf) {
+			return buf[:sz], 0
+		}
+	}
+}
+
+func (b dirBranch) Open(name string, flags uint32) (fs.FileHandle, syscall.Errno) {
+	fd, err := syscall.Open(b.path(name), int(flags), 0)
+	if err != nil {
+		return nil, err.(syscall.Errno)
+	}
+	return fs.NewLoopbackFile(fd), 0
+}
+
+func (b dirBranch) Getxattr(name string, attr string, dest []byte) (uint32, syscall.Errno) {
+	sz, err := unix.Lgetxattr(b.path(name), attr, dest)
+	return uint32(sz), fs.ToErrno(err)
+}
+
+func (b dirBranch) Listxattr(name string, dest []byte) (uint32, syscall.Errno) {
+	sz, err := unix.Llistxattr(b.path(name), dest)
+	return uint32(sz), fs.ToErrno(err)
+}
@@ -0,0 +1,342 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unionfs
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// ChangeKind says how a file of a union differs from the read-only
+// branches.
+type ChangeKind int
+
+const (
+	// Added files only exist in the writable branch.
+	Added ChangeKind = iota
+	// Modified files of the writable branch replace a file of the
+	// read-only branches.
+	Modified
+	// Deleted files of the read-only branches are hidden.
+	Deleted
+)
+
+// String returns the letter that "docker diff" uses for k.
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "A"
+	case Modified:
+		return "C"
+	case Deleted:
+		return "D"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// Change is a file where the union differs from its read-only
+// branches.
+type Change struct {
+	// Path is relative to the root of the union.
+	Path string
+	Kind ChangeKind
+}
+
+// change is a Change with the details that a layer needs.
+type change struct {
+	Change
+
+	// opaque is set for directories that hide the directory of
+	// the read-only branches.
+	opaque bool
+
+	// implied is set for deletions that follow from an opaque
+	// parent.
+	implied bool
+}
+
+func unionRoot(root fs.InodeEmbedder) (*unionFSRoot, error) {
+	r, ok := root.(*unionFSRoot)
+	if !ok {
+		return nil, fmt.Errorf("%T is not the root of a union", root)
+	}
+	return r, nil
+}
+
+// Changes lists the differences between the union root and its
+// read-only branches, sorted by path. Directories of the writable
+// branch that also exist in a read-only branch are reported as
+// modified.
+func Changes(root fs.InodeEmbedder) ([]Change, error) {
+	r, err := unionRoot(root)
+	if err != nil {
+		return nil, err
+	}
+	var result []Change
+	for _, c := range r.changes() {
+		result = append(result, c.Change)
+	}
+	return result, nil
+}
+
+// WriteLayer writes the changes of the union root to w as an
+// uncompressed OCI image layer. Deleted files are written as .wh.
+// whiteouts, and directories that hide the directory of the
+// read-only branches are followed by a .wh..wh..opq marker. The
+// layer can be applied as a read-only branch with OpenLayer, or
+// stacked onto the layers of the read-only branches with NewLayerFS.
+func WriteLayer(root fs.InodeEmbedder, w io.Writer) error {
+	r, err := unionRoot(root)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	links := map[fileID]string{}
+	for _, c := range r.changes() {
+		if c.Kind == Deleted {
+			if c.implied {
+				continue
+			}
+			dir, base := filepath.Split(c.Path)
+			if err := tw.WriteHeader(&tar.Header{
+				Name:     dir + whiteoutPrefix + base,
+				Typeflag: tar.TypeReg,
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := r.writeTarEntry(tw, c, links); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// lowerExists returns true if p exists in the read-only branches.
+func (r *unionFSRoot) lowerExists(p string) bool {
+	return len(r.resolveFrom(p, 1)) > 0
+}
+
+// changes returns the changes of the union, sorted by path.
+func (r *unionFSRoot) changes() []change {
+	seen := map[string]change{}
+	add := func(c change) {
+		if old, ok := seen[c.Path]; ok && !old.implied {
+			return
+		}
+		seen[c.Path] = c
+	}
+	r.dirChanges("", false, add)
+
+	if r.opts.Whiteouts == DeletionMarkers {
+		r.forEachMeta(delDir, "", func(name, file string, content []byte) {
+			if r.lowerExists(name) && r.getBranch(name, nil) < 0 {
+				add(change{Change: Change{Path: name, Kind: Deleted}})
+			}
+		})
+	}
+
+	result := make([]change, 0, len(seen))
+	for _, c := range seen {
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
+
+// dirChanges reports the changes in the directory dir of the union.
+// If whole is set, the directory replaces its counterpart in the
+// read-only branches, so all of its entries are changes.
+func (r *unionFSRoot) dirChanges(dir string, whole bool, add func(change)) {
+	deleted := func(p string) {
+		if !whole && r.lowerExists(p) {
+			add(change{Change: Change{Path: p, Kind: Deleted}})
+		}
+	}
+
+	upper := map[string]uint32{}
+	readBranch(r.branches[0], dir, upper)
+	names := map[string]bool{}
+	for nm, mode := range upper {
+		p := filepath.Join(dir, nm)
+		if r.opts.Whiteouts == DeletionMarkers {
+			if dir == "" && (nm == delDir || nm == redirDir) {
+				continue
+			}
+		} else if strings.HasPrefix(nm, whiteoutPrefix) {
+			if nm != opaqueWhiteout {
+				deleted(filepath.Join(dir, nm[len(whiteoutPrefix):]))
+			}
+			continue
+		} else if mode == syscall.S_IFCHR {
+			var attr fuse.Attr
+			if r.branches[0].Lstat(p, &attr) == 0 && isWhiteoutDev(&attr) {
+				deleted(p)
+				continue
+			}
+		}
+		names[nm] = true
+	}
+	if whole {
+		for _, e := range r.readDir(dir) {
+			names[e.Name] = true
+		}
+	}
+
+	for nm := range names {
+		p := filepath.Join(dir, nm)
+		var attr fuse.Attr
+		idx := r.getBranch(p, &attr)
+		if idx < 0 {
+			continue
+		}
+		c := change{Change: Change{Path: p, Kind: Added}}
+		if r.lowerExists(p) {
+			c.Kind = Modified
+		}
+		if attr.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+			add(c)
+			continue
+		}
+
+		replace := whole
+		if idx == 0 {
+			_, redirected := r.layerRedirect(0, p, p)
+			replace = replace || redirected || r.isOpaque(0, p)
+		}
+		if replace {
+			if lower := r.resolveFrom(p, 1); len(lower) > 0 && lower[0].attr.Mode&syscall.S_IFMT == syscall.S_IFDIR {
+				c.opaque = true
+			}
+
+			// Entries of the read-only branches that the
+			// union does not show are deleted implicitly.
+			union := map[string]bool{}
+			for _, e := range r.readDir(p) {
+				union[e.Name] = true
+			}
+			for _, e := range r.readDirFrom(p, 1) {
+				if !union[e.Name] {
+					add(change{
+						Change:  Change{Path: filepath.Join(p, e.Name), Kind: Deleted},
+						implied: true,
+					})
+				}
+			}
+		}
+		add(c)
+		r.dirChanges(p, replace, add)
+	}
+}
+
+// fileID identifies a file of a branch, to find hard links.
+type fileID struct {
+	idx int
+	ino uint64
+}
+
+// writeTarEntry writes the file of change c, as it appears in the
+// union, to tw. Files in links are written as hard links.
+func (r *unionFSRoot) writeTarEntry(tw *tar.Writer, c change, links map[fileID]string) error {
+	var attr fuse.Attr
+	idx := r.getBranch(c.Path, &attr)
+	if idx < 0 {
+		return nil
+	}
+	b := r.branches[idx]
+	p := r.branchPath(idx, c.Path)
+
+	hdr := &tar.Header{
+		Name:    c.Path,
+		Mode:    int64(attr.Mode & 07777),
+		Uid:     int(attr.Uid),
+		Gid:     int(attr.Gid),
+		ModTime: time.Unix(int64(attr.Mtime), int64(attr.Mtimensec)),
+	}
+	for _, x := range listXattrs(b, p) {
+		if r.isPrivateXattr(x) {
+			continue
+		}
+		if val, ok := getXattr(b, p, x); ok {
+			if hdr.PAXRecords == nil {
+				hdr.PAXRecords = map[string]string{}
+			}
+			hdr.PAXRecords["SCHILY.xattr."+x] = string(val)
+		}
+	}
+
+	switch attr.Mode & syscall.S_IFMT {
+	case syscall.S_IFDIR:
+		hdr.Typeflag = tar.TypeDir
+		hdr.Name += "/"
+	case syscall.S_IFLNK:
+		target, errno := b.Readlink(p)
+		if errno != 0 {
+			return fmt.Errorf("readlink %s: %w", c.Path, errno)
+		}
+		hdr.Typeflag = tar.TypeSymlink
+		hdr.Linkname = string(target)
+	case syscall.S_IFCHR, syscall.S_IFBLK:
+		hdr.Typeflag = tar.TypeChar
+		if attr.Mode&syscall.S_IFMT == syscall.S_IFBLK {
+			hdr.Typeflag = tar.TypeBlock
+		}
+		hdr.Devmajor = int64((attr.Rdev >> 8) & 0xfff)
+		hdr.Devminor = int64(attr.Rdev&0xff | (attr.Rdev>>12)&0xfff00)
+	case syscall.S_IFIFO:
+		hdr.Typeflag = tar.TypeFifo
+	case syscall.S_IFREG:
+		id := fileID{idx, attr.Ino}
+		if first, ok := links[id]; ok {
+			hdr.Typeflag = tar.TypeLink
+			hdr.Linkname = first
+			return tw.WriteHeader(hdr)
+		}
+		if attr.Nlink > 1 {
+			links[id] = c.Path
+		}
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = int64(attr.Size)
+	default:
+		// Sockets cannot be stored in a tar file.
+		return nil
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if hdr.Typeflag == tar.TypeReg {
+		if idx == 0 {
+			var errno syscall.Errno
+			if idx, p, errno = r.dataPath(p); errno != 0 {
+				return fmt.Errorf("read %s: %w", c.Path, errno)
+			}
+			b = r.branches[idx]
+		}
+		if errno := copyFile(tw, b, p); errno != 0 {
+			return fmt.Errorf("read %s: %w", c.Path, errno)
+		}
+	}
+	if c.opaque {
+		return tw.WriteHeader(&tar.Header{
+			Name:     filepath.Join(c.Path, opaqueWhiteout),
+			Typeflag: tar.TypeReg,
+		})
+	}
+	return nil
+}
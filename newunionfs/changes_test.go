@@ -0,0 +1,162 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unionfs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gofs "github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/internal/testutil"
+)
+
+// treeContents describes the files below dir by their contents, or
+// their symlink target.
+func treeContents(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	result := map[string]string{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		switch {
+		case d.IsDir():
+			result[rel] = "dir"
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			result[rel] = "-> " + target
+		default:
+			content, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			result[rel] = string(content)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDir: %v", err)
+	}
+	return result
+}
+
+func TestChanges(t *testing.T) {
+	for name, opts := range map[string]*Options{
+		"markers": nil,
+		"overlay": {Whiteouts: OverlayWhiteouts},
+		"aufs":    {Whiteouts: AUFSWhiteouts},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tc := newTestCaseOptions(t, true, opts)
+			defer tc.Clean()
+			for _, f := range []string{"dir/keep", "file", "olddir/a", "opq/x"} {
+				p := filepath.Join(tc.ro, f)
+				os.MkdirAll(filepath.Dir(p), 0755)
+				if err := os.WriteFile(p, []byte(f), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for f, content := range map[string]string{
+				"new":       "n",
+				"file":      "x",
+				"dir/added": "y",
+			} {
+				if err := os.WriteFile(filepath.Join(tc.mnt, f), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, err := range []error{
+				os.Symlink("new", tc.mnt+"/link"),
+				os.Link(tc.mnt+"/new", tc.mnt+"/hard"),
+				os.Remove(tc.mnt + "/dir/keep"),
+				os.Rename(tc.mnt+"/olddir", tc.mnt+"/renamed"),
+				os.Remove(tc.mnt + "/opq/x"),
+				os.Remove(tc.mnt + "/opq"),
+				os.Mkdir(tc.mnt+"/opq", 0755),
+				os.WriteFile(tc.mnt+"/opq/y", []byte("z"), 0644),
+			} {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := Changes(tc.root)
+			if err != nil {
+				t.Fatalf("Changes: %v", err)
+			}
+			want := []Change{
+				{"dir", Modified},
+				{"dir/added", Added},
+				{"dir/keep", Deleted},
+				{"file", Modified},
+				{"hard", Added},
+				{"link", Added},
+				{"new", Added},
+				{"olddir", Deleted},
+				{"opq", Modified},
+				{"opq/x", Deleted},
+				{"opq/y", Added},
+				{"renamed", Added},
+				{"renamed/a", Added},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Changes: got %v, want %v", got, want)
+			}
+
+			// Stacking the layer onto the read-only branch must
+			// give the same tree.
+			layer := filepath.Join(tc.dir, "layer.tar")
+			f, err := os.Create(layer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := WriteLayer(tc.root, f); err != nil {
+				t.Fatalf("WriteLayer: %v", err)
+			}
+			f.Close()
+
+			idx, err := OpenLayer(layer)
+			if err != nil {
+				t.Fatalf("OpenLayer: %v", err)
+			}
+			defer idx.Close()
+			var attr, hard fuse.Attr
+			idx.Lstat("new", &attr)
+			idx.Lstat("hard", &hard)
+			if attr.Ino != hard.Ino {
+				t.Errorf("hard link was not preserved")
+			}
+
+			rw := filepath.Join(tc.dir, "rw2")
+			mnt := filepath.Join(tc.dir, "mnt2")
+			for _, d := range []string{rw, mnt} {
+				if err := os.Mkdir(d, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			root := NewBranchUnionFS(rw, []Branch{idx, NewDirBranch(tc.ro)}, &Options{Whiteouts: AUFSWhiteouts})
+			mountOpts := &gofs.Options{}
+			mountOpts.Debug = testutil.VerboseTest()
+			server, err := gofs.Mount(mnt, root, mountOpts)
+			if err != nil {
+				t.Fatalf("Mount: %v", err)
+			}
+			defer server.Unmount()
+
+			if got, want := treeContents(t, mnt), treeContents(t, tc.mnt); !reflect.DeepEqual(got, want) {
+				t.Errorf("applied layer: got %v, want %v", got, want)
+			}
+		})
+	}
+}
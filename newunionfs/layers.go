@@ -0,0 +1,95 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unionfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/zipfs"
+)
+
+// layerFormat returns the compression of the tar file name, in the
+// format of zipfs.NewTarIndex.
+func layerFormat(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return "gz", nil
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return "bz2", nil
+	case bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return "", fmt.Errorf("%s: zstd compression is not supported", name)
+	}
+	return "", nil
+}
+
+var _ = (Branch)((*zipfs.TarIndex)(nil))
+
+// OpenLayer opens the image layer name, a tar file that is optionally
+// compressed with gzip or bzip2, for use as a read-only branch.
+func OpenLayer(name string) (*zipfs.TarIndex, error) {
+	format, err := layerFormat(name)
+	if err != nil {
+		return nil, err
+	}
+	return zipfs.NewTarIndex(name, format)
+}
+
+// layerIndexes closes the indexes of the layers of a NewLayerFS.
+type layerIndexes []*zipfs.TarIndex
+
+func (l layerIndexes) Close() error {
+	var errs []error
+	for _, idx := range l {
+		errs = append(errs, idx.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// NewLayerFS returns the root of a union of the writable directory
+// upper and container image layers, which are tar files, optionally
+// compressed with gzip or bzip2. The layers are listed from the base
+// up, as in an OCI image manifest. They are read in place, and their
+// .wh. whiteouts and .wh..wh..opq opaque markers are applied. The
+// returned Closer closes the layers; call it once the file system is
+// unmounted.
+//
+// Since deletion markers cannot describe the whiteouts of the layers,
+// opts.Whiteouts defaults to AUFSWhiteouts.
+func NewLayerFS(upper string, layers []string, opts *Options) (fs.InodeEmbedder, io.Closer, error) {
+	var indexes layerIndexes
+	var lower []Branch
+	for i := len(layers) - 1; i >= 0; i-- {
+		idx, err := OpenLayer(layers[i])
+		if err != nil {
+			indexes.Close()
+			return nil, nil, err
+		}
+		indexes = append(indexes, idx)
+		lower = append(lower, idx)
+	}
+
+	o := Options{Whiteouts: AUFSWhiteouts}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Whiteouts == DeletionMarkers {
+		o.Whiteouts = AUFSWhiteouts
+	}
+	return NewBranchUnionFS(upper, lower, &o), indexes, nil
+}
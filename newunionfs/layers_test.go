@@ -0,0 +1,129 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unionfs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/internal/testutil"
+)
+
+// writeLayer writes a tar file with the given files. Names ending in
+// a slash are directories.
+func writeLayer(t *testing.T, name string, compress bool, files [][2]string) {
+	t.Helper()
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var w io.Writer = f
+	if compress {
+		zw := gzip.NewWriter(f)
+		defer zw.Close()
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	for _, file := range files {
+		h := &tar.Header{Name: file[0], Mode: 0644, Size: int64(len(file[1]))}
+		if strings.HasSuffix(file[0], "/") {
+			h.Typeflag = tar.TypeDir
+			h.Mode = 0755
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(file[1]))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLayerFS(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.tar.gz")
+	writeLayer(t, base, true, [][2]string{
+		{"etc/", ""},
+		{"etc/passwd", "root\n"},
+		{"etc/group", "wheel\n"},
+		{"usr/bin/tool", "v1"},
+		{"opq/a", "a"},
+		{"opq/sub/x", "x"},
+	})
+	top := filepath.Join(dir, "top.tar")
+	writeLayer(t, top, false, [][2]string{
+		{"etc/.wh.group", ""},
+		{"usr/bin/tool", "v2"},
+		{"opq/.wh..wh..opq", ""},
+		{"opq/b", "b"},
+		{"new", "n"},
+	})
+
+	rw := filepath.Join(dir, "rw")
+	mnt := filepath.Join(dir, "mnt")
+	for _, d := range []string{rw, mnt} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root, layers, err := NewLayerFS(rw, []string{base, top}, nil)
+	if err != nil {
+		t.Fatalf("NewLayerFS: %v", err)
+	}
+	defer layers.Close()
+	opts := &fs.Options{}
+	opts.Debug = testutil.VerboseTest()
+	server, err := fs.Mount(mnt, root, opts)
+	if err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	defer server.Unmount()
+
+	for d, want := range map[string]map[string]bool{
+		"":    {"etc": true, "usr": true, "opq": true, "new": true},
+		"etc": {"passwd": true},
+		"opq": {"b": true},
+	} {
+		if got := readDirNames(t, filepath.Join(mnt, d)); !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadDir %q: got %v, want %v", d, got, want)
+		}
+	}
+	if got, err := os.ReadFile(mnt + "/usr/bin/tool"); err != nil || string(got) != "v2" {
+		t.Errorf("ReadFile tool: got %q, %v", got, err)
+	}
+	if _, err := os.Lstat(mnt + "/etc/group"); !os.IsNotExist(err) {
+		t.Errorf("whited out file: got %v, want ENOENT", err)
+	}
+
+	// Writing copies the file from the compressed base layer.
+	f, err := os.OpenFile(mnt+"/etc/passwd", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	f.Write([]byte("user\n"))
+	f.Close()
+	if got, err := os.ReadFile(rw + "/etc/passwd"); err != nil || string(got) != "root\nuser\n" {
+		t.Errorf("copied up file: got %q, %v", got, err)
+	}
+
+	if err := os.Remove(mnt + "/new"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := os.Lstat(rw + "/.wh.new"); err != nil {
+		t.Errorf("no whiteout in the writable directory: %v", err)
+	}
+	if _, err := os.Lstat(mnt + "/new"); !os.IsNotExist(err) {
+		t.Errorf("removed file: got %v, want ENOENT", err)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unionfs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"golang.org/x/sys/unix"
+)
+
+// promoteMeta is like promote, but if Options.MetaCopy is set, a
+// regular file p is copied to the writable branch without its data.
+func (r *unionFSRoot) promoteMeta(p string) syscall.Errno {
+	var attr fuse.Attr
+	idx := r.getBranch(p, &attr)
+	if !r.opts.MetaCopy || idx <= 0 || attr.Mode&syscall.S_IFMT != syscall.S_IFREG {
+		return r.promote(p)
+	}
+	if errno := r.promote(filepath.Dir(p)); errno != 0 {
+		return errno
+	}
+	return r.copyUp(p, idx, &attr, true)
+}
+
+// promoteMetaFile creates the regular file p of branch idx in the
+// writable branch with the size of the original, but none of its
+// data. As in overlayfs, the copy is marked with the metacopy
+// extended attribute, and the redirect attribute holds the path of
+// the data in the read-only branches.
+func (r *unionFSRoot) promoteMetaFile(p string, idx int, attr *fuse.Attr) syscall.Errno {
+	dest := filepath.Join(r.upper, p)
+	fd, err := syscall.Open(dest, syscall.O_CREAT|syscall.O_EXCL|syscall.O_WRONLY, attr.Mode&0777)
+	if err != nil {
+		return err.(syscall.Errno)
+	}
+	err = syscall.Ftruncate(fd, int64(attr.Size))
+	if err == nil {
+		err = unix.Fsetxattr(fd, r.xattr("metacopy"), nil, 0)
+	}
+	if err == nil {
+		err = unix.Fsetxattr(fd, r.xattr("redirect"), []byte("/"+r.branchPath(idx, p)), 0)
+	}
+	syscall.Close(fd)
+	if err != nil {
+		syscall.Unlink(dest)
+		return fs.ToErrno(err)
+	}
+	return 0
+}
+
+// dataPath returns the branch and the path in it that hold the data
+// of the file p of the writable branch. This is p itself unless p was
+// copied up without its data.
+func (r *unionFSRoot) dataPath(p string) (int, string, syscall.Errno) {
+	b := r.branches[0]
+	if !r.opts.MetaCopy {
+		return 0, p, 0
+	}
+	if _, errno := b.Getxattr(p, r.xattr("metacopy"), nil); errno != 0 {
+		return 0, p, 0
+	}
+	lower := p
+	if target, ok := getXattr(b, p, r.xattr("redirect")); ok {
+		lower = strings.TrimPrefix(string(target), "/")
+	}
+	for _, e := range r.resolveFrom(lower, 1) {
+		if e.attr.Mode&syscall.S_IFMT == syscall.S_IFREG {
+			return e.idx, e.path, 0
+		}
+	}
+	// The read-only branches changed underneath us.
+	return 0, "", syscall.EIO
+}
+
+// copyUpData completes the copy of the file p of the writable branch
+// if it was copied up without its data. If trunc is set, the data is
+// about to be discarded, so it is not copied.
+func (r *unionFSRoot) copyUpData(p string, trunc bool) syscall.Errno {
+	idx, src, errno := r.dataPath(p)
+	if errno != 0 || idx == 0 {
+		return errno
+	}
+
+	dest := filepath.Join(r.upper, p)
+	if !trunc {
+		var st syscall.Stat_t
+		if err := syscall.Lstat(dest, &st); err != nil {
+			return err.(syscall.Errno)
+		}
+		f, err := os.OpenFile(dest, os.O_WRONLY, 0)
+		if err != nil {
+			return fs.ToErrno(err)
+		}
+		errno := copyFile(f, r.branches[idx], src)
+		if err := f.Close(); err != nil && errno == 0 {
+			errno = fs.ToErrno(err)
+		}
+		if errno != 0 {
+			return errno
+		}
+
+		// Writing the data must not change the timestamps.
+		ts := []unix.Timespec{unix.Timespec(st.Atim), unix.Timespec(st.Mtim)}
+		unix.UtimesNanoAt(unix.AT_FDCWD, dest, ts, unix.AT_SYMLINK_NOFOLLOW)
+	}
+	unix.Lremovexattr(dest, r.xattr("redirect"))
+	return fs.ToErrno(unix.Lremovexattr(dest, r.xattr("metacopy")))
+}
+
+// dataFile reads the data of a file that was copied up without it.
+// It has no Getattr or Setattr, so the attributes come from the
+// writable branch.
+type dataFile struct {
+	fh fs.FileHandle
+}
+
+var _ = (fs.FileReader)((*dataFile)(nil))
+var _ = (fs.FileReleaser)((*dataFile)(nil))
+
+func (f *dataFile) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	return f.fh.(fs.FileReader).Read(ctx, dest, off)
+}
+
+func (f *dataFile) Release(ctx context.Context) syscall.Errno {
+	if rel, ok := f.fh.(fs.FileReleaser); ok {
+		return rel.Release(ctx)
+	}
+	return 0
+}
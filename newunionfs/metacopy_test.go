@@ -0,0 +1,130 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unionfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestMetaCopy(t *testing.T) {
+	for name, opts := range map[string]*Options{
+		"markers": {MetaCopy: true},
+		"overlay": {MetaCopy: true, Whiteouts: OverlayWhiteouts},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tc := newTestCaseOptions(t, true, opts)
+			defer tc.Clean()
+			for _, f := range []string{"append", "trunc", "create"} {
+				if err := os.WriteFile(tc.ro+"/dir/"+f, []byte("content"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			metacopy := tc.root.xattr("metacopy")
+			isMetaCopy := func(f string) bool {
+				_, err := unix.Lgetxattr(tc.rw+"/dir/"+f, metacopy, nil)
+				return err == nil
+			}
+
+			for _, f := range []string{"ro-file", "append", "trunc", "create"} {
+				if err := os.Chmod(tc.mnt+"/dir/"+f, 0600); err != nil {
+					t.Fatalf("Chmod: %v", err)
+				}
+				if !isMetaCopy(f) {
+					t.Fatalf("%s: no metadata copy", f)
+				}
+			}
+			mtime := time.Unix(1000000000, 0)
+			if err := os.Chtimes(tc.mnt+"/dir/ro-file", mtime, mtime); err != nil {
+				t.Fatalf("Chtimes: %v", err)
+			}
+
+			var st syscall.Stat_t
+			if err := syscall.Lstat(tc.rw+"/dir/ro-file", &st); err != nil {
+				t.Fatal(err)
+			}
+			if st.Size != 3 || st.Blocks != 0 {
+				t.Errorf("copy has size %d, %d blocks; want 3, 0", st.Size, st.Blocks)
+			}
+
+			f, err := os.Open(tc.mnt + "/dir/ro-file")
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := io.ReadAll(f)
+			if err != nil || string(content) != "bla" {
+				t.Errorf("ReadAll: got %q, %v", content, err)
+			}
+			if fi, err := f.Stat(); err != nil || fi.Mode().Perm() != 0600 || !fi.ModTime().Equal(mtime) {
+				t.Errorf("Stat: got %v, %v", fi, err)
+			}
+			f.Close()
+
+			if sz, err := unix.Llistxattr(tc.mnt+"/dir/ro-file", make([]byte, 1024)); err != nil || sz != 0 {
+				t.Errorf("Listxattr: got %d, %v", sz, err)
+			}
+
+			var layer bytes.Buffer
+			if err := WriteLayer(tc.root, &layer); err != nil {
+				t.Fatalf("WriteLayer: %v", err)
+			}
+			tr := tar.NewReader(&layer)
+			for {
+				h, err := tr.Next()
+				if err != nil {
+					t.Fatalf("Next: %v", err)
+				}
+				if h.Name == "dir/ro-file" {
+					data, _ := io.ReadAll(tr)
+					if string(data) != "bla" {
+						t.Errorf("layer has %q, want %q", data, "bla")
+					}
+					break
+				}
+			}
+
+			// Writing copies the data.
+			f, err = os.OpenFile(tc.mnt+"/dir/append", os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Write([]byte("+"))
+			f.Close()
+			if err := os.Truncate(tc.mnt+"/dir/trunc", 4); err != nil {
+				t.Fatalf("Truncate: %v", err)
+			}
+			if err := os.WriteFile(tc.mnt+"/dir/create", []byte("new"), 0644); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			for f, want := range map[string]string{
+				"append": "content+",
+				"trunc":  "cont",
+				"create": "new",
+			} {
+				if isMetaCopy(f) {
+					t.Errorf("%s: data was not copied", f)
+				}
+				got, err := os.ReadFile(tc.rw + "/dir/" + f)
+				if err != nil || string(got) != want {
+					t.Errorf("%s: got %q, %v, want %q", f, got, err, want)
+				}
+				if got, err := os.ReadFile(tc.mnt + "/dir/" + f); err != nil || string(got) != want {
+					t.Errorf("%s in union: got %q, %v, want %q", f, got, err, want)
+				}
+			}
+			if got, _ := os.ReadFile(tc.ro + "/dir/append"); !strings.HasPrefix(string(got), "content") || len(got) != 7 {
+				t.Errorf("read-only branch changed: %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unionfs
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+)
+
+// CreatePolicy chooses the branch of a pool in which new files and
+// directories are created.
+type CreatePolicy interface {
+	// Choose returns the index in branches of the branch for a
+	// new entry in the directory dir, which is relative to the
+	// branches and exists in at least one of them. If the chosen
+	// branch does not have dir, it is created.
+	Choose(branches []string, dir string) (int, syscall.Errno)
+}
+
+// MostFreeSpace creates files in the branch with the most space
+// available.
+type MostFreeSpace struct{}
+
+var _ = (CreatePolicy)(MostFreeSpace{})
+
+func (MostFreeSpace) Choose(branches []string, dir string) (int, syscall.Errno) {
+	all := make([]int, len(branches))
+	for i := range all {
+		all[i] = i
+	}
+	return mostFree(branches, all), 0
+}
+
+// ExistingPath creates files in the branch with the most space
+// available among those that already have the directory, so
+// directories are not spread over the branches.
+type ExistingPath struct{}
+
+var _ = (CreatePolicy)(ExistingPath{})
+
+func (ExistingPath) Choose(branches []string, dir string) (int, syscall.Errno) {
+	var have []int
+	for i, b := range branches {
+		var st syscall.Stat_t
+		if syscall.Lstat(filepath.Join(b, dir), &st) == nil && st.Mode&syscall.S_IFMT == syscall.S_IFDIR {
+			have = append(have, i)
+		}
+	}
+	if len(have) == 0 {
+		return 0, syscall.ENOENT
+	}
+	return mostFree(branches, have), 0
+}
+
+// RoundRobin creates files in each branch in turn. It must not be
+// copied after first use.
+type RoundRobin struct {
+	next atomic.Uint32
+}
+
+var _ = (CreatePolicy)((*RoundRobin)(nil))
+
+func (p *RoundRobin) Choose(branches []string, dir string) (int, syscall.Errno) {
+	return int((p.next.Add(1) - 1) % uint32(len(branches))), 0
+}
+
+// mostFree returns the one of the branches with index in candidates
+// that has the most space available. Branches whose space cannot be
+// determined lose.
+func mostFree(branches []string, candidates []int) int {
+	best := candidates[0]
+	var bestFree uint64
+	for _, i := range candidates {
+		var s syscall.Statfs_t
+		if syscall.Statfs(branches[i], &s) != nil {
+			continue
+		}
+		if free := s.Bavail * uint64(s.Bsize); free > bestFree {
+			best, bestFree = i, free
+		}
+	}
+	return best
+}
@@ -0,0 +1,551 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unionfs
+
+import (
+	"context"
+	"path/filepath"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"golang.org/x/sys/unix"
+)
+
+// PoolOptions configures a pool.
+type PoolOptions struct {
+	// Create chooses the branch for new files. It defaults to
+	// ExistingPath.
+	Create CreatePolicy
+}
+
+type poolRoot struct {
+	poolNode
+
+	branches []string
+	opts     PoolOptions
+
+	// dev is the device of the first branch, which is not mixed
+	// into inode numbers.
+	dev uint64
+}
+
+type poolNode struct {
+	fs.Inode
+}
+
+// NewPoolFS returns the root of a pool of the directories branches,
+// as in mergerfs. Unlike in a union, all branches are writable.
+// Directories are merged, and other files are taken from the first
+// branch that has them. Existing files are changed in place, in all
+// branches that have them, and new files are created in the branch
+// that the create policy chooses. Renames and hard links are done
+// within each branch, so files never move between branches.
+func NewPoolFS(branches []string, opts *PoolOptions) fs.InodeEmbedder {
+	r := &poolRoot{
+		branches: branches,
+	}
+	if opts != nil {
+		r.opts = *opts
+	}
+	if r.opts.Create == nil {
+		r.opts.Create = ExistingPath{}
+	}
+	var st syscall.Stat_t
+	if err := syscall.Stat(branches[0], &st); err == nil {
+		r.dev = uint64(st.Dev)
+	}
+	return r
+}
+
+func (n *poolNode) root() *poolRoot {
+	return n.Root().Operations().(*poolRoot)
+}
+
+// path returns the path of name in branch idx.
+func (r *poolRoot) path(idx int, name string) string {
+	return filepath.Join(r.branches[idx], name)
+}
+
+// find returns the first branch that has name, and fills st with its
+// attributes there. It returns -1 if no branch has name.
+func (r *poolRoot) find(name string, st *syscall.Stat_t) int {
+	for i := range r.branches {
+		if syscall.Lstat(r.path(i, name), st) == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// existing returns the branches that have name.
+func (r *poolRoot) existing(name string) []int {
+	var result []int
+	for i := range r.branches {
+		var st syscall.Stat_t
+		if syscall.Lstat(r.path(i, name), &st) == nil {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// idFromStat composes an inode number from the inode and device of
+// a file, as the loopback file system does, so files of branches on
+// different devices do not collide.
+func (r *poolRoot) idFromStat(st *syscall.Stat_t) fs.StableAttr {
+	swapped := (uint64(st.Dev) << 32) | (uint64(st.Dev) >> 32)
+	swappedRootDev := (r.dev << 32) | (r.dev >> 32)
+	return fs.StableAttr{
+		Mode: uint32(st.Mode),
+		Gen:  1,
+		Ino:  (swapped ^ swappedRootDev) ^ st.Ino,
+	}
+}
+
+// fillAttr fills out with the attributes st.
+func (r *poolRoot) fillAttr(st *syscall.Stat_t, out *fuse.Attr) {
+	out.FromStat(st)
+	out.Ino = r.idFromStat(st).Ino
+}
+
+// clonePath creates the directory dir in branch idx if it is not
+// there, copying the mode, owner and timestamps of the directories
+// it creates from the first branch that has them.
+func (r *poolRoot) clonePath(idx int, dir string) syscall.Errno {
+	if dir == "" || dir == "." {
+		return 0
+	}
+	dest := r.path(idx, dir)
+	var st syscall.Stat_t
+	if syscall.Lstat(dest, &st) == nil {
+		return 0
+	}
+	if errno := r.clonePath(idx, filepath.Dir(dir)); errno != 0 {
+		return errno
+	}
+	if r.find(dir, &st) < 0 {
+		return syscall.ENOENT
+	}
+	if err := syscall.Mkdir(dest, st.Mode&07777); err != nil && err != syscall.EEXIST {
+		return err.(syscall.Errno)
+	}
+
+	// ignore errors: the directory is usable without them.
+	syscall.Chmod(dest, st.Mode&07777)
+	syscall.Lchown(dest, int(st.Uid), int(st.Gid))
+	ts := []unix.Timespec{unix.Timespec(st.Atim), unix.Timespec(st.Mtim)}
+	unix.UtimesNanoAt(unix.AT_FDCWD, dest, ts, unix.AT_SYMLINK_NOFOLLOW)
+	return 0
+}
+
+// createPath returns the path in the chosen branch for the new entry
+// name in the directory dir of the pool.
+func (r *poolRoot) createPath(dir, name string) (string, syscall.Errno) {
+	idx, errno := r.opts.Create.Choose(r.branches, dir)
+	if errno != 0 {
+		return "", errno
+	}
+	if idx < 0 || idx >= len(r.branches) {
+		return "", syscall.EIO
+	}
+	if errno := r.clonePath(idx, dir); errno != 0 {
+		return "", errno
+	}
+	return r.path(idx, filepath.Join(dir, name)), 0
+}
+
+// newChild returns the node for the new entry at abs, the absolute
+// path in its branch.
+func (n *poolNode) newChild(ctx context.Context, abs string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	var st syscall.Stat_t
+	if err := syscall.Lstat(abs, &st); err != nil {
+		return nil, err.(syscall.Errno)
+	}
+	r := n.root()
+	r.fillAttr(&st, &out.Attr)
+	return n.NewInode(ctx, &poolNode{}, r.idFromStat(&st)), 0
+}
+
+var _ = (fs.NodeLookuper)((*poolNode)(nil))
+
+func (n *poolNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	r := n.root()
+	var st syscall.Stat_t
+	if r.find(filepath.Join(n.Path(nil), name), &st) < 0 {
+		return nil, syscall.ENOENT
+	}
+	r.fillAttr(&st, &out.Attr)
+	return n.NewInode(ctx, &poolNode{}, r.idFromStat(&st)), 0
+}
+
+var _ = (fs.NodeGetattrer)((*poolNode)(nil))
+
+func (n *poolNode) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	if fga, ok := fh.(fs.FileGetattrer); ok && fga != nil {
+		return fga.Getattr(ctx, out)
+	}
+	r := n.root()
+	var st syscall.Stat_t
+	if r.find(n.Path(nil), &st) < 0 {
+		return syscall.ENOENT
+	}
+	r.fillAttr(&st, &out.Attr)
+	return 0
+}
+
+var _ = (fs.NodeSetattrer)((*poolNode)(nil))
+
+func (n *poolNode) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if fsa, ok := fh.(fs.FileSetattrer); ok && fsa != nil {
+		return fsa.Setattr(ctx, in, out)
+	}
+	r := n.root()
+	p := n.Path(nil)
+	idxs := r.existing(p)
+	if len(idxs) == 0 {
+		return syscall.ENOENT
+	}
+	for _, idx := range idxs {
+		if errno := setattrPath(r.path(idx, p), in); errno != 0 {
+			return errno
+		}
+	}
+	return n.Getattr(ctx, nil, out)
+}
+
+var _ = (fs.NodeOpener)((*poolNode)(nil))
+
+func (n *poolNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	r := n.root()
+	p := n.Path(nil)
+	var st syscall.Stat_t
+	idx := r.find(p, &st)
+	if idx < 0 {
+		return nil, 0, syscall.ENOENT
+	}
+	fh, errno := NewDirBranch(r.branches[idx]).Open(p, flags)
+	return fh, 0, errno
+}
+
+var _ = (fs.NodeCreater)((*poolNode)(nil))
+
+func (n *poolNode) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
+	abs, errno := n.root().createPath(n.Path(nil), name)
+	if errno != 0 {
+		return nil, nil, 0, errno
+	}
+	fd, err := syscall.Open(abs, int(flags)|syscall.O_CREAT, mode)
+	if err != nil {
+		return nil, nil, 0, err.(syscall.Errno)
+	}
+	ch, errno := n.newChild(ctx, abs, out)
+	if errno != 0 {
+		syscall.Close(fd)
+		return nil, nil, 0, errno
+	}
+	return ch, fs.NewLoopbackFile(fd), 0, 0
+}
+
+var _ = (fs.NodeMkdirer)((*poolNode)(nil))
+
+func (n *poolNode) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	abs, errno := n.root().createPath(n.Path(nil), name)
+	if errno != 0 {
+		return nil, errno
+	}
+	if err := syscall.Mkdir(abs, mode); err != nil {
+		return nil, err.(syscall.Errno)
+	}
+	return n.newChild(ctx, abs, out)
+}
+
+var _ = (fs.NodeMknoder)((*poolNode)(nil))
+
+func (n *poolNode) Mknod(ctx context.Context, name string, mode uint32, dev uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	abs, errno := n.root().createPath(n.Path(nil), name)
+	if errno != 0 {
+		return nil, errno
+	}
+	if err := syscall.Mknod(abs, mode, int(dev)); err != nil {
+		return nil, err.(syscall.Errno)
+	}
+	return n.newChild(ctx, abs, out)
+}
+
+var _ = (fs.NodeSymlinker)((*poolNode)(nil))
+
+func (n *poolNode) Symlink(ctx context.Context, target, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	abs, errno := n.root().createPath(n.Path(nil), name)
+	if errno != 0 {
+		return nil, errno
+	}
+	if err := syscall.Symlink(target, abs); err != nil {
+		return nil, err.(syscall.Errno)
+	}
+	return n.newChild(ctx, abs, out)
+}
+
+var _ = (fs.NodeLinker)((*poolNode)(nil))
+
+// Link links name to the target in each branch that has the target.
+// It stops at the first error, and keeps the links that were made in
+// the branches before it.
+func (n *poolNode) Link(ctx context.Context, target fs.InodeEmbedder, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	r := n.root()
+	dir := n.Path(nil)
+	p := filepath.Join(dir, name)
+	var st syscall.Stat_t
+	if r.find(p, &st) >= 0 {
+		return nil, syscall.EEXIST
+	}
+	targetPath := target.EmbeddedInode().Path(nil)
+	idxs := r.existing(targetPath)
+	if len(idxs) == 0 {
+		return nil, syscall.ENOENT
+	}
+	for _, idx := range idxs {
+		if errno := r.clonePath(idx, dir); errno != 0 {
+			return nil, errno
+		}
+		if err := syscall.Link(r.path(idx, targetPath), r.path(idx, p)); err != nil {
+			return nil, err.(syscall.Errno)
+		}
+	}
+	return n.newChild(ctx, r.path(idxs[0], p), out)
+}
+
+var _ = (fs.NodeUnlinker)((*poolNode)(nil))
+
+func (n *poolNode) Unlink(ctx context.Context, name string) syscall.Errno {
+	return n.root().forAll(filepath.Join(n.Path(nil), name), syscall.Unlink)
+}
+
+var _ = (fs.NodeRmdirer)((*poolNode)(nil))
+
+// Rmdir removes the directory name from each branch that has it. The
+// merged directory is checked first, so a directory that is not
+// empty in some branch is not removed from any of them.
+func (n *poolNode) Rmdir(ctx context.Context, name string) syscall.Errno {
+	r := n.root()
+	p := filepath.Join(n.Path(nil), name)
+	var st syscall.Stat_t
+	if r.find(p, &st) < 0 {
+		return syscall.ENOENT
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		return syscall.ENOTDIR
+	}
+	if len(r.readDir(p)) > 0 {
+		return syscall.ENOTEMPTY
+	}
+	return r.forAll(p, syscall.Rmdir)
+}
+
+// forAll calls fn with the path of p in each branch that has it,
+// stopping at the first error.
+func (r *poolRoot) forAll(p string, fn func(string) error) syscall.Errno {
+	idxs := r.existing(p)
+	if len(idxs) == 0 {
+		return syscall.ENOENT
+	}
+	for _, idx := range idxs {
+		if err := fn(r.path(idx, p)); err != nil {
+			return fs.ToErrno(err)
+		}
+	}
+	return 0
+}
+
+var _ = (fs.NodeRenamer)((*poolNode)(nil))
+
+// Rename renames name in each branch that has it. The entries of the
+// other branches that the new name would hide are removed. Rename
+// stops at the first error without undoing the earlier branches, so
+// the entry may then be visible under both names, or the old target
+// may be partly removed.
+func (n *poolNode) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	if flags&fs.RENAME_EXCHANGE != 0 {
+		return syscall.EINVAL
+	}
+	r := n.root()
+	oldPath := filepath.Join(n.Path(nil), name)
+	newDir := newParent.EmbeddedInode().Path(nil)
+	newPath := filepath.Join(newDir, newName)
+
+	var st, dst syscall.Stat_t
+	if r.find(oldPath, &st) < 0 {
+		return syscall.ENOENT
+	}
+	isDir := st.Mode&syscall.S_IFMT == syscall.S_IFDIR
+	if r.find(newPath, &dst) >= 0 {
+		if flags&fs.RENAME_NOREPLACE != 0 {
+			return syscall.EEXIST
+		}
+		dstDir := dst.Mode&syscall.S_IFMT == syscall.S_IFDIR
+		if isDir && !dstDir {
+			return syscall.ENOTDIR
+		}
+		if !isDir && dstDir {
+			return syscall.EISDIR
+		}
+		if dstDir && len(r.readDir(newPath)) > 0 {
+			return syscall.ENOTEMPTY
+		}
+	}
+
+	src := map[int]bool{}
+	for _, idx := range r.existing(oldPath) {
+		src[idx] = true
+		if errno := r.clonePath(idx, newDir); errno != 0 {
+			return errno
+		}
+		if err := syscall.Rename(r.path(idx, oldPath), r.path(idx, newPath)); err != nil {
+			return err.(syscall.Errno)
+		}
+	}
+	for _, idx := range r.existing(newPath) {
+		if src[idx] {
+			continue
+		}
+		remove := syscall.Unlink
+		if isDir {
+			remove = syscall.Rmdir
+		}
+		if err := remove(r.path(idx, newPath)); err != nil {
+			return err.(syscall.Errno)
+		}
+	}
+	return 0
+}
+
+var _ = (fs.NodeReaddirer)((*poolNode)(nil))
+
+func (n *poolNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	return fs.NewListDirStream(n.root().readDir(n.Path(nil))), 0
+}
+
+// readDir lists the directory dir of all branches. Entries of earlier
+// branches hide those of later ones.
+func (r *poolRoot) readDir(dir string) []fuse.DirEntry {
+	seen := map[string]bool{}
+	var result []fuse.DirEntry
+	for _, b := range r.branches {
+		entries, errno := NewDirBranch(b).ReadDir(dir)
+		if errno != 0 {
+			continue
+		}
+		for _, e := range entries {
+			if !seen[e.Name] {
+				seen[e.Name] = true
+				result = append(result, e)
+			}
+		}
+	}
+	return result
+}
+
+var _ = (fs.NodeReadlinker)((*poolNode)(nil))
+
+func (n *poolNode) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
+	r := n.root()
+	p := n.Path(nil)
+	var st syscall.Stat_t
+	idx := r.find(p, &st)
+	if idx < 0 {
+		return nil, syscall.ENOENT
+	}
+	return NewDirBranch(r.branches[idx]).Readlink(p)
+}
+
+var _ = (fs.NodeGetxattrer)((*poolNode)(nil))
+
+func (n *poolNode) Getxattr(ctx context.Context, attr string, dest []byte) (uint32, syscall.Errno) {
+	r := n.root()
+	p := n.Path(nil)
+	var st syscall.Stat_t
+	idx := r.find(p, &st)
+	if idx < 0 {
+		return 0, syscall.ENOENT
+	}
+	return NewDirBranch(r.branches[idx]).Getxattr(p, attr, dest)
+}
+
+var _ = (fs.NodeListxattrer)((*poolNode)(nil))
+
+func (n *poolNode) Listxattr(ctx context.Context, dest []byte) (uint32, syscall.Errno) {
+	r := n.root()
+	p := n.Path(nil)
+	var st syscall.Stat_t
+	idx := r.find(p, &st)
+	if idx < 0 {
+		return 0, syscall.ENOENT
+	}
+	return NewDirBranch(r.branches[idx]).Listxattr(p, dest)
+}
+
+var _ = (fs.NodeSetxattrer)((*poolNode)(nil))
+
+func (n *poolNode) Setxattr(ctx context.Context, attr string, data []byte, flags uint32) syscall.Errno {
+	return n.root().forAll(n.Path(nil), func(p string) error {
+		return unix.Lsetxattr(p, attr, data, int(flags))
+	})
+}
+
+var _ = (fs.NodeRemovexattrer)((*poolNode)(nil))
+
+func (n *poolNode) Removexattr(ctx context.Context, attr string) syscall.Errno {
+	return n.root().forAll(n.Path(nil), func(p string) error {
+		return unix.Lremovexattr(p, attr)
+	})
+}
+
+var _ = (fs.NodeStatfser)((*poolNode)(nil))
+
+// Statfs adds up the space of the branches. Branches on the same
+// device are counted once.
+func (n *poolNode) Statfs(ctx context.Context, out *fuse.StatfsOut) syscall.Errno {
+	r := n.root()
+	devs := map[uint64]bool{}
+	var total syscall.Statfs_t
+	for _, b := range r.branches {
+		var st syscall.Stat_t
+		if err := syscall.Stat(b, &st); err != nil {
+			return fs.ToErrno(err)
+		}
+		if devs[uint64(st.Dev)] {
+			continue
+		}
+		devs[uint64(st.Dev)] = true
+
+		var s syscall.Statfs_t
+		if err := syscall.Statfs(b, &s); err != nil {
+			return fs.ToErrno(err)
+		}
+		addStatfs(&total, &s)
+	}
+	out.FromStatfsT(&total)
+	return 0
+}
+
+// addStatfs adds the space of s to total, in the block size of the
+// first file system added.
+func addStatfs(total, s *syscall.Statfs_t) {
+	if total.Bsize == 0 {
+		*total = *s
+		return
+	}
+	scale := func(blocks uint64) uint64 {
+		return blocks * uint64(s.Bsize) / uint64(total.Bsize)
+	}
+	total.Blocks += scale(s.Blocks)
+	total.Bfree += scale(s.Bfree)
+	total.Bavail += scale(s.Bavail)
+	total.Files += s.Files
+	total.Ffree += s.Ffree
+	if s.Namelen < total.Namelen {
+		total.Namelen = s.Namelen
+	}
+}
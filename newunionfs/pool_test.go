@@ -0,0 +1,209 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unionfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/internal/testutil"
+)
+
+// newPoolTestCase mounts a pool of three branches, which are
+// populated with files. Names ending in a slash are directories.
+func newPoolTestCase(t *testing.T, files map[string]string, opts *PoolOptions) (mnt string, branches []string) {
+	t.Helper()
+	dir := t.TempDir()
+	mnt = filepath.Join(dir, "mnt")
+	for i := 0; i < 3; i++ {
+		branches = append(branches, filepath.Join(dir, fmt.Sprintf("b%d", i)))
+	}
+	for _, d := range append([]string{mnt}, branches...) {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mountOpts := &fs.Options{}
+	mountOpts.Debug = testutil.VerboseTest()
+	server, err := fs.Mount(mnt, NewPoolFS(branches, opts), mountOpts)
+	if err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+	t.Cleanup(func() { server.Unmount() })
+	return mnt, branches
+}
+
+// branchesWith returns the indexes of the branches that have p.
+func branchesWith(branches []string, p string) []int {
+	var result []int
+	for i, b := range branches {
+		if _, err := os.Lstat(filepath.Join(b, p)); err == nil {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+func TestPool(t *testing.T) {
+	mnt, branches := newPoolTestCase(t, map[string]string{
+		"b0/dir/a":   "a0",
+		"b1/dir/b":   "b1",
+		"b2/dir/a":   "a2",
+		"b1/only1/x": "x",
+	}, nil)
+
+	for d, want := range map[string]map[string]bool{
+		"":    {"dir": true, "only1": true},
+		"dir": {"a": true, "b": true},
+	} {
+		if got := readDirNames(t, filepath.Join(mnt, d)); !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadDir %q: got %v, want %v", d, got, want)
+		}
+	}
+	if got, err := os.ReadFile(mnt + "/dir/a"); err != nil || string(got) != "a0" {
+		t.Errorf("ReadFile: got %q, %v", got, err)
+	}
+
+	// Existing files are changed in place.
+	f, err := os.OpenFile(mnt+"/dir/b", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("+"))
+	f.Close()
+	if got, err := os.ReadFile(branches[1] + "/dir/b"); err != nil || string(got) != "b1+" {
+		t.Errorf("modified file: got %q, %v", got, err)
+	}
+	if err := os.Chmod(mnt+"/dir/a", 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{0, 2} {
+		if fi, err := os.Stat(branches[i] + "/dir/a"); err != nil || fi.Mode().Perm() != 0600 {
+			t.Errorf("chmod in branch %d: got %v, %v", i, fi, err)
+		}
+	}
+
+	// The default policy creates files next to their siblings.
+	if err := os.WriteFile(mnt+"/only1/new", []byte("n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := branchesWith(branches, "only1/new"); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("new file in branches %v, want [1]", got)
+	}
+
+	// Renames and links stay within the branches.
+	if err := os.Rename(mnt+"/dir/a", mnt+"/only1/a"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if got := branchesWith(branches, "only1/a"); !reflect.DeepEqual(got, []int{0, 2}) {
+		t.Errorf("renamed file in branches %v, want [0 2]", got)
+	}
+	if fi, err := os.Stat(branches[0] + "/only1"); err != nil || !fi.IsDir() {
+		t.Errorf("directory was not cloned: %v, %v", fi, err)
+	}
+	if err := os.Link(mnt+"/dir/b", mnt+"/only1/b"); err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+	if got := branchesWith(branches, "only1/b"); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("link in branches %v, want [1]", got)
+	}
+
+	if err := os.Remove(mnt + "/only1/a"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if got := branchesWith(branches, "only1/a"); got != nil {
+		t.Errorf("removed file in branches %v", got)
+	}
+
+	// All branches are on the same device, so they are counted
+	// once.
+	var got, want syscall.Statfs_t
+	if err := syscall.Statfs(mnt, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Statfs(branches[0], &want); err != nil {
+		t.Fatal(err)
+	}
+	if got.Blocks != want.Blocks {
+		t.Errorf("Statfs: got %d blocks, want %d", got.Blocks, want.Blocks)
+	}
+}
+
+func TestPoolRmdir(t *testing.T) {
+	mnt, branches := newPoolTestCase(t, map[string]string{
+		"b1/dir/x": "x",
+	}, nil)
+	for _, i := range []int{0, 2} {
+		if err := os.Mkdir(branches[i]+"/dir", 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The directory is only empty in some branches, so it is kept
+	// in all of them.
+	if err := os.Remove(mnt + "/dir"); !errors.Is(err, syscall.ENOTEMPTY) {
+		t.Fatalf("Remove: got %v, want ENOTEMPTY", err)
+	}
+	if got := branchesWith(branches, "dir"); !reflect.DeepEqual(got, []int{0, 1, 2}) {
+		t.Errorf("directory in branches %v, want [0 1 2]", got)
+	}
+
+	if err := os.Remove(mnt + "/dir/x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(mnt + "/dir"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if got := branchesWith(branches, "dir"); got != nil {
+		t.Errorf("removed directory in branches %v", got)
+	}
+}
+
+func TestPoolRoundRobin(t *testing.T) {
+	mnt, branches := newPoolTestCase(t, map[string]string{
+		"b1/dir/x": "x",
+	}, &PoolOptions{Create: &RoundRobin{}})
+	if err := os.Chmod(branches[1]+"/dir", 0700); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		p := fmt.Sprintf("dir/%d", i)
+		if err := os.WriteFile(filepath.Join(mnt, p), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := branchesWith(branches, p); !reflect.DeepEqual(got, []int{i}) {
+			t.Errorf("%s in branches %v, want [%d]", p, got, i)
+		}
+	}
+	if fi, err := os.Stat(branches[2] + "/dir"); err != nil || fi.Mode().Perm() != 0700 {
+		t.Errorf("cloned directory: got %v, %v", fi, err)
+	}
+}
+
+func TestAddStatfs(t *testing.T) {
+	var total syscall.Statfs_t
+	addStatfs(&total, &syscall.Statfs_t{Bsize: 4096, Blocks: 10, Bfree: 4, Bavail: 2, Files: 5, Namelen: 255})
+	addStatfs(&total, &syscall.Statfs_t{Bsize: 1024, Blocks: 8, Bfree: 8, Bavail: 4, Files: 1, Namelen: 100})
+	want := syscall.Statfs_t{Bsize: 4096, Blocks: 12, Bfree: 6, Bavail: 3, Files: 6, Namelen: 100}
+	if total != want {
+		t.Errorf("got %+v, want %+v", total, want)
+	}
+}
@@ -8,14 +8,17 @@ import (
 	"context"
 	"crypto/md5"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"syscall"
 
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
+	"golang.org/x/sys/unix"
 )
 
 func filePathHash(path string) string {
@@ -29,19 +32,116 @@ func filePathHash(path string) string {
 type unionFSRoot struct {
 	unionFSNode
 
-	roots []string
+	// upper is the directory of the writable branch. It is also
+	// branches[0].
+	upper    string
+	branches []Branch
+	opts     Options
+}
+
+// Whiteouts selects how the writable branch records the files of the
+// read-only branches that were deleted.
+type Whiteouts int
+
+const (
+	// DeletionMarkers stores deletions and directory renames in
+	// the DELETIONS and REDIRECTS directories of the writable
+	// branch.
+	DeletionMarkers Whiteouts = iota
+
+	// OverlayWhiteouts uses the format of Linux overlayfs:
+	// deleted files are character devices with device number
+	// 0/0, and directories are marked opaque or redirected with
+	// the overlay.opaque and overlay.redirect extended
+	// attributes. Creating the devices needs CAP_MKNOD.
+	OverlayWhiteouts
+
+	// AUFSWhiteouts uses the format of AUFS and OCI image layers:
+	// a file name is deleted by an empty file .wh.name, and a
+	// directory is opaque if it contains .wh..wh..opq. Renamed
+	// directories are copied to the writable branch entirely.
+	AUFSWhiteouts
+)
+
+// Options configures a union file system.
+type Options struct {
+	Whiteouts Whiteouts
+
+	// UserXattr uses the user.overlay.* extended attributes
+	// rather than trusted.overlay.*, as overlayfs does with the
+	// userxattr mount option. Setting trusted.* attributes needs
+	// CAP_SYS_ADMIN. As with AUFS whiteouts, renamed directories
+	// are then copied to the writable branch entirely.
+	UserXattr bool
+
+	// MetaCopy copies regular files of the read-only branches
+	// to the writable branch without their data if only their
+	// attributes change, as overlayfs does with the metacopy
+	// mount option. The data is copied when the file is first
+	// opened for writing. The overlay.metacopy and
+	// overlay.redirect extended attributes of the copies are
+	// hidden from the union, whatever the whiteout format.
+	MetaCopy bool
+}
+
+// NewUnionFS returns the root of a union of the directories roots.
+// The first one is writable, and takes precedence over the others,
+// which are read-only.
+func NewUnionFS(roots []string, opts *Options) fs.InodeEmbedder {
+	var lower []Branch
+	for _, root := range roots[1:] {
+		lower = append(lower, NewDirBranch(root))
+	}
+	return NewBranchUnionFS(roots[0], lower, opts)
+}
+
+// NewBranchUnionFS returns the root of a union of the writable
+// directory upper and the read-only branches lower, the highest
+// first.
+func NewBranchUnionFS(upper string, lower []Branch, opts *Options) fs.InodeEmbedder {
+	r := &unionFSRoot{
+		upper:    upper,
+		branches: append([]Branch{NewDirBranch(upper)}, lower...),
+	}
+	if opts != nil {
+		r.opts = *opts
+	}
+	return r
 }
 
 type unionFSNode struct {
 	fs.Inode
 }
 
+// delDir holds the deletion markers, which hide files of the
+// read-only branches. Each marker is named by the hash of the path it
+// hides, and contains the path.
 const delDir = "DELETIONS"
 
+// redirDir holds the redirects of directories that were renamed while
+// they had contents in the read-only branches. A redirect is named by
+// the hash of the new path, and contains the new path and the path in
+// the read-only branches, separated by a NUL byte.
+const redirDir = "REDIRECTS"
+
 var delDirHash = filePathHash(delDir)
+var redirDirHash = filePathHash(redirDir)
+
+// reserved returns true if name is reserved for metadata in the
+// directory n.
+func (n *unionFSNode) reserved(name string) bool {
+	return n.root().reserved(n.IsRoot(), name)
+}
+
+func (r *unionFSRoot) reserved(isRoot bool, name string) bool {
+	if r.opts.Whiteouts != DeletionMarkers {
+		return strings.HasPrefix(name, whiteoutPrefix)
+	}
+	return isRoot && (name == delDir || name == redirDir)
+}
 
 func (r *unionFSRoot) allMarkers(result map[string]struct{}) syscall.Errno {
-	dir := filepath.Join(r.roots[0], delDir)
+	dir := filepath.Join(r.upper, delDir)
 
 	ds, errno := fs.NewLoopbackDirStream(dir)
 	if errno != 0 {
@@ -63,7 +163,12 @@ func (r *unionFSRoot) allMarkers(result map[string]struct{}) syscall.Errno {
 	return 0
 }
 
+// rmMarker removes the deletion marker or whiteout for name. It
+// returns ENOENT if there is none.
 func (r *unionFSRoot) rmMarker(name string) syscall.Errno {
+	if r.opts.Whiteouts != DeletionMarkers {
+		return r.rmWhiteout(name)
+	}
 	err := syscall.Unlink(r.markerPath(name))
 	if err != nil {
 		return err.(syscall.Errno)
@@ -71,8 +176,17 @@ func (r *unionFSRoot) rmMarker(name string) syscall.Errno {
 	return 0
 }
 
+// writeMarker hides name of the read-only branches.
 func (r *unionFSRoot) writeMarker(name string) syscall.Errno {
-	dir := filepath.Join(r.roots[0], delDir)
+	if r.opts.Whiteouts != DeletionMarkers {
+		return r.writeWhiteout(name)
+	}
+	return r.writeMeta(delDir, name, []byte(name))
+}
+
+// writeMeta stores a deletion marker or redirect for name.
+func (r *unionFSRoot) writeMeta(metaDir, name string, content []byte) syscall.Errno {
+	dir := filepath.Join(r.upper, metaDir)
 	var st syscall.Stat_t
 	if err := syscall.Stat(dir, &st); err == syscall.ENOENT {
 		if err := syscall.Mkdir(dir, 0755); err != nil {
@@ -83,14 +197,187 @@ func (r *unionFSRoot) writeMarker(name string) syscall.Errno {
 		return err.(syscall.Errno)
 	}
 
-	dest := r.markerPath(name)
+	dest := filepath.Join(dir, filePathHash(name))
 
-	err := os.WriteFile(dest, []byte(name), 0644)
+	err := os.WriteFile(dest, content, 0644)
 	return fs.ToErrno(err)
 }
 
+// forEachMeta calls fn with the path and the file of each deletion
+// marker or redirect that applies to p or a path below it, or to any
+// path if p is "". The directory is read completely before fn is
+// called, so fn may change it.
+func (r *unionFSRoot) forEachMeta(metaDir, p string, fn func(name, file string, content []byte)) {
+	dir := filepath.Join(r.upper, metaDir)
+	ds, errno := fs.NewLoopbackDirStream(dir)
+	if errno != 0 {
+		return
+	}
+	var files []string
+	for ds.HasNext() {
+		e, errno := ds.Next()
+		if errno != 0 {
+			break
+		}
+		if e.Mode == syscall.S_IFREG {
+			files = append(files, filepath.Join(dir, e.Name))
+		}
+	}
+	ds.Close()
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		name, _, _ := strings.Cut(string(content), "\x00")
+		if p == "" || name == p || strings.HasPrefix(name, p+"/") {
+			fn(name, file, content)
+		}
+	}
+}
+
+// dropMeta removes the deletion markers or redirects below p, and for
+// p itself if self is set.
+func (r *unionFSRoot) dropMeta(metaDir, p string, self bool) {
+	r.forEachMeta(metaDir, p, func(name, file string, content []byte) {
+		if self || name != p {
+			syscall.Unlink(file)
+		}
+	})
+}
+
+// renameMeta moves the deletion markers or redirects for old and the
+// paths below it to new.
+func (r *unionFSRoot) renameMeta(metaDir, old, new string) syscall.Errno {
+	var errno syscall.Errno
+	r.forEachMeta(metaDir, old, func(name, file string, content []byte) {
+		name = new + name[len(old):]
+		if _, rest, ok := strings.Cut(string(content), "\x00"); ok {
+			content = []byte(name + "\x00" + rest)
+		} else {
+			content = []byte(name)
+		}
+		if e := r.writeMeta(metaDir, name, content); e != 0 {
+			errno = e
+			return
+		}
+		syscall.Unlink(file)
+	})
+	return errno
+}
+
+// redirect returns the path in the read-only branches for the renamed
+// directory name.
+func (r *unionFSRoot) redirect(name string) (string, bool) {
+	content, err := os.ReadFile(filepath.Join(r.upper, redirDir, filePathHash(name)))
+	if err != nil {
+		return "", false
+	}
+	upper, lower, ok := strings.Cut(string(content), "\x00")
+	if !ok || upper != name {
+		return "", false
+	}
+	return lower, true
+}
+
+// branchEntry is a file of the union as found in one branch.
+type branchEntry struct {
+	idx  int
+	path string
+	attr fuse.Attr
+}
+
+// resolve finds the path name of the union in the branches, the
+// highest first. Only directories are merged, so an entry that is
+// not a directory is the only one. Lookups in lower branches stop at
+// whiteouts and opaque directories, and follow the redirects of
+// renamed directories. It returns nil if name does not exist.
+func (r *unionFSRoot) resolve(name string) []branchEntry {
+	return r.resolveFrom(name, 0)
+}
+
+// resolveFrom is like resolve, but ignores the branches before from.
+func (r *unionFSRoot) resolveFrom(name string, from int) []branchEntry {
+	var stack []branchEntry
+	for i := from; i < len(r.branches); i++ {
+		b := r.branches[i]
+		e := branchEntry{idx: i}
+		if b.Lstat("", &e.attr) == 0 {
+			stack = append(stack, e)
+		}
+	}
+	if name == "" {
+		return stack
+	}
+
+	upper := ""
+	for _, c := range strings.Split(name, "/") {
+		upper = filepath.Join(upper, c)
+		if from == 0 && r.opts.Whiteouts == DeletionMarkers && r.isDeleted(upper) {
+			return nil
+		}
+
+		parents := map[int]string{}
+		for _, e := range stack {
+			parents[e.idx] = e.path
+		}
+		var next []branchEntry
+		redirected := false
+		target := ""
+		for i := from; i < len(r.branches); i++ {
+			b := r.branches[i]
+			e := branchEntry{idx: i}
+			if redirected {
+				e.path = target
+			} else if parent, ok := parents[i]; ok {
+				e.path = filepath.Join(parent, c)
+			} else {
+				continue
+			}
+
+			errno := b.Lstat(e.path, &e.attr)
+			if r.isWhiteout(i, e.path, &e.attr, errno) {
+				break
+			}
+			if errno != 0 {
+				continue
+			}
+			isDir := e.attr.Mode&syscall.S_IFMT == syscall.S_IFDIR
+			if len(next) > 0 && !isDir {
+				break
+			}
+			next = append(next, e)
+			if !isDir || r.isOpaque(i, e.path) {
+				break
+			}
+			if t, ok := r.layerRedirect(i, upper, e.path); ok {
+				redirected = true
+				target = t
+			}
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		stack = next
+	}
+	return stack
+}
+
+// branchPath returns the path of name in branch idx.
+func (r *unionFSRoot) branchPath(idx int, name string) string {
+	if idx > 0 {
+		for _, e := range r.resolve(name) {
+			if e.idx == idx {
+				return e.path
+			}
+		}
+	}
+	return name
+}
+
 func (r *unionFSRoot) markerPath(name string) string {
-	return filepath.Join(r.roots[0], delDir, filePathHash(name))
+	return filepath.Join(r.upper, delDir, filePathHash(name))
 }
 
 func (r *unionFSRoot) isDeleted(name string) bool {
@@ -106,80 +393,87 @@ func (n *unionFSNode) root() *unionFSRoot {
 var _ = (fs.NodeSetattrer)((*unionFSNode)(nil))
 
 func (n *unionFSNode) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
-	if errno := n.promote(); errno != 0 {
+	r := n.root()
+	nm, idx := n.getBranch(nil)
+	if fsa, ok := fh.(fs.FileSetattrer); ok && idx == 0 {
+		return fsa.Setattr(ctx, in, out)
+	}
+
+	// Only truncation needs the data.
+	if _, ok := in.GetSize(); ok {
+		if errno := n.promote(); errno != 0 {
+			return errno
+		}
+		if errno := r.copyUpData(nm, false); errno != 0 {
+			return errno
+		}
+	} else if errno := r.promoteMeta(nm); errno != 0 {
 		return errno
 	}
 
-	if fh != nil {
-		return fh.(fs.FileSetattrer).Setattr(ctx, in, out)
+	p := filepath.Join(r.upper, nm)
+	if errno := setattrPath(p, in); errno != 0 {
+		return errno
 	}
 
-	p := filepath.Join(n.root().roots[0], n.Path(nil))
-	fsa, ok := fh.(fs.FileSetattrer)
-	if ok && fsa != nil {
-		fsa.Setattr(ctx, in, out)
-	} else {
-		if m, ok := in.GetMode(); ok {
-			if err := syscall.Chmod(p, m); err != nil {
-				return fs.ToErrno(err)
-			}
-		}
+	st := syscall.Stat_t{}
+	if err := syscall.Lstat(p, &st); err != nil {
+		return fs.ToErrno(err)
+	}
+	out.FromStat(&st)
+	return 0
+}
 
-		uid, uok := in.GetUID()
-		gid, gok := in.GetGID()
-		if uok || gok {
-			suid := -1
-			sgid := -1
-			if uok {
-				suid = int(uid)
-			}
-			if gok {
-				sgid = int(gid)
-			}
-			if err := syscall.Chown(p, suid, sgid); err != nil {
-				return fs.ToErrno(err)
-			}
+// setattrPath changes the attributes of the file p as requested by
+// in.
+func setattrPath(p string, in *fuse.SetAttrIn) syscall.Errno {
+	if m, ok := in.GetMode(); ok {
+		if err := syscall.Chmod(p, m); err != nil {
+			return fs.ToErrno(err)
 		}
+	}
 
-		mtime, mok := in.GetMTime()
-		atime, aok := in.GetATime()
-
-		if mok || aok {
+	uid, uok := in.GetUID()
+	gid, gok := in.GetGID()
+	if uok || gok {
+		suid := -1
+		sgid := -1
+		if uok {
+			suid = int(uid)
+		}
+		if gok {
+			sgid = int(gid)
+		}
+		if err := syscall.Lchown(p, suid, sgid); err != nil {
+			return fs.ToErrno(err)
+		}
+	}
 
-			ap := &atime
-			mp := &mtime
-			if !aok {
-				ap = nil
-			}
-			if !mok {
-				mp = nil
-			}
-			var ts [2]syscall.Timespec
-			ts[0] = fuse.UtimeToTimespec(ap)
-			ts[1] = fuse.UtimeToTimespec(mp)
+	mtime, mok := in.GetMTime()
+	atime, aok := in.GetATime()
 
-			if err := syscall.UtimesNano(p, ts[:]); err != nil {
-				return fs.ToErrno(err)
-			}
+	if mok || aok {
+		ap := &atime
+		mp := &mtime
+		if !aok {
+			ap = nil
+		}
+		if !mok {
+			mp = nil
 		}
+		var ts [2]unix.Timespec
+		ts[0] = unix.Timespec(fuse.UtimeToTimespec(ap))
+		ts[1] = unix.Timespec(fuse.UtimeToTimespec(mp))
 
-		if sz, ok := in.GetSize(); ok {
-			if err := syscall.Truncate(p, int64(sz)); err != nil {
-				return fs.ToErrno(err)
-			}
+		if err := unix.UtimesNanoAt(unix.AT_FDCWD, p, ts[:], unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			return fs.ToErrno(err)
 		}
 	}
 
-	fga, ok := fh.(fs.FileGetattrer)
-	if ok && fga != nil {
-		fga.Getattr(ctx, out)
-	} else {
-		st := syscall.Stat_t{}
-		err := syscall.Lstat(p, &st)
-		if err != nil {
+	if sz, ok := in.GetSize(); ok {
+		if err := syscall.Truncate(p, int64(sz)); err != nil {
 			return fs.ToErrno(err)
 		}
-		out.FromStat(&st)
 	}
 	return 0
 }
@@ -187,12 +481,11 @@ func (n *unionFSNode) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.Se
 var _ = (fs.NodeCreater)((*unionFSNode)(nil))
 
 func (n *unionFSNode) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
-	if n.IsRoot() && name == delDir {
+	if n.reserved(name) {
 		return nil, nil, 0, syscall.EPERM
 	}
 
-	var st syscall.Stat_t
-	dirName, idx := n.getBranch(&st)
+	dirName, idx := n.getBranch(nil)
 	if idx > 0 {
 		if errno := n.promote(); errno != 0 {
 			return nil, nil, 0, errno
@@ -204,13 +497,17 @@ func (n *unionFSNode) Create(ctx context.Context, name string, flags uint32, mod
 	if errno := r.rmMarker(fullPath); errno != 0 && errno != syscall.ENOENT {
 		return nil, nil, 0, errno
 	}
+	if errno := r.copyUpData(fullPath, true); errno != 0 {
+		return nil, nil, 0, errno
+	}
 
-	abs := filepath.Join(n.root().roots[0], fullPath)
+	abs := filepath.Join(n.root().upper, fullPath)
 	fd, err := syscall.Creat(abs, mode)
 	if err != nil {
 		return nil, nil, 0, err.(syscall.Errno)
 	}
 
+	var st syscall.Stat_t
 	if err := syscall.Fstat(fd, &st); err != nil {
 		// now what?
 		syscall.Close(fd)
@@ -224,56 +521,242 @@ func (n *unionFSNode) Create(ctx context.Context, name string, flags uint32, mod
 	return ch, fs.NewLoopbackFile(fd), 0, 0
 }
 
+// prepareCreate readies the writable branch for a new entry name in
+// n: it copies up n, and removes the deletion marker for name. It
+// returns the path of the new entry in the union.
+func (n *unionFSNode) prepareCreate(name string) (string, syscall.Errno) {
+	if n.reserved(name) {
+		return "", syscall.EPERM
+	}
+	r := n.root()
+	p := filepath.Join(n.Path(nil), name)
+	if r.getBranch(p, nil) >= 0 {
+		return "", syscall.EEXIST
+	}
+	if errno := n.promote(); errno != 0 {
+		return "", errno
+	}
+	if errno := r.rmMarker(p); errno != 0 && errno != syscall.ENOENT {
+		return "", errno
+	}
+	return p, 0
+}
+
+// newChild returns the node for the new entry p in the writable
+// branch.
+func (n *unionFSNode) newChild(ctx context.Context, p string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if errno := n.root().branches[0].Lstat(p, &out.Attr); errno != 0 {
+		return nil, errno
+	}
+	return n.NewInode(ctx, &unionFSNode{}, fs.StableAttr{Mode: out.Mode, Ino: out.Ino}), 0
+}
+
+var _ = (fs.NodeMkdirer)((*unionFSNode)(nil))
+
+func (n *unionFSNode) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	p, errno := n.prepareCreate(name)
+	if errno != 0 {
+		return nil, errno
+	}
+	r := n.root()
+	if err := syscall.Mkdir(filepath.Join(r.upper, p), mode); err != nil {
+		return nil, err.(syscall.Errno)
+	}
+	// The name may have been deleted from the read-only branches,
+	// whose entries must not show through.
+	if errno := r.hideLower(p); errno != 0 {
+		return nil, errno
+	}
+	return n.newChild(ctx, p, out)
+}
+
+var _ = (fs.NodeMknoder)((*unionFSNode)(nil))
+
+func (n *unionFSNode) Mknod(ctx context.Context, name string, mode uint32, dev uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	p, errno := n.prepareCreate(name)
+	if errno != 0 {
+		return nil, errno
+	}
+	if err := syscall.Mknod(filepath.Join(n.root().upper, p), mode, int(dev)); err != nil {
+		return nil, err.(syscall.Errno)
+	}
+	return n.newChild(ctx, p, out)
+}
+
+var _ = (fs.NodeLinker)((*unionFSNode)(nil))
+
+func (n *unionFSNode) Link(ctx context.Context, target fs.InodeEmbedder, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	p, errno := n.prepareCreate(name)
+	if errno != 0 {
+		return nil, errno
+	}
+	r := n.root()
+	targetPath := target.EmbeddedInode().Path(nil)
+	if errno := r.promote(targetPath); errno != 0 {
+		return nil, errno
+	}
+	if err := syscall.Link(filepath.Join(r.upper, targetPath), filepath.Join(r.upper, p)); err != nil {
+		return nil, err.(syscall.Errno)
+	}
+	return n.newChild(ctx, p, out)
+}
+
+var _ = (fs.NodeRenamer)((*unionFSNode)(nil))
+
+func (n *unionFSNode) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	if flags&fs.RENAME_EXCHANGE != 0 {
+		return syscall.EINVAL
+	}
+	newDir := newParent.EmbeddedInode()
+	r := n.root()
+	if n.reserved(name) || r.reserved(newDir.IsRoot(), newName) {
+		return syscall.EPERM
+	}
+
+	oldPath := filepath.Join(n.Path(nil), name)
+	newPath := filepath.Join(newDir.Path(nil), newName)
+	var st, dst fuse.Attr
+	if r.getBranch(oldPath, &st) < 0 {
+		return syscall.ENOENT
+	}
+	isDir := st.Mode&syscall.S_IFMT == syscall.S_IFDIR
+	if r.getBranch(newPath, &dst) >= 0 {
+		if flags&fs.RENAME_NOREPLACE != 0 {
+			return syscall.EEXIST
+		}
+		dstDir := dst.Mode&syscall.S_IFMT == syscall.S_IFDIR
+		if isDir && !dstDir {
+			return syscall.ENOTDIR
+		}
+		if !isDir && dstDir {
+			return syscall.EISDIR
+		}
+		if dstDir && len(r.readDir(newPath)) > 0 {
+			return syscall.ENOTEMPTY
+		}
+	}
+
+	// A directory with contents in the read-only branches is
+	// redirected to them, rather than copied up recursively, unless
+	// the format has no redirects.
+	lower := ""
+	if isDir && !r.hasRedirects() {
+		if errno := r.promoteTree(oldPath); errno != 0 {
+			return errno
+		}
+	} else if isDir {
+		lower = r.lowerDir(oldPath)
+	}
+	if errno := r.promote(oldPath); errno != 0 {
+		return errno
+	}
+	if errno := r.promote(filepath.Dir(newPath)); errno != 0 {
+		return errno
+	}
+	if errno := r.rmMarker(newPath); errno != 0 && errno != syscall.ENOENT {
+		return errno
+	}
+	if isDir && r.opts.Whiteouts != DeletionMarkers {
+		r.clearWhiteouts(newPath)
+	}
+	if err := syscall.Rename(filepath.Join(r.upper, oldPath), filepath.Join(r.upper, newPath)); err != nil {
+		return err.(syscall.Errno)
+	}
+
+	if isDir && r.opts.Whiteouts == DeletionMarkers {
+		// The whiteouts of the other formats move along with
+		// the directory.
+		r.dropMeta(delDir, newPath, false)
+		r.dropMeta(redirDir, newPath, true)
+		if errno := r.renameMeta(delDir, oldPath, newPath); errno != 0 {
+			return errno
+		}
+		if errno := r.renameMeta(redirDir, oldPath, newPath); errno != 0 {
+			return errno
+		}
+	}
+	if isDir {
+		if lower != "" {
+			if errno := r.setRedirect(newPath, lower); errno != 0 {
+				return errno
+			}
+		} else if errno := r.hideLower(newPath); errno != 0 {
+			return errno
+		}
+	}
+
+	// The read-only branches may still have the old name.
+	if r.getBranch(oldPath, nil) >= 0 {
+		return r.writeMarker(oldPath)
+	}
+	return 0
+}
+
 var _ = (fs.NodeOpener)((*unionFSNode)(nil))
 
 func (n *unionFSNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
 	isWR := (flags&syscall.O_RDWR != 0) || (flags&syscall.O_WRONLY != 0)
 
-	var st syscall.Stat_t
-	nm, idx := n.getBranch(&st)
-	if isWR && idx > 0 {
-		if errno := n.promote(); errno != 0 {
+	nm, idx := n.getBranch(nil)
+	if idx < 0 {
+		return nil, 0, syscall.ENOENT
+	}
+	r := n.root()
+	if !isWR && idx == 0 {
+		dataIdx, p, errno := r.dataPath(nm)
+		if errno != 0 {
 			return nil, 0, errno
 		}
-		idx = 0
+		if dataIdx > 0 {
+			fh, errno := r.branches[dataIdx].Open(p, flags)
+			if errno != 0 {
+				return nil, 0, errno
+			}
+			return &dataFile{fh}, 0, 0
+		}
 	}
-
-	fd, err := syscall.Open(filepath.Join(n.root().roots[idx], nm), int(flags), 0)
-	if err != nil {
-		return nil, 0, err.(syscall.Errno)
+	if isWR {
+		if idx > 0 {
+			if errno := n.promote(); errno != 0 {
+				return nil, 0, errno
+			}
+		}
+		if errno := r.copyUpData(nm, flags&syscall.O_TRUNC != 0); errno != 0 {
+			return nil, 0, errno
+		}
+		idx = 0
 	}
 
-	return fs.NewLoopbackFile(fd), 0, 0
+	fh, errno := r.branches[idx].Open(r.branchPath(idx, nm), flags)
+	return fh, 0, errno
 }
 
 var _ = (fs.NodeGetattrer)((*unionFSNode)(nil))
 
 func (n *unionFSNode) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	var st syscall.Stat_t
-	_, idx := n.getBranch(&st)
+	if fga, ok := fh.(fs.FileGetattrer); ok && fga != nil {
+		return fga.Getattr(ctx, out)
+	}
+
+	_, idx := n.getBranch(&out.Attr)
 	if idx < 0 {
 		return syscall.ENOENT
 	}
-
-	out.FromStat(&st)
 	return 0
 }
 
 var _ = (fs.NodeLookuper)((*unionFSNode)(nil))
 
 func (n *unionFSNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	if n.IsRoot() && name == delDir {
+	if n.reserved(name) {
 		return nil, syscall.ENOENT
 	}
 
-	var st syscall.Stat_t
-
 	p := filepath.Join(n.Path(nil), name)
-	idx := n.root().getBranch(p, &st)
+	idx := n.root().getBranch(p, &out.Attr)
 	if idx >= 0 {
 		// XXX use idx in Ino?
-		ch := n.NewInode(ctx, &unionFSNode{}, fs.StableAttr{Mode: st.Mode, Ino: st.Ino})
-		out.FromStat(&st)
+		ch := n.NewInode(ctx, &unionFSNode{}, fs.StableAttr{Mode: out.Mode, Ino: out.Ino})
 		out.Mode |= 0111
 		return ch, 0
 	}
@@ -289,63 +772,188 @@ func (n *unionFSNode) Unlink(ctx context.Context, name string) syscall.Errno {
 var _ = (fs.NodeRmdirer)((*unionFSNode)(nil))
 
 func (n *unionFSNode) Rmdir(ctx context.Context, name string) syscall.Errno {
-	return n.root().delPath(filepath.Join(n.Path(nil), name))
+	r := n.root()
+	p := filepath.Join(n.Path(nil), name)
+
+	var attr fuse.Attr
+	idx := r.getBranch(p, &attr)
+	if idx < 0 {
+		return syscall.ENOENT
+	}
+	if attr.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		return syscall.ENOTDIR
+	}
+	if len(r.readDir(p)) > 0 {
+		return syscall.ENOTEMPTY
+	}
+	if idx == 0 {
+		// Entries of the writable branch are never hidden, so
+		// the directory is empty there, apart from whiteouts.
+		if r.opts.Whiteouts != DeletionMarkers {
+			r.clearWhiteouts(p)
+		}
+		if err := syscall.Rmdir(filepath.Join(r.upper, p)); err != nil {
+			return err.(syscall.Errno)
+		}
+	}
+	if r.opts.Whiteouts == DeletionMarkers {
+		r.dropMeta(delDir, p, false)
+		r.dropMeta(redirDir, p, true)
+	}
+	if r.getBranch(p, nil) >= 0 {
+		return r.writeMarker(p)
+	}
+	return 0
 }
 
 var _ = (fs.NodeSymlinker)((*unionFSNode)(nil))
 
 func (n *unionFSNode) Symlink(ctx context.Context, target, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	n.promote()
-	path := filepath.Join(n.root().roots[0], n.Path(nil), name)
-	err := syscall.Symlink(target, path)
-
-	if err != nil {
+	p, errno := n.prepareCreate(name)
+	if errno != 0 {
+		return nil, errno
+	}
+	if err := syscall.Symlink(target, filepath.Join(n.root().upper, p)); err != nil {
 		return nil, err.(syscall.Errno)
 	}
+	return n.newChild(ctx, p, out)
+}
 
-	var st syscall.Stat_t
-	if err := syscall.Lstat(path, &st); err != nil {
-		return nil, err.(syscall.Errno)
+var _ = (fs.NodeReadlinker)((*unionFSNode)(nil))
+
+func (n *unionFSNode) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
+	nm, idx := n.getBranch(nil)
+	if idx < 0 {
+		return nil, syscall.ENOENT
 	}
 
-	out.FromStat(&st)
+	r := n.root()
+	return r.branches[idx].Readlink(r.branchPath(idx, nm))
+}
 
-	ch := n.NewInode(ctx, &unionFSNode{}, fs.StableAttr{
-		Mode: syscall.S_IFLNK,
-		Ino:  st.Ino,
-	})
-	return ch, 0
+var _ = (fs.NodeGetxattrer)((*unionFSNode)(nil))
+
+func (n *unionFSNode) Getxattr(ctx context.Context, attr string, dest []byte) (uint32, syscall.Errno) {
+	if n.root().isPrivateXattr(attr) {
+		return 0, syscall.ENODATA
+	}
+	nm, idx := n.getBranch(nil)
+	if idx < 0 {
+		return 0, syscall.ENOENT
+	}
+	r := n.root()
+	return r.branches[idx].Getxattr(r.branchPath(idx, nm), attr, dest)
 }
 
-var _ = (fs.NodeReadlinker)((*unionFSNode)(nil))
+var _ = (fs.NodeListxattrer)((*unionFSNode)(nil))
 
-func (n *unionFSNode) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
+func (n *unionFSNode) Listxattr(ctx context.Context, dest []byte) (uint32, syscall.Errno) {
 	nm, idx := n.getBranch(nil)
+	if idx < 0 {
+		return 0, syscall.ENOENT
+	}
+	r := n.root()
+	b := r.branches[idx]
+	p := r.branchPath(idx, nm)
+	if r.opts.Whiteouts == DeletionMarkers && !r.opts.MetaCopy {
+		return b.Listxattr(p, dest)
+	}
 
-	var buf [1024]byte
-	count, err := syscall.Readlink(filepath.Join(n.root().roots[idx], nm), buf[:])
-	if err != nil {
-		return nil, err.(syscall.Errno)
+	var list []byte
+	for _, attr := range listXattrs(b, p) {
+		if !r.isPrivateXattr(attr) {
+			list = append(append(list, attr...), 0)
+		}
+	}
+	if len(dest) == 0 {
+		return uint32(len(list)), 0
+	}
+	if len(list) > len(dest) {
+		return uint32(len(list)), syscall.ERANGE
+	}
+	return uint32(copy(dest, list)), 0
+}
+
+var _ = (fs.NodeSetxattrer)((*unionFSNode)(nil))
+
+func (n *unionFSNode) Setxattr(ctx context.Context, attr string, data []byte, flags uint32) syscall.Errno {
+	if n.root().isPrivateXattr(attr) {
+		return syscall.EPERM
+	}
+	if errno := n.promote(); errno != 0 {
+		return errno
 	}
+	err := unix.Lsetxattr(filepath.Join(n.root().upper, n.Path(nil)), attr, data, int(flags))
+	return fs.ToErrno(err)
+}
+
+var _ = (fs.NodeRemovexattrer)((*unionFSNode)(nil))
 
-	return buf[:count], 0
+func (n *unionFSNode) Removexattr(ctx context.Context, attr string) syscall.Errno {
+	if n.root().isPrivateXattr(attr) {
+		return syscall.EPERM
+	}
+	if errno := n.promote(); errno != 0 {
+		return errno
+	}
+	err := unix.Lremovexattr(filepath.Join(n.root().upper, n.Path(nil)), attr)
+	return fs.ToErrno(err)
 }
 
 var _ = (fs.NodeReaddirer)((*unionFSNode)(nil))
 
 func (n *unionFSNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
-	root := n.root()
+	return fs.NewListDirStream(n.root().readDir(n.Path(nil))), 0
+}
 
-	markers := map[string]struct{}{delDirHash: {}}
-	// ignore error: assume no markers
-	root.allMarkers(markers)
+// readDir lists the directory dir of the union.
+func (r *unionFSRoot) readDir(dir string) []fuse.DirEntry {
+	return r.readDirFrom(dir, 0)
+}
 
-	dir := n.Path(nil)
+// readDirFrom is like readDir, but ignores the branches before from.
+func (r *unionFSRoot) readDirFrom(dir string, from int) []fuse.DirEntry {
+	markers := map[string]struct{}{}
+	if from == 0 && r.opts.Whiteouts == DeletionMarkers {
+		markers[delDirHash] = struct{}{}
+		markers[redirDirHash] = struct{}{}
+		// ignore error: assume no markers
+		r.allMarkers(markers)
+	}
 
 	names := map[string]uint32{}
-	for i := range root.roots {
-		// deepest root first.
-		readRoot(root.roots[len(root.roots)-i-1], dir, names)
+	hidden := map[string]bool{}
+	for _, e := range r.resolveFrom(dir, from) {
+		if e.attr.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+			continue
+		}
+		b := r.branches[e.idx]
+		entries := map[string]uint32{}
+		readBranch(b, e.path, entries)
+		var whiteouts []string
+		for nm, mode := range entries {
+			if r.opts.Whiteouts != DeletionMarkers {
+				if strings.HasPrefix(nm, whiteoutPrefix) {
+					if nm != opaqueWhiteout {
+						whiteouts = append(whiteouts, nm[len(whiteoutPrefix):])
+					}
+					continue
+				}
+				var attr fuse.Attr
+				if mode == syscall.S_IFCHR && b.Lstat(filepath.Join(e.path, nm), &attr) == 0 && isWhiteoutDev(&attr) {
+					whiteouts = append(whiteouts, nm)
+					continue
+				}
+			}
+			if _, ok := names[nm]; ok || hidden[nm] {
+				continue
+			}
+			names[nm] = mode
+		}
+		// Whiteouts only hide the entries of lower branches.
+		for _, nm := range whiteouts {
+			hidden[nm] = true
+		}
 	}
 	result := make([]fuse.DirEntry, 0, len(names))
 	for nm, mode := range names {
@@ -360,63 +968,97 @@ func (n *unionFSNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno)
 		})
 	}
 	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
-	return fs.NewListDirStream(result), 0
+	return result
 }
 
-func readRoot(root string, dir string, result map[string]uint32) {
-	ds, errno := fs.NewLoopbackDirStream(filepath.Join(root, dir))
-	if errno != 0 {
-		return
+// readBranch adds the entries of the directory dir of branch b to
+// result.
+func readBranch(b Branch, dir string, result map[string]uint32) {
+	entries, _ := b.ReadDir(dir)
+	for _, e := range entries {
+		result[e.Name] = e.Mode
 	}
-	defer ds.Close()
-	for ds.HasNext() {
-		e, errno := ds.Next()
-		if errno != 0 {
-			return
+}
+
+// lowerDir returns the path of the directory name in the highest
+// read-only branch that has it, or "" if none has.
+func (r *unionFSRoot) lowerDir(name string) string {
+	for _, e := range r.resolve(name) {
+		if e.idx > 0 && e.attr.Mode&syscall.S_IFMT == syscall.S_IFDIR {
+			return e.path
 		}
+	}
+	return ""
+}
 
-		result[e.Name] = e.Mode
+// hasLowerDir returns true if one of the read-only branches has the
+// directory name.
+func (r *unionFSRoot) hasLowerDir(name string) bool {
+	return r.lowerDir(name) != ""
+}
+
+// hideLower hides the entries of the read-only branches in the
+// directory dir. With deletion markers, markers are written for
+// those that are not in the writable branch. Otherwise, dir is made
+// opaque.
+func (r *unionFSRoot) hideLower(dir string) syscall.Errno {
+	if r.opts.Whiteouts != DeletionMarkers {
+		if !r.hasLowerDir(dir) {
+			return 0
+		}
+		return r.setOpaque(dir)
+	}
+
+	names := map[string]uint32{}
+	for _, e := range r.resolve(dir) {
+		if e.idx > 0 {
+			readBranch(r.branches[e.idx], e.path, names)
+		}
 	}
+	for nm := range names {
+		var st syscall.Stat_t
+		p := filepath.Join(dir, nm)
+		if syscall.Lstat(filepath.Join(r.upper, p), &st) == nil {
+			continue
+		}
+		if errno := r.writeMarker(p); errno != 0 {
+			return errno
+		}
+	}
+	return 0
 }
 
 // getBranch returns the root where we can find the given file. It
-// will check the deletion markers in roots[0].
-func (n *unionFSNode) getBranch(st *syscall.Stat_t) (string, int) {
+// will check the deletion markers in the writable branch.
+func (n *unionFSNode) getBranch(attr *fuse.Attr) (string, int) {
 	name := n.Path(nil)
-	return name, n.root().getBranch(name, st)
+	return name, n.root().getBranch(name, attr)
 }
 
-func (r *unionFSRoot) getBranch(name string, st *syscall.Stat_t) int {
-	if r.isDeleted(name) {
+func (r *unionFSRoot) getBranch(name string, attr *fuse.Attr) int {
+	stack := r.resolve(name)
+	if len(stack) == 0 {
 		return -1
 	}
-	if st == nil {
-		st = &syscall.Stat_t{}
+	if attr != nil {
+		*attr = stack[0].attr
 	}
-	for i, root := range r.roots {
-		p := filepath.Join(root, name)
-		err := syscall.Lstat(p, st)
-		if err == nil {
-			return i
-		}
-	}
-	return -1
+	return stack[0].idx
 }
 
 func (n *unionFSRoot) delPath(p string) syscall.Errno {
-	var st syscall.Stat_t
 	r := n.root()
-	idx := r.getBranch(p, &st)
+	idx := r.getBranch(p, nil)
 
 	if idx < 0 {
 		return 0
 	}
 	if idx == 0 {
-		err := syscall.Unlink(filepath.Join(r.roots[idx], p))
+		err := syscall.Unlink(filepath.Join(r.upper, p))
 		if err != nil {
 			return fs.ToErrno(err)
 		}
-		idx = r.getBranch(p, &st)
+		idx = r.getBranch(p, nil)
 	}
 	if idx > 0 {
 		return r.writeMarker(p)
@@ -426,91 +1068,189 @@ func (n *unionFSRoot) delPath(p string) syscall.Errno {
 }
 
 func (n *unionFSNode) promote() syscall.Errno {
-	p := &n.Inode
-	r := n.root()
+	return n.root().promote(n.Path(nil))
+}
 
-	type tup struct {
-		*unionFSNode
-		name string
-		idx  int
-		st   syscall.Stat_t
+// promote copies the file or directory p, and the directories
+// containing it, to the writable branch if they are not there yet.
+// Directories are copied without their contents.
+func (r *unionFSRoot) promote(p string) syscall.Errno {
+	var todo []string
+	for ; p != "" && p != "."; p = filepath.Dir(p) {
+		todo = append(todo, p)
 	}
-
-	var parents []tup
-	for p != nil && p != &r.Inode {
-		asUN := p.Operations().(*unionFSNode)
-
-		var st syscall.Stat_t
-		name, idx := asUN.getBranch(&st)
+	for i := len(todo) - 1; i >= 0; i-- {
+		var attr fuse.Attr
+		idx := r.getBranch(todo[i], &attr)
 		if idx == 0 {
-			break
+			continue
 		}
 		if idx < 0 {
 			log.Println("promote called on nonexistent file")
 			return syscall.EIO
 		}
-
-		parents = append(parents, tup{asUN, name, idx, st})
-		_, p = p.Parent()
+		if errno := r.copyUp(todo[i], idx, &attr, false); errno != 0 {
+			return errno
+		}
 	}
+	return 0
+}
 
-	for i := len(parents) - 1; i >= 0; i-- {
-		t := parents[i]
-
-		path := t.Path(nil)
-		if t.IsDir() {
-			if err := syscall.Mkdir(filepath.Join(r.roots[0], path), t.st.Mode); err != nil {
-				return err.(syscall.Errno)
-			}
-		} else if t.Mode()&syscall.S_IFREG != 0 {
-			if errno := r.promoteRegularFile(path, t.idx, &t.st); errno != 0 {
-				return errno
-			}
+// promoteTree copies the directory p with all its contents to the
+// writable branch.
+func (r *unionFSRoot) promoteTree(p string) syscall.Errno {
+	if errno := r.promote(p); errno != 0 {
+		return errno
+	}
+	for _, e := range r.readDir(p) {
+		child := filepath.Join(p, e.Name)
+		var errno syscall.Errno
+		if e.Mode == syscall.S_IFDIR {
+			errno = r.promoteTree(child)
 		} else {
-			log.Panicf("don't know how to handle mode %o", t.Mode())
+			errno = r.promote(child)
 		}
-		var ts [2]syscall.Timespec
-		ts[0] = t.st.Atim
-		ts[1] = t.st.Mtim
+		if errno != 0 {
+			return errno
+		}
+	}
+	return 0
+}
 
-		// ignore error.
-		syscall.UtimesNano(path, ts[:])
+// copyUp copies the file p with attributes attr from branch idx to
+// the writable branch, along with its timestamps and extended
+// attributes. If meta is set, a regular file is copied without its
+// data.
+func (r *unionFSRoot) copyUp(p string, idx int, attr *fuse.Attr, meta bool) syscall.Errno {
+	b := r.branches[idx]
+	src := r.branchPath(idx, p)
+	dest := filepath.Join(r.upper, p)
+	switch attr.Mode & syscall.S_IFMT {
+	case syscall.S_IFDIR:
+		if err := syscall.Mkdir(dest, attr.Mode&07777); err != nil {
+			return err.(syscall.Errno)
+		}
+	case syscall.S_IFREG:
+		promote := r.promoteRegularFile
+		if meta {
+			promote = r.promoteMetaFile
+		}
+		if errno := promote(p, idx, attr); errno != 0 {
+			return errno
+		}
+	case syscall.S_IFLNK:
+		target, errno := b.Readlink(src)
+		if errno != 0 {
+			return errno
+		}
+		if err := syscall.Symlink(string(target), dest); err != nil {
+			return err.(syscall.Errno)
+		}
+	default:
+		if err := syscall.Mknod(dest, attr.Mode, int(attr.Rdev)); err != nil {
+			return err.(syscall.Errno)
+		}
+	}
+
+	// ignore errors: the copy is usable without them.
+	r.copyXattrs(b, src, dest)
+	ts := []unix.Timespec{
+		{Sec: int64(attr.Atime), Nsec: int64(attr.Atimensec)},
+		{Sec: int64(attr.Mtime), Nsec: int64(attr.Mtimensec)},
 	}
+	unix.UtimesNanoAt(unix.AT_FDCWD, dest, ts, unix.AT_SYMLINK_NOFOLLOW)
 	return 0
 }
 
-func (r *unionFSRoot) promoteRegularFile(p string, idx int, st *syscall.Stat_t) syscall.Errno {
-	dest, err := syscall.Creat(filepath.Join(r.roots[0], p), st.Mode)
-	if err != nil {
-		return err.(syscall.Errno)
+// listXattrs returns the names of the extended attributes of p in
+// branch b.
+func listXattrs(b Branch, p string) []string {
+	sz, errno := b.Listxattr(p, nil)
+	if errno != 0 || sz == 0 {
+		return nil
 	}
-	src, err := syscall.Open(filepath.Join(r.roots[idx], p), syscall.O_RDONLY, 0)
+	names := make([]byte, sz)
+	sz, errno = b.Listxattr(p, names)
+	if errno != 0 || sz == 0 || int(sz) > len(names) {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(string(names[:sz]), "\x00"), "\x00")
+}
+
+// copyXattrs copies the extended attributes of src in branch b to
+// dest, except for the whiteout metadata.
+func (r *unionFSRoot) copyXattrs(b Branch, src, dest string) {
+	for _, attr := range listXattrs(b, src) {
+		if r.isPrivateXattr(attr) {
+			continue
+		}
+		if val, ok := getXattr(b, src, attr); ok {
+			unix.Lsetxattr(dest, attr, val, 0)
+		}
+	}
+}
+
+// getXattr returns the value of the extended attribute attr of p in
+// branch b.
+func getXattr(b Branch, p, attr string) ([]byte, bool) {
+	sz, errno := b.Getxattr(p, attr, nil)
+	if errno != 0 {
+		return nil, false
+	}
+	val := make([]byte, sz)
+	sz, errno = b.Getxattr(p, attr, val)
+	if errno != 0 || int(sz) > len(val) {
+		return nil, false
+	}
+	return val[:sz], true
+}
+
+func (r *unionFSRoot) promoteRegularFile(p string, idx int, attr *fuse.Attr) syscall.Errno {
+	dest, err := os.OpenFile(filepath.Join(r.upper, p), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(attr.Mode&0777))
 	if err != nil {
-		syscall.Close(dest)
-		return err.(syscall.Errno)
+		return fs.ToErrno(err)
+	}
+	ret := copyFile(dest, r.branches[idx], r.branchPath(idx, p))
+	if err := dest.Close(); err != nil && ret == 0 {
+		ret = fs.ToErrno(err)
+	}
+	return ret
+}
+
+// copyFile writes the contents of the file p of branch b to dest.
+func copyFile(dest io.Writer, b Branch, p string) syscall.Errno {
+	src, errno := b.Open(p, syscall.O_RDONLY)
+	if errno != 0 {
+		return errno
 	}
 
+	ctx := context.Background()
 	var ret syscall.Errno
-	var buf [128 >> 10]byte
-	for {
-		n, err := syscall.Read(src, buf[:])
-		if n == 0 {
+	var buf [128 << 10]byte
+	for off := int64(0); ; {
+		res, errno := src.(fs.FileReader).Read(ctx, buf[:], off)
+		if errno != 0 {
+			ret = errno
 			break
 		}
-		if err != nil {
-			ret = err.(syscall.Errno)
+		data, status := res.Bytes(buf[:])
+		res.Done()
+		if !status.Ok() {
+			ret = syscall.Errno(status)
+			break
+		}
+		if len(data) == 0 {
 			break
 		}
 
-		if _, err := syscall.Write(dest, buf[:n]); err != nil {
-			ret = err.(syscall.Errno)
+		if _, err := dest.Write(data); err != nil {
+			ret = fs.ToErrno(err)
 			break
 		}
+		off += int64(len(data))
 	}
-	syscall.Close(src)
-
-	if err := syscall.Close(dest); err != nil && ret == 0 {
-		ret = err.(syscall.Errno)
+	if rel, ok := src.(fs.FileReleaser); ok {
+		rel.Release(ctx)
 	}
 	return ret
 }
@@ -6,6 +6,7 @@ package unionfs
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -16,6 +17,7 @@ import (
 	"github.com/hanwen/go-fuse/v2/fuse"
 	"github.com/hanwen/go-fuse/v2/internal/testutil"
 	"github.com/hanwen/go-fuse/v2/posixtest"
+	"golang.org/x/sys/unix"
 )
 
 type testCase struct {
@@ -35,6 +37,11 @@ func (tc *testCase) Clean() {
 }
 
 func newTestCase(t *testing.T, populate bool) *testCase {
+	t.Helper()
+	return newTestCaseOptions(t, populate, nil)
+}
+
+func newTestCaseOptions(t *testing.T, populate bool, unionOpts *Options) *testCase {
 	t.Helper()
 	dir := t.TempDir()
 	dirs := []string{"ro", "rw", "mnt"}
@@ -56,9 +63,7 @@ func newTestCase(t *testing.T, populate bool) *testCase {
 		rw:  dir + "/rw",
 		ro:  dir + "/ro",
 	}
-	tc.root = &unionFSRoot{
-		roots: []string{tc.rw, tc.ro},
-	}
+	tc.root = NewUnionFS([]string{tc.rw, tc.ro}, unionOpts).(*unionFSRoot)
 
 	server, err := fs.Mount(tc.mnt, tc.root, &opts)
 	if err != nil {
@@ -268,6 +273,292 @@ func TestReaddir(t *testing.T) {
 	}
 }
 
+func readDirNames(t *testing.T, dir string) map[string]bool {
+	t.Helper()
+	res, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	got := map[string]bool{}
+	for _, e := range res {
+		got[e.Name()] = true
+	}
+	return got
+}
+
+func TestMkdirDeleted(t *testing.T) {
+	tc := newTestCase(t, true)
+	defer tc.Clean()
+
+	if err := os.Remove(tc.mnt + "/dir/ro-file"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := os.Remove(tc.mnt + "/dir"); err != nil {
+		t.Fatalf("Remove dir: %v", err)
+	}
+	if _, err := os.Lstat(tc.mnt + "/dir"); !os.IsNotExist(err) {
+		t.Fatalf("Lstat after Remove: got %v, want ENOENT", err)
+	}
+	if err := os.Mkdir(tc.mnt+"/dir", 0755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if got := readDirNames(t, tc.mnt+"/dir"); len(got) != 0 {
+		t.Errorf("new directory shows %v", got)
+	}
+}
+
+func TestRenameFile(t *testing.T) {
+	tc := newTestCase(t, true)
+	defer tc.Clean()
+
+	if err := os.Rename(tc.mnt+"/dir/ro-file", tc.mnt+"/dir/moved"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if got, err := os.ReadFile(tc.mnt + "/dir/moved"); err != nil || string(got) != "bla" {
+		t.Errorf("ReadFile: got %q, %v", got, err)
+	}
+	if want := map[string]bool{"moved": true}; !reflect.DeepEqual(readDirNames(t, tc.mnt+"/dir"), want) {
+		t.Errorf("got %v, want %v", readDirNames(t, tc.mnt+"/dir"), want)
+	}
+	if _, err := os.Lstat(tc.ro + "/dir/ro-file"); err != nil {
+		t.Errorf("read-only branch changed: %v", err)
+	}
+}
+
+func TestRenameDir(t *testing.T) {
+	tc := newTestCase(t, true)
+	defer tc.Clean()
+
+	if err := os.MkdirAll(tc.ro+"/dir/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tc.ro+"/dir/sub/file", []byte("sub"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(tc.mnt + "/dir/ro-file"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	if err := os.Rename(tc.mnt+"/dir", tc.mnt+"/moved"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if _, err := os.Lstat(tc.mnt + "/dir"); !os.IsNotExist(err) {
+		t.Errorf("Lstat old name: got %v, want ENOENT", err)
+	}
+	if want := map[string]bool{"sub": true}; !reflect.DeepEqual(readDirNames(t, tc.mnt+"/moved"), want) {
+		t.Errorf("got %v, want %v", readDirNames(t, tc.mnt+"/moved"), want)
+	}
+	if want := map[string]bool{"moved": true}; !reflect.DeepEqual(readDirNames(t, tc.mnt), want) {
+		t.Errorf("root: got %v, want %v", readDirNames(t, tc.mnt), want)
+	}
+
+	// Renaming again follows the redirect, and a subdirectory
+	// moves out of it.
+	if err := os.Rename(tc.mnt+"/moved", tc.mnt+"/again"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if err := os.Rename(tc.mnt+"/again/sub", tc.mnt+"/sub"); err != nil {
+		t.Fatalf("Rename sub: %v", err)
+	}
+	if got, err := os.ReadFile(tc.mnt + "/sub/file"); err != nil || string(got) != "sub" {
+		t.Errorf("ReadFile: got %q, %v", got, err)
+	}
+	if got := readDirNames(t, tc.mnt+"/again"); len(got) != 0 {
+		t.Errorf("renamed directory: got %v, want empty", got)
+	}
+
+	if err := os.Mkdir(tc.mnt+"/dir", 0755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if got := readDirNames(t, tc.mnt+"/dir"); len(got) != 0 {
+		t.Errorf("new directory shows %v", got)
+	}
+	if _, err := os.Lstat(tc.ro + "/dir/ro-file"); err != nil {
+		t.Errorf("read-only branch changed: %v", err)
+	}
+}
+
+func TestLinkMknod(t *testing.T) {
+	tc := newTestCase(t, true)
+	defer tc.Clean()
+
+	if err := os.Link(tc.mnt+"/dir/ro-file", tc.mnt+"/link"); err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+	if got, err := os.ReadFile(tc.mnt + "/link"); err != nil || string(got) != "bla" {
+		t.Errorf("ReadFile: got %q, %v", got, err)
+	}
+	var st syscall.Stat_t
+	if err := syscall.Lstat(tc.mnt+"/dir/ro-file", &st); err != nil {
+		t.Fatalf("Lstat: %v", err)
+	} else if st.Nlink != 2 {
+		t.Errorf("got nlink %d, want 2", st.Nlink)
+	}
+
+	if err := syscall.Mknod(tc.mnt+"/fifo", syscall.S_IFIFO|0644, 0); err != nil {
+		t.Fatalf("Mknod: %v", err)
+	}
+	if err := syscall.Lstat(tc.rw+"/fifo", &st); err != nil {
+		t.Fatalf("Lstat: %v", err)
+	} else if st.Mode&syscall.S_IFMT != syscall.S_IFIFO {
+		t.Errorf("got mode %o, want fifo", st.Mode)
+	}
+}
+
+func TestXattrCopyUp(t *testing.T) {
+	tc := newTestCase(t, true)
+	defer tc.Clean()
+
+	if err := syscall.Setxattr(tc.ro+"/dir/ro-file", "user.lower", []byte("ro"), 0); err != nil {
+		t.Skipf("Setxattr: %v", err)
+	}
+	buf := make([]byte, 64)
+	if sz, err := syscall.Getxattr(tc.mnt+"/dir/ro-file", "user.lower", buf); err != nil || string(buf[:sz]) != "ro" {
+		t.Errorf("Getxattr: got %q, %v", buf[:sz], err)
+	}
+	if err := syscall.Setxattr(tc.mnt+"/dir/ro-file", "user.upper", []byte("rw"), 0); err != nil {
+		t.Fatalf("Setxattr: %v", err)
+	}
+	for attr, want := range map[string]string{"user.lower": "ro", "user.upper": "rw"} {
+		if sz, err := syscall.Getxattr(tc.rw+"/dir/ro-file", attr, buf); err != nil || string(buf[:sz]) != want {
+			t.Errorf("Getxattr(%q) in writable branch: got %q, %v", attr, buf[:sz], err)
+		}
+	}
+	if got, err := os.ReadFile(tc.rw + "/dir/ro-file"); err != nil || string(got) != "bla" {
+		t.Errorf("copied up content: got %q, %v", got, err)
+	}
+}
+
+func TestWhiteouts(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		opts Options
+	}{
+		{"overlay", Options{Whiteouts: OverlayWhiteouts}},
+		{"overlay-user", Options{Whiteouts: OverlayWhiteouts, UserXattr: true}},
+		{"aufs", Options{Whiteouts: AUFSWhiteouts}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if c.opts.Whiteouts == OverlayWhiteouts && os.Geteuid() != 0 {
+				t.Skip("creating whiteout devices needs root")
+			}
+			testWhiteouts(t, &c.opts)
+		})
+	}
+}
+
+func testWhiteouts(t *testing.T, opts *Options) {
+	tc := newTestCaseOptions(t, true, opts)
+	defer tc.Clean()
+	r := tc.root
+
+	if err := os.MkdirAll(tc.ro+"/tree/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tc.ro+"/tree/sub/file", []byte("sub"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	isWhiteout := func(p string) bool {
+		var st syscall.Stat_t
+		if opts.Whiteouts == OverlayWhiteouts {
+			return syscall.Lstat(filepath.Join(tc.rw, p), &st) == nil && st.Mode&syscall.S_IFMT == syscall.S_IFCHR && st.Rdev == 0
+		}
+		dir, base := filepath.Split(p)
+		return syscall.Lstat(filepath.Join(tc.rw, dir, whiteoutPrefix+base), &st) == nil
+	}
+	isOpaque := func(p string) bool {
+		return r.isOpaque(0, p)
+	}
+
+	if err := os.Remove(tc.mnt + "/dir/ro-file"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if !isWhiteout("dir/ro-file") {
+		t.Errorf("no whiteout for dir/ro-file")
+	}
+	if got := readDirNames(t, tc.mnt+"/dir"); len(got) != 0 {
+		t.Errorf("got %v, want empty", got)
+	}
+	if _, err := os.Lstat(filepath.Join(tc.rw, delDir)); !os.IsNotExist(err) {
+		t.Errorf("%s created: %v", delDir, err)
+	}
+
+	// Removing and recreating a directory must not show the
+	// contents of the read-only branch again.
+	if err := os.Remove(tc.mnt + "/dir"); err != nil {
+		t.Fatalf("Rmdir: %v", err)
+	}
+	if !isWhiteout("dir") {
+		t.Errorf("no whiteout for dir")
+	}
+	if err := os.Mkdir(tc.mnt+"/dir", 0755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if got := readDirNames(t, tc.mnt+"/dir"); len(got) != 0 {
+		t.Errorf("recreated directory shows %v", got)
+	}
+	if !isOpaque("dir") {
+		t.Errorf("recreated directory is not opaque")
+	}
+
+	if err := os.Rename(tc.mnt+"/tree", tc.mnt+"/moved"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if !isWhiteout("tree") {
+		t.Errorf("no whiteout for tree")
+	}
+	if got, err := os.ReadFile(tc.mnt + "/moved/sub/file"); err != nil || string(got) != "sub" {
+		t.Errorf("ReadFile: got %q, %v", got, err)
+	}
+	if want := map[string]bool{"dir": true, "moved": true}; !reflect.DeepEqual(readDirNames(t, tc.mnt), want) {
+		t.Errorf("root: got %v, want %v", readDirNames(t, tc.mnt), want)
+	}
+	if r.hasRedirects() {
+		buf := make([]byte, 64)
+		sz, err := unix.Lgetxattr(filepath.Join(tc.rw, "moved"), r.xattr("redirect"), buf)
+		if err != nil || string(buf[:sz]) != "/tree" {
+			t.Errorf("redirect: got %q, %v", buf[:sz], err)
+		}
+		if _, err := unix.Lgetxattr(filepath.Join(tc.mnt, "moved"), r.xattr("redirect"), buf); err != unix.ENODATA {
+			t.Errorf("redirect visible in the union: %v", err)
+		}
+	}
+
+	if opts.Whiteouts != OverlayWhiteouts {
+		return
+	}
+	// The writable branch must look the same to kernel overlayfs.
+	tc.Clean()
+	ovl := filepath.Join(tc.dir, "ovl")
+	work := filepath.Join(tc.dir, "work")
+	for _, d := range []string{ovl, work} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", tc.ro, tc.rw, work)
+	if opts.UserXattr {
+		data += ",userxattr"
+	} else {
+		data += ",redirect_dir=on"
+	}
+	if err := syscall.Mount("overlay", ovl, "overlay", 0, data); err != nil {
+		t.Skipf("mount overlay: %v", err)
+	}
+	defer syscall.Unmount(ovl, 0)
+
+	if want := map[string]bool{"dir": true, "moved": true}; !reflect.DeepEqual(readDirNames(t, ovl), want) {
+		t.Errorf("overlayfs root: got %v, want %v", readDirNames(t, ovl), want)
+	}
+	if got := readDirNames(t, ovl+"/dir"); len(got) != 0 {
+		t.Errorf("overlayfs dir: got %v, want empty", got)
+	}
+	if got, err := os.ReadFile(ovl + "/moved/sub/file"); err != nil || string(got) != "sub" {
+		t.Errorf("overlayfs ReadFile: got %q, %v", got, err)
+	}
+}
+
 func TestPosix(t *testing.T) {
 	cases := []string{
 		"SymlinkReadlink",
@@ -275,11 +566,17 @@ func TestPosix(t *testing.T) {
 		"TruncateFile",
 		"TruncateNoFile",
 		"FdLeak",
-		//		"MkdirRmdir",
-		//		"NlinkZero",
+		"MkdirRmdir",
+		"NlinkZero",
 		"ParallelFileOpen",
-		//		"Link",
+		"Link",
+		"LinkUnlinkRename",
 		"ReadDir",
+		"RenameOverwriteDestNoExist",
+		"RenameOverwriteDestExist",
+		"RenameOpenDir",
+		"SetattrSymlink",
+		"XAttr",
 	}
 
 	for _, nm := range cases {
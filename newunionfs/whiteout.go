@@ -0,0 +1,187 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unionfs
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"golang.org/x/sys/unix"
+)
+
+// Names used by AUFS whiteouts, which OCI image layers use too.
+const (
+	whiteoutPrefix = ".wh."
+	opaqueWhiteout = ".wh..wh..opq"
+)
+
+// xattr returns the name of the overlayfs extended attribute attr.
+func (r *unionFSRoot) xattr(attr string) string {
+	if r.opts.UserXattr {
+		return "user.overlay." + attr
+	}
+	return "trusted.overlay." + attr
+}
+
+// hasRedirects returns true if renamed directories are redirected to
+// the read-only branches. Like overlayfs, this is not supported with
+// AUFS whiteouts or user extended attributes, so those copy renamed
+// directories up entirely.
+func (r *unionFSRoot) hasRedirects() bool {
+	return r.opts.Whiteouts == DeletionMarkers || (r.opts.Whiteouts == OverlayWhiteouts && !r.opts.UserXattr)
+}
+
+// isPrivateXattr returns true if attr holds whiteout or metacopy
+// metadata, which is hidden from the union.
+func (r *unionFSRoot) isPrivateXattr(attr string) bool {
+	return (r.opts.Whiteouts != DeletionMarkers || r.opts.MetaCopy) && strings.HasPrefix(attr, r.xattr(""))
+}
+
+// isWhiteoutDev returns true if attr describes an overlayfs whiteout,
+// a character device with device number 0/0.
+func isWhiteoutDev(attr *fuse.Attr) bool {
+	return attr.Mode&syscall.S_IFMT == syscall.S_IFCHR && attr.Rdev == 0
+}
+
+// isWhiteout returns true if p is whited out in branch idx. errno and
+// attr are the result of calling Lstat on p.
+func (r *unionFSRoot) isWhiteout(idx int, p string, attr *fuse.Attr, errno syscall.Errno) bool {
+	if r.opts.Whiteouts == DeletionMarkers {
+		return false
+	}
+	if errno == 0 && isWhiteoutDev(attr) {
+		return true
+	}
+	dir, base := filepath.Split(p)
+	var wattr fuse.Attr
+	return r.branches[idx].Lstat(filepath.Join(dir, whiteoutPrefix+base), &wattr) == 0
+}
+
+// isOpaque returns true if the directory p of branch idx hides the
+// contents of the branches below.
+func (r *unionFSRoot) isOpaque(idx int, p string) bool {
+	if r.opts.Whiteouts == DeletionMarkers {
+		return false
+	}
+	b := r.branches[idx]
+	var buf [1]byte
+	if sz, errno := b.Getxattr(p, r.xattr("opaque"), buf[:]); errno == 0 && sz == 1 && buf[0] == 'y' {
+		return true
+	}
+	var attr fuse.Attr
+	return b.Lstat(filepath.Join(p, opaqueWhiteout), &attr) == 0
+}
+
+// layerRedirect returns the path in the branches below idx for the
+// renamed directory p of branch idx, which is the directory upper of
+// the union.
+func (r *unionFSRoot) layerRedirect(idx int, upper, p string) (string, bool) {
+	if r.opts.Whiteouts == DeletionMarkers {
+		if idx != 0 {
+			return "", false
+		}
+		return r.redirect(upper)
+	}
+	if !r.hasRedirects() {
+		return "", false
+	}
+	buf := make([]byte, 1024)
+	sz, errno := r.branches[idx].Getxattr(p, r.xattr("redirect"), buf)
+	if errno != 0 || sz == 0 || int(sz) > len(buf) {
+		return "", false
+	}
+	target := string(buf[:sz])
+	if strings.HasPrefix(target, "/") {
+		return strings.TrimPrefix(target, "/"), true
+	}
+	// Relative redirects name an entry of the same directory.
+	return filepath.Join(filepath.Dir(p), target), true
+}
+
+// writeWhiteout hides p of the branches below the writable one.
+func (r *unionFSRoot) writeWhiteout(p string) syscall.Errno {
+	if errno := r.promote(filepath.Dir(p)); errno != 0 {
+		return errno
+	}
+	if r.opts.Whiteouts == OverlayWhiteouts {
+		err := syscall.Mknod(filepath.Join(r.upper, p), syscall.S_IFCHR, 0)
+		return fs.ToErrno(err)
+	}
+	dir, base := filepath.Split(p)
+	fd, err := syscall.Creat(filepath.Join(r.upper, dir, whiteoutPrefix+base), 0)
+	if err != nil {
+		return err.(syscall.Errno)
+	}
+	syscall.Close(fd)
+	return 0
+}
+
+// rmWhiteout removes the whiteout for p. It returns ENOENT if there is
+// none.
+func (r *unionFSRoot) rmWhiteout(p string) syscall.Errno {
+	var attr fuse.Attr
+	if r.branches[0].Lstat(p, &attr) == 0 && isWhiteoutDev(&attr) {
+		return fs.ToErrno(syscall.Unlink(filepath.Join(r.upper, p)))
+	}
+	dir, base := filepath.Split(p)
+	return fs.ToErrno(syscall.Unlink(filepath.Join(r.upper, dir, whiteoutPrefix+base)))
+}
+
+// setOpaque makes the directory p of the writable branch hide the
+// contents of the branches below.
+func (r *unionFSRoot) setOpaque(p string) syscall.Errno {
+	if r.opts.Whiteouts == OverlayWhiteouts {
+		err := unix.Lsetxattr(filepath.Join(r.upper, p), r.xattr("opaque"), []byte("y"), 0)
+		return fs.ToErrno(err)
+	}
+	fd, err := syscall.Creat(filepath.Join(r.upper, p, opaqueWhiteout), 0)
+	if err != nil {
+		return err.(syscall.Errno)
+	}
+	syscall.Close(fd)
+	return 0
+}
+
+// setRedirect makes the lower branches of the renamed directory p be
+// looked up at lower.
+func (r *unionFSRoot) setRedirect(p, lower string) syscall.Errno {
+	if r.opts.Whiteouts == DeletionMarkers {
+		return r.writeMeta(redirDir, p, []byte(p+"\x00"+lower))
+	}
+	err := unix.Lsetxattr(filepath.Join(r.upper, p), r.xattr("redirect"), []byte("/"+lower), 0)
+	return fs.ToErrno(err)
+}
+
+// clearWhiteouts removes the whiteouts and opaque marker from the
+// directory p of the writable branch, so it can be removed or
+// replaced once it looks empty.
+func (r *unionFSRoot) clearWhiteouts(p string) {
+	dir := filepath.Join(r.upper, p)
+	ds, errno := fs.NewLoopbackDirStream(dir)
+	if errno != 0 {
+		return
+	}
+	var names []string
+	for ds.HasNext() {
+		e, errno := ds.Next()
+		if errno != 0 {
+			break
+		}
+		if strings.HasPrefix(e.Name, whiteoutPrefix) || e.Mode == syscall.S_IFCHR {
+			names = append(names, e.Name)
+		}
+	}
+	ds.Close()
+
+	for _, nm := range names {
+		var attr fuse.Attr
+		if strings.HasPrefix(nm, whiteoutPrefix) || (r.branches[0].Lstat(filepath.Join(p, nm), &attr) == 0 && isWhiteoutDev(&attr)) {
+			syscall.Unlink(filepath.Join(dir, nm))
+		}
+	}
+}
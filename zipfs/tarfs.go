@@ -142,29 +142,38 @@ func (rc *readCloser) Close() error {
 // InodeEmbedder. The inode can either be mounted as the root of a
 // FUSE mount, or added as a child to some other FUSE tree.
 func NewTarCompressedTree(name string, format string) (fs.InodeEmbedder, error) {
+	stream, err := openTar(name, format)
+	if err != nil {
+		return nil, err
+	}
+	return &tarRoot{rc: stream}, nil
+}
+
+// openTar opens the tar file name, which is compressed if format is
+// "gz" or "bz2".
+func openTar(name string, format string) (io.ReadCloser, error) {
 	f, err := os.Open(name)
 	if err != nil {
 		return nil, err
 	}
 
-	var stream io.ReadCloser
 	switch format {
 	case "gz":
 		unzip, err := gzip.NewReader(f)
 		if err != nil {
+			f.Close()
 			return nil, err
 		}
-		stream = &readCloser{
+		return &readCloser{
 			unzip,
 			f.Close,
-		}
+		}, nil
 	case "bz2":
 		unzip := bzip2.NewReader(f)
-		stream = &readCloser{
+		return &readCloser{
 			unzip,
 			f.Close,
-		}
+		}, nil
 	}
-
-	return &tarRoot{rc: stream}, nil
+	return f, nil
 }
@@ -0,0 +1,476 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zipfs
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// TarIndex gives access to the files of a tar archive, without
+// loading their contents in memory. The data of an uncompressed
+// archive is read in place. For a compressed archive, each open file
+// decompresses the stream up to its data, and then reads on from
+// there.
+//
+// Its methods take slash-separated paths relative to the root of the
+// archive, which is "", and have the signatures of the Branch
+// interface of the newunionfs package.
+type TarIndex struct {
+	name   string
+	format string
+
+	// file is the archive if it is uncompressed.
+	file    *os.File
+	entries map[string]*tarEntry
+}
+
+type tarEntry struct {
+	attr fuse.Attr
+	link string
+
+	// xattrs holds the extended attributes, from the SCHILY.xattr
+	// PAX records.
+	xattrs   map[string]string
+	children map[string]*tarEntry
+
+	// num is the position of the header in the archive, and offset
+	// the position of the data in the file, or -1 if it cannot be
+	// read in place.
+	num    int
+	offset int64
+}
+
+// tarIno hands out inode numbers. They are in the upper half of the
+// range, so they do not collide with those of other file systems
+// combined with the archive.
+var tarIno atomic.Uint64
+
+func newTarEntry(mode uint32) *tarEntry {
+	e := &tarEntry{offset: -1}
+	e.attr.Mode = mode
+	e.attr.Nlink = 1
+	e.attr.Ino = 1<<63 | tarIno.Add(1)
+	if mode&syscall.S_IFMT == syscall.S_IFDIR {
+		e.children = map[string]*tarEntry{}
+	}
+	return e
+}
+
+// offsetReader tracks the position in a file, while letting the tar
+// reader skip file data by seeking.
+type offsetReader struct {
+	f   *os.File
+	off int64
+}
+
+func (r *offsetReader) Read(b []byte) (int, error) {
+	n, err := r.f.Read(b)
+	r.off += int64(n)
+	return n, err
+}
+
+func (r *offsetReader) Seek(off int64, whence int) (int64, error) {
+	pos, err := r.f.Seek(off, whence)
+	if err == nil {
+		r.off = pos
+	}
+	return pos, err
+}
+
+// NewTarIndex reads the headers of the tar file name, which is
+// compressed if format is "gz" or "bz2". Later entries for a path
+// replace earlier ones, and missing parent directories are added.
+func NewTarIndex(name string, format string) (*TarIndex, error) {
+	stream, err := openTar(name, format)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	idx := &TarIndex{
+		name:    name,
+		format:  format,
+		entries: map[string]*tarEntry{"": newTarEntry(syscall.S_IFDIR | 0755)},
+	}
+	var r io.Reader = stream
+	var or *offsetReader
+	if f, ok := stream.(*os.File); ok {
+		or = &offsetReader{f: f}
+		r = or
+	}
+
+	tr := tar.NewReader(r)
+	for num := 0; ; num++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		e, err := idx.add(hdr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if e == nil {
+			continue
+		}
+		e.num = num
+		if or != nil && !isSparse(hdr) {
+			e.offset = or.off
+		}
+	}
+
+	if format != "gz" && format != "bz2" {
+		if idx.file, err = os.Open(name); err != nil {
+			return nil, err
+		}
+	}
+	return idx, nil
+}
+
+// isSparse returns true if the data of hdr is stored in one of the
+// sparse formats, so it is not a copy of the file.
+func isSparse(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for k := range hdr.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// tarDev encodes a device number like the Linux kernel does.
+func tarDev(major, minor int64) uint32 {
+	return uint32(minor&0xff | major<<8 | (minor&^0xff)<<12)
+}
+
+// add records hdr. It returns the entry whose data is in the archive,
+// if any.
+func (idx *TarIndex) add(hdr *tar.Header) (*tarEntry, error) {
+	p := strings.Trim(path.Clean("/"+hdr.Name), "/")
+	if p == "" {
+		if hdr.Typeflag == tar.TypeDir {
+			HeaderToFileInfo(&idx.entries[""].attr, hdr)
+			idx.entries[""].attr.Mode = syscall.S_IFDIR | uint32(hdr.Mode)&07777
+		}
+		return nil, nil
+	}
+	parent := idx.mkdirAll(path.Dir(p))
+	base := path.Base(p)
+
+	if hdr.Typeflag == tar.TypeLink {
+		target := idx.entries[strings.Trim(path.Clean("/"+hdr.Linkname), "/")]
+		if target == nil || target.children != nil {
+			return nil, fmt.Errorf("entry %q: bad link target %q", hdr.Name, hdr.Linkname)
+		}
+		target.attr.Nlink++
+		idx.put(parent, p, base, target)
+		return nil, nil
+	}
+
+	var mode uint32
+	switch hdr.Typeflag {
+	case tar.TypeReg, tar.TypeRegA, tar.TypeGNUSparse:
+		mode = syscall.S_IFREG
+	case tar.TypeDir:
+		mode = syscall.S_IFDIR
+	case tar.TypeSymlink:
+		mode = syscall.S_IFLNK
+	case tar.TypeChar:
+		mode = syscall.S_IFCHR
+	case tar.TypeBlock:
+		mode = syscall.S_IFBLK
+	case tar.TypeFifo:
+		mode = syscall.S_IFIFO
+	default:
+		// Ignore other types, such as the headers of
+		// archivers.
+		return nil, nil
+	}
+
+	e := newTarEntry(mode)
+	if old := idx.entries[p]; old != nil && old.children != nil && mode == syscall.S_IFDIR {
+		// A directory may be listed after its contents.
+		e = old
+	}
+	HeaderToFileInfo(&e.attr, hdr)
+	e.attr.Mode = mode | uint32(hdr.Mode)&07777
+	e.attr.Blocks = (e.attr.Size + 511) / 512
+	switch mode {
+	case syscall.S_IFDIR:
+		e.attr.Size = 0
+		e.attr.Blocks = 0
+	case syscall.S_IFLNK:
+		e.link = hdr.Linkname
+		e.attr.Size = uint64(len(e.link))
+	case syscall.S_IFCHR, syscall.S_IFBLK:
+		e.attr.Rdev = tarDev(hdr.Devmajor, hdr.Devminor)
+	}
+	for k, v := range hdr.PAXRecords {
+		if attr, ok := strings.CutPrefix(k, "SCHILY.xattr."); ok {
+			if e.xattrs == nil {
+				e.xattrs = map[string]string{}
+			}
+			e.xattrs[attr] = v
+		}
+	}
+	idx.put(parent, p, base, e)
+	if mode != syscall.S_IFREG {
+		return nil, nil
+	}
+	return e, nil
+}
+
+// put stores e as the entry p, named base in parent.
+func (idx *TarIndex) put(parent *tarEntry, p, base string, e *tarEntry) {
+	if old := idx.entries[p]; old != nil && old != e && old.children != nil {
+		// A file replaces a directory and all it contained.
+		for k := range idx.entries {
+			if strings.HasPrefix(k, p+"/") {
+				delete(idx.entries, k)
+			}
+		}
+	}
+	idx.entries[p] = e
+	parent.children[base] = e
+}
+
+// mkdirAll returns the directory p, adding it and its parents if
+// needed.
+func (idx *TarIndex) mkdirAll(p string) *tarEntry {
+	if p == "." {
+		p = ""
+	}
+	if e := idx.entries[p]; e != nil && e.children != nil {
+		return e
+	}
+	parent := idx.mkdirAll(path.Dir(p))
+	e := newTarEntry(syscall.S_IFDIR | 0755)
+	idx.put(parent, p, path.Base(p), e)
+	return e
+}
+
+func (idx *TarIndex) lookup(name string) (*tarEntry, syscall.Errno) {
+	e := idx.entries[name]
+	if e == nil {
+		return nil, syscall.ENOENT
+	}
+	return e, 0
+}
+
+// Lstat fills out with the attributes of name.
+func (idx *TarIndex) Lstat(name string, out *fuse.Attr) syscall.Errno {
+	e, errno := idx.lookup(name)
+	if errno != 0 {
+		return errno
+	}
+	*out = e.attr
+	return 0
+}
+
+// ReadDir lists the directory name.
+func (idx *TarIndex) ReadDir(name string) ([]fuse.DirEntry, syscall.Errno) {
+	e, errno := idx.lookup(name)
+	if errno != 0 {
+		return nil, errno
+	}
+	if e.children == nil {
+		return nil, syscall.ENOTDIR
+	}
+	result := make([]fuse.DirEntry, 0, len(e.children))
+	for nm, ch := range e.children {
+		result = append(result, fuse.DirEntry{
+			Name: nm,
+			Mode: ch.attr.Mode & syscall.S_IFMT,
+			Ino:  ch.attr.Ino,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, 0
+}
+
+// Readlink returns the target of the symlink name.
+func (idx *TarIndex) Readlink(name string) ([]byte, syscall.Errno) {
+	e, errno := idx.lookup(name)
+	if errno != 0 {
+		return nil, errno
+	}
+	if e.attr.Mode&syscall.S_IFMT != syscall.S_IFLNK {
+		return nil, syscall.EINVAL
+	}
+	return []byte(e.link), 0
+}
+
+// Open opens the file name for reading.
+func (idx *TarIndex) Open(name string, flags uint32) (fs.FileHandle, syscall.Errno) {
+	e, errno := idx.lookup(name)
+	if errno != 0 {
+		return nil, errno
+	}
+	if flags&syscall.O_ACCMODE != syscall.O_RDONLY {
+		return nil, syscall.EROFS
+	}
+	return &tarFile{idx: idx, entry: e}, 0
+}
+
+// Getxattr reads the extended attribute attr of name.
+func (idx *TarIndex) Getxattr(name string, attr string, dest []byte) (uint32, syscall.Errno) {
+	e, errno := idx.lookup(name)
+	if errno != 0 {
+		return 0, errno
+	}
+	val, ok := e.xattrs[attr]
+	if !ok {
+		return 0, fs.ENOATTR
+	}
+	if len(dest) == 0 {
+		return uint32(len(val)), 0
+	}
+	if len(val) > len(dest) {
+		return uint32(len(val)), syscall.ERANGE
+	}
+	return uint32(copy(dest, val)), 0
+}
+
+// Listxattr lists the extended attributes of name.
+func (idx *TarIndex) Listxattr(name string, dest []byte) (uint32, syscall.Errno) {
+	e, errno := idx.lookup(name)
+	if errno != 0 {
+		return 0, errno
+	}
+	var names []string
+	for k := range e.xattrs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var list []byte
+	for _, k := range names {
+		list = append(append(list, k...), 0)
+	}
+	if len(dest) == 0 {
+		return uint32(len(list)), 0
+	}
+	if len(list) > len(dest) {
+		return uint32(len(list)), syscall.ERANGE
+	}
+	return uint32(copy(dest, list)), 0
+}
+
+// Close closes the archive.
+func (idx *TarIndex) Close() error {
+	if idx.file == nil {
+		return nil
+	}
+	return idx.file.Close()
+}
+
+// openData returns the archive, decoded up to the data of e, and a
+// reader for the data.
+func (idx *TarIndex) openData(e *tarEntry) (io.Closer, io.Reader, error) {
+	stream, err := openTar(idx.name, idx.format)
+	if err != nil {
+		return nil, nil, err
+	}
+	tr := tar.NewReader(stream)
+	for i := 0; i <= e.num; i++ {
+		if _, err := tr.Next(); err != nil {
+			stream.Close()
+			return nil, nil, err
+		}
+	}
+	return stream, tr, nil
+}
+
+// tarFile is an open file of a TarIndex.
+type tarFile struct {
+	idx   *TarIndex
+	entry *tarEntry
+
+	// mu protects the fields below. If the file cannot be read in
+	// place, it is streamed from the archive. Reads that go back
+	// start decoding the archive anew.
+	mu     sync.Mutex
+	stream io.Closer
+	data   io.Reader
+	pos    int64
+}
+
+var _ = (fs.FileReader)((*tarFile)(nil))
+var _ = (fs.FileReleaser)((*tarFile)(nil))
+
+func (f *tarFile) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	size := int64(f.entry.attr.Size)
+	if off >= size {
+		return fuse.ReadResultData(nil), 0
+	}
+	if end := off + int64(len(dest)); end > size {
+		dest = dest[:size-off]
+	}
+	if f.entry.offset >= 0 && f.idx.file != nil {
+		n, err := f.idx.file.ReadAt(dest, f.entry.offset+off)
+		if err != nil && err != io.EOF {
+			return nil, fs.ToErrno(err)
+		}
+		return fuse.ReadResultData(dest[:n]), 0
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.data == nil || off < f.pos {
+		f.closeStream()
+		stream, data, err := f.idx.openData(f.entry)
+		if err != nil {
+			return nil, syscall.EIO
+		}
+		f.stream, f.data, f.pos = stream, data, 0
+	}
+	if off > f.pos {
+		n, err := io.CopyN(io.Discard, f.data, off-f.pos)
+		f.pos += n
+		if err == io.EOF {
+			return fuse.ReadResultData(nil), 0
+		} else if err != nil {
+			return nil, syscall.EIO
+		}
+	}
+	n, err := io.ReadFull(f.data, dest)
+	f.pos += int64(n)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, syscall.EIO
+	}
+	return fuse.ReadResultData(dest[:n]), 0
+}
+
+// closeStream closes the archive. Must be called with f.mu held.
+func (f *tarFile) closeStream() {
+	if f.stream != nil {
+		f.stream.Close()
+	}
+	f.stream, f.data, f.pos = nil, nil, 0
+}
+
+func (f *tarFile) Release(ctx context.Context) syscall.Errno {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closeStream()
+	return 0
+}
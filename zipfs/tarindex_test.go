@@ -0,0 +1,149 @@
+// Copyright 2026 the Go-FUSE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zipfs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func writeTestTar(t *testing.T, name string, compress bool) {
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var w io.Writer = f
+	if compress {
+		zw := gzip.NewWriter(f)
+		defer zw.Close()
+		w = zw
+	}
+
+	tw := tar.NewWriter(w)
+	for _, h := range []*tar.Header{
+		{Name: "dir/file.txt", Mode: 0644, Size: 7},
+		{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0700},
+		{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "dir/file.txt"},
+		{Name: "hard", Typeflag: tar.TypeLink, Linkname: "dir/file.txt"},
+		{Name: "null", Typeflag: tar.TypeChar, Mode: 0666, Devmajor: 1, Devminor: 3},
+		{Name: "xattr", Mode: 0644, Size: 3, Format: tar.FormatPAX, PAXRecords: map[string]string{"SCHILY.xattr.user.foo": "bar"}},
+	} {
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if h.Size > 0 {
+			tw.Write([]byte("content")[:h.Size])
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTarIndex(t *testing.T) {
+	for _, format := range []string{"", "gz"} {
+		t.Run("format="+format, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "test.tar")
+			writeTestTar(t, name, format == "gz")
+			idx, err := NewTarIndex(name, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer idx.Close()
+
+			entries, errno := idx.ReadDir("")
+			if errno != 0 {
+				t.Fatalf("ReadDir: %v", errno)
+			}
+			got := map[string]uint32{}
+			for _, e := range entries {
+				got[e.Name] = e.Mode
+			}
+			want := map[string]uint32{
+				"dir":   syscall.S_IFDIR,
+				"link":  syscall.S_IFLNK,
+				"hard":  syscall.S_IFREG,
+				"null":  syscall.S_IFCHR,
+				"xattr": syscall.S_IFREG,
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ReadDir: got %v, want %v", got, want)
+			}
+
+			var attr, hard fuse.Attr
+			if errno := idx.Lstat("dir", &attr); errno != 0 || attr.Mode != syscall.S_IFDIR|0700 {
+				t.Errorf("Lstat dir: got mode %o, %v", attr.Mode, errno)
+			}
+			if errno := idx.Lstat("dir/file.txt", &attr); errno != 0 || attr.Size != 7 || attr.Nlink != 2 {
+				t.Errorf("Lstat file: got %v, %v", &attr, errno)
+			}
+			if idx.Lstat("hard", &hard); hard.Ino != attr.Ino {
+				t.Errorf("hard link has inode %d, want %d", hard.Ino, attr.Ino)
+			}
+			if errno := idx.Lstat("null", &attr); errno != 0 || attr.Rdev != 1<<8|3 {
+				t.Errorf("Lstat null: got rdev %x, %v", attr.Rdev, errno)
+			}
+			if errno := idx.Lstat("missing", &attr); errno != syscall.ENOENT {
+				t.Errorf("Lstat missing: got %v, want ENOENT", errno)
+			}
+
+			if got, errno := idx.Readlink("link"); errno != 0 || string(got) != "dir/file.txt" {
+				t.Errorf("Readlink: got %q, %v", got, errno)
+			}
+
+			for _, p := range []string{"dir/file.txt", "hard"} {
+				fh, errno := idx.Open(p, syscall.O_RDONLY)
+				if errno != 0 {
+					t.Fatalf("Open %q: %v", p, errno)
+				}
+				buf := make([]byte, 16)
+				res, errno := fh.(*tarFile).Read(context.Background(), buf, 3)
+				if errno != 0 {
+					t.Fatalf("Read %q: %v", p, errno)
+				}
+				data, _ := res.Bytes(buf)
+				if string(data) != "tent" {
+					t.Errorf("Read %q: got %q, want %q", p, data, "tent")
+				}
+
+				// Reading backwards restarts a compressed
+				// stream.
+				res, errno = fh.(*tarFile).Read(context.Background(), buf[:4], 0)
+				if data, _ := res.Bytes(buf); errno != 0 || string(data) != "cont" {
+					t.Errorf("Read %q at 0: got %q, %v", p, data, errno)
+				}
+				fh.(*tarFile).Release(context.Background())
+			}
+			if inPlace := idx.entries["dir/file.txt"].offset >= 0; inPlace != (format == "") {
+				t.Errorf("read in place: got %v", inPlace)
+			}
+			if _, errno := idx.Open("dir/file.txt", syscall.O_RDWR); errno != syscall.EROFS {
+				t.Errorf("Open for writing: got %v, want EROFS", errno)
+			}
+
+			buf := make([]byte, 16)
+			if sz, errno := idx.Getxattr("xattr", "user.foo", buf); errno != 0 || string(buf[:sz]) != "bar" {
+				t.Errorf("Getxattr: got %q, %v", buf[:sz], errno)
+			}
+			if sz, errno := idx.Listxattr("xattr", buf); errno != 0 || string(buf[:sz]) != "user.foo\x00" {
+				t.Errorf("Listxattr: got %q, %v", buf[:sz], errno)
+			}
+			if _, errno := idx.Getxattr("xattr", "user.bar", buf); errno != fs.ENOATTR {
+				t.Errorf("Getxattr missing: got %v, want ENOATTR", errno)
+			}
+		})
+	}
+}